@@ -39,13 +39,48 @@ os.environ["OPENCV_FFMPEG_CAPTURE_OPTIONS"] = "rtsp_transport;tcp"
 logging.basicConfig(level=logging.INFO, format="[AI] %(message)s")
 log = logging.getLogger("ai-detector")
 
+def load_internal_secret():
+    try:
+        with open("/run/secrets/internal_api_secret") as f:
+            return f.read().strip()
+    except OSError:
+        return os.environ.get("NVR_INTERNAL_SECRET", "").strip()
+
+# Sent as X-Internal-Secret on /internal/* calls; the backend only hands out
+# webhook tokens to a caller that presents it
+INTERNAL_SECRET = load_internal_secret()
+
+def internal_headers():
+    return {"X-Internal-Secret": INTERNAL_SECRET} if INTERNAL_SECRET else {}
+
 watchers = {}
+# Latest webhook token per camera, refreshed every poll so a rotated token
+# reaches running watchers without a restart
+webhook_tokens = {}
+
+def webhook_headers(cam_id):
+    return {"X-Webhook-Token": webhook_tokens.get(cam_id, "")}
+
+# How often the worker reports in; the backend flags it stale after two minutes by default
+HEARTBEAT_INTERVAL = 30
+WORKER_NAME = os.environ.get("HOSTNAME", "ai-detector")
+
+def heartbeat_loop():
+    while True:
+        try:
+            resp = requests.post(f"{API_URL}/internal/ai/heartbeat", json={"worker": WORKER_NAME}, headers=internal_headers(), timeout=2)
+            if resp.status_code != 200:
+                log.warning(f"Heartbeat failed: HTTP {resp.status_code}")
+        except Exception:
+            pass
+        time.sleep(HEARTBEAT_INTERVAL)
 
 def get_cameras():
     try:
-        resp = requests.get(f"{API_URL}/internal/cameras", timeout=2)
+        resp = requests.get(f"{API_URL}/internal/cameras", headers=internal_headers(), timeout=2)
         if resp.status_code == 200:
             return resp.json()
+        log.warning(f"Camera list failed: HTTP {resp.status_code}")
     except Exception:
         pass 
     return []
@@ -150,7 +185,7 @@ def process_camera(camera, stop_event):
             if not is_recording:
                 log.info(f"[{cam_name}] MOVING {valid_detection_label.upper()}! Recording started.")
                 try:
-                    requests.post(f"{API_URL}/webhook/motion/start/{cam_id}", timeout=1)
+                    requests.post(f"{API_URL}/webhook/motion/start/{cam_id}", headers=webhook_headers(cam_id), timeout=1)
                 except: pass
                 is_recording = True
         else:
@@ -160,7 +195,7 @@ def process_camera(camera, stop_event):
                 else:
                     log.info(f"[{cam_name}] Clear. Recording stopped.")
                     try:
-                        requests.post(f"{API_URL}/webhook/motion/end/{cam_id}", timeout=1)
+                        requests.post(f"{API_URL}/webhook/motion/end/{cam_id}", headers=webhook_headers(cam_id), timeout=1)
                     except: pass
                     is_recording = False
 
@@ -169,6 +204,8 @@ def process_camera(camera, stop_event):
 def main():
     global MODEL_NAME
     log.info("--- AI Detector Starting (Global Gating Active) ---")
+    if not INTERNAL_SECRET:
+        log.warning("No internal API secret set; the backend sends no webhook tokens and motion events will be rejected")
     
     if os.path.exists(MODEL_NAME):
         shutil.rmtree(MODEL_NAME)
@@ -179,6 +216,8 @@ def main():
     except Exception:
         MODEL_NAME = PT_NAME
 
+    threading.Thread(target=heartbeat_loop, daemon=True).start()
+
     watchers = {}
     while True:
         cameras = get_cameras()
@@ -186,6 +225,7 @@ def main():
 
         for cam in cameras:
             cid = cam['id']
+            webhook_tokens[cid] = cam.get('webhook_token', '')
             if cam.get('motion_type') == 'webhook':
                 active_ids.add(cid)
                 if cid not in watchers:
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/database"
+	"nvr-server/internal/mediamtx"
+)
+
+// AIHeartbeatStale is how long without a heartbeat before the AI worker is flagged
+var AIHeartbeatStale = config.Duration("NVR_AI_HEARTBEAT_STALE", 2*time.Minute)
+
+// InternalSecret guards /api/internal/*; empty leaves those routes open as before
+var InternalSecret = loadInternalSecret()
+
+func loadInternalSecret() string {
+	if content, err := os.ReadFile("/run/secrets/internal_api_secret"); err == nil {
+		return strings.TrimSpace(string(content))
+	}
+	return config.String("NVR_INTERNAL_SECRET", "")
+}
+
+type AIWorkerStatus struct {
+	LastSeen *time.Time `json:"last_seen"`
+	Worker   string     `json:"worker,omitempty"`
+	Stale    bool       `json:"stale"`
+}
+
+var (
+	aiLastSeen time.Time
+	aiWorker   string
+	aiMu       sync.Mutex
+)
+
+// internalAuth checks X-Internal-Secret on AI -> API routes when a secret is
+// configured, and marks requests that passed the check (see internalCaller)
+func internalAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if InternalSecret == "" {
+			return next(c)
+		}
+		given := c.Request().Header.Get("X-Internal-Secret")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(InternalSecret)) != 1 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"detail": "Invalid internal secret"})
+		}
+		c.Set("internal_caller", true)
+		return next(c)
+	}
+}
+
+// internalCaller reports whether the request presented a configured internal
+// secret; without one the internal routes are open and must not hand out credentials
+func internalCaller(c echo.Context) bool {
+	ok, _ := c.Get("internal_caller").(bool)
+	return ok
+}
+
+func aiHeartbeat(c echo.Context) error {
+	var req struct {
+		Worker string `json:"worker"`
+	}
+	c.Bind(&req)
+
+	aiMu.Lock()
+	aiLastSeen = time.Now()
+	aiWorker = strings.TrimSpace(req.Worker)
+	aiMu.Unlock()
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// aiWorkerStatus reports the last heartbeat; a worker never seen counts as stale
+func aiWorkerStatus() AIWorkerStatus {
+	aiMu.Lock()
+	defer aiMu.Unlock()
+	if aiLastSeen.IsZero() {
+		return AIWorkerStatus{Stale: true}
+	}
+	seen := aiLastSeen
+	return AIWorkerStatus{
+		LastSeen: &seen,
+		Worker:   aiWorker,
+		Stale:    time.Since(seen) > AIHeartbeatStale,
+	}
+}
+
+// getServices reports the state of the services the NVR depends on
+func getServices(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+	defer cancel()
+
+	dbStatus := "ok"
+	if sqlDB, err := database.DB.DB(); err != nil {
+		dbStatus = err.Error()
+	} else if err := sqlDB.PingContext(ctx); err != nil {
+		dbStatus = err.Error()
+	}
+	mediamtxStatus := "ok"
+	if err := mediamtx.PingContext(ctx); err != nil {
+		mediamtxStatus = err.Error()
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"database":  dbStatus,
+		"mediamtx":  mediamtxStatus,
+		"ai_worker": aiWorkerStatus(),
+		"substream": substreamIssues(getUser(c).ID),
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nvr-server/internal/mediamtx"
+)
+
+// resetHeartbeat forgets any heartbeat a previous test recorded
+func resetHeartbeat(t *testing.T) {
+	t.Helper()
+	aiMu.Lock()
+	aiLastSeen, aiWorker = time.Time{}, ""
+	aiMu.Unlock()
+	t.Cleanup(func() {
+		aiMu.Lock()
+		aiLastSeen, aiWorker = time.Time{}, ""
+		aiMu.Unlock()
+	})
+}
+
+func TestAIHeartbeat(t *testing.T) {
+	resetHeartbeat(t)
+	if status := aiWorkerStatus(); !status.Stale || status.LastSeen != nil {
+		t.Errorf("never-seen worker = %+v, want stale", status)
+	}
+
+	if rec := callHandler(aiHeartbeat, http.MethodPost, "/", `{"worker":" ai-1 "}`, nil); rec.Code != http.StatusOK {
+		t.Fatalf("heartbeat: status %d", rec.Code)
+	}
+	status := aiWorkerStatus()
+	if status.Stale || status.LastSeen == nil || status.Worker != "ai-1" {
+		t.Errorf("after heartbeat = %+v", status)
+	}
+
+	aiMu.Lock()
+	aiLastSeen = time.Now().Add(-AIHeartbeatStale - time.Second)
+	aiMu.Unlock()
+	if !aiWorkerStatus().Stale {
+		t.Error("worker silent past the stale window not flagged")
+	}
+}
+
+func TestInternalAuth(t *testing.T) {
+	prev := InternalSecret
+	t.Cleanup(func() { InternalSecret = prev })
+	guarded := internalAuth(okHandler)
+
+	call := func(secret string) int {
+		c, rec := handlerContext(http.MethodPost, "/api/internal/ai/heartbeat", "", nil)
+		if secret != "" {
+			c.Request().Header.Set("X-Internal-Secret", secret)
+		}
+		serve(guarded, c)
+		return rec.Code
+	}
+
+	InternalSecret = ""
+	if code := call(""); code != http.StatusNoContent {
+		t.Errorf("no secret configured: status %d", code)
+	}
+	InternalSecret = "s3cret"
+	for secret, want := range map[string]int{"s3cret": http.StatusNoContent, "wrong": http.StatusUnauthorized, "": http.StatusUnauthorized} {
+		if code := call(secret); code != want {
+			t.Errorf("secret %q: status %d, want %d", secret, code, want)
+		}
+	}
+}
+
+func TestGetServices(t *testing.T) {
+	testDB(t)
+	resetHeartbeat(t)
+	user := createTestUser(t, "user@example.com", false)
+
+	services := func() map[string]json.RawMessage {
+		var body map[string]json.RawMessage
+		rec := callHandler(getServices, http.MethodGet, "/api/system/services", "", user)
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+		}
+		return body
+	}
+
+	newFakeMediaMTX(t)
+	body := services()
+	if string(body["database"]) != `"ok"` || string(body["mediamtx"]) != `"ok"` {
+		t.Errorf("healthy services = %s / %s", body["database"], body["mediamtx"])
+	}
+	var worker AIWorkerStatus
+	json.Unmarshal(body["ai_worker"], &worker)
+	if !worker.Stale {
+		t.Errorf("ai_worker = %+v, want stale before any heartbeat", worker)
+	}
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	mediamtx.APIBase = down.URL
+	if body := services(); string(body["mediamtx"]) == `"ok"` {
+		t.Error("unreachable MediaMTX reported ok")
+	}
+}
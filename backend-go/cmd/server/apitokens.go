@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+const (
+	ScopeRead = "read"
+	ScopeFull = "full"
+
+	apiTokenPrefix = "nvr_"
+)
+
+type CreateApiTokenRequest struct {
+	Name          string `json:"name"`
+	Scope         string `json:"scope"`
+	ExpiresInDays int    `json:"expires_in_days"` // 0 = never
+}
+
+type CreateApiTokenResponse struct {
+	models.ApiToken
+	Token string `json:"token"`
+}
+
+func hashApiToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiTokenAuth authenticates "Authorization: Token <value>" and enforces its scope
+func apiTokenAuth(c echo.Context, raw string, next echo.HandlerFunc) error {
+	var token models.ApiToken
+	if err := database.DB.Where("token_hash = ?", hashApiToken(strings.TrimSpace(raw))).First(&token).Error; err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token")
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Token expired")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, token.UserID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User not found")
+	}
+
+	if token.Scope != ScopeFull {
+		switch c.Request().Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			return c.JSON(http.StatusForbidden, map[string]string{"detail": "Token is read-only"})
+		}
+	}
+
+	database.DB.Model(&token).Update("last_used_at", now)
+
+	c.Set("user", &user)
+	c.Set("api_token", &token)
+	return next(c)
+}
+
+// sessionOnlyMiddleware keeps API tokens from minting or revoking other tokens
+func sessionOnlyMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.Get("api_token") != nil {
+			return c.JSON(http.StatusForbidden, map[string]string{"detail": "Not available with API token auth"})
+		}
+		return next(c)
+	}
+}
+
+// --- API TOKEN HANDLERS ---
+
+func listApiTokens(c echo.Context) error {
+	tokens := make([]models.ApiToken, 0)
+	database.DB.Where("user_id = ?", getUser(c).ID).Order("created_at desc").Find(&tokens)
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func createApiToken(c echo.Context) error {
+	req := new(CreateApiTokenRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid request"})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Name is required"})
+	}
+	if req.Scope == "" {
+		req.Scope = ScopeRead
+	}
+	if req.Scope != ScopeRead && req.Scope != ScopeFull {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Scope must be 'read' or 'full'"})
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "Could not generate token"})
+	}
+	raw := apiTokenPrefix + hex.EncodeToString(buf)
+
+	token := models.ApiToken{
+		UserID:    getUser(c).ID,
+		Name:      req.Name,
+		TokenHash: hashApiToken(raw),
+		Scope:     req.Scope,
+	}
+	if req.ExpiresInDays > 0 {
+		exp := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &exp
+	}
+	database.DB.Create(&token)
+
+	// The plaintext value is only ever returned here
+	return c.JSON(http.StatusOK, CreateApiTokenResponse{ApiToken: token, Token: raw})
+}
+
+func revokeApiToken(c echo.Context) error {
+	res := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), getUser(c).ID).Delete(&models.ApiToken{})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return notFound(c, "Token")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
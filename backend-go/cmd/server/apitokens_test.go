@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"nvr-server/internal/models"
+)
+
+// createToken mints an API token for user through the handler
+func createToken(t *testing.T, user *models.User, scope string) CreateApiTokenResponse {
+	t.Helper()
+	rec := callHandler(createApiToken, http.MethodPost, "/api/tokens", `{"name":"ci","scope":"`+scope+`"}`, user)
+	var resp CreateApiTokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("create %s token: %d %s", scope, rec.Code, rec.Body)
+	}
+	if !strings.HasPrefix(resp.Token, apiTokenPrefix) {
+		t.Fatalf("token %q lacks the %s prefix", resp.Token, apiTokenPrefix)
+	}
+	return resp
+}
+
+// withToken calls an authenticated okHandler with "Authorization: Token <raw>"
+func withToken(method, raw string) int {
+	c, rec := handlerContext(method, "/api/cameras", "", nil)
+	c.Request().Header.Set("Authorization", "Token "+raw)
+	serve(jwtMiddleware(okHandler), c)
+	return rec.Code
+}
+
+func TestAPITokenScopesAndRevocation(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+	read := createToken(t, user, ScopeRead)
+	full := createToken(t, user, ScopeFull)
+
+	if code := withToken(http.MethodGet, read.Token); code != http.StatusNoContent {
+		t.Errorf("read token GET: status %d", code)
+	}
+	if code := withToken(http.MethodPost, read.Token); code != http.StatusForbidden {
+		t.Errorf("read token POST: status %d, want 403", code)
+	}
+	if code := withToken(http.MethodDelete, full.Token); code != http.StatusNoContent {
+		t.Errorf("full token DELETE: status %d", code)
+	}
+	if code := withToken(http.MethodGet, "nvr_not-a-token"); code != http.StatusUnauthorized {
+		t.Errorf("unknown token: status %d, want 401", code)
+	}
+
+	// Another user can't revoke it; the owner can, and it stops working at once
+	other := createTestUser(t, "other@example.com", false)
+	id := strconv.Itoa(int(read.ID))
+	if rec := callHandler(revokeApiToken, http.MethodDelete, "/", "", other, "id", id); rec.Code != http.StatusNotFound {
+		t.Errorf("foreign revoke: status %d, want 404", rec.Code)
+	}
+	if rec := callHandler(revokeApiToken, http.MethodDelete, "/", "", user, "id", id); rec.Code != http.StatusNoContent {
+		t.Fatalf("revoke: status %d", rec.Code)
+	}
+	if code := withToken(http.MethodGet, read.Token); code != http.StatusUnauthorized {
+		t.Errorf("revoked token: status %d, want 401", code)
+	}
+}
+
+func TestSessionOnlyRoutesRefuseAPITokens(t *testing.T) {
+	guarded := sessionOnlyMiddleware(okHandler)
+
+	c, rec := handlerContext(http.MethodPost, "/api/tokens", "", &models.User{ID: 1})
+	c.Set("api_token", &models.ApiToken{ID: 1, Scope: ScopeFull})
+	serve(guarded, c)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("token-authenticated request: status %d, want 403", rec.Code)
+	}
+
+	if rec := callHandler(guarded, http.MethodPost, "/api/tokens", "", &models.User{ID: 1}); rec.Code != http.StatusNoContent {
+		t.Errorf("session request: status %d", rec.Code)
+	}
+}
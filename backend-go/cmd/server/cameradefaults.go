@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/models"
+)
+
+// Camera fields that SystemSettings.CameraDefaults may preset. Identity fields
+// (name, URLs, owner, path) are deliberately excluded.
+var cameraDefaultFields = map[string]bool{
+	"continuous_recording":  true,
+	"motion_type":           true,
+	"motion_sensitivity":    true,
+	"motion_roi":            true,
+	"rtsp_skip_cert_verify": true,
+	"auto_reconnect":        true,
+	"auto_heal_ip":          true,
+	"transcode_h264":        true,
+	"privacy_mask":          true,
+	"retention_days":        true,
+	"segment_format":        true,
+	"provisional_thumbnail": true,
+	"snapshot_on_event":     true,
+	"ai_classes":            true,
+}
+
+// normalizeCameraDefaults validates a CameraDefaults JSON object: known fields only,
+// with values that decode into a Camera
+func normalizeCameraDefaults(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return "", fmt.Errorf("camera_defaults must be a JSON object")
+	}
+	var unknown []string
+	for k := range fields {
+		if !cameraDefaultFields[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("camera_defaults cannot set: %s", strings.Join(unknown, ", "))
+	}
+	var probe models.Camera
+	if err := json.Unmarshal([]byte(raw), &probe); err != nil {
+		return "", fmt.Errorf("camera_defaults: %v", err)
+	}
+	return raw, nil
+}
+
+// bindNewCamera decodes a create request on top of CameraDefaults: any field the
+// client sent wins, fields it omitted take the default
+func bindNewCamera(c echo.Context, cam *models.Camera) error {
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(c.Request().Body).Decode(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+
+	merged := make(map[string]json.RawMessage)
+	if defaults := loadSettings().CameraDefaults; defaults != "" {
+		json.Unmarshal([]byte(defaults), &merged)
+	}
+	for k, v := range body {
+		merged[k] = v
+	}
+
+	data, _ := json.Marshal(merged)
+	if err := json.Unmarshal(data, cam); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestNormalizeCameraDefaults(t *testing.T) {
+	cases := map[string]bool{
+		"":   true,
+		"  ": true,
+		`{"continuous_recording":true,"motion_sensitivity":40}`: true,
+		`{"segment_format":"mkv","retention_days":5}`:           true,
+		`[1,2]`:                         false,
+		`{"name":"cam"}`:                false,
+		`{"rtsp_url":"rtsp://x"}`:       false,
+		`{"motion_sensitivity":"high"}`: false,
+	}
+	for raw, ok := range cases {
+		if _, err := normalizeCameraDefaults(raw); (err == nil) != ok {
+			t.Errorf("%s: err %v, want ok %v", raw, err, ok)
+		}
+	}
+}
+
+func TestBindNewCameraDefaults(t *testing.T) {
+	testDB(t)
+	database.DB.Create(&models.SystemSettings{
+		AllowRegistration: true,
+		CameraDefaults:    `{"continuous_recording":true,"motion_sensitivity":40,"segment_format":"mkv"}`,
+	})
+
+	bind := func(body string) models.Camera {
+		var cam models.Camera
+		c, _ := handlerContext(http.MethodPost, "/api/cameras", body, nil)
+		if err := bindNewCamera(c, &cam); err != nil {
+			t.Fatalf("%s: %v", body, err)
+		}
+		return cam
+	}
+
+	cam := bind(`{"name":"front","rtsp_url":"rtsp://192.0.2.10/live"}`)
+	if !cam.ContinuousRecording || cam.MotionSensitivity != 40 || cam.SegmentFormat != "mkv" || cam.Name != "front" {
+		t.Errorf("omitted fields not defaulted: %+v", cam)
+	}
+
+	// Explicit values win, including zero values
+	cam = bind(`{"name":"back","rtsp_url":"rtsp://192.0.2.11/live","continuous_recording":false,"motion_sensitivity":0}`)
+	if cam.ContinuousRecording || cam.MotionSensitivity != 0 || cam.SegmentFormat != "mkv" {
+		t.Errorf("explicit fields overridden: %+v", cam)
+	}
+
+	c, _ := handlerContext(http.MethodPost, "/api/cameras", "not json", nil)
+	if err := bindNewCamera(c, &models.Camera{}); err == nil {
+		t.Error("malformed body accepted")
+	}
+}
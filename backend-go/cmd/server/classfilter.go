@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// ?classes=person,vehicle&class_match=any|all filters events on their detected
+// classes. "any" (the default) keeps events that saw at least one of the listed
+// classes, "all" keeps events that saw every one of them. Events with no
+// detected classes never match. Listings echo the applied mode in X-Class-Match.
+const (
+	classMatchAny = "any"
+	classMatchAll = "all"
+)
+
+type classFilter struct {
+	Classes []string
+	Match   string
+}
+
+// parseClassFilter reads the classes and class_match params; ok is false when
+// class_match has an unknown value
+func parseClassFilter(c echo.Context) (f classFilter, ok bool) {
+	f.Classes = parseClassList(c.QueryParam("classes"))
+	f.Match = strings.ToLower(strings.TrimSpace(c.QueryParam("class_match")))
+	if f.Match == "" {
+		f.Match = classMatchAny
+	}
+	return f, f.Match == classMatchAny || f.Match == classMatchAll
+}
+
+// apply adds the match condition as a subquery on the indexed event_classes table
+func (f classFilter) apply(tx *gorm.DB) *gorm.DB {
+	if len(f.Classes) == 0 {
+		return tx
+	}
+
+	if f.Match == classMatchAll {
+		distinct := make(map[string]bool, len(f.Classes))
+		for _, class := range f.Classes {
+			distinct[class] = true
+		}
+		return tx.Where("events.id IN (?)", database.DB.Model(&models.EventClass{}).
+			Select("event_id").
+			Where("class IN ?", f.Classes).
+			Group("event_id").
+			Having("COUNT(DISTINCT class) = ?", len(distinct)))
+	}
+	return tx.Where("EXISTS (?)", database.DB.Model(&models.EventClass{}).
+		Select("1").
+		Where("event_classes.event_id = events.id AND event_classes.class IN ?", f.Classes))
+}
+
+// checkClassFilter rejects an unknown class_match and reports the applied mode
+// to the client in X-Class-Match
+func checkClassFilter(c echo.Context) error {
+	f, ok := parseClassFilter(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid class_match (expected any or all)")
+	}
+	if len(f.Classes) > 0 {
+		c.Response().Header().Set("X-Class-Match", f.Match)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestParseClassFilter(t *testing.T) {
+	cases := []struct {
+		query   string
+		classes []string
+		match   string
+		ok      bool
+	}{
+		{"", []string{}, classMatchAny, true},
+		{"?classes=person,+car,,", []string{"person", "car"}, classMatchAny, true},
+		{"?classes=person&class_match=ALL", []string{"person"}, classMatchAll, true},
+		{"?classes=person&class_match=some", []string{"person"}, "some", false},
+	}
+	for _, tc := range cases {
+		c, _ := handlerContext(http.MethodGet, "/api/events"+tc.query, "", nil)
+		f, ok := parseClassFilter(c)
+		if !reflect.DeepEqual(f.Classes, tc.classes) || f.Match != tc.match || ok != tc.ok {
+			t.Errorf("%q = %+v, %v", tc.query, f, ok)
+		}
+	}
+}
+
+func TestGetEventsClassMatch(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+
+	ids := make(map[string]uint)
+	for name, classes := range map[string][]string{
+		"person":     {"person"},
+		"car":        {"car"},
+		"person+car": {"person", "car"},
+		"dog":        {"dog"},
+		"none":       nil,
+	} {
+		event := models.Event{CameraID: cam.ID, UserID: user.ID, StartTime: time.Now(), Reason: models.ReasonMotion}
+		database.DB.Create(&event)
+		for _, class := range classes {
+			database.DB.Create(&models.EventClass{EventID: event.ID, Class: class})
+		}
+		ids[name] = event.ID
+	}
+
+	matching := func(query string, want ...string) {
+		t.Helper()
+		rec := callHandler(getEvents, http.MethodGet, "/api/events"+query, "", user)
+		var events []models.Event
+		if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, body %s", query, rec.Code, rec.Body)
+		}
+		var got, wantIDs []uint
+		for _, e := range events {
+			got = append(got, e.ID)
+		}
+		for _, name := range want {
+			wantIDs = append(wantIDs, ids[name])
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		sort.Slice(wantIDs, func(i, j int) bool { return wantIDs[i] < wantIDs[j] })
+		if !reflect.DeepEqual(got, wantIDs) {
+			t.Errorf("%s: events %v, want %v", query, got, want)
+		}
+	}
+
+	matching("?classes=person,car", "person", "car", "person+car")
+	matching("?classes=person,car&class_match=any", "person", "car", "person+car")
+	matching("?classes=person,car&class_match=all", "person+car")
+	matching("?classes=person,person&class_match=all", "person", "person+car")
+	matching("?classes=cat")
+
+	rec := callHandler(getEvents, http.MethodGet, "/api/events?classes=person&class_match=all", "", user)
+	if rec.Header().Get("X-Class-Match") != classMatchAll {
+		t.Errorf("X-Class-Match = %q", rec.Header().Get("X-Class-Match"))
+	}
+	if rec := callHandler(getEvents, http.MethodGet, "/api/events?classes=person&class_match=most", "", user); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown class_match: status %d", rec.Code)
+	}
+}
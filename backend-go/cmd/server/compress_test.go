@@ -0,0 +1,80 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+func TestSkipCompression(t *testing.T) {
+	cases := map[string]bool{
+		"/api/cameras":                        false,
+		"/api/events?page=2":                  false,
+		"/recordings/event_1_20240101.mp4":    true,
+		"/api/media/recordings/x.mp4":         true,
+		"/api/cameras/3/preview":              true,
+		"/api/cameras/3/latest.jpg":           true,
+		"/api/cameras/3/mask.png":             true,
+		"/api/cameras/3/test-record":          true,
+		"/api/events/5/download":              true,
+		"/api/cameras/3/recordings/day?x=jpg": false,
+	}
+	for target, want := range cases {
+		c, _ := handlerContext(http.MethodGet, target, "", nil)
+		if got := skipCompression(c); got != want {
+			t.Errorf("skipCompression(%s) = %v, want %v", target, got, want)
+		}
+	}
+
+	c, _ := handlerContext(http.MethodGet, "/api/cameras", "", nil)
+	c.Request().Header.Set("Range", "bytes=0-99")
+	if !skipCompression(c) {
+		t.Error("Range request compressed")
+	}
+}
+
+func TestGzipAPIButNotMedia(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.GzipWithConfig(middleware.GzipConfig{Skipper: skipCompression}))
+	payload := strings.Repeat(`{"name":"front door"},`, 200)
+	e.GET("/api/cameras", func(c echo.Context) error {
+		return c.JSONBlob(http.StatusOK, []byte("["+payload+"{}]"))
+	})
+	e.GET("/recordings/:file", func(c echo.Context) error {
+		return c.Blob(http.StatusOK, "video/mp4", []byte(payload))
+	})
+
+	get := func(target string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	res := get("/api/cameras")
+	if res.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("JSON not compressed: Content-Encoding %q", res.Header.Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); !strings.HasPrefix(string(body), `[{"name":"front door"}`) {
+		t.Errorf("decompressed body = %.40q", body)
+	}
+
+	res = get("/recordings/event_1.mp4")
+	if res.Header.Get("Content-Encoding") != "" {
+		t.Errorf("video compressed: Content-Encoding %q", res.Header.Get("Content-Encoding"))
+	}
+	if body, _ := io.ReadAll(res.Body); string(body) != payload {
+		t.Error("video body altered")
+	}
+}
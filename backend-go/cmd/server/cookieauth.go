@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// Cookie auth lets a same-origin SPA keep its tokens out of JavaScript: login and
+// refresh also set them as httpOnly cookies, and requests without an
+// Authorization header authenticate from the cookie. Bearer and API-token
+// headers keep working and take precedence.
+var (
+	CookieAuth = config.Bool("NVR_COOKIE_AUTH", false)
+
+	// Turn off only for plain-HTTP development; browsers drop Secure cookies over http
+	CookieSecure = config.Bool("NVR_COOKIE_SECURE", true)
+
+	// strict, lax or none ("none" requires CookieSecure)
+	CookieSameSite = config.String("NVR_COOKIE_SAMESITE", "strict")
+
+	// Comma-separated origins allowed to make credentialed cross-origin requests;
+	// empty keeps the permissive "*" policy without credentials
+	CORSOrigins = config.String("NVR_CORS_ORIGINS", "")
+)
+
+const (
+	accessCookie  = "nvr_access"
+	refreshCookie = "nvr_refresh"
+
+	// The refresh cookie is only sent to /token/refresh and /token/logout
+	refreshCookiePath = "/token"
+)
+
+// corsConfig builds the CORS policy from CORSOrigins
+func corsConfig() middleware.CORSConfig {
+	cfg := middleware.CORSConfig{
+		ExposeHeaders: []string{"X-Total-Count", "Link", "ETag", "X-Events-Truncated"},
+	}
+	for _, origin := range strings.Split(CORSOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.AllowOrigins = append(cfg.AllowOrigins, origin)
+		}
+	}
+	cfg.AllowCredentials = len(cfg.AllowOrigins) > 0
+	return cfg
+}
+
+func cookieSameSite() http.SameSite {
+	switch strings.ToLower(CookieSameSite) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
+
+func authCookie(name, value, path string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Expires:  expires,
+		MaxAge:   int(time.Until(expires).Seconds()),
+		HttpOnly: true,
+		Secure:   CookieSecure,
+		SameSite: cookieSameSite(),
+	}
+}
+
+// setAuthCookies stores freshly issued tokens when cookie auth is on
+func setAuthCookies(c echo.Context, access, refresh string, now time.Time) {
+	if !CookieAuth {
+		return
+	}
+	c.SetCookie(authCookie(accessCookie, access, "/", now.Add(AccessTokenDuration)))
+	c.SetCookie(authCookie(refreshCookie, refresh, refreshCookiePath, now.Add(RefreshTokenDuration)))
+	setCSRFCookie(c, now)
+}
+
+func clearAuthCookies(c echo.Context) {
+	for _, cookie := range []*http.Cookie{
+		authCookie(accessCookie, "", "/", time.Unix(0, 0)),
+		authCookie(refreshCookie, "", refreshCookiePath, time.Unix(0, 0)),
+		{Name: csrfCookie, Path: "/"},
+	} {
+		cookie.MaxAge = -1
+		c.SetCookie(cookie)
+	}
+}
+
+// requestToken returns the Authorization header, or the cookie of the given name
+// as a bearer value when cookie auth is on and the header is absent
+func requestToken(c echo.Context, cookieName string) string {
+	if header := c.Request().Header.Get("Authorization"); header != "" || !CookieAuth {
+		return header
+	}
+	if cookie, err := c.Cookie(cookieName); err == nil && cookie.Value != "" {
+		return "Bearer " + cookie.Value
+	}
+	return ""
+}
+
+// logout ends the cookie session: the refresh token's session row is removed
+// and both cookies are expired. Header-based clients just discard their tokens.
+func logout(c echo.Context) error {
+	tokenString := strings.TrimPrefix(requestToken(c, refreshCookie), "Bearer ")
+	claims := &JwtCustomClaims{}
+	if token, err := jwt.ParseWithClaims(tokenString, claims, jwtKeyFunc); err == nil && token.Valid && claims.Type == "refresh" {
+		database.DB.Where("jti = ? AND user_id = ?", claims.ID, claims.UserID).Delete(&models.UserSession{})
+	}
+	clearAuthCookies(c)
+	return c.NoContent(http.StatusNoContent)
+}
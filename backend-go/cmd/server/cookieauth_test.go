@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// useCookieAuth turns cookie auth on or off for the test
+func useCookieAuth(t *testing.T, on bool) {
+	t.Helper()
+	prev := CookieAuth
+	CookieAuth = on
+	t.Cleanup(func() { CookieAuth = prev })
+}
+
+// loginCookies issues tokens for user and returns the cookies set, by name
+func loginCookies(t *testing.T, user *models.User) map[string]*http.Cookie {
+	t.Helper()
+	c, rec := handlerContext(http.MethodPost, "/token", "", nil)
+	if err := generateTokens(c, user); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("generateTokens: %v, status %d", err, rec.Code)
+	}
+	cookies := make(map[string]*http.Cookie)
+	for _, cookie := range rec.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	return cookies
+}
+
+func TestRequestToken(t *testing.T) {
+	request := func(header, cookie string) string {
+		c, _ := handlerContext(http.MethodGet, "/api/me", "", nil)
+		if header != "" {
+			c.Request().Header.Set("Authorization", header)
+		}
+		if cookie != "" {
+			c.Request().AddCookie(&http.Cookie{Name: accessCookie, Value: cookie})
+		}
+		return requestToken(c, accessCookie)
+	}
+
+	useCookieAuth(t, false)
+	if got := request("", "abc"); got != "" {
+		t.Errorf("cookie used with cookie auth off: %q", got)
+	}
+	useCookieAuth(t, true)
+	if got := request("", "abc"); got != "Bearer abc" {
+		t.Errorf("cookie only = %q", got)
+	}
+	if got := request("Token nvr_x", "abc"); got != "Token nvr_x" {
+		t.Errorf("header and cookie = %q, want the header", got)
+	}
+}
+
+func TestCORSConfig(t *testing.T) {
+	prev := CORSOrigins
+	t.Cleanup(func() { CORSOrigins = prev })
+
+	CORSOrigins = ""
+	if cfg := corsConfig(); cfg.AllowCredentials || len(cfg.AllowOrigins) != 0 {
+		t.Errorf("no origins: %+v", cfg)
+	}
+	CORSOrigins = " https://nvr.example.com , ,http://localhost:3000"
+	cfg := corsConfig()
+	if !cfg.AllowCredentials || !reflect.DeepEqual(cfg.AllowOrigins, []string{"https://nvr.example.com", "http://localhost:3000"}) {
+		t.Errorf("listed origins: %+v", cfg)
+	}
+}
+
+func TestCookieAuth(t *testing.T) {
+	testDB(t)
+	testSecrets(t)
+	user := createTestUser(t, "user@example.com", false)
+
+	useCookieAuth(t, false)
+	if cookies := loginCookies(t, user); len(cookies) != 0 {
+		t.Errorf("cookies set with cookie auth off: %v", cookies)
+	}
+
+	useCookieAuth(t, true)
+	cookies := loginCookies(t, user)
+	access, refresh := cookies[accessCookie], cookies[refreshCookie]
+	if access == nil || !access.HttpOnly || refresh == nil || !refresh.HttpOnly || refresh.Path != refreshCookiePath {
+		t.Fatalf("auth cookies = %+v, %+v", access, refresh)
+	}
+	if csrf := cookies[csrfCookie]; csrf == nil || csrf.HttpOnly || csrf.Value == "" {
+		t.Errorf("CSRF cookie = %+v, want one readable by the SPA", csrf)
+	}
+
+	call := func(header string) int {
+		c, rec := handlerContext(http.MethodGet, "/api/me", "", nil)
+		c.Request().AddCookie(access)
+		if header != "" {
+			c.Request().Header.Set("Authorization", header)
+		}
+		serve(jwtMiddleware(okHandler), c)
+		return rec.Code
+	}
+	if code := call(""); code != http.StatusNoContent {
+		t.Errorf("cookie auth: status %d", code)
+	}
+	// A bad header is not rescued by a good cookie
+	if code := call("Bearer nope"); code != http.StatusUnauthorized {
+		t.Errorf("bad header with good cookie: status %d, want 401", code)
+	}
+
+	sessions := func() (n int64) {
+		database.DB.Model(&models.UserSession{}).Where("user_id = ?", user.ID).Count(&n)
+		return n
+	}
+	before := sessions()
+	c, rec := handlerContext(http.MethodPost, "/token/logout", "", nil)
+	c.Request().AddCookie(refresh)
+	serve(logout, c)
+	if after := sessions(); rec.Code != http.StatusNoContent || after != before-1 {
+		t.Errorf("logout: status %d, sessions %d -> %d", rec.Code, before, after)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.MaxAge >= 0 {
+			t.Errorf("cookie %s not expired on logout", cookie.Name)
+		}
+	}
+}
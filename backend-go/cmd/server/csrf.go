@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// With cookie auth the browser attaches credentials on its own, so state-changing
+// requests authenticated by cookie must also echo the CSRF token (readable from
+// the nvr_csrf cookie) in X-CSRF-Token. Requests with an Authorization header
+// never rely on ambient credentials and are exempt.
+const (
+	csrfCookie = "nvr_csrf"
+	csrfHeader = "X-CSRF-Token"
+)
+
+func csrfMiddleware() echo.MiddlewareFunc {
+	return middleware.CSRFWithConfig(middleware.CSRFConfig{
+		Skipper:        skipCSRF,
+		TokenLookup:    "header:" + csrfHeader,
+		CookieName:     csrfCookie,
+		CookiePath:     "/",
+		CookieMaxAge:   int(RefreshTokenDuration.Seconds()),
+		CookieSecure:   CookieSecure,
+		CookieSameSite: cookieSameSite(),
+	})
+}
+
+// skipCSRF exempts everything except requests that carry an auth cookie and no
+// Authorization header. Safe methods are never checked by the middleware itself;
+// they still pass through it so it can issue the token cookie.
+func skipCSRF(c echo.Context) bool {
+	if !CookieAuth || c.Request().Header.Get("Authorization") != "" {
+		return true
+	}
+	for _, name := range []string{accessCookie, refreshCookie} {
+		if cookie, err := c.Cookie(name); err == nil && cookie.Value != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// setCSRFCookie issues a fresh token at login so the SPA can make its first
+// write without a preceding GET
+func setCSRFCookie(c echo.Context, now time.Time) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     csrfCookie,
+		Value:    hex.EncodeToString(buf),
+		Path:     "/",
+		Expires:  now.Add(RefreshTokenDuration),
+		Secure:   CookieSecure,
+		SameSite: cookieSameSite(),
+	})
+}
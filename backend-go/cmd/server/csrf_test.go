@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	useCookieAuth(t, true)
+	guarded := csrfMiddleware()(okHandler)
+	const token = "0123456789abcdef"
+
+	call := func(method string, authCookie bool, header, csrf string) int {
+		c, rec := handlerContext(method, "/api/cameras", "", nil)
+		if authCookie {
+			c.Request().AddCookie(&http.Cookie{Name: accessCookie, Value: "jwt"})
+			c.Request().AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+		}
+		if header != "" {
+			c.Request().Header.Set("Authorization", header)
+		}
+		if csrf != "" {
+			c.Request().Header.Set(csrfHeader, csrf)
+		}
+		serve(guarded, c)
+		return rec.Code
+	}
+
+	cases := []struct {
+		what         string
+		method       string
+		cookie       bool
+		header, csrf string
+		want         int
+	}{
+		{"cookie write without token", http.MethodPost, true, "", "", http.StatusBadRequest},
+		{"cookie write with wrong token", http.MethodDelete, true, "", "forged", http.StatusForbidden},
+		{"cookie write with token", http.MethodPut, true, "", token, http.StatusNoContent},
+		{"cookie read", http.MethodGet, true, "", "", http.StatusNoContent},
+		{"header write", http.MethodPost, true, "Bearer x", "", http.StatusNoContent},
+		{"anonymous write", http.MethodPost, false, "", "", http.StatusNoContent},
+	}
+	for _, tc := range cases {
+		if code := call(tc.method, tc.cookie, tc.header, tc.csrf); code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.what, code, tc.want)
+		}
+	}
+
+	// A cookie session's first read is handed a token to echo back
+	c, rec := handlerContext(http.MethodGet, "/api/me", "", nil)
+	c.Request().AddCookie(&http.Cookie{Name: accessCookie, Value: "jwt"})
+	serve(guarded, c)
+	issued := false
+	for _, cookie := range rec.Result().Cookies() {
+		issued = issued || (cookie.Name == csrfCookie && cookie.Value != "")
+	}
+	if !issued {
+		t.Error("no CSRF cookie issued on a cookie-authenticated GET")
+	}
+
+	useCookieAuth(t, false)
+	if code := call(http.MethodPost, true, "", ""); code != http.StatusNoContent {
+		t.Errorf("cookie auth off: status %d", code)
+	}
+}
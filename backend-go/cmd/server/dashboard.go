@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+)
+
+// DashboardCacheTTL is how long a user's storage total (a full directory walk) is reused
+var DashboardCacheTTL = config.Duration("NVR_DASHBOARD_CACHE_TTL", 30*time.Second)
+
+type DashboardCamera struct {
+	ID                  uint       `json:"id"`
+	Name                string     `json:"name"`
+	Events24h           int64      `json:"events_24h"`
+	Recording           bool       `json:"recording"`
+	ContinuousRecording bool       `json:"continuous_recording"`
+	Reachable           bool       `json:"reachable"`
+	LastWebhookAt       *time.Time `json:"last_webhook_at"`
+}
+
+type DashboardStorage struct {
+	UsedBytes    int64 `json:"used_bytes"`
+	MaxStorageMB int   `json:"max_storage_mb"`
+}
+
+type Dashboard struct {
+	Health           map[string]interface{} `json:"health"`
+	Storage          DashboardStorage       `json:"storage"`
+	Cameras          []DashboardCamera      `json:"cameras"`
+	ActiveRecordings int                    `json:"active_recordings"`
+	MaintenanceMode  bool                   `json:"maintenance_mode"`
+	AIWorker         AIWorkerStatus         `json:"ai_worker"`
+}
+
+type cachedUsage struct {
+	bytes int64
+	at    time.Time
+}
+
+var (
+	storageCache   = make(map[uint]cachedUsage)
+	storageCacheMu sync.Mutex
+)
+
+// cachedStorageBytes wraps detector.UserStorageBytes with a short per-user cache
+func cachedStorageBytes(userID uint) int64 {
+	storageCacheMu.Lock()
+	entry, ok := storageCache[userID]
+	storageCacheMu.Unlock()
+	if ok && time.Since(entry.at) < DashboardCacheTTL {
+		return entry.bytes
+	}
+
+	bytes := detector.UserStorageBytes(userID)
+	storageCacheMu.Lock()
+	storageCache[userID] = cachedUsage{bytes: bytes, at: time.Now()}
+	storageCacheMu.Unlock()
+	return bytes
+}
+
+// getDashboard composes health, storage, camera and recording state for the
+// caller in one response, replacing several round trips on page load
+func getDashboard(c echo.Context) error {
+	user := getUser(c)
+
+	var cameras []models.Camera
+	if err := database.DB.Where("owner_id = ?", user.ID).Order("display_order asc").Find(&cameras).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+
+	type countRow struct {
+		CameraID uint
+		Count    int64
+	}
+	var counts []countRow
+	database.DB.Model(&models.Event{}).
+		Select("camera_id, COUNT(*) AS count").
+		Where("user_id = ? AND start_time >= ?", user.ID, time.Now().Add(-24*time.Hour)).
+		Group("camera_id").
+		Scan(&counts)
+	perCamera := make(map[uint]int64, len(counts))
+	for _, r := range counts {
+		perCamera[r.CameraID] = r.Count
+	}
+
+	dash := Dashboard{
+		Health: systemHealth(),
+		Storage: DashboardStorage{
+			UsedBytes:    cachedStorageBytes(user.ID),
+			MaxStorageMB: user.MaxStorageMB,
+		},
+		Cameras:         make([]DashboardCamera, 0, len(cameras)),
+		MaintenanceMode: loadSettings().MaintenanceMode,
+		AIWorker:        aiWorkerStatus(),
+	}
+	for _, cam := range cameras {
+		event, continuous := Detector.IsRecording(cam.ID)
+		if event {
+			dash.ActiveRecordings++
+		}
+		dash.Cameras = append(dash.Cameras, DashboardCamera{
+			ID:                  cam.ID,
+			Name:                cam.Name,
+			Events24h:           perCamera[cam.ID],
+			Recording:           event,
+			ContinuousRecording: continuous,
+			Reachable:           Detector.Reachable(cam.ID),
+			LastWebhookAt:       cam.LastWebhookAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, dash)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+)
+
+// resetStorageCache empties the dashboard's storage cache around a test
+func resetStorageCache(t *testing.T) {
+	t.Helper()
+	clear(storageCache)
+	t.Cleanup(func() { clear(storageCache) })
+}
+
+func TestGetDashboard(t *testing.T) {
+	testDB(t)
+	testRecordingRoots(t)
+	resetStorageCache(t)
+	user := createTestUser(t, "user@example.com", false)
+	other := createTestUser(t, "other@example.com", false)
+	back := createTestCamera(t, user, "back")
+	front := createTestCamera(t, user, "front")
+	database.DB.Model(back).Update("display_order", 2)
+	database.DB.Model(front).Update("display_order", 1)
+	theirs := createTestCamera(t, other, "theirs")
+
+	now := time.Now()
+	for _, e := range []models.Event{
+		{CameraID: front.ID, UserID: user.ID, StartTime: now.Add(-time.Hour)},
+		{CameraID: front.ID, UserID: user.ID, StartTime: now.Add(-23 * time.Hour)},
+		{CameraID: front.ID, UserID: user.ID, StartTime: now.Add(-25 * time.Hour)},
+		{CameraID: theirs.ID, UserID: other.ID, StartTime: now},
+	} {
+		database.DB.Create(&e)
+	}
+	writeFile(t, filepath.Join(detector.EventRoot, fmt.Sprintf("event_%d_20240101-080000.mp4", front.ID)), strings.Repeat("x", 100))
+	writeFile(t, filepath.Join(detector.EventRoot, fmt.Sprintf("event_%d_20240101-080000.mp4", theirs.ID)), strings.Repeat("x", 900))
+
+	var dash Dashboard
+	rec := callHandler(getDashboard, http.MethodGet, "/api/dashboard", "", user)
+	if err := json.Unmarshal(rec.Body.Bytes(), &dash); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+
+	if len(dash.Cameras) != 2 || dash.Cameras[0].ID != front.ID || dash.Cameras[1].ID != back.ID {
+		t.Fatalf("cameras = %+v, want front then back", dash.Cameras)
+	}
+	if dash.Cameras[0].Events24h != 2 || dash.Cameras[1].Events24h != 0 {
+		t.Errorf("events_24h = %d, %d; want 2, 0", dash.Cameras[0].Events24h, dash.Cameras[1].Events24h)
+	}
+	if !dash.Cameras[0].Reachable || dash.Cameras[0].Recording || dash.ActiveRecordings != 0 {
+		t.Errorf("idle camera state = %+v, active %d", dash.Cameras[0], dash.ActiveRecordings)
+	}
+	if dash.Storage.UsedBytes != 100 {
+		t.Errorf("storage used = %d, want only the caller's 100 bytes", dash.Storage.UsedBytes)
+	}
+	if dash.Health == nil {
+		t.Error("health section missing")
+	}
+}
+
+func TestCachedStorageBytes(t *testing.T) {
+	testDB(t)
+	testRecordingRoots(t)
+	resetStorageCache(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	clip := func(name string, size int) {
+		writeFile(t, filepath.Join(detector.EventRoot, fmt.Sprintf("event_%d_%s.mp4", cam.ID, name)), strings.Repeat("x", size))
+	}
+
+	clip("20240101-080000", 10)
+	if got := cachedStorageBytes(user.ID); got != 10 {
+		t.Fatalf("first read = %d, want 10", got)
+	}
+
+	clip("20240101-090000", 5)
+	if got := cachedStorageBytes(user.ID); got != 10 {
+		t.Errorf("within the TTL = %d, want the cached 10", got)
+	}
+
+	prev := DashboardCacheTTL
+	DashboardCacheTTL = 0
+	t.Cleanup(func() { DashboardCacheTTL = prev })
+	if got := cachedStorageBytes(user.ID); got != 15 {
+		t.Errorf("after the TTL = %d, want 15", got)
+	}
+}
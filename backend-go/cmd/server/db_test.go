@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+)
+
+// testDB points database.DB at the Postgres named by NVR_TEST_DATABASE_URL,
+// migrated and emptied, with an idle Detector. Tests that need it are skipped
+// when the variable is unset. The database is wiped, so never aim it at real data.
+func testDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("NVR_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("NVR_TEST_DATABASE_URL not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true, Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	prevDB, prevDetector := database.DB, Detector
+	database.DB = db
+	Detector = detector.NewManager()
+	t.Cleanup(func() {
+		database.DB, Detector = prevDB, prevDetector
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	database.Migrate()
+	var tables []string
+	db.Raw("SELECT quote_ident(tablename) FROM pg_tables WHERE schemaname = current_schema()").Scan(&tables)
+	if len(tables) > 0 {
+		if err := db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error; err != nil {
+			t.Fatalf("empty test database: %v", err)
+		}
+	}
+}
+
+// createTestUser stores a user for handler tests
+func createTestUser(t *testing.T, email string, admin bool) *models.User {
+	t.Helper()
+	user := &models.User{Email: email, HashedPassword: "x", IsAdmin: admin}
+	if err := database.DB.Create(user).Error; err != nil {
+		t.Fatalf("create user %s: %v", email, err)
+	}
+	return user
+}
+
+// createTestCamera stores a camera owned by owner
+func createTestCamera(t *testing.T, owner *models.User, name string) *models.Camera {
+	t.Helper()
+	cam := &models.Camera{
+		Name:    name,
+		Path:    fmt.Sprintf("user_%d_%s", owner.ID, name),
+		RTSPUrl: "rtsp://192.0.2.1/" + name,
+		OwnerID: owner.ID,
+		Version: 1,
+	}
+	if err := database.DB.Create(cam).Error; err != nil {
+		t.Fatalf("create camera %s: %v", name, err)
+	}
+	return cam
+}
+
+// callHandler runs h as user (nil for an anonymous request) with a JSON body
+// and :name/value route params given as pairs
+func callHandler(h echo.HandlerFunc, method, target, body string, user *models.User, params ...string) *httptest.ResponseRecorder {
+	c, rec := handlerContext(method, target, body, user, params...)
+	serve(h, c)
+	return rec
+}
+
+// handlerContext builds the context callHandler uses, for tests that need to
+// adjust the request (headers, cookies) before serving it
+func handlerContext(method, target, body string, user *models.User, params ...string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	c := echo.New().NewContext(req, rec)
+	if user != nil {
+		c.Set("user", user)
+	}
+	var names, values []string
+	for i := 0; i+1 < len(params); i += 2 {
+		names, values = append(names, params[i]), append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	return c, rec
+}
+
+// serve runs h on c, rendering a returned error the way the server does
+func serve(h echo.HandlerFunc, c echo.Context) {
+	if err := h(c); err != nil {
+		jsonErrorHandler(err, c)
+	}
+}
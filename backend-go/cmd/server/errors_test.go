@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestJSONErrorsForRouting(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = jsonErrorHandler
+	e.GET("/api/cameras", okHandler)
+
+	cases := []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/api/nope", http.StatusNotFound},
+		{http.MethodPost, "/api/cameras", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+
+		if rec.Code != tc.status {
+			t.Errorf("%s %s: status %d, want %d", tc.method, tc.path, rec.Code, tc.status)
+		}
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["detail"] != http.StatusText(tc.status) {
+			t.Errorf("%s %s: body %q, want {\"detail\": %q}", tc.method, tc.path, rec.Body, http.StatusText(tc.status))
+		}
+		if tc.status == http.StatusMethodNotAllowed && rec.Header().Get("Allow") == "" {
+			t.Errorf("%s %s: no Allow header", tc.method, tc.path)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/api/nope", nil))
+	if rec.Code != http.StatusNotFound || rec.Body.Len() != 0 {
+		t.Errorf("HEAD unknown route: status %d, %d byte body", rec.Code, rec.Body.Len())
+	}
+}
+
+func TestJSONErrorsForHandlerErrors(t *testing.T) {
+	rec := callHandler(func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token")
+	}, http.MethodGet, "/api/me", "", nil)
+	if rec.Code != http.StatusUnauthorized || rec.Body.String() != "{\"detail\":\"Invalid token\"}\n" {
+		t.Errorf("HTTPError: %d %q", rec.Code, rec.Body)
+	}
+
+	rec = callHandler(func(c echo.Context) error {
+		return json.Unmarshal([]byte("{"), &struct{}{})
+	}, http.MethodGet, "/api/me", "", nil)
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() != "{\"detail\":\"Internal Server Error\"}\n" {
+		t.Errorf("plain error: %d %q", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// listFingerprint summarizes a result set cheaply: any insert, delete or update
+// moves at least one of the row count, the newest id or the newest updated_at
+func listFingerprint(tx *gorm.DB, table string) string {
+	var row struct {
+		Count      int64
+		MaxID      uint
+		MaxUpdated *time.Time
+	}
+	tx.Select(fmt.Sprintf("COUNT(*) AS count, MAX(%[1]s.id) AS max_id, MAX(%[1]s.updated_at) AS max_updated", table)).Scan(&row)
+
+	updated := int64(0)
+	if row.MaxUpdated != nil {
+		updated = row.MaxUpdated.UnixNano()
+	}
+	return fmt.Sprintf("%d-%d-%d", row.Count, row.MaxID, updated)
+}
+
+// notModified sets a weak ETag and reports whether the client's copy is still current
+func notModified(c echo.Context, fingerprints ...string) bool {
+	etag := fmt.Sprintf(`W/"%s"`, strings.Join(fingerprints, "."))
+	c.Response().Header().Set("ETag", etag)
+
+	for _, candidate := range strings.Split(c.Request().Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func respondNotModified(c echo.Context) error {
+	return c.NoContent(http.StatusNotModified)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestNotModified(t *testing.T) {
+	cases := map[string]bool{
+		"":                    false,
+		`W/"3-7-100"`:         true,
+		`"3-7-100"`:           true,
+		`"old", W/"3-7-100"`:  true,
+		"*":                   true,
+		`W/"3-7-101"`:         false,
+		`W/"3-7-100.extra"`:   false,
+		`"something", "else"`: false,
+	}
+	for header, want := range cases {
+		c, rec := handlerContext(http.MethodGet, "/api/cameras", "", nil)
+		if header != "" {
+			c.Request().Header.Set("If-None-Match", header)
+		}
+		if got := notModified(c, "3-7-100"); got != want {
+			t.Errorf("If-None-Match %q: notModified = %v, want %v", header, got, want)
+		}
+		if etag := rec.Header().Get("ETag"); etag != `W/"3-7-100"` {
+			t.Errorf("ETag = %q", etag)
+		}
+	}
+}
+
+func TestGetCamerasConditional(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+
+	first := callHandler(getCameras, http.MethodGet, "/api/cameras", "", user)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first fetch: status %d, ETag %q", first.Code, etag)
+	}
+
+	get := func() int {
+		c, rec := handlerContext(http.MethodGet, "/api/cameras", "", user)
+		c.Request().Header.Set("If-None-Match", etag)
+		serve(getCameras, c)
+		return rec.Code
+	}
+	if code := get(); code != http.StatusNotModified {
+		t.Errorf("unchanged list: status %d, want 304", code)
+	}
+
+	database.DB.Model(&models.Camera{}).Where("id = ?", cam.ID).Update("name", "renamed")
+	if code := get(); code != http.StatusOK {
+		t.Errorf("after an edit: status %d, want 200", code)
+	}
+
+	// Another user's cameras are not part of this list's ETag
+	etag = callHandler(getCameras, http.MethodGet, "/api/cameras", "", user).Header().Get("ETag")
+	createTestCamera(t, createTestUser(t, "other@example.com", false), "theirs")
+	if code := get(); code != http.StatusNotModified {
+		t.Errorf("after another user's create: status %d, want 304", code)
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+	"nvr-server/internal/slug"
+)
+
+var (
+	// Largest total clip size one archive may contain
+	ArchiveMaxBytes = int64(config.Int("NVR_ARCHIVE_MAX_MB", 4096)) << 20
+
+	// How long a finished archive waits to be downloaded before it is deleted
+	ArchiveTTL = config.Duration("NVR_ARCHIVE_TTL", time.Hour)
+
+	// Where archives are built; needs room for ArchiveMaxBytes per concurrent job
+	ArchiveDir = config.String("NVR_ARCHIVE_DIR", filepath.Join(os.TempDir(), "nvr-archives"))
+)
+
+const (
+	archivePending = "pending"
+	archiveRunning = "running"
+	archiveDone    = "done"
+	archiveFailed  = "failed"
+	archiveExpired = "expired"
+)
+
+// ArchiveJob is a background ZIP export of one camera's event clips. Jobs live
+// in memory only; a restart forgets them and their files are swept on the next export.
+type ArchiveJob struct {
+	ID          string     `json:"id"`
+	CameraID    uint       `json:"camera_id"`
+	State       string     `json:"state"`
+	Events      int        `json:"events"`
+	Bytes       int64      `json:"bytes"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+
+	userID   uint
+	token    string
+	file     string
+	filename string
+}
+
+// archiveEntry is one event in the archive's events.json
+type archiveEntry struct {
+	ID              uint      `json:"id"`
+	StartTime       time.Time `json:"start_time"`
+	EndTime         time.Time `json:"end_time"`
+	Reason          string    `json:"reason"`
+	DetectedClasses []string  `json:"detected_classes"`
+	Files           []string  `json:"files"`
+}
+
+type archiveEvent struct {
+	event models.Event
+	files []string
+}
+
+var (
+	archiveMu   sync.Mutex
+	archiveJobs = make(map[string]*ArchiveJob)
+)
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// exportCameraEvents starts an archive of the camera's finished event clips
+// between ?start_ts and ?end_ts (RFC3339, both optional) and returns 202 with
+// the job; poll GET /api/exports/:id until it is done.
+func exportCameraEvents(c echo.Context) error {
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+	user := getUser(c)
+
+	tx := database.DB.Where("camera_id = ? AND user_id = ? AND video_path <> '' AND end_time > ?", cam.ID, user.ID, time.Time{})
+	for param, cond := range map[string]string{"start_ts": "start_time >= ?", "end_ts": "start_time <= ?"} {
+		if raw := c.QueryParam(param); raw != "" {
+			ts, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid " + param})
+			}
+			tx = tx.Where(cond, ts)
+		}
+	}
+
+	var events []models.Event
+	if err := tx.Order("start_time asc").Find(&events).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+	if len(events) == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"detail": "No recorded events in range"})
+	}
+
+	var total int64
+	items := make([]archiveEvent, 0, len(events))
+	for _, ev := range events {
+		item := archiveEvent{event: ev}
+		videoPath := detector.MediaPath(ev.VideoPath)
+		for _, f := range append(detector.EventParts(videoPath), detector.SidecarPath(videoPath)) {
+			if info, err := os.Stat(f); err == nil {
+				total += info.Size()
+				item.files = append(item.files, f)
+			}
+		}
+		items = append(items, item)
+	}
+	if total > ArchiveMaxBytes {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]interface{}{
+			"detail":    fmt.Sprintf("Archive would be %d MB, limit is %d MB; narrow the date range", total>>20, ArchiveMaxBytes>>20),
+			"bytes":     total,
+			"max_bytes": ArchiveMaxBytes,
+		})
+	}
+
+	id, err := randomHex(12)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "Could not create job"})
+	}
+	token, err := randomHex(24)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "Could not create job"})
+	}
+
+	archiveMu.Lock()
+	pruneArchiveJobs()
+	for _, j := range archiveJobs {
+		if j.userID == user.ID && (j.State == archivePending || j.State == archiveRunning) {
+			archiveMu.Unlock()
+			return c.JSON(http.StatusConflict, map[string]interface{}{"detail": "An export is already running", "job": j})
+		}
+	}
+	job := &ArchiveJob{
+		ID:        id,
+		CameraID:  cam.ID,
+		State:     archivePending,
+		Events:    len(items),
+		CreatedAt: time.Now(),
+		userID:    user.ID,
+		token:     token,
+		filename:  fmt.Sprintf("%s_events_%s.zip", slug.Make(cam.Name), time.Now().Format("20060102-150405")),
+	}
+	archiveJobs[id] = job
+	snapshot := *job
+	archiveMu.Unlock()
+
+	go buildArchive(job, items)
+	return c.JSON(http.StatusAccepted, snapshot)
+}
+
+// buildArchive writes the ZIP: clips stored uncompressed (they are already
+// compressed video) under event_<id>/, plus events.json describing each event
+func buildArchive(job *ArchiveJob, items []archiveEvent) {
+	setArchiveState(job, func(j *ArchiveJob) { j.State = archiveRunning })
+
+	file, size, err := writeArchive(job.ID, items)
+	if err != nil {
+		log.Printf("Event export %s failed: %v\n", job.ID, err)
+		os.Remove(file)
+	}
+
+	setArchiveState(job, func(j *ArchiveJob) {
+		now := time.Now()
+		j.FinishedAt = &now
+		if err != nil {
+			j.State, j.Error = archiveFailed, err.Error()
+			return
+		}
+		expires := now.Add(ArchiveTTL)
+		j.State, j.file, j.Bytes, j.ExpiresAt = archiveDone, file, size, &expires
+		j.DownloadURL = publicURL(fmt.Sprintf("/api/exports/%s/download?token=%s", j.ID, j.token))
+	})
+}
+
+func writeArchive(id string, items []archiveEvent) (string, int64, error) {
+	if err := os.MkdirAll(ArchiveDir, 0755); err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(ArchiveDir, id+".zip")
+	out, err := os.Create(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	entries := make([]archiveEntry, 0, len(items))
+	for _, item := range items {
+		entry := archiveEntry{
+			ID:              item.event.ID,
+			StartTime:       item.event.StartTime,
+			EndTime:         item.event.EndTime,
+			Reason:          item.event.Reason,
+			DetectedClasses: make([]string, 0),
+			Files:           make([]string, 0, len(item.files)),
+		}
+		json.Unmarshal([]byte(item.event.DetectedClasses), &entry.DetectedClasses)
+
+		for _, f := range item.files {
+			name := fmt.Sprintf("event_%d/%s", item.event.ID, filepath.Base(f))
+			if err := addArchiveFile(zw, name, f); err != nil {
+				return path, 0, fmt.Errorf("%s: %w", name, err)
+			}
+			entry.Files = append(entry.Files, name)
+		}
+		entries = append(entries, entry)
+	}
+
+	w, err := zw.Create("events.json")
+	if err != nil {
+		return path, 0, err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(entries); err != nil {
+		return path, 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return path, 0, err
+	}
+
+	info, err := out.Stat()
+	if err != nil {
+		return path, 0, err
+	}
+	return path, info.Size(), nil
+}
+
+func addArchiveFile(zw *zip.Writer, name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name, header.Method = name, zip.Store
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func setArchiveState(job *ArchiveJob, update func(*ArchiveJob)) {
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	update(job)
+}
+
+// pruneArchiveJobs expires finished jobs past their TTL and deletes archive files
+// no job refers to (e.g. left over from before a restart). Callers hold archiveMu.
+func pruneArchiveJobs() {
+	now := time.Now()
+	live := make(map[string]bool)
+	for id, j := range archiveJobs {
+		if j.ExpiresAt != nil && now.After(*j.ExpiresAt) {
+			if j.file != "" {
+				os.Remove(j.file)
+			}
+			delete(archiveJobs, id)
+			continue
+		}
+		if j.FinishedAt != nil && j.State != archiveDone && now.Sub(*j.FinishedAt) > ArchiveTTL {
+			delete(archiveJobs, id)
+			continue
+		}
+		live[id+".zip"] = true
+	}
+
+	files, _ := os.ReadDir(ArchiveDir)
+	for _, f := range files {
+		if !live[f.Name()] {
+			os.Remove(filepath.Join(ArchiveDir, f.Name()))
+		}
+	}
+}
+
+// getArchiveJob reports an export job's progress to the user who started it
+func getArchiveJob(c echo.Context) error {
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	pruneArchiveJobs()
+
+	job, ok := archiveJobs[c.Param("id")]
+	if !ok || job.userID != getUser(c).ID {
+		return notFound(c, "Export")
+	}
+	return c.JSON(http.StatusOK, *job)
+}
+
+// downloadArchive serves a finished archive once: the token in the link stands
+// in for auth, and the file is deleted after it has been sent
+func downloadArchive(c echo.Context) error {
+	archiveMu.Lock()
+	job, ok := archiveJobs[c.Param("id")]
+	if !ok || job.State != archiveDone || subtle.ConstantTimeCompare([]byte(c.QueryParam("token")), []byte(job.token)) != 1 {
+		archiveMu.Unlock()
+		return c.JSON(http.StatusNotFound, map[string]string{"detail": "Download link is invalid, used or expired"})
+	}
+	file, filename := job.file, job.filename
+	job.State, job.file, job.DownloadURL = archiveExpired, "", ""
+	archiveMu.Unlock()
+
+	defer os.Remove(file)
+	return c.Attachment(file, filename)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+)
+
+// useArchiveDir builds archives in a temporary directory and forgets any jobs
+func useArchiveDir(t *testing.T) {
+	t.Helper()
+	prev := ArchiveDir
+	ArchiveDir = t.TempDir()
+	reset := func() {
+		archiveMu.Lock()
+		archiveJobs = make(map[string]*ArchiveJob)
+		archiveMu.Unlock()
+	}
+	reset()
+	t.Cleanup(func() {
+		reset()
+		ArchiveDir = prev
+	})
+}
+
+func TestWriteArchive(t *testing.T) {
+	useArchiveDir(t)
+	dir := t.TempDir()
+	clip := filepath.Join(dir, "event_1_20240102-120000.mp4")
+	sidecar := filepath.Join(dir, "event_1_20240102-120000.json")
+	writeFile(t, clip, "video")
+	writeFile(t, sidecar, "{}")
+
+	items := []archiveEvent{{
+		event: models.Event{ID: 1, Reason: models.ReasonMotion, DetectedClasses: `["person"]`},
+		files: []string{clip, sidecar},
+	}}
+	path, size, err := writeArchive("job1", items)
+	if err != nil || size == 0 || filepath.Dir(path) != ArchiveDir {
+		t.Fatalf("writeArchive = %s, %d, %v", path, size, err)
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	contents := make(map[string]string)
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, ".mp4") && f.Method != zip.Store {
+			t.Errorf("%s compressed (method %d); clips are stored", f.Name, f.Method)
+		}
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(data)
+	}
+	if contents["event_1/event_1_20240102-120000.mp4"] != "video" {
+		t.Errorf("archive holds %v", contents)
+	}
+	var entries []archiveEntry
+	if err := json.Unmarshal([]byte(contents["events.json"]), &entries); err != nil || len(entries) != 1 {
+		t.Fatalf("events.json = %s", contents["events.json"])
+	}
+	if e := entries[0]; e.ID != 1 || len(e.Files) != 2 || len(e.DetectedClasses) != 1 || e.DetectedClasses[0] != "person" {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+func TestPruneArchiveJobs(t *testing.T) {
+	useArchiveDir(t)
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	longAgo := time.Now().Add(-ArchiveTTL - time.Minute)
+	for _, name := range []string{"expired.zip", "live.zip", "orphan.zip"} {
+		writeFile(t, filepath.Join(ArchiveDir, name), "zip")
+	}
+
+	archiveMu.Lock()
+	archiveJobs["expired"] = &ArchiveJob{ID: "expired", State: archiveDone, ExpiresAt: &past, file: filepath.Join(ArchiveDir, "expired.zip")}
+	archiveJobs["live"] = &ArchiveJob{ID: "live", State: archiveDone, ExpiresAt: &future, file: filepath.Join(ArchiveDir, "live.zip")}
+	archiveJobs["failed"] = &ArchiveJob{ID: "failed", State: archiveFailed, FinishedAt: &longAgo}
+	pruneArchiveJobs()
+	_, keptLive := archiveJobs["live"]
+	remaining := len(archiveJobs)
+	archiveMu.Unlock()
+
+	if !keptLive || remaining != 1 {
+		t.Errorf("%d jobs left, live kept %v", remaining, keptLive)
+	}
+	files, _ := os.ReadDir(ArchiveDir)
+	if len(files) != 1 || files[0].Name() != "live.zip" {
+		t.Errorf("files left = %v, want only live.zip", files)
+	}
+}
+
+func TestExportCameraEvents(t *testing.T) {
+	testDB(t)
+	testRecordingRoots(t)
+	useArchiveDir(t)
+	user := createTestUser(t, "user@example.com", false)
+	other := createTestUser(t, "other@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	camID := strconv.Itoa(int(cam.ID))
+
+	if rec := callHandler(exportCameraEvents, http.MethodPost, "/", "", user, "id", camID); rec.Code != http.StatusNotFound {
+		t.Errorf("no events: status %d", rec.Code)
+	}
+
+	clip := filepath.Join(detector.EventRoot, "event_1_20240102-120000.mp4")
+	writeFile(t, clip, "video")
+	start := time.Now().Add(-time.Hour)
+	database.DB.Create(&models.Event{CameraID: cam.ID, UserID: user.ID, StartTime: start, EndTime: start.Add(time.Minute), VideoPath: detector.LogicalPath(clip)})
+
+	if rec := callHandler(exportCameraEvents, http.MethodPost, "/?start_ts=yesterday", "", user, "id", camID); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad start_ts: status %d", rec.Code)
+	}
+	rec := callHandler(exportCameraEvents, http.MethodPost, "/", "", user, "id", camID)
+	var job ArchiveJob
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil || rec.Code != http.StatusAccepted || job.Events != 1 {
+		t.Fatalf("export: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.State != archiveDone && job.State != archiveFailed && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		json.Unmarshal(callHandler(getArchiveJob, http.MethodGet, "/", "", user, "id", job.ID).Body.Bytes(), &job)
+	}
+	if job.State != archiveDone || job.DownloadURL == "" {
+		t.Fatalf("job = %+v", job)
+	}
+	if rec := callHandler(getArchiveJob, http.MethodGet, "/", "", other, "id", job.ID); rec.Code != http.StatusNotFound {
+		t.Errorf("another user's job: status %d", rec.Code)
+	}
+
+	token := job.DownloadURL[strings.Index(job.DownloadURL, "token=")+len("token="):]
+	if rec := callHandler(downloadArchive, http.MethodGet, "/?token=wrong", "", nil, "id", job.ID); rec.Code != http.StatusNotFound {
+		t.Errorf("wrong token: status %d", rec.Code)
+	}
+	if rec := callHandler(downloadArchive, http.MethodGet, "/?token="+token, "", nil, "id", job.ID); rec.Code != http.StatusOK {
+		t.Errorf("download: status %d", rec.Code)
+	}
+	// The link is single use and the file is gone
+	if rec := callHandler(downloadArchive, http.MethodGet, "/?token="+token, "", nil, "id", job.ID); rec.Code != http.StatusNotFound {
+		t.Errorf("second download: status %d", rec.Code)
+	}
+	if files, _ := os.ReadDir(ArchiveDir); len(files) != 0 {
+		t.Errorf("archive left on disk after download: %v", files)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+const (
+	// Upper bound for EventGroupGapSeconds and ?group_gap=
+	maxEventGroupGap = 3600
+
+	// Grouping is done in memory over at most this many of the newest matching
+	// events; past it the response carries X-Events-Truncated
+	maxGroupedEvents = 5000
+)
+
+// EventGroup is a read-time merge of one camera's back-to-back events. The
+// underlying events are untouched; Event is the first of them, for thumbnails.
+type EventGroup struct {
+	CameraID        uint         `json:"camera_id"`
+	StartTime       time.Time    `json:"start_time"`
+	EndTime         time.Time    `json:"end_time"`
+	Count           int          `json:"count"`
+	EventIDs        []uint       `json:"event_ids"`
+	DetectedClasses []string     `json:"detected_classes"`
+	Event           models.Event `json:"event"`
+}
+
+// getEventGroups serves getEvents?grouped=true: matching events are merged per
+// camera when one starts at most the gap after the previous one ended. The gap
+// is EventGroupGapSeconds unless ?group_gap= (seconds) overrides it.
+func getEventGroups(c echo.Context) error {
+	gap := time.Duration(loadSettings().EventGroupGapSeconds) * time.Second
+	if raw := c.QueryParam("group_gap"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > maxEventGroupGap {
+			return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid group_gap"})
+		}
+		gap = time.Duration(n) * time.Second
+	}
+
+	events := make([]models.Event, 0)
+	tx := database.DB.Model(&models.Event{}).Where("user_id = ?", getUser(c).ID)
+	applyEventFilters(tx, c).Preload("Camera").Order("start_time desc").Limit(maxGroupedEvents + 1).Find(&events)
+
+	// The oldest groups (and the total) no longer cover every matching event;
+	// say so rather than passing a partial count off as exact
+	if len(events) > maxGroupedEvents {
+		events = events[:maxGroupedEvents]
+		c.Response().Header().Set("X-Events-Truncated", "true")
+	}
+
+	groups := groupEvents(events, gap, time.Now())
+	page, paged := parsePagination(c)
+	if !paged {
+		return c.JSON(http.StatusOK, groups)
+	}
+
+	total := int64(len(groups))
+	start := min((page.Page-1)*page.PageSize, len(groups))
+	end := min(start+page.PageSize, len(groups))
+	return respondPage(c, groups[start:end], total, page)
+}
+
+// groupEvents clusters events (any order) per camera and returns the groups
+// newest first. An event still recording counts as ending at now.
+func groupEvents(events []models.Event, gap time.Duration, now time.Time) []EventGroup {
+	sorted := make([]models.Event, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].CameraID != sorted[j].CameraID {
+			return sorted[i].CameraID < sorted[j].CameraID
+		}
+		return sorted[i].StartTime.Before(sorted[j].StartTime)
+	})
+
+	groups := make([]EventGroup, 0)
+	var cur *EventGroup
+	var seen map[string]bool
+	for _, ev := range sorted {
+		end := ev.EndTime
+		if end.IsZero() || end.Before(ev.StartTime) {
+			end = now
+		}
+
+		if cur == nil || cur.CameraID != ev.CameraID || ev.StartTime.Sub(cur.EndTime) > gap {
+			groups = append(groups, EventGroup{
+				CameraID:        ev.CameraID,
+				StartTime:       ev.StartTime,
+				EndTime:         end,
+				EventIDs:        make([]uint, 0, 1),
+				DetectedClasses: make([]string, 0),
+				Event:           ev,
+			})
+			cur = &groups[len(groups)-1]
+			seen = make(map[string]bool)
+		}
+
+		cur.Count++
+		cur.EventIDs = append(cur.EventIDs, ev.ID)
+		if end.After(cur.EndTime) {
+			cur.EndTime = end
+		}
+		var classes []string
+		json.Unmarshal([]byte(ev.DetectedClasses), &classes)
+		for _, class := range classes {
+			if !seen[class] {
+				seen[class] = true
+				cur.DetectedClasses = append(cur.DetectedClasses, class)
+			}
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].StartTime.After(groups[j].StartTime) })
+	return groups
+}
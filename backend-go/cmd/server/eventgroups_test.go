@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestGroupEvents(t *testing.T) {
+	base := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return base.Add(time.Duration(s) * time.Second) }
+	now := at(1000)
+	events := []models.Event{
+		// Camera 1: 1 and 2 are 30s apart (within the gap), 3 starts 90s after 2 ends
+		{ID: 2, CameraID: 1, StartTime: at(40), EndTime: at(50), DetectedClasses: `["car","person"]`},
+		{ID: 1, CameraID: 1, StartTime: at(0), EndTime: at(10), DetectedClasses: `["person"]`},
+		{ID: 3, CameraID: 1, StartTime: at(140), EndTime: at(150)},
+		// Camera 2 overlaps camera 1 in time but never merges with it
+		{ID: 4, CameraID: 2, StartTime: at(45), EndTime: at(55)},
+		// Still recording: counts as ending now
+		{ID: 5, CameraID: 2, StartTime: at(900)},
+	}
+
+	groups := groupEvents(events, 60*time.Second, now)
+	type summary struct {
+		Camera uint
+		IDs    []uint
+		Start  time.Time
+		End    time.Time
+	}
+	var got []summary
+	for _, g := range groups {
+		got = append(got, summary{g.CameraID, g.EventIDs, g.StartTime, g.EndTime})
+		if g.Count != len(g.EventIDs) {
+			t.Errorf("group %v: count %d", g.EventIDs, g.Count)
+		}
+	}
+	want := []summary{
+		{2, []uint{5}, at(900), now},
+		{1, []uint{3}, at(140), at(150)},
+		{2, []uint{4}, at(45), at(55)},
+		{1, []uint{1, 2}, at(0), at(50)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groups =\n  %+v\nwant\n  %+v", got, want)
+	}
+	if merged := groups[3]; merged.Event.ID != 1 || !reflect.DeepEqual(merged.DetectedClasses, []string{"person", "car"}) {
+		t.Errorf("merged group: first event %d, classes %v", merged.Event.ID, merged.DetectedClasses)
+	}
+
+	// A gap of exactly the distance still merges; one second less splits
+	pair := []models.Event{{ID: 1, CameraID: 1, StartTime: at(0), EndTime: at(10)}, {ID: 2, CameraID: 1, StartTime: at(40), EndTime: at(50)}}
+	if n := len(groupEvents(pair, 30*time.Second, now)); n != 1 {
+		t.Errorf("gap equal to the distance: %d groups, want 1", n)
+	}
+	if n := len(groupEvents(pair, 29*time.Second, now)); n != 2 {
+		t.Errorf("gap below the distance: %d groups, want 2", n)
+	}
+	if groups := groupEvents(nil, time.Minute, now); groups == nil || len(groups) != 0 {
+		t.Errorf("no events = %v, want an empty list", groups)
+	}
+}
+
+func TestGetEventGroups(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	start := time.Now().Add(-time.Hour)
+	for _, offset := range []time.Duration{0, 20 * time.Second, 10 * time.Minute} {
+		database.DB.Create(&models.Event{CameraID: cam.ID, UserID: user.ID, StartTime: start.Add(offset), EndTime: start.Add(offset + 5*time.Second)})
+	}
+
+	count := func(query string) int {
+		var groups []EventGroup
+		rec := callHandler(getEvents, http.MethodGet, "/api/events?grouped=true"+query, "", user)
+		if err := json.Unmarshal(rec.Body.Bytes(), &groups); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, body %s", query, rec.Code, rec.Body)
+		}
+		return len(groups)
+	}
+	if n := count("&group_gap=60"); n != 2 {
+		t.Errorf("60s gap: %d groups, want 2", n)
+	}
+	if n := count("&group_gap=0"); n != 3 {
+		t.Errorf("0s gap: %d groups, want 3", n)
+	}
+	if rec := callHandler(getEvents, http.MethodGet, "/api/events?grouped=true&group_gap=99999", "", user); rec.Code != http.StatusBadRequest {
+		t.Errorf("oversized group_gap: status %d", rec.Code)
+	}
+}
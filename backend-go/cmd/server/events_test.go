@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+)
+
+func TestWebhookStartRejectsUnknownReason(t *testing.T) {
+	rec := callHandler(webhookStart, http.MethodPost, "/api/webhook/1/start?reason=alien", "", nil, "id", "1")
+	var body struct {
+		Detail  string   `json:"detail"`
+		Allowed []string `json:"allowed"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	if body.Detail == "" || len(body.Allowed) != len(models.EventReasons) {
+		t.Errorf("error body = %+v", body)
+	}
+}
+
+func TestGetEventReasons(t *testing.T) {
+	var reasons []string
+	rec := callHandler(getEventReasons, http.MethodGet, "/api/events/reasons", "", nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &reasons); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	if len(reasons) != len(models.EventReasons) || reasons[0] != models.ReasonMotion {
+		t.Errorf("reasons = %v", reasons)
+	}
+	for _, r := range reasons {
+		if !models.ValidEventReason(r) {
+			t.Errorf("listed reason %q is not valid", r)
+		}
+	}
+	if models.ValidEventReason("") || models.ValidEventReason("Motion") {
+		t.Error("ValidEventReason accepted an empty or differently cased reason")
+	}
+}
+
+func TestGetEventsFiltersByReason(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	for _, reason := range []string{models.ReasonMotion, models.ReasonPerson, models.ReasonPerson} {
+		database.DB.Create(&models.Event{CameraID: cam.ID, UserID: user.ID, StartTime: time.Now(), Reason: reason})
+	}
+
+	var events []models.Event
+	rec := callHandler(getEvents, http.MethodGet, "/api/events?reason=person", "", user)
+	if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	if len(events) != 2 {
+		t.Fatalf("reason=person returned %d events, want 2", len(events))
+	}
+	for _, e := range events {
+		if e.Reason != models.ReasonPerson {
+			t.Errorf("reason=person returned a %q event", e.Reason)
+		}
+	}
+}
+
+func TestGetEventPreview(t *testing.T) {
+	testDB(t)
+	testRecordingRoots(t)
+	user := createTestUser(t, "user@example.com", false)
+	other := createTestUser(t, "other@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	writeFile(t, filepath.Join(detector.EventRoot, "event_1_preview.webp"), "webp")
+	with := &models.Event{CameraID: cam.ID, UserID: user.ID, StartTime: time.Now(), PreviewPath: "recordings/event_1_preview.webp"}
+	without := &models.Event{CameraID: cam.ID, UserID: user.ID, StartTime: time.Now()}
+	database.DB.Create(with)
+	database.DB.Create(without)
+	withID, withoutID := strconv.Itoa(int(with.ID)), strconv.Itoa(int(without.ID))
+
+	rec := callHandler(getEventPreview, http.MethodGet, "/", "", user, "id", withID)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/webp" || rec.Body.String() != "webp" {
+		t.Errorf("owner: status %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec := callHandler(getEventPreview, http.MethodGet, "/", "", other, "id", withID); rec.Code != http.StatusNotFound {
+		t.Errorf("another user: status %d, want 404", rec.Code)
+	}
+	if rec := callHandler(getEventPreview, http.MethodGet, "/", "", user, "id", withoutID); rec.Code != http.StatusNotFound {
+		t.Errorf("event without a preview: status %d, want 404", rec.Code)
+	}
+
+	var detail EventDetail
+	rec = callHandler(getEvent, http.MethodGet, "/", "", user, "id", withID)
+	json.Unmarshal(rec.Body.Bytes(), &detail)
+	if detail.PreviewURL == "" {
+		t.Errorf("event detail has no preview_url: %s", rec.Body)
+	}
+}
+
+func TestWebhooksTouchCamera(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	id := strconv.Itoa(int(cam.ID))
+	before := time.Now().Add(-time.Second)
+
+	if rec := callHandler(webhookEnd, http.MethodPost, "/", "", nil, "id", id); rec.Code != http.StatusOK {
+		t.Fatalf("webhook end: status %d", rec.Code)
+	}
+	var stored models.Camera
+	database.DB.First(&stored, cam.ID)
+	if stored.LastWebhookAt == nil || stored.LastWebhookAt.Before(before) {
+		t.Errorf("last_webhook_at = %v", stored.LastWebhookAt)
+	}
+	// Webhooks arrive constantly; they must not invalidate camera list ETags
+	if !stored.UpdatedAt.Equal(cam.UpdatedAt) {
+		t.Errorf("updated_at moved from %v to %v", cam.UpdatedAt, stored.UpdatedAt)
+	}
+}
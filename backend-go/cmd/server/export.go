@@ -0,0 +1,103 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// csvFlushEvery controls how many rows are buffered before pushing to the client
+const csvFlushEvery = 100
+
+// exportEventsCSV streams the caller's events as CSV, honoring the same filters as getEventSummary
+func exportEventsCSV(c echo.Context) error {
+	if err := checkClassFilter(c); err != nil {
+		return err
+	}
+	tx := database.DB.Model(&models.Event{}).
+		Select("events.id, cameras.name, events.start_time, events.end_time, events.reason, events.detected_classes").
+		Joins("LEFT JOIN cameras ON cameras.id = events.camera_id").
+		Where("events.user_id = ?", getUser(c).ID)
+	tx = applyEventFilters(tx, c)
+
+	rows, err := tx.Order("events.start_time asc").Rows()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+	defer rows.Close()
+
+	filename := fmt.Sprintf("events_%s.csv", time.Now().Format("20060102-150405"))
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	res.WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(res)
+	w.Write(eventCSVHeader)
+
+	count := 0
+	for rows.Next() {
+		var (
+			id        uint
+			camName   sql.NullString
+			startTime time.Time
+			endTime   sql.NullTime
+			reason    string
+			classes   sql.NullString
+		)
+		if err := rows.Scan(&id, &camName, &startTime, &endTime, &reason, &classes); err != nil {
+			break
+		}
+
+		w.Write(eventCSVRecord(id, camName.String, startTime, endTime, reason, classes.String))
+
+		count++
+		if count%csvFlushEvery == 0 {
+			w.Flush()
+			res.Flush()
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+var eventCSVHeader = []string{"id", "camera_name", "start_time", "end_time", "reason", "detected_classes"}
+
+// eventCSVRecord is one export row; an event still recording has an empty end_time
+func eventCSVRecord(id uint, camName string, start time.Time, end sql.NullTime, reason, classes string) []string {
+	endStr := ""
+	if end.Valid && !end.Time.IsZero() {
+		endStr = end.Time.Format(time.RFC3339)
+	}
+	return []string{
+		strconv.FormatUint(uint64(id), 10),
+		camName,
+		start.Format(time.RFC3339),
+		endStr,
+		reason,
+		formatClassList(classes),
+	}
+}
+
+// formatClassList turns the stored JSON array into a spreadsheet-friendly "a;b" string
+func formatClassList(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	var classes []string
+	if err := json.Unmarshal([]byte(raw), &classes); err != nil {
+		return raw
+	}
+	return strings.Join(classes, ";")
+}
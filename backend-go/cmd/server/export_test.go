@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventCSVRows(t *testing.T) {
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	end := sql.NullTime{Time: start.Add(45 * time.Second), Valid: true}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(eventCSVHeader)
+	w.Write(eventCSVRecord(7, "Front, door", start, end, "motion", `["person","car"]`))
+	w.Write(eventCSVRecord(8, "", start, sql.NullTime{}, "ai", ""))
+	w.Write(eventCSVRecord(9, "Yard", start, end, "manual", "not json"))
+	w.Flush()
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	want := [][]string{
+		{"id", "camera_name", "start_time", "end_time", "reason", "detected_classes"},
+		{"7", "Front, door", "2024-03-01T08:00:00Z", "2024-03-01T08:00:45Z", "motion", "person;car"},
+		{"8", "", "2024-03-01T08:00:00Z", "", "ai", ""},
+		{"9", "Yard", "2024-03-01T08:00:00Z", "2024-03-01T08:00:45Z", "manual", "not json"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records =\n%q\nwant\n%q", records, want)
+	}
+}
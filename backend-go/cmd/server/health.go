@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/mediamtx"
+)
+
+// healthz is the liveness probe: the process is up and serving requests
+func healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz is the readiness probe: the dependencies needed to serve traffic answer
+func readyz(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+	defer cancel()
+
+	checks := map[string]string{"database": "ok", "mediamtx": "ok"}
+	ready := true
+
+	if sqlDB, err := database.DB.DB(); err != nil {
+		checks["database"], ready = err.Error(), false
+	} else if err := sqlDB.PingContext(ctx); err != nil {
+		checks["database"], ready = err.Error(), false
+	}
+	if err := mediamtx.PingContext(ctx); err != nil {
+		checks["mediamtx"], ready = err.Error(), false
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	return c.JSON(status, map[string]interface{}{"ready": ready, "checks": checks})
+}
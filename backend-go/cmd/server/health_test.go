@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/mediamtx"
+)
+
+type readiness struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+func getReadyz(t *testing.T) (int, readiness) {
+	t.Helper()
+	rec := callHandler(readyz, http.MethodGet, "/readyz", "", nil)
+	var body readiness
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("readyz body %q: %v", rec.Body, err)
+	}
+	return rec.Code, body
+}
+
+func TestHealthz(t *testing.T) {
+	if rec := callHandler(healthz, http.MethodGet, "/healthz", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("healthz: status %d", rec.Code)
+	}
+}
+
+func TestReadyzDatabaseDown(t *testing.T) {
+	newFakeMediaMTX(t)
+
+	// Nothing listens on port 1, so every ping fails
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=nvr dbname=nvr sslmode=disable connect_timeout=1"),
+		&gorm.Config{DisableAutomaticPing: true, Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = prev })
+
+	code, body := getReadyz(t)
+	if code != http.StatusServiceUnavailable || body.Ready {
+		t.Errorf("database down: status %d, ready %v", code, body.Ready)
+	}
+	if body.Checks["database"] == "ok" || body.Checks["mediamtx"] != "ok" {
+		t.Errorf("checks = %v, want only the database failing", body.Checks)
+	}
+
+	// Liveness doesn't depend on the database
+	if rec := callHandler(healthz, http.MethodGet, "/healthz", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("healthz with the database down: status %d", rec.Code)
+	}
+}
+
+func TestReadyzMediaMTXDown(t *testing.T) {
+	testDB(t)
+	prev := mediamtx.APIBase
+	mediamtx.APIBase = "http://127.0.0.1:1"
+	t.Cleanup(func() { mediamtx.APIBase = prev })
+
+	code, body := getReadyz(t)
+	if code != http.StatusServiceUnavailable || body.Checks["database"] != "ok" || body.Checks["mediamtx"] == "ok" {
+		t.Errorf("mediamtx down: status %d, checks %v", code, body.Checks)
+	}
+
+	newFakeMediaMTX(t)
+	if code, body := getReadyz(t); code != http.StatusOK || !body.Ready {
+		t.Errorf("all up: status %d, checks %v", code, body.Checks)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// Fields that change on their own and would only add noise to the history
+var historyIgnoredFields = map[string]bool{
+	"updated_at":      true,
+	"version":         true,
+	"last_webhook_at": true,
+	"stream_width":    true,
+	"stream_height":   true,
+	"stream_fps":      true,
+	"stream_codec":    true,
+}
+
+// recordCameraChanges stores one log row per field that differs between before and after
+func recordCameraChanges(before, after *models.Camera, userID uint) {
+	if logs := cameraChanges(before, after, userID); len(logs) > 0 {
+		database.DB.Create(&logs)
+	}
+}
+
+// cameraChanges builds the log rows for recordCameraChanges, sorted by field
+func cameraChanges(before, after *models.Camera, userID uint) []models.CameraChangeLog {
+	oldFields, newFields := cameraFields(before), cameraFields(after)
+
+	names := make([]string, 0, len(newFields))
+	for name := range newFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	logs := make([]models.CameraChangeLog, 0)
+	for _, name := range names {
+		if historyIgnoredFields[name] || oldFields[name] == newFields[name] {
+			continue
+		}
+		logs = append(logs, models.CameraChangeLog{
+			CameraID: after.ID,
+			UserID:   userID,
+			Field:    name,
+			OldValue: redactField(name, oldFields[name]),
+			NewValue: redactField(name, newFields[name]),
+		})
+	}
+	return logs
+}
+
+// cameraFields flattens a camera into json-name -> display value
+func cameraFields(cam *models.Camera) map[string]string {
+	raw, _ := json.Marshal(cam)
+	var decoded map[string]interface{}
+	json.Unmarshal(raw, &decoded)
+
+	fields := make(map[string]string, len(decoded))
+	for name, v := range decoded {
+		switch val := v.(type) {
+		case string:
+			fields[name] = val
+		case nil:
+			fields[name] = ""
+		default:
+			b, _ := json.Marshal(val)
+			fields[name] = string(b)
+		}
+	}
+	return fields
+}
+
+// redactField keeps credentials out of the history: passwords embedded in stream
+// URLs are masked and secret-looking fields are hidden entirely
+func redactField(name, value string) string {
+	if value == "" {
+		return value
+	}
+	if strings.Contains(name, "password") || strings.Contains(name, "token") || strings.Contains(name, "secret") {
+		return "[redacted]"
+	}
+	if strings.HasSuffix(name, "_url") {
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			if _, hasPass := u.User.Password(); hasPass {
+				u.User = url.UserPassword(u.User.Username(), "xxxxx")
+				return u.String()
+			}
+		}
+	}
+	return value
+}
+
+func getCameraHistory(c echo.Context) error {
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+
+	tx := database.DB.Model(&models.CameraChangeLog{}).Where("camera_id = ?", cam.ID)
+	if field := c.QueryParam("field"); field != "" {
+		tx = tx.Where("field = ?", field)
+	}
+	tx = tx.Session(&gorm.Session{})
+	ordered := tx.Order("created_at desc").Order("id desc")
+
+	entries := make([]models.CameraChangeLog, 0)
+	page, paged := parsePagination(c)
+	if !paged {
+		if err := ordered.Find(&entries).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+		}
+		return c.JSON(http.StatusOK, entries)
+	}
+
+	var total int64
+	tx.Count(&total)
+	if err := page.apply(ordered).Find(&entries).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+	return respondPage(c, entries, total, page)
+}
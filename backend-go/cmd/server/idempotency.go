@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// maxIdempotencyKeyLen keeps client keys within the indexed column
+const maxIdempotencyKeyLen = 255
+
+// How long a create can be safely retried with the same Idempotency-Key
+var IdempotencyKeyTTL = config.Duration("NVR_IDEMPOTENCY_TTL", 24*time.Hour)
+
+// How long a claim may stay pending. A request that died without completing or
+// releasing its claim only blocks the key this long, not for the whole TTL.
+var IdempotencyPendingLease = config.Duration("NVR_IDEMPOTENCY_PENDING_LEASE", 2*time.Minute)
+
+// claimIdempotencyKey reserves key for this user before the camera is created.
+// If the key was already used, the camera it produced is returned instead; a
+// claim with no camera yet means the first request is still running (409).
+func claimIdempotencyKey(c echo.Context, userID uint, key string) (*models.IdempotencyKey, *models.Camera, error) {
+	if len(key) > maxIdempotencyKeyLen {
+		return nil, nil, c.JSON(http.StatusBadRequest, map[string]string{"detail": "Idempotency-Key too long"})
+	}
+
+	database.DB.Where("user_id = ? AND key = ? AND expires_at < ?", userID, key, time.Now()).Delete(&models.IdempotencyKey{})
+
+	claim := &models.IdempotencyKey{UserID: userID, Key: key, ExpiresAt: time.Now().Add(IdempotencyPendingLease)}
+	if err := database.DB.Create(claim).Error; err == nil {
+		return claim, nil, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := database.DB.Where("user_id = ? AND key = ?", userID, key).First(&existing).Error; err != nil {
+		return nil, nil, c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+	if existing.CameraID == 0 {
+		return nil, nil, c.JSON(http.StatusConflict, map[string]string{"detail": "A request with this Idempotency-Key is still in progress"})
+	}
+
+	var cam models.Camera
+	if err := database.DB.Where("id = ? AND owner_id = ?", existing.CameraID, userID).First(&cam).Error; err != nil {
+		// The camera has since been deleted; let the key be reused
+		database.DB.Delete(&existing)
+		return claimIdempotencyKey(c, userID, key)
+	}
+	return nil, &cam, nil
+}
+
+// completeIdempotencyKey records the camera a claimed key produced and extends
+// the claim from its pending lease to the full TTL
+func completeIdempotencyKey(claim *models.IdempotencyKey, cameraID uint) {
+	if claim != nil {
+		database.DB.Model(claim).Updates(map[string]interface{}{
+			"camera_id":  cameraID,
+			"expires_at": time.Now().Add(IdempotencyKeyTTL),
+		})
+	}
+}
+
+// releaseIdempotencyKey drops a claim whose create failed so the client can retry
+func releaseIdempotencyKey(claim *models.IdempotencyKey) {
+	if claim != nil {
+		database.DB.Delete(claim)
+	}
+}
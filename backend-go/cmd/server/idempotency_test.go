@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// createWithKey posts a camera carrying an Idempotency-Key header
+func createWithKey(user *models.User, name, key string) *httptest.ResponseRecorder {
+	c, rec := handlerContext(http.MethodPost, "/api/cameras", cameraBody(name), user)
+	c.Request().Header.Set("Idempotency-Key", key)
+	serve(createCamera, c)
+	return rec
+}
+
+func TestCreateCameraIdempotent(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+
+	var first, again models.Camera
+	rec := createWithKey(user, "front", "key-1")
+	if err := json.Unmarshal(rec.Body.Bytes(), &first); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("first create: %d %s", rec.Code, rec.Body)
+	}
+	rec = createWithKey(user, "front", "key-1")
+	if err := json.Unmarshal(rec.Body.Bytes(), &again); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("retry: %d %s", rec.Code, rec.Body)
+	}
+	if again.ID != first.ID {
+		t.Errorf("retry returned camera %d, want %d", again.ID, first.ID)
+	}
+
+	var count int64
+	database.DB.Model(&models.Camera{}).Where("owner_id = ?", user.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("user owns %d cameras after a retry, want 1", count)
+	}
+
+	// Keys are per user
+	other := createTestUser(t, "other@example.com", false)
+	var theirs models.Camera
+	json.Unmarshal(createWithKey(other, "back", "key-1").Body.Bytes(), &theirs)
+	if theirs.ID == 0 || theirs.ID == first.ID || theirs.OwnerID != other.ID {
+		t.Errorf("another user's create with the same key = %+v", theirs)
+	}
+
+	// Once the camera is gone the key can make a new one
+	database.DB.Delete(&models.Camera{}, first.ID)
+	json.Unmarshal(createWithKey(user, "front", "key-1").Body.Bytes(), &again)
+	if again.ID == 0 || again.ID == first.ID {
+		t.Errorf("create after deleting the camera returned %d", again.ID)
+	}
+}
+
+func TestCreateCameraPendingKey(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+
+	// A create still in flight holds the key
+	database.DB.Create(&models.IdempotencyKey{UserID: user.ID, Key: "busy", ExpiresAt: time.Now().Add(time.Minute)})
+	if rec := createWithKey(user, "front", "busy"); rec.Code != http.StatusConflict {
+		t.Errorf("pending key: status %d, want 409", rec.Code)
+	}
+
+	// One that died without finishing only blocks the key for its lease
+	database.DB.Create(&models.IdempotencyKey{UserID: user.ID, Key: "stale", ExpiresAt: time.Now().Add(-time.Second)})
+	if rec := createWithKey(user, "front", "stale"); rec.Code != http.StatusOK {
+		t.Errorf("expired pending key: status %d, want 200", rec.Code)
+	}
+	var claim models.IdempotencyKey
+	database.DB.Where("user_id = ? AND key = ?", user.ID, "stale").First(&claim)
+	if claim.CameraID == 0 || time.Until(claim.ExpiresAt) < IdempotencyKeyTTL-time.Minute {
+		t.Errorf("completed claim = %+v, want a camera and the full TTL", claim)
+	}
+
+	if rec := createWithKey(user, "back", strings.Repeat("k", maxIdempotencyKeyLen+1)); rec.Code != http.StatusBadRequest {
+		t.Errorf("oversized key: status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/detector"
+)
+
+// Largest upload importContinuous accepts
+var MaxImportBytes = int64(config.Int("NVR_MAX_IMPORT_MB", 2048)) << 20
+
+// importContinuous ingests an external MP4 as a continuous segment. The multipart
+// body is read part by part so the video streams straight to disk; "timestamp"
+// (RFC 3339, the clip's start) may come before or after the "file" part.
+func importContinuous(c echo.Context) error {
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxImportBytes)
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Expected multipart/form-data"})
+	}
+
+	dir := detector.ContinuousDir(cam.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "Could not create recording directory"})
+	}
+
+	var (
+		tmpPath   string
+		startTime time.Time
+	)
+	defer func() {
+		if tmpPath != "" {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return importReadError(c, err)
+		}
+
+		switch part.FormName() {
+		case "timestamp":
+			raw, _ := io.ReadAll(io.LimitReader(part, 64))
+			startTime, err = time.Parse(time.RFC3339, strings.TrimSpace(string(raw)))
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"detail": "timestamp must be RFC 3339"})
+			}
+		case "file":
+			if tmpPath != "" {
+				return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Only one file per import"})
+			}
+			tmp, err := os.CreateTemp(dir, ".import-*.mp4")
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "Could not create temp file"})
+			}
+			tmpPath = tmp.Name()
+			_, err = io.Copy(tmp, part)
+			tmp.Close()
+			if err != nil {
+				return importReadError(c, err)
+			}
+		}
+		part.Close()
+	}
+
+	if tmpPath == "" || startTime.IsZero() {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "file and timestamp are required"})
+	}
+
+	duration, err := detector.ProbeClip(c.Request().Context(), tmpPath)
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"detail": "Not a playable video: " + err.Error()})
+	}
+
+	startTime = startTime.UTC()
+	filename := startTime.Format(detector.SegmentTimeLayout) + ".mp4"
+	if _, err := os.Stat(detector.SegmentPath(cam.ID, filename)); err == nil {
+		return c.JSON(http.StatusConflict, map[string]string{"detail": "A recording already exists at " + filename})
+	}
+	dest := filepath.Join(detector.SegmentDir(cam.ID, startTime), filename)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "Could not create recording directory"})
+	}
+	if err := os.Rename(tmpPath, dest); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "Could not store recording"})
+	}
+	tmpPath = ""
+
+	// Retention works off mtime, so date the file by when the footage ended
+	end := startTime.Add(time.Duration(duration * float64(time.Second)))
+	os.Chtimes(dest, end, end)
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"filename":         filename,
+		"url":              fmt.Sprintf("continuous/%d/%s", cam.ID, filename),
+		"start_time":       startTime,
+		"duration_seconds": duration,
+	})
+}
+
+func importReadError(c echo.Context, err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"detail": fmt.Sprintf("Upload exceeds %d MB", MaxImportBytes>>20)})
+	}
+	return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Upload interrupted"})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+)
+
+// useFFprobe points detector.FFprobeBin at a shell script for the rest of the test
+func useFFprobe(t *testing.T, script string) {
+	t.Helper()
+	stub := filepath.Join(t.TempDir(), "ffprobe")
+	if err := os.WriteFile(stub, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	prev := detector.FFprobeBin
+	detector.FFprobeBin = stub
+	t.Cleanup(func() { detector.FFprobeBin = prev })
+}
+
+// postImport uploads a clip with the given form fields (empty values are left out)
+func postImport(user *models.User, cam *models.Camera, timestamp, clip string) *http.Response {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if clip != "" {
+		fw, _ := w.CreateFormFile("file", "clip.mp4")
+		fw.Write([]byte(clip))
+	}
+	if timestamp != "" {
+		w.WriteField("timestamp", timestamp)
+	}
+	w.Close()
+
+	c, rec := handlerContext(http.MethodPost, "/", body.String(), user, "id", strconv.Itoa(int(cam.ID)))
+	c.Request().Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	serve(importContinuous, c)
+	return rec.Result()
+}
+
+func TestImportContinuous(t *testing.T) {
+	testDB(t)
+	testRecordingRoots(t)
+	useFFprobe(t, "echo 60")
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+
+	res := postImport(user, cam, "2024-03-04T05:06:07+02:00", "video bytes")
+	var out struct {
+		Filename string  `json:"filename"`
+		Duration float64 `json:"duration_seconds"`
+	}
+	json.NewDecoder(res.Body).Decode(&out)
+	if res.StatusCode != http.StatusCreated || out.Filename != "20240304-030607.mp4" || out.Duration != 60 {
+		t.Fatalf("import: status %d, %+v", res.StatusCode, out)
+	}
+
+	path := detector.SegmentPath(cam.ID, out.Filename)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("imported segment missing: %v", err)
+	}
+	if want := time.Date(2024, 3, 4, 3, 7, 7, 0, time.UTC); !info.ModTime().Equal(want) {
+		t.Errorf("mtime = %v, want the footage's end %v", info.ModTime().UTC(), want)
+	}
+
+	if res := postImport(user, cam, "2024-03-04T03:06:07Z", "again"); res.StatusCode != http.StatusConflict {
+		t.Errorf("importing over an existing segment: status %d, want 409", res.StatusCode)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "video bytes" {
+		t.Errorf("existing segment overwritten: %q", data)
+	}
+
+	// Only the one segment remains: rejected uploads leave no temp files
+	entries, _ := os.ReadDir(detector.ContinuousDir(cam.ID))
+	if len(entries) != 1 {
+		t.Errorf("continuous dir holds %d entries, want 1", len(entries))
+	}
+}
+
+func TestImportContinuousRejects(t *testing.T) {
+	testDB(t)
+	testRecordingRoots(t)
+	user := createTestUser(t, "user@example.com", false)
+	other := createTestUser(t, "other@example.com", false)
+	cam := createTestCamera(t, user, "front")
+
+	useFFprobe(t, "echo 'Invalid data found' >&2; exit 1")
+	cases := []struct {
+		what            string
+		user            *models.User
+		timestamp, clip string
+		want            int
+	}{
+		{"another user's camera", other, "2024-03-04T05:06:07Z", "x", http.StatusNotFound},
+		{"no timestamp", user, "", "x", http.StatusBadRequest},
+		{"no file", user, "2024-03-04T05:06:07Z", "", http.StatusBadRequest},
+		{"bad timestamp", user, "yesterday", "x", http.StatusBadRequest},
+		{"unplayable file", user, "2024-03-04T05:06:07Z", "not a video", http.StatusUnprocessableEntity},
+	}
+	for _, tc := range cases {
+		if res := postImport(tc.user, cam, tc.timestamp, tc.clip); res.StatusCode != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.what, res.StatusCode, tc.want)
+		}
+	}
+
+	prev := MaxImportBytes
+	MaxImportBytes = 1024
+	t.Cleanup(func() { MaxImportBytes = prev })
+	if res := postImport(user, cam, "2024-03-04T05:06:07Z", string(make([]byte, 4096))); res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload: status %d, want 413", res.StatusCode)
+	}
+
+	entries, _ := os.ReadDir(detector.ContinuousDir(cam.ID))
+	if len(entries) != 0 {
+		t.Errorf("rejected imports left %d files behind", len(entries))
+	}
+}
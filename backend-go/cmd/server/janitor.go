@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+)
+
+type JanitorStatus struct {
+	Paused      bool       `json:"paused"`
+	PausedUntil *time.Time `json:"paused_until"`
+}
+
+func janitorStatus(settings models.SystemSettings) JanitorStatus {
+	until := settings.JanitorPausedUntil
+	if until == nil || !time.Now().Before(*until) {
+		return JanitorStatus{}
+	}
+	return JanitorStatus{Paused: true, PausedUntil: until}
+}
+
+func getJanitorStatus(c echo.Context) error {
+	return c.JSON(http.StatusOK, janitorStatus(loadSettings()))
+}
+
+// pauseJanitor holds retention deletion for ?minutes= (default and cap: JanitorMaxPause).
+// The deadline is stored in settings so a restart doesn't silently resume or forget it.
+func pauseJanitor(c echo.Context) error {
+	duration := detector.JanitorMaxPause
+	if m := c.QueryParam("minutes"); m != "" {
+		n, err := strconv.Atoi(m)
+		if err != nil || n < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid minutes value"})
+		}
+		duration = min(time.Duration(n)*time.Minute, detector.JanitorMaxPause)
+	}
+
+	settings := loadSettings()
+	until := time.Now().Add(duration)
+	if err := database.DB.Model(&settings).Update("janitor_paused_until", until).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+	settings.JanitorPausedUntil = &until
+	return c.JSON(http.StatusOK, janitorStatus(settings))
+}
+
+func resumeJanitor(c echo.Context) error {
+	settings := loadSettings()
+	if err := database.DB.Model(&settings).Update("janitor_paused_until", nil).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+	return c.JSON(http.StatusOK, JanitorStatus{})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+)
+
+func TestPauseAndResumeJanitor(t *testing.T) {
+	testDB(t)
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true})
+	admin := createTestUser(t, "admin@example.com", true)
+
+	call := func(h echo.HandlerFunc, target string) (int, JanitorStatus) {
+		var s JanitorStatus
+		rec := callHandler(h, http.MethodPost, target, "", admin)
+		json.Unmarshal(rec.Body.Bytes(), &s)
+		return rec.Code, s
+	}
+	stored := func() *time.Time {
+		return loadSettings().JanitorPausedUntil
+	}
+
+	if code, s := call(getJanitorStatus, "/"); code != http.StatusOK || s.Paused {
+		t.Fatalf("initial status %d, %+v", code, s)
+	}
+
+	for _, bad := range []string{"0", "-5", "soon"} {
+		if code, _ := call(pauseJanitor, "/?minutes="+bad); code != http.StatusBadRequest {
+			t.Errorf("minutes=%s: status %d, want 400", bad, code)
+		}
+	}
+
+	before := time.Now()
+	code, s := call(pauseJanitor, "/?minutes=30")
+	if code != http.StatusOK || !s.Paused || s.PausedUntil == nil {
+		t.Fatalf("pause: status %d, %+v", code, s)
+	}
+	if d := s.PausedUntil.Sub(before); d < 30*time.Minute || d > 31*time.Minute {
+		t.Errorf("paused for %v, want 30m", d)
+	}
+	if until := stored(); until == nil || !until.After(before) {
+		t.Errorf("pause not stored: %v", until)
+	}
+	if _, s := call(getJanitorStatus, "/"); !s.Paused {
+		t.Error("status doesn't report the pause")
+	}
+
+	// Longer pauses are capped so deletion can't be forgotten for good
+	_, s = call(pauseJanitor, "/?minutes=1000000")
+	if d := time.Until(*s.PausedUntil); d > detector.JanitorMaxPause {
+		t.Errorf("pause of %v exceeds the %v cap", d, detector.JanitorMaxPause)
+	}
+
+	if code, s := call(resumeJanitor, "/"); code != http.StatusOK || s.Paused {
+		t.Errorf("resume: status %d, %+v", code, s)
+	}
+	if until := stored(); until != nil {
+		t.Errorf("pause kept after resume: %v", until)
+	}
+}
+
+func TestJanitorStatusIgnoresExpiredPause(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	if s := janitorStatus(models.SystemSettings{JanitorPausedUntil: &past}); s.Paused || s.PausedUntil != nil {
+		t.Errorf("expired pause reported: %+v", s)
+	}
+}
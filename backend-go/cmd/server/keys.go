@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// deriveKey derives a purpose-specific key from the JWT secret, so a value
+// MAC'd for one purpose can never pass as a token or signature for another
+func deriveKey(label string) []byte {
+	key, err := hkdf.Key(sha256.New, JwtSecret, nil, "nvr-server "+label, sha256.Size)
+	if err != nil {
+		// Only possible for an out-of-range length, which sha256.Size isn't
+		panic(err)
+	}
+	return key
+}
+
+// setPreviousSecretWindow fixes when the previous JWT secret stops being accepted:
+// NVR_JWT_PREVIOUS_UNTIL if set, otherwise PreviousSecretGrace after this backend
+// first saw that secret. The first sighting is stored, so restarts don't extend it.
+func setPreviousSecretWindow() {
+	if PreviousJwtSecret == nil {
+		return
+	}
+	defer func() {
+		log.Printf("Accepting tokens signed with the previous JWT secret until %s\n", PreviousSecretUntil.Format(time.RFC3339))
+	}()
+
+	if raw := config.String("NVR_JWT_PREVIOUS_UNTIL", ""); raw != "" {
+		if until, err := time.Parse(time.RFC3339, raw); err == nil {
+			PreviousSecretUntil = until
+			return
+		}
+		log.Printf("Ignoring NVR_JWT_PREVIOUS_UNTIL=%q: not an RFC 3339 time\n", raw)
+	}
+
+	sum := sha256.Sum256(PreviousJwtSecret)
+	keyID := hex.EncodeToString(sum[:8])
+	seenAt := time.Now()
+	database.DB.Model(&models.SystemSettings{}).
+		Where("previous_jwt_key_id IS DISTINCT FROM ?", keyID).
+		Updates(map[string]interface{}{"previous_jwt_key_id": keyID, "previous_jwt_seen_at": seenAt})
+
+	var settings models.SystemSettings
+	if err := database.DB.First(&settings).Error; err == nil && settings.PreviousJwtKeyID == keyID && settings.PreviousJwtSeenAt != nil {
+		seenAt = *settings.PreviousJwtSeenAt
+	}
+	PreviousSecretUntil = seenAt.Add(PreviousSecretGrace)
+}
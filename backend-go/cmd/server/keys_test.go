@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signedWith returns an access token for user 1 signed with secret
+func signedWith(t *testing.T, secret string) string {
+	t.Helper()
+	claims := &JwtCustomClaims{
+		UserID: 1,
+		Type:   "access",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestPreviousSecretGraceWindow(t *testing.T) {
+	testSecrets(t)
+	prevSecret, prevUntil := PreviousJwtSecret, PreviousSecretUntil
+	t.Cleanup(func() { PreviousJwtSecret, PreviousSecretUntil = prevSecret, prevUntil })
+
+	valid := func(token string) bool {
+		parsed, err := jwt.ParseWithClaims(token, &JwtCustomClaims{}, jwtKeyFunc)
+		return err == nil && parsed.Valid
+	}
+	current := signedWith(t, string(JwtSecret))
+	old := signedWith(t, "old-secret")
+	stranger := signedWith(t, "unknown-secret")
+
+	PreviousJwtSecret = nil
+	if !valid(current) || valid(old) {
+		t.Errorf("no rotation: current %v, old %v", valid(current), valid(old))
+	}
+
+	PreviousJwtSecret = []byte("old-secret")
+	PreviousSecretUntil = time.Now().Add(time.Hour)
+	if !valid(current) || !valid(old) {
+		t.Errorf("inside the grace window: current %v, old %v; want both valid", valid(current), valid(old))
+	}
+	if valid(stranger) {
+		t.Error("token signed with an unknown secret accepted")
+	}
+
+	PreviousSecretUntil = time.Now().Add(-time.Second)
+	if !valid(current) || valid(old) {
+		t.Errorf("after the grace window: current %v, old %v", valid(current), valid(old))
+	}
+
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, &JwtCustomClaims{UserID: 1})
+	unsigned, _ := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if valid(unsigned) {
+		t.Error("unsigned token accepted")
+	}
+}
+
+func TestPreviousSecretExplicitExpiry(t *testing.T) {
+	prevSecret, prevUntil := PreviousJwtSecret, PreviousSecretUntil
+	t.Cleanup(func() { PreviousJwtSecret, PreviousSecretUntil = prevSecret, prevUntil })
+
+	PreviousJwtSecret = []byte("old-secret")
+	t.Setenv("NVR_JWT_PREVIOUS_UNTIL", "2030-01-02T03:04:05Z")
+	setPreviousSecretWindow()
+	if want := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC); !PreviousSecretUntil.Equal(want) {
+		t.Errorf("PreviousSecretUntil = %v, want %v", PreviousSecretUntil, want)
+	}
+}
+
+func TestPreviousSecretWindowSurvivesRestart(t *testing.T) {
+	testDB(t)
+	ensureDefaultSettings()
+	prevSecret, prevUntil := PreviousJwtSecret, PreviousSecretUntil
+	t.Cleanup(func() { PreviousJwtSecret, PreviousSecretUntil = prevSecret, prevUntil })
+
+	PreviousJwtSecret = []byte("old-secret")
+	setPreviousSecretWindow()
+	first := PreviousSecretUntil
+	if d := time.Until(first); d <= 0 || d > PreviousSecretGrace {
+		t.Fatalf("window ends in %v, want within %v", d, PreviousSecretGrace)
+	}
+
+	// A restart with the same previous secret keeps the original deadline
+	time.Sleep(10 * time.Millisecond)
+	setPreviousSecretWindow()
+	if !PreviousSecretUntil.Equal(first) {
+		t.Errorf("restart moved the deadline from %v to %v", first, PreviousSecretUntil)
+	}
+
+	// A different previous secret starts a new window
+	PreviousJwtSecret = []byte("older-secret")
+	setPreviousSecretWindow()
+	if !PreviousSecretUntil.After(first) {
+		t.Errorf("new previous secret kept the old deadline %v", PreviousSecretUntil)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/mediamtx"
+	"nvr-server/internal/models"
+)
+
+// LiveStatusCacheTTL is how long one MediaMTX path listing is shared between requests
+var LiveStatusCacheTTL = config.Duration("NVR_LIVE_STATUS_CACHE_TTL", 5*time.Second)
+
+// CameraLiveStatus is what the grid needs to show "live available": Ready means
+// MediaMTX has a working source to serve viewers from
+type CameraLiveStatus struct {
+	CameraID uint   `json:"camera_id"`
+	Path     string `json:"path"`
+	Ready    bool   `json:"ready"`
+	Readers  int    `json:"readers"`
+}
+
+var (
+	pathStatsMu    sync.Mutex
+	pathStatsCache map[string]mediamtx.PathStats
+	pathStatsAt    time.Time
+)
+
+// cachedPathStats returns MediaMTX runtime stats keyed by path name, refreshed
+// at most once per LiveStatusCacheTTL. On a failed refresh the error is returned
+// and the stale entry is left for the next caller to retry.
+func cachedPathStats() (map[string]mediamtx.PathStats, error) {
+	pathStatsMu.Lock()
+	defer pathStatsMu.Unlock()
+	if pathStatsCache != nil && time.Since(pathStatsAt) < LiveStatusCacheTTL {
+		return pathStatsCache, nil
+	}
+
+	paths, err := mediamtx.ListPaths()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]mediamtx.PathStats, len(paths))
+	for _, p := range paths {
+		byName[p.Name] = p
+	}
+	pathStatsCache, pathStatsAt = byName, time.Now()
+	return byName, nil
+}
+
+// getProcessStatus reports each owned camera's recording processes: whether the
+// continuous and event ffmpegs are running, since when, and the last segment write
+func getProcessStatus(c echo.Context) error {
+	var cameras []models.Camera
+	if err := database.DB.Select("id").Where("owner_id = ?", getUser(c).ID).Order("display_order asc").Find(&cameras).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+
+	results := make([]detector.CameraProcessStatus, 0, len(cameras))
+	for _, cam := range cameras {
+		results = append(results, Detector.ProcessStatus(cam.ID))
+	}
+	return c.JSON(http.StatusOK, results)
+}
+
+// getLiveStatus reports, per owned camera, whether MediaMTX has the source
+// ready and how many clients are currently reading it
+func getLiveStatus(c echo.Context) error {
+	var cameras []models.Camera
+	if err := database.DB.Select("id, path").Where("owner_id = ?", getUser(c).ID).Order("display_order asc").Find(&cameras).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+
+	stats, err := cachedPathStats()
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"detail": "MediaMTX unavailable"})
+	}
+
+	results := make([]CameraLiveStatus, 0, len(cameras))
+	for _, cam := range cameras {
+		p, ok := stats[cam.Path]
+		results = append(results, CameraLiveStatus{
+			CameraID: cam.ID,
+			Path:     cam.Path,
+			Ready:    ok && p.Ready,
+			Readers:  len(p.Readers),
+		})
+	}
+	return c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nvr-server/internal/detector"
+	"nvr-server/internal/mediamtx"
+)
+
+// resetPathStats drops the cached MediaMTX listing around a test
+func resetPathStats(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		pathStatsMu.Lock()
+		pathStatsCache, pathStatsAt = nil, time.Time{}
+		pathStatsMu.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestGetLiveStatus(t *testing.T) {
+	testDB(t)
+	resetPathStats(t)
+	user := createTestUser(t, "user@example.com", false)
+	live := createTestCamera(t, user, "live")
+	down := createTestCamera(t, user, "down")
+	fake := newFakeMediaMTX(t, live.Path, down.Path)
+	fake.ready[live.Path] = true
+
+	statuses := func() map[uint]CameraLiveStatus {
+		var list []CameraLiveStatus
+		rec := callHandler(getLiveStatus, http.MethodGet, "/api/cameras/live-status", "", user)
+		if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+		}
+		byID := make(map[uint]CameraLiveStatus)
+		for _, s := range list {
+			byID[s.CameraID] = s
+		}
+		return byID
+	}
+
+	got := statuses()
+	if !got[live.ID].Ready || got[down.ID].Ready || len(got) != 2 {
+		t.Errorf("statuses = %+v", got)
+	}
+
+	// Within the TTL the cached listing is reused
+	fake.mu.Lock()
+	fake.ready[down.Path] = true
+	fake.mu.Unlock()
+	if statuses()[down.ID].Ready {
+		t.Error("cache bypassed within its TTL")
+	}
+	prev := LiveStatusCacheTTL
+	LiveStatusCacheTTL = 0
+	t.Cleanup(func() { LiveStatusCacheTTL = prev })
+	if !statuses()[down.ID].Ready {
+		t.Error("camera still not ready after the cache expired")
+	}
+}
+
+func TestGetLiveStatusMediaMTXDown(t *testing.T) {
+	testDB(t)
+	resetPathStats(t)
+	user := createTestUser(t, "user@example.com", false)
+	createTestCamera(t, user, "front")
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	prev := mediamtx.APIBase
+	mediamtx.APIBase = srv.URL
+	t.Cleanup(func() { mediamtx.APIBase = prev })
+
+	if rec := callHandler(getLiveStatus, http.MethodGet, "/api/cameras/live-status", "", user); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want 503", rec.Code)
+	}
+}
+
+func TestGetProcessStatus(t *testing.T) {
+	testDB(t)
+	testRecordingRoots(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	createTestCamera(t, createTestUser(t, "other@example.com", false), "back")
+
+	rec := callHandler(getProcessStatus, http.MethodGet, "/api/cameras/process-status", "", user)
+	var out []detector.CameraProcessStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	if len(out) != 1 || out[0].CameraID != cam.ID || out[0].Continuous != nil {
+		t.Errorf("got %+v, want only the idle owned camera", out)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/database"
+)
+
+// loginContext builds a /token request with the given content type
+func loginContext(contentType, body string) (echo.Context, *httptest.ResponseRecorder) {
+	c, rec := handlerContext(http.MethodPost, "/token", body, nil)
+	c.Request().Header.Set(echo.HeaderContentType, contentType)
+	return c, rec
+}
+
+func TestLoginCredentials(t *testing.T) {
+	form := url.Values{"username": {"a@example.com"}, "password": {"pw"}}.Encode()
+	formEmail := url.Values{"email": {"b@example.com"}, "password": {"pw"}}.Encode()
+	cases := []struct {
+		contentType, body, user, pass string
+	}{
+		{echo.MIMEApplicationForm, form, "a@example.com", "pw"},
+		{echo.MIMEApplicationForm, formEmail, "b@example.com", "pw"},
+		{echo.MIMEApplicationJSON, `{"username":"c@example.com","password":"pw"}`, "c@example.com", "pw"},
+		{echo.MIMEApplicationJSONCharsetUTF8, `{"email":"d@example.com","password":"pw"}`, "d@example.com", "pw"},
+		{echo.MIMEApplicationJSON, `{"username":`, "", ""},
+	}
+	for _, tc := range cases {
+		c, _ := loginContext(tc.contentType, tc.body)
+		if user, pass := loginCredentials(c); user != tc.user || pass != tc.pass {
+			t.Errorf("%s %s: got %q/%q, want %q/%q", tc.contentType, tc.body, user, pass, tc.user, tc.pass)
+		}
+	}
+}
+
+func TestLoginRequiresBothFields(t *testing.T) {
+	c, rec := loginContext(echo.MIMEApplicationJSON, `{"username":"a@example.com"}`)
+	serve(login, c)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("missing password: status %d, want 400", rec.Code)
+	}
+}
+
+func TestLoginWithFormAndJSON(t *testing.T) {
+	testDB(t)
+	testSecrets(t)
+	hashed, _ := hashPassword("s3cret-pass")
+	user := createTestUser(t, "user@example.com", false)
+	database.DB.Model(user).Update("hashed_password", string(hashed))
+
+	bodies := map[string]string{
+		echo.MIMEApplicationForm: url.Values{"username": {"user@example.com"}, "password": {"s3cret-pass"}}.Encode(),
+		echo.MIMEApplicationJSON: `{"email":"user@example.com","password":"s3cret-pass"}`,
+	}
+	for contentType, body := range bodies {
+		c, rec := loginContext(contentType, body)
+		serve(login, c)
+		var resp LoginResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK || resp.AccessToken == "" {
+			t.Errorf("%s login: %d %s", contentType, rec.Code, rec.Body)
+		}
+
+		c, rec = loginContext(contentType, strings.Replace(body, "s3cret-pass", "wrong", 1))
+		serve(login, c)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s wrong password: status %d, want 401", contentType, rec.Code)
+		}
+	}
+}
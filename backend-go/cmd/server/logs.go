@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/detector"
+)
+
+const (
+	defaultLogTail = 200
+	maxLogTail     = 5000
+)
+
+type LogFileInfo struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// --- LOG HANDLERS ---
+
+func listLogs(c echo.Context) error {
+	return c.JSON(http.StatusOK, logFiles(detector.LogDir))
+}
+
+// logFiles lists the .log files directly in dir, by name
+func logFiles(dir string) []LogFileInfo {
+	results := make([]LogFileInfo, 0)
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return results
+	}
+
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".log") {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		results = append(results, LogFileInfo{
+			Name:     f.Name(),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+func tailLog(c echo.Context) error {
+	path, ok := resolveLogPath(c.Param("name"))
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid log name"})
+	}
+
+	lines := defaultLogTail
+	if t := c.QueryParam("tail"); t != "" {
+		n, err := strconv.Atoi(t)
+		if err != nil || n < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid tail value"})
+		}
+		lines = min(n, maxLogTail)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"detail": "Log not found"})
+	}
+	defer f.Close()
+
+	data, err := tailLines(f, lines)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "Could not read log"})
+	}
+	return c.Blob(http.StatusOK, "text/plain; charset=utf-8", data)
+}
+
+// resolveLogPath maps a bare log file name onto LogDir, refusing anything
+// that would escape it
+func resolveLogPath(name string) (string, bool) {
+	if name == "" || name != filepath.Base(name) || strings.Contains(name, "..") || !strings.HasSuffix(name, ".log") {
+		return "", false
+	}
+	path := filepath.Join(detector.LogDir, name)
+	if filepath.Dir(path) != filepath.Clean(detector.LogDir) {
+		return "", false
+	}
+	return path, true
+}
+
+// tailLines reads backwards from the end of the file until it has seen n lines
+func tailLines(f *os.File, n int) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	const chunkSize = 8192
+	offset := info.Size()
+	var buf []byte
+
+	for offset > 0 && bytes.Count(buf, []byte("\n")) <= n {
+		readSize := int64(chunkSize)
+		if offset < readSize {
+			readSize = offset
+		}
+		offset -= readSize
+
+		chunk := make([]byte, readSize)
+		if _, err := f.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		buf = append(chunk, buf...)
+	}
+
+	if len(buf) == 0 {
+		return buf, nil
+	}
+
+	// Ignore the trailing newline so it doesn't count as an empty last line
+	trimmed := bytes.TrimSuffix(buf, []byte("\n"))
+	lines := bytes.Split(trimmed, []byte("\n"))
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return append(bytes.Join(lines, []byte("\n")), '\n'), nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/detector"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogFilesListsOnlyLogs(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "cam_2.log"), "b")
+	writeFile(t, filepath.Join(dir, "cam_1.log"), "abc")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "x")
+	writeFile(t, filepath.Join(dir, "old.log", "inner.log"), "x")
+
+	files := logFiles(dir)
+	if len(files) != 2 {
+		t.Fatalf("got %+v, want cam_1.log and cam_2.log", files)
+	}
+	if files[0].Name != "cam_1.log" || files[0].Size != 3 || files[1].Name != "cam_2.log" {
+		t.Errorf("files = %+v", files)
+	}
+
+	if got := logFiles(filepath.Join(dir, "missing")); got == nil || len(got) != 0 {
+		t.Errorf("missing dir = %#v, want an empty list", got)
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 3000; i++ {
+		b.WriteString("line ")
+		b.WriteString(strings.Repeat("x", i%7))
+		b.WriteString("\n")
+	}
+	b.WriteString("last\n")
+	path := filepath.Join(t.TempDir(), "cam.log")
+	writeFile(t, path, b.String())
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	data, err := tailLines(f, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "line xx\nline xxx\nlast\n"; string(data) != want {
+		t.Errorf("tail 3 = %q, want %q", data, want)
+	}
+
+	data, _ = tailLines(f, 10000)
+	if string(data) != b.String() {
+		t.Errorf("tail past the start returned %d bytes, want the whole %d byte file", len(data), b.Len())
+	}
+}
+
+func TestTailLogRejectsTraversal(t *testing.T) {
+	e := echo.New()
+	for _, name := range []string{
+		"../../etc/passwd",
+		"../secrets.log",
+		"..%2F..%2Fetc.log",
+		"nested/cam.log",
+		"/etc/cam.log",
+		"cam.txt",
+		"..log",
+		"",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/system/logs/x", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("name")
+		c.SetParamValues(name)
+
+		if err := tailLog(c); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("name %q: status %d, want 400", name, rec.Code)
+		}
+	}
+}
+
+func TestResolveLogPathStaysInLogDir(t *testing.T) {
+	path, ok := resolveLogPath("cam_1.log")
+	if !ok || filepath.Dir(path) != filepath.Clean(detector.LogDir) {
+		t.Errorf("resolveLogPath(cam_1.log) = %q, %v", path, ok)
+	}
+}
@@ -1,9 +1,9 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,9 +15,6 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -25,9 +22,13 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"nvr-server/internal/config"
 	"nvr-server/internal/database"
 	"nvr-server/internal/detector"
+	"nvr-server/internal/mediamtx"
 	"nvr-server/internal/models"
+	"nvr-server/internal/secrets"
+	"nvr-server/internal/slug"
 )
 
 // --- CONFIGURATION ---
@@ -39,6 +40,15 @@ const (
 var (
 	Detector  *detector.Manager
 	JwtSecret []byte
+
+	// Key in use before the last rotation; verification still accepts it until
+	// PreviousSecretUntil so a rotation doesn't log everyone out at once
+	PreviousJwtSecret   []byte
+	PreviousSecretUntil time.Time
+
+	// How long after the rotation was first seen tokens signed with the previous
+	// key stay valid (NVR_JWT_PREVIOUS_UNTIL sets the end explicitly instead)
+	PreviousSecretGrace = config.Duration("NVR_JWT_PREVIOUS_GRACE", 24*time.Hour)
 )
 
 // --- STRUCTS ---
@@ -57,6 +67,9 @@ type UserUpdateRequest struct {
 	DisplayName string `json:"display_name"`
 }
 
+// minPasswordLength applies to password changes
+const minPasswordLength = 8
+
 type ChangePasswordRequest struct {
 	CurrentPassword string `json:"current_password"`
 	NewPassword     string `json:"new_password"`
@@ -67,7 +80,27 @@ type BatchDeleteRequest struct {
 }
 
 type SystemSettingsRequest struct {
-	RetentionDays int `json:"retention_days"`
+	RetentionDays     *int  `json:"retention_days"`
+	AllowRegistration *bool `json:"allow_registration"`
+	MaxCamerasPerUser *int  `json:"max_cameras_per_user"`
+	MinEventSeconds   *int  `json:"min_event_seconds"`
+	MaintenanceMode   *bool `json:"maintenance_mode"`
+
+	NotifyWebhookURL       *string `json:"notify_webhook_url"`
+	PublicBaseURL          *string `json:"public_base_url"`
+	CameraDefaults         *string `json:"camera_defaults"`
+	StorageWarnThresholdGB *int    `json:"storage_warn_threshold_gb"`
+	EventPartMinutes       *int    `json:"event_part_minutes"`
+	MaxEventMinutes        *int    `json:"max_event_minutes"`
+	MinEventsKept          *int    `json:"min_events_kept"`
+	EventGroupGapSeconds   *int    `json:"event_group_gap_seconds"`
+	MinFreeRecordingGB     *int    `json:"min_free_recording_gb"`
+	MaxSessionsPerUser     *int    `json:"max_sessions_per_user"`
+	RetentionRules         *string `json:"retention_rules"`
+
+	MaxConcurrentEventRecordings *int    `json:"max_concurrent_event_recordings"`
+	EventCapacityPolicy          *string `json:"event_capacity_policy"`
+	AnimatedPreviews             *bool   `json:"animated_previews"`
 }
 
 // --- JWT CLAIMS ---
@@ -84,50 +117,75 @@ func main() {
 	// 2. Initialize Database
 	database.InitDB()
 	ensureDefaultSettings()
+	setPreviousSecretWindow()
+	ensureAdminUser()
+	backfillWebhookTokens()
+	if InternalSecret == "" {
+		log.Println("WARNING: No internal API secret set; the AI worker gets no webhook tokens and its events are rejected")
+	}
 
 	// 3. Initialize Detector
 	Detector = detector.NewManager()
 	Detector.Start()
+	go reconcileTestPaths()
 
 	// 4. Setup Server
 	e := echo.New()
+	e.HTTPErrorHandler = jsonErrorHandler
 	
 	// --- LOGGING CONFIGURATION ---
 	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
 		Skipper: func(c echo.Context) bool {
-			return strings.HasPrefix(c.Request().URL.Path, "/api/system/health")
+			path := c.Request().URL.Path
+			return path == "/healthz" || path == "/readyz" || path == "/api/internal/ai/heartbeat" || strings.HasPrefix(path, "/api/system/health")
 		},
 		Format:           "${time_custom} | ${status} | ${method}\t${uri}\t(${latency_human})\n",
 		CustomTimeFormat: "15:04:05",
 	}))
 
 	e.Use(middleware.Recover())
-	e.Use(middleware.CORS())
+	e.Use(middleware.CORSWithConfig(corsConfig()))
+	e.Use(csrfMiddleware())
+	e.Use(middleware.GzipWithConfig(middleware.GzipConfig{Skipper: skipCompression}))
+	e.Use(requestTimeout)
 
-	// 5. Static Files
-	e.Static("/recordings", "/recordings")
+	// 5. Recordings (authenticated or pre-signed, never public)
+	e.GET("/recordings/*", serveRecording)
 
 	// ===========================
 	//       PUBLIC ROUTES
 	// ===========================
 
+	// Probes for container orchestration (cheap, unauthenticated)
+	e.GET("/healthz", healthz)
+	e.GET("/readyz", readyz)
+
 	e.POST("/register", register)
 	e.POST("/token", login)
 	e.POST("/token/refresh", refresh)
+	e.POST("/token/logout", logout)
 	
 	// Webhooks (Motion -> API)
-	e.POST("/api/webhook/motion/start/:id", webhookStart)
-	e.POST("/api/webhook/motion/end/:id", webhookEnd)
+	e.POST("/api/webhook/motion/start/:id", webhookStart, webhookAuth)
+	e.POST("/api/webhook/motion/end/:id", webhookEnd, webhookAuth)
 	
+	// Pre-signed media links (signature replaces the bearer token)
+	e.GET("/api/media", serveSignedMedia)
+
+	// One-time event archive downloads (token in the link replaces the bearer token)
+	e.GET("/api/exports/:id/download", downloadArchive)
+
 	// Internal (AI -> API)
-	e.GET("/api/internal/cameras", getAllCameras)
+	e.GET("/api/internal/cameras", getAllCameras, internalAuth)
+	e.POST("/api/internal/ai/heartbeat", aiHeartbeat, internalAuth)
 
 	// ===========================
 	//      PROTECTED ROUTES
 	// ===========================
 	
-	authGroup := e.Group("")
-	authGroup.Use(jwtMiddleware)
+	// Middleware is attached per route rather than via Group.Use, whose catch-all
+	// route would answer unknown paths with 401 and hide 405 Method Not Allowed
+	authGroup := routeGroup{e: e, middleware: []echo.MiddlewareFunc{jwtMiddleware, maintenanceMiddleware}}
 
 	// User Routes
 	authGroup.GET("/users/me", getMe)
@@ -135,11 +193,18 @@ func main() {
 	authGroup.POST("/api/users/change-password", changePassword)
 	authGroup.DELETE("/api/users/delete-account", deleteAccount)
 	authGroup.POST("/api/users/logout-all", logoutAll)
+	authGroup.GET("/api/users/me/storage", getMyStorage)
+	authGroup.PUT("/api/users/:id/quota", setUserQuota, adminMiddleware)
 	
 	// Session Routes
 	authGroup.GET("/api/sessions", getSessions)
 	authGroup.DELETE("/api/sessions/:id", deleteSession)
 
+	// API Tokens (session auth only)
+	authGroup.GET("/api/tokens", listApiTokens, sessionOnlyMiddleware)
+	authGroup.POST("/api/tokens", createApiToken, sessionOnlyMiddleware)
+	authGroup.DELETE("/api/tokens/:id", revokeApiToken, sessionOnlyMiddleware)
+
 	// WebRTC Creds
 	authGroup.GET("/api/webrtc-creds", getWebRTCCreds)
 
@@ -150,24 +215,58 @@ func main() {
 	authGroup.DELETE("/api/cameras/:id", deleteCamera)
 	authGroup.POST("/api/cameras/reorder", reorderCameras)
 	authGroup.POST("/api/cameras/test-connection", testConnection)
+	authGroup.POST("/api/cameras/validate-roi", validateROI)
+	authGroup.GET("/api/cameras/live-status", getLiveStatus)
+	authGroup.GET("/api/cameras/process-status", getProcessStatus)
 	authGroup.DELETE("/api/cameras/:id/recordings", wipeCameraRecordings)
+	authGroup.POST("/api/cameras/:id/test-record", testRecord)
+	authGroup.GET("/api/cameras/:id/mask.png", getMaskPreview)
+	authGroup.GET("/api/cameras/:id/bandwidth", getCameraBandwidth)
+	authGroup.GET("/api/cameras/:id/recording-stats", getRecordingStats)
+	authGroup.GET("/api/cameras/:id/latest.jpg", getLatestFrame)
+	authGroup.GET("/api/cameras/:id/history", getCameraHistory)
+	authGroup.POST("/api/cameras/:id/webhook-token", rotateWebhookToken)
+	authGroup.POST("/api/cameras/:id/reregister", reregisterCamera)
+	authGroup.POST("/api/cameras/:id/events/export", exportCameraEvents)
+	authGroup.GET("/api/exports/:id", getArchiveJob)
 
 	// Events
 	authGroup.GET("/api/events", getEvents)
+	authGroup.GET("/api/recordings", getRecordingsTimeline)
 	authGroup.GET("/api/events/summary", getEventSummary)
+	authGroup.GET("/api/events/export.csv", exportEventsCSV)
+	authGroup.GET("/api/events/reasons", getEventReasons)
+	authGroup.GET("/api/events/today", getEventsToday)
+	authGroup.GET("/api/events/:id", getEvent)
+	authGroup.GET("/api/events/:id/preview", getEventPreview)
 	authGroup.DELETE("/api/events/:id", deleteEvent)
 	authGroup.POST("/api/events/batch-delete", batchDeleteEvents)
 
 	// Recordings & System
 	authGroup.GET("/api/cameras/:id/recordings", getContinuousRecordings)
 	authGroup.GET("/api/cameras/:id/recordings/timeline", getContinuousTimeline)
+	authGroup.GET("/api/cameras/:id/coverage", getContinuousCoverage)
+	authGroup.POST("/api/cameras/:id/onvif/capabilities", probeOnvifCapabilities)
+	authGroup.POST("/api/cameras/:id/recordings/import", importContinuous)
 	authGroup.DELETE("/api/cameras/:id/recordings/:filename", deleteContinuousFile)
 	
 	authGroup.GET("/api/system/health", getSystemHealth)
+	authGroup.GET("/api/system/services", getServices)
+	authGroup.GET("/api/dashboard", getDashboard)
 	authGroup.GET("/api/system/settings", getSystemSettings)
-	authGroup.PUT("/api/system/settings", updateSystemSettings)
-	authGroup.POST("/api/system/restart", restartSystem)
-	authGroup.DELETE("/api/system/recordings", wipeAllRecordings)
+	authGroup.PUT("/api/system/settings", updateSystemSettings, adminMiddleware)
+	authGroup.POST("/api/system/restart", restartSystem, adminMiddleware)
+	authGroup.DELETE("/api/system/recordings", wipeAllRecordings, adminMiddleware)
+
+	// Logs (Admin)
+	authGroup.GET("/api/system/logs", listLogs, adminMiddleware)
+	authGroup.GET("/api/system/logs/:name", tailLog, adminMiddleware)
+	authGroup.POST("/api/system/verify-recordings", verifyRecordings, adminMiddleware)
+
+	// Janitor (Admin)
+	authGroup.GET("/api/system/janitor", getJanitorStatus, adminMiddleware)
+	authGroup.POST("/api/system/janitor/pause", pauseJanitor, adminMiddleware)
+	authGroup.POST("/api/system/janitor/resume", resumeJanitor, adminMiddleware)
 	
 	authGroup.GET("/api/download", downloadFile)
 
@@ -184,14 +283,131 @@ func main() {
 	
 	ctxData, cancelData := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancelData()
+
+	cleanupPendingTestPaths()
 	
 	if err := e.Shutdown(ctxData); err != nil {
 		e.Logger.Fatal(err)
 	}
+
+	// HTTP handlers are drained, so nothing new can reach the detector
+	if err := Detector.Stop(ctxData); err != nil {
+		log.Printf("Detector did not stop cleanly: %v\n", err)
+	}
 }
 
 // --- HELPERS ---
 
+// Responses that are already compressed media (or that stream it)
+var mediaPathPrefixes = []string{"/recordings/", "/api/media", "/api/download"}
+var mediaPathSuffixes = []string{".jpg", ".png", "/preview", "/test-record", "/download"}
+
+// skipCompression keeps gzip away from video and images: they don't shrink, and
+// compressing would break Range requests used for seeking
+func skipCompression(c echo.Context) bool {
+	if c.Request().Header.Get("Range") != "" {
+		return true
+	}
+	path := c.Request().URL.Path
+	for _, p := range mediaPathPrefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	for _, s := range mediaPathSuffixes {
+		if strings.HasSuffix(path, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type CameraUpdateResponse struct {
+	models.Camera
+	DuplicateOf *models.Camera `json:"duplicate_of,omitempty"`
+}
+
+// ifMatchVersion reads a camera version from If-Match, accepting 3, "3" or W/"3"
+func ifMatchVersion(c echo.Context) (int, bool) {
+	raw := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if raw == "" {
+		return 0, false
+	}
+	v, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(raw, "W/"), `"`))
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// validateStreamSecrets rejects ${secret:name} placeholders that don't resolve, so a
+// typo shows up on save rather than as a camera that silently never connects
+func validateStreamSecrets(cam *models.Camera) error {
+	for _, u := range []string{cam.RTSPUrl, cam.RTSPSubstreamUrl} {
+		if err := secrets.Validate(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// routeGroup registers routes with a shared middleware chain
+type routeGroup struct {
+	e          *echo.Echo
+	middleware []echo.MiddlewareFunc
+}
+
+func (g routeGroup) add(method, path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	chain := append(append([]echo.MiddlewareFunc{}, g.middleware...), m...)
+	return g.e.Add(method, path, h, chain...)
+}
+
+func (g routeGroup) GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.add(http.MethodGet, path, h, m...)
+}
+
+func (g routeGroup) POST(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.add(http.MethodPost, path, h, m...)
+}
+
+func (g routeGroup) PUT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.add(http.MethodPut, path, h, m...)
+}
+
+func (g routeGroup) PATCH(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.add(http.MethodPatch, path, h, m...)
+}
+
+func (g routeGroup) DELETE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.add(http.MethodDelete, path, h, m...)
+}
+
+// jsonErrorHandler renders every error, including unknown routes (404) and wrong
+// methods (405, with Echo's Allow header), as {"detail": ...} like the handlers do
+func jsonErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	code := http.StatusInternalServerError
+	message := http.StatusText(code)
+	if he, ok := err.(*echo.HTTPError); ok {
+		if internal, ok := he.Internal.(*echo.HTTPError); ok {
+			he = internal
+		}
+		code = he.Code
+		message = fmt.Sprint(he.Message)
+	} else {
+		log.Printf("Unhandled error on %s %s: %v\n", c.Request().Method, c.Request().URL.Path, err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		c.NoContent(code)
+		return
+	}
+	c.JSON(code, map[string]string{"detail": message})
+}
+
 func loadSecrets() {
 	content, err := os.ReadFile("/run/secrets/jwt_secret_key")
 	if err == nil {
@@ -199,28 +415,72 @@ func loadSecrets() {
 	} else {
 		JwtSecret = []byte("supersecretfallbackkey")
 	}
+
+	if prev, err := os.ReadFile("/run/secrets/jwt_secret_key_previous"); err == nil {
+		if p := strings.TrimSpace(string(prev)); p != "" && p != string(JwtSecret) {
+			PreviousJwtSecret = []byte(p)
+		}
+	}
+	loadMediaSigningKey()
+}
+
+// jwtKeyFunc verifies with the current secret and, during the rotation grace
+// window, the previous one
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+	if PreviousJwtSecret == nil || time.Now().After(PreviousSecretUntil) {
+		return JwtSecret, nil
+	}
+	return jwt.VerificationKeySet{Keys: []jwt.VerificationKey{JwtSecret, PreviousJwtSecret}}, nil
 }
 
 func ensureDefaultSettings() {
 	var s models.SystemSettings
 	if err := database.DB.First(&s).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			database.DB.Create(&models.SystemSettings{RetentionDays: 30})
+			database.DB.Create(&models.SystemSettings{RetentionDays: 30, AllowRegistration: true})
 		}
 	}
+	// Rows from before allow_registration was stored explicitly
+	database.DB.Model(&models.SystemSettings{}).Where("allow_registration IS NULL").Update("allow_registration", true)
+}
+
+// loadSettings returns the stored system settings, or defaults if the row is missing
+func loadSettings() models.SystemSettings {
+	settings := models.SystemSettings{RetentionDays: 30, AllowRegistration: true, MaxCamerasPerUser: 32, MaxSessionsPerUser: 20, MinEventSeconds: 3, StorageWarnThresholdGB: 50, MaxEventMinutes: 30, EventGroupGapSeconds: 60, MinFreeRecordingGB: 2}
+	database.DB.First(&settings)
+	return settings
+}
+
+// ensureAdminUser promotes the oldest account on installs that predate admin roles
+func ensureAdminUser() {
+	var count int64
+	database.DB.Model(&models.User{}).Where("is_admin = ?", true).Count(&count)
+	if count > 0 {
+		return
+	}
+	var first models.User
+	if err := database.DB.Order("id asc").First(&first).Error; err == nil {
+		database.DB.Model(&first).Update("is_admin", true)
+		log.Printf("Promoted %s to admin\n", first.Email)
+	}
 }
 
 func jwtMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		authHeader := c.Request().Header.Get("Authorization")
+		authHeader := requestToken(c, accessCookie)
 		if authHeader == "" {
 			return echo.NewHTTPError(http.StatusUnauthorized, "Missing token")
 		}
+
+		if strings.HasPrefix(authHeader, "Token ") {
+			return apiTokenAuth(c, strings.TrimPrefix(authHeader, "Token "), next)
+		}
 		
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.ParseWithClaims(tokenString, &JwtCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return JwtSecret, nil
-		})
+		token, err := jwt.ParseWithClaims(tokenString, &JwtCustomClaims{}, jwtKeyFunc)
 
 		if err != nil || !token.Valid {
 			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token")
@@ -242,39 +502,149 @@ func jwtMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
+func adminMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !getUser(c).IsAdmin {
+			return c.JSON(http.StatusForbidden, map[string]string{"detail": "Admin privileges required"})
+		}
+		return next(c)
+	}
+}
+
+// maintenanceMiddleware rejects writes from non-admins while maintenance mode is on
+func maintenanceMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		switch c.Request().Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return next(c)
+		}
+		if getUser(c).IsAdmin || !loadSettings().MaintenanceMode {
+			return next(c)
+		}
+		return maintenanceResponse(c)
+	}
+}
+
+func maintenanceResponse(c echo.Context) error {
+	return c.JSON(http.StatusServiceUnavailable, map[string]string{"detail": "System is in maintenance mode"})
+}
+
 func getUser(c echo.Context) *models.User {
 	return c.Get("user").(*models.User)
 }
 
+// --- OWNERSHIP ---
+// Scoped lookups never reveal whether another user's resource exists:
+// missing and not-owned both produce the same 404.
+
+func notFound(c echo.Context, what string) error {
+	return c.JSON(http.StatusNotFound, map[string]string{"detail": what + " not found"})
+}
+
+// findOwnedCamera loads the camera named by the :id param if the caller owns it
+func findOwnedCamera(c echo.Context) (*models.Camera, error) {
+	var cam models.Camera
+	if err := database.DB.Where("id = ? AND owner_id = ?", c.Param("id"), getUser(c).ID).First(&cam).Error; err != nil {
+		return nil, err
+	}
+	return &cam, nil
+}
+
+// findOwnedEvent loads the event named by the :id param if the caller owns it
+func findOwnedEvent(c echo.Context) (*models.Event, error) {
+	var event models.Event
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), getUser(c).ID).First(&event).Error; err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
 // --- AUTH HANDLERS ---
 
+// registerLockKey is the Postgres advisory lock that serializes sign-ups ("NVR")
+const registerLockKey = 0x4e5652
+
+var errRegistrationDisabled = errors.New("registration is disabled")
+
 func register(c echo.Context) error {
 	req := new(RegisterRequest)
 	if err := c.Bind(req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid request"})
 	}
 
-	var count int64
-	database.DB.Model(&models.User{}).Where("email = ?", req.Email).Count(&count)
-	if count > 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Email already registered"})
+	if loadSettings().MaintenanceMode {
+		return maintenanceResponse(c)
 	}
 
-	hashed, _ := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashed, _ := hashPassword(req.Password)
 	
 	user := models.User{
 		Email:          req.Email,
 		HashedPassword: string(hashed),
 		TokensValidFrom: time.Now(),
 	}
-	database.DB.Create(&user)
+	// The first account on a fresh install administers the system and is always
+	// allowed. Sign-ups are serialized so two concurrent ones can't both see an
+	// empty table and both become admin.
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", registerLockKey).Error; err != nil {
+			return err
+		}
+		var userCount int64
+		if err := tx.Model(&models.User{}).Count(&userCount).Error; err != nil {
+			return err
+		}
+		if userCount > 0 && !loadSettings().AllowRegistration {
+			return errRegistrationDisabled
+		}
+		user.IsAdmin = userCount == 0
+		return tx.Create(&user).Error
+	})
+	if err != nil {
+		// The unique index on email decides concurrent sign-ups, not a prior lookup
+		switch {
+		case errors.Is(err, errRegistrationDisabled):
+			return c.JSON(http.StatusForbidden, map[string]string{"detail": "Registration is disabled"})
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			return c.JSON(http.StatusConflict, map[string]string{"detail": "Email already registered"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
 	
 	return c.JSON(http.StatusOK, user)
 }
 
-func login(c echo.Context) error {
+type LoginRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// loginCredentials accepts the OAuth2 password form as well as a JSON body
+func loginCredentials(c echo.Context) (string, string) {
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		req := new(LoginRequest)
+		if err := json.NewDecoder(c.Request().Body).Decode(req); err != nil {
+			return "", ""
+		}
+		if req.Username == "" {
+			req.Username = req.Email
+		}
+		return req.Username, req.Password
+	}
+
 	username := c.FormValue("username")
-	password := c.FormValue("password")
+	if username == "" {
+		username = c.FormValue("email")
+	}
+	return username, c.FormValue("password")
+}
+
+func login(c echo.Context) error {
+	username, password := loginCredentials(c)
+	if username == "" || password == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Username and password are required"})
+	}
 
 	var user models.User
 	if err := database.DB.Where("email = ?", username).First(&user).Error; err != nil {
@@ -284,17 +654,16 @@ func login(c echo.Context) error {
 	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password)); err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"detail": "Invalid credentials"})
 	}
+	rehashIfWeak(&user, password)
 
 	return generateTokens(c, &user)
 }
 
 func refresh(c echo.Context) error {
-	authHeader := c.Request().Header.Get("Authorization")
+	authHeader := requestToken(c, refreshCookie)
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 	
-	token, err := jwt.ParseWithClaims(tokenString, &JwtCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return JwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JwtCustomClaims{}, jwtKeyFunc)
 
 	if err != nil || !token.Valid {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"detail": "Invalid refresh token"})
@@ -314,6 +683,8 @@ func refresh(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"detail": "Token revoked"})
 	}
 
+	database.DB.Model(&models.UserSession{}).Where("jti = ?", claims.ID).Update("last_used_at", time.Now())
+
 	return generateTokens(c, &user)
 }
 
@@ -350,9 +721,12 @@ func generateTokens(c echo.Context, user *models.User) error {
 		UserAgent: c.Request().UserAgent(),
 		IPAddress: c.RealIP(),
 		CreatedAt: now,
+		LastUsedAt: now,
 		ExpiresAt: now.Add(RefreshTokenDuration),
 	}
+	evictExcessSessions(user.ID, loadSettings().MaxSessionsPerUser)
 	database.DB.Create(&session)
+	setAuthCookies(c, accStr, refStr, now)
 
 	return c.JSON(http.StatusOK, LoginResponse{
 		AccessToken:  accStr,
@@ -361,6 +735,25 @@ func generateTokens(c echo.Context, user *models.User) error {
 	})
 }
 
+// evictExcessSessions makes room for one more session under the per-user cap by
+// dropping expired sessions, then the least recently used ones
+func evictExcessSessions(userID uint, limit int) {
+	if limit <= 0 {
+		return
+	}
+	database.DB.Where("user_id = ? AND expires_at < ?", userID, time.Now()).Delete(&models.UserSession{})
+
+	var ids []uint
+	database.DB.Model(&models.UserSession{}).
+		Where("user_id = ?", userID).
+		Order("last_used_at desc").Order("created_at desc").
+		Offset(limit - 1).
+		Pluck("id", &ids)
+	if len(ids) > 0 {
+		database.DB.Delete(&models.UserSession{}, ids)
+	}
+}
+
 func getMe(c echo.Context) error {
 	return c.JSON(http.StatusOK, getUser(c))
 }
@@ -376,6 +769,31 @@ func updateMe(c echo.Context) error {
 	return c.JSON(http.StatusOK, user)
 }
 
+func getMyStorage(c echo.Context) error {
+	user := getUser(c)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"used_bytes":     detector.UserStorageBytes(user.ID),
+		"max_storage_mb": user.MaxStorageMB,
+	})
+}
+
+func setUserQuota(c echo.Context) error {
+	type QuotaReq struct {
+		MaxStorageMB int `json:"max_storage_mb"`
+	}
+	req := new(QuotaReq)
+	if err := c.Bind(req); err != nil || req.MaxStorageMB < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "max_storage_mb must be 0 (unlimited) or more"})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, c.Param("id")).Error; err != nil {
+		return notFound(c, "User")
+	}
+	database.DB.Model(&user).Update("max_storage_mb", req.MaxStorageMB)
+	return c.JSON(http.StatusOK, user)
+}
+
 func changePassword(c echo.Context) error {
 	user := getUser(c)
 	req := new(ChangePasswordRequest)
@@ -384,8 +802,15 @@ func changePassword(c echo.Context) error {
 	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(req.CurrentPassword)); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Incorrect password"})
 	}
+	if len(req.NewPassword) < minPasswordLength {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": fmt.Sprintf("New password must be at least %d characters", minPasswordLength)})
+	}
+	// CurrentPassword was just verified, so comparing plaintexts is equivalent to checking the hash
+	if req.NewPassword == req.CurrentPassword {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "New password must be different from the current password"})
+	}
 
-	hash, _ := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	hash, _ := hashPassword(req.NewPassword)
 	user.HashedPassword = string(hash)
 	user.TokensValidFrom = time.Now() 
 	database.DB.Save(user)
@@ -402,14 +827,32 @@ func logoutAll(c echo.Context) error {
 }
 
 func getSessions(c echo.Context) error {
-	var sessions []models.UserSession
-	database.DB.Where("user_id = ?", getUser(c).ID).Find(&sessions)
-	return c.JSON(http.StatusOK, sessions)
+	tx := database.DB.Model(&models.UserSession{}).Where("user_id = ?", getUser(c).ID)
+	if c.QueryParam("active") == "true" {
+		tx = tx.Where("expires_at > ?", time.Now())
+	}
+	tx = tx.Session(&gorm.Session{})
+	ordered := tx.Order("last_used_at desc").Order("created_at desc")
+
+	sessions := make([]models.UserSession, 0)
+	page, paged := parsePagination(c)
+	if !paged {
+		ordered.Find(&sessions)
+		return c.JSON(http.StatusOK, sessions)
+	}
+
+	var total int64
+	tx.Count(&total)
+	page.apply(ordered).Find(&sessions)
+	return respondPage(c, sessions, total, page)
 }
 
 func deleteSession(c echo.Context) error {
 	id := c.Param("id")
-	database.DB.Delete(&models.UserSession{}, id)
+	res := database.DB.Where("id = ? AND user_id = ?", id, getUser(c).ID).Delete(&models.UserSession{})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return notFound(c, "Session")
+	}
 	return c.NoContent(http.StatusNoContent)
 }
 
@@ -426,60 +869,226 @@ func getWebRTCCreds(c echo.Context) error {
 // --- CAMERA HANDLERS ---
 
 func getCameras(c echo.Context) error {
+	if notModified(c, cameraFingerprint(getUser(c).ID)) {
+		return respondNotModified(c)
+	}
+
 	var cameras []models.Camera
 	database.DB.Where("owner_id = ?", getUser(c).ID).Order("display_order asc").Find(&cameras)
+	annotateSubstream(cameras)
 	return c.JSON(http.StatusOK, cameras)
 }
 
+func cameraFingerprint(ownerID uint) string {
+	return listFingerprint(database.DB.Model(&models.Camera{}).Where("owner_id = ?", ownerID), "cameras")
+}
+
 // --- Internal (No Auth) ---
 func getAllCameras(c echo.Context) error {
 	var cameras []models.Camera
 	if err := database.DB.Find(&cameras).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-	return c.JSON(http.StatusOK, cameras)
+	// Rows saved before validation existed may hold values the AI worker can't use
+	for i := range cameras {
+		if !normalizeMotionConfig(&cameras[i]) {
+			log.Printf("[%s] Unknown motion type %q, sending \"off\" to the AI worker\n", cameras[i].Name, cameras[i].MotionType)
+			cameras[i].MotionType = "off"
+		}
+	}
+	// Webhook tokens only go to a caller that proved it holds the internal secret
+	withTokens := internalCaller(c)
+	out := make([]InternalCamera, len(cameras))
+	for i, cam := range cameras {
+		out[i] = InternalCamera{Camera: cam}
+		if withTokens {
+			out[i].WebhookToken = workerWebhookToken(cam)
+		}
+	}
+	return c.JSON(http.StatusOK, out)
 }
 
 func createCamera(c echo.Context) error {
 	cam := new(models.Camera)
-	if err := c.Bind(cam); err != nil {
+	if err := bindNewCamera(c, cam); err != nil {
 		return err
 	}
-	cam.OwnerID = getUser(c).ID
+	user := getUser(c)
+	cam.OwnerID = user.ID
+
+	if errs := validateCamera(cam); len(errs) > 0 {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"detail": "Invalid camera", "errors": errs})
+	}
+
+	// Same stream twice means a second recorder and MediaMTX path for nothing
+	duplicate := findDuplicateStream(cam)
+	if duplicate != nil && c.QueryParam("reject_duplicates") == "true" {
+		return c.JSON(http.StatusConflict, map[string]interface{}{"detail": "Another camera already uses this stream", "duplicate_of": duplicate})
+	}
+
+	// Retried creates carrying the same key get the original camera back
+	var claim *models.IdempotencyKey
+	if key := strings.TrimSpace(c.Request().Header.Get("Idempotency-Key")); key != "" {
+		var existing *models.Camera
+		var err error
+		claim, existing, err = claimIdempotencyKey(c, user.ID, key)
+		if existing != nil {
+			return c.JSON(http.StatusOK, existing)
+		}
+		if claim == nil {
+			return err
+		}
+	}
+
+	if limit := loadSettings().MaxCamerasPerUser; limit > 0 && !user.IsAdmin {
+		var owned int64
+		database.DB.Model(&models.Camera{}).Where("owner_id = ?", user.ID).Count(&owned)
+		if owned >= int64(limit) {
+			releaseIdempotencyKey(claim)
+			return c.JSON(http.StatusForbidden, map[string]string{"detail": fmt.Sprintf("Camera limit reached (%d)", limit)})
+		}
+	}
 	
-	safeName := strings.ReplaceAll(strings.ToLower(cam.Name), " ", "_")
-	cam.Path = fmt.Sprintf("user_%d_%s", cam.OwnerID, safeName)
+	// Remember the device's MAC so AutoHealIP can find it again if its IP changes
+	if cam.DeviceMAC == "" {
+		cam.DeviceMAC = detector.LookupMAC(detector.StreamHost(cam.RTSPUrl))
+	}
+
+	cam.Path = uniqueCameraPath(fmt.Sprintf("user_%d_%s", cam.OwnerID, slug.Make(cam.Name)))
 	
 	var maxOrder int
 	row := database.DB.Model(&models.Camera{}).Select("MAX(display_order)").Row()
 	_ = row.Scan(&maxOrder) 
 	cam.DisplayOrder = maxOrder + 1
+
+	webhookToken, tokenHash, err := newWebhookToken()
+	if err != nil {
+		releaseIdempotencyKey(claim)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "Could not generate token"})
+	}
+	cam.WebhookTokenHash = tokenHash
 	
-	database.DB.Create(cam)
+	if err := database.DB.Create(cam).Error; err != nil {
+		releaseIdempotencyKey(claim)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+	completeIdempotencyKey(claim, cam.ID)
 	Detector.SyncCameras() 
+	cam.Warnings = detector.SubstreamWarnings(*cam)
 	
-	return c.JSON(http.StatusOK, cam)
+	return c.JSON(http.StatusOK, CameraWithWebhookToken{Camera: *cam, WebhookToken: webhookToken, DuplicateOf: duplicate})
+}
+
+// uniqueCameraPath appends _2, _3, ... when another camera already uses base,
+// e.g. two cameras whose names slugify the same way
+func uniqueCameraPath(base string) string {
+	path := base
+	for n := 2; ; n++ {
+		var count int64
+		database.DB.Model(&models.Camera{}).Where("path = ?", path).Count(&count)
+		if count == 0 {
+			return path
+		}
+		path = fmt.Sprintf("%s_%d", base, n)
+	}
 }
 
 func updateCamera(c echo.Context) error {
-	id := c.Param("id")
-	var cam models.Camera
-	if err := database.DB.First(&cam, id).Error; err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"detail": "Camera not found"})
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
 	}
 	
-	c.Bind(&cam)
-	database.DB.Save(&cam)
+	before := *cam
+	id, ownerID := cam.ID, cam.OwnerID
+	cam.Version = 0
+	c.Bind(cam)
+	cam.ID, cam.OwnerID = id, ownerID
+
+	// Optimistic concurrency: an edit that names the version it was based on (If-Match
+	// or body) must match the stored one. Clients that send no version get
+	// last-write-wins, as before versions existed.
+	expected := cam.Version
+	if v, ok := ifMatchVersion(c); ok {
+		expected = v
+	}
+	if expected != 0 && expected != before.Version {
+		return c.JSON(http.StatusConflict, map[string]interface{}{"detail": "Camera was modified elsewhere; reload and retry", "camera": before})
+	}
+	cam.Version = before.Version + 1
+
+	// Turning on 24/7 recording for a user already at their quota would only churn the janitor
+	if cam.ContinuousRecording && !before.ContinuousRecording {
+		if user := getUser(c); user.MaxStorageMB > 0 && detector.UserStorageBytes(user.ID) >= int64(user.MaxStorageMB)<<20 {
+			return c.JSON(http.StatusForbidden, map[string]string{"detail": fmt.Sprintf("Storage quota reached (%d MB)", user.MaxStorageMB)})
+		}
+	}
+
+	if !normalizeMotionConfig(cam) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "motion_type must be off, webhook or active"})
+	}
+	if roi := detector.ParseROI(cam.MotionROI); !roi.Valid() {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"detail": "Invalid motion ROI", "roi": roi})
+	}
+	if mask := detector.ParseROI(cam.PrivacyMask); !mask.Valid() {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"detail": "Invalid privacy mask", "privacy_mask": mask})
+	}
+	if !detector.ValidSegmentFormat(cam.SegmentFormat) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "segment_format must be mp4, fmp4 or mkv"})
+	}
+	if cam.RetentionDays < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "retention_days cannot be negative"})
+	}
+	if err := validateStreamSecrets(cam); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": err.Error()})
+	}
+	duplicate := findDuplicateStream(cam)
+	if duplicate != nil && c.QueryParam("reject_duplicates") == "true" {
+		return c.JSON(http.StatusConflict, map[string]interface{}{"detail": "Another camera already uses this stream", "duplicate_of": duplicate})
+	}
+	res := database.DB.Model(cam).Where("version = ?", before.Version).Select("*").Updates(cam)
+	if res.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+	if res.RowsAffected == 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"detail": "Camera was modified elsewhere; reload and retry"})
+	}
+	recordCameraChanges(&before, cam, getUser(c).ID)
 	Detector.SyncCameras()
 	
-	return c.JSON(http.StatusOK, cam)
+	cam.Warnings = detector.SubstreamWarnings(*cam)
+	c.Response().Header().Set("ETag", strconv.Quote(strconv.Itoa(cam.Version)))
+	return c.JSON(http.StatusOK, CameraUpdateResponse{Camera: *cam, DuplicateOf: duplicate})
+}
+
+type CameraDeleteResponse struct {
+	FilesRemoved int                  `json:"files_removed"`
+	FilesFailed  []detector.FileError `json:"files_failed"`
+	FilesKept    bool                 `json:"files_kept"`
 }
 
+// deleteCamera removes the camera, its events (FK cascade) and, unless
+// ?keep_files=true, its footage on disk. Files that can't be removed are
+// reported in the response; the camera is deleted regardless.
 func deleteCamera(c echo.Context) error {
-	id := c.Param("id")
-	database.DB.Delete(&models.Camera{}, id)
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+	if err := database.DB.Delete(cam).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+	database.DB.Where("camera_id = ?", cam.ID).Delete(&models.CameraChangeLog{})
+	Detector.StopCamera(cam.ID)
 	Detector.SyncCameras()
-	return c.NoContent(http.StatusNoContent)
+
+	resp := CameraDeleteResponse{FilesFailed: make([]detector.FileError, 0)}
+	if c.QueryParam("keep_files") == "true" {
+		resp.FilesKept = true
+	} else {
+		resp.FilesRemoved, resp.FilesFailed = detector.RemoveCameraFiles(cam.ID)
+	}
+	return c.JSON(http.StatusOK, resp)
 }
 
 func reorderCameras(c echo.Context) error {
@@ -490,11 +1099,118 @@ func reorderCameras(c echo.Context) error {
 	c.Bind(req)
 	
 	for i, id := range req.CameraIDs {
-		database.DB.Model(&models.Camera{}).Where("id = ?", id).Update("display_order", i)
+		database.DB.Model(&models.Camera{}).Where("id = ? AND owner_id = ?", id, getUser(c).ID).Update("display_order", i)
 	}
 	return c.JSON(http.StatusOK, map[string]string{"message": "Reordered"})
 }
 
+func validateROI(c echo.Context) error {
+	type ROIReq struct {
+		MotionROI string `json:"motion_roi"`
+	}
+	req := new(ROIReq)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid request"})
+	}
+
+	result := detector.ParseROI(req.MotionROI)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"valid":        result.Valid(),
+		"cells":        result.Cells,
+		"out_of_range": result.OutOfRange,
+		"malformed":    result.Malformed,
+	})
+}
+
+func getMaskPreview(c echo.Context) error {
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+
+	scale := 32
+	if s, err := strconv.Atoi(c.QueryParam("scale")); err == nil && s >= 1 && s <= 64 {
+		scale = s
+	}
+
+	data, err := detector.RenderMaskPNG(cam.MotionROI, scale)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "Could not render mask"})
+	}
+	return c.Blob(http.StatusOK, "image/png", data)
+}
+
+func getCameraBandwidth(c echo.Context) error {
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+
+	window := 24 * time.Hour
+	if r := c.QueryParam("range"); r != "" {
+		d, err := time.ParseDuration(r)
+		if err != nil || d <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid range (e.g. 1h, 24h)"})
+		}
+		window = min(d, detector.BandwidthRetention)
+	}
+
+	var samples []models.BandwidthSample
+	database.DB.Where("camera_id = ? AND time >= ?", cam.ID, time.Now().Add(-window)).Order("time asc").Find(&samples)
+	points, totalRx, totalTx := detector.BandwidthRates(samples)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"camera_id":            cam.ID,
+		"range_seconds":        int(window.Seconds()),
+		"points":               points,
+		"total_bytes_received": totalRx,
+		"total_bytes_sent":     totalTx,
+	})
+}
+
+func getRecordingStats(c echo.Context) error {
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+	return c.JSON(http.StatusOK, Detector.RecordingStats(cam.ID))
+}
+
+// reregisterCamera deletes and re-adds the camera's MediaMTX path to unstick a
+// wedged stream without editing the camera
+func reregisterCamera(c echo.Context) error {
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+	result, err := Detector.Reregister(c.Request().Context(), *cam)
+	if err != nil {
+		return c.JSON(http.StatusBadGateway, map[string]interface{}{"detail": "Re-register failed: " + err.Error(), "result": result})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// getLatestFrame serves the detector's cached still, capturing one on demand
+// only when nothing is cached yet
+func getLatestFrame(c echo.Context) error {
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+
+	frame, ok := Detector.LatestFrame(cam.ID)
+	if !ok {
+		frame, err = Detector.CaptureFrame(c.Request().Context(), *cam)
+		if err != nil {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{"detail": "Camera unreachable"})
+		}
+	}
+
+	c.Response().Header().Set(echo.HeaderLastModified, frame.CapturedAt.UTC().Format(http.TimeFormat))
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	return c.Blob(http.StatusOK, "image/jpeg", frame.JPEG)
+}
+
 func testConnection(c echo.Context) error {
 	type TestReq struct {
 		RTSPUrl string `json:"rtsp_url"`
@@ -504,58 +1220,57 @@ func testConnection(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
 
-	pathName := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	source, err := secrets.Resolve(req.RTSPUrl)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	pathName := fmt.Sprintf("%s%d", testPathPrefix, time.Now().UnixNano())
 	
 	payload := map[string]interface{}{
-		"source":         req.RTSPUrl,
-		"sourceOnDemand": true,
+		"source":         source,
+		"sourceOnDemand": false, // pull now so readiness can be checked before answering
 	}
-	jsonData, _ := json.Marshal(payload)
-	
-	url := fmt.Sprintf("http://mediamtx:9997/v3/config/paths/add/%s", pathName)
-	apiReq, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	apiReq.SetBasicAuth("admin", "mysecretpassword")
-	apiReq.Header.Set("Content-Type", "application/json")
 	
-	client := &http.Client{Timeout: 2 * time.Second}
-	resp, err := client.Do(apiReq)
+	status, err := mediamtx.AddPathContext(c.Request().Context(), pathName, payload)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "MediaMTX unreachable"})
 	}
-	defer resp.Body.Close()
 	
-	if resp.StatusCode >= 400 {
+	if status >= 400 {
 		 return c.JSON(http.StatusBadRequest, map[string]string{"error": "Could not connect to camera stream"})
 	}
 
-	go func(p string) {
-		time.Sleep(60 * time.Second)
-		delUrl := fmt.Sprintf("http://mediamtx:9997/v3/config/paths/delete/%s", p)
-		delReq, _ := http.NewRequest("DELETE", delUrl, nil)
-		delReq.SetBasicAuth("admin", "mysecretpassword")
-		client.Do(delReq)
-	}(pathName)
+	scheduleTestPathCleanup(pathName)
+
+	if !waitTestPathReady(c.Request().Context(), pathName) {
+		discardTestPath(pathName)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Could not connect to camera stream"})
+	}
 
 	return c.JSON(http.StatusOK, map[string]string{"path": pathName})
 }
 
 func wipeCameraRecordings(c echo.Context) error {
-	idParam := c.Param("id")
-	camID, _ := strconv.Atoi(idParam)
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+	if dateStr := c.QueryParam("date_str"); dateStr != "" {
+		return deleteContinuousDay(c, cam, dateStr)
+	}
+	camID := cam.ID
 	
 	database.DB.Where("camera_id = ?", camID).Delete(&models.Event{})
 	
-	files, err := os.ReadDir("/recordings")
-	if err == nil {
-		prefix := fmt.Sprintf("event_%d_", camID)
-		for _, f := range files {
-			if strings.HasPrefix(f.Name(), prefix) {
-				os.Remove(filepath.Join("/recordings", f.Name()))
-			}
+	prefix := fmt.Sprintf("event_%d_", camID)
+	detector.WalkEventFiles(func(path string, info os.FileInfo) {
+		if strings.HasPrefix(info.Name(), prefix) {
+			os.Remove(path)
 		}
-	}
+	})
 	
-	contPath := filepath.Join("/recordings", "continuous", idParam)
+	contPath := detector.ContinuousDir(camID)
 	os.RemoveAll(contPath)
 	os.MkdirAll(contPath, 0755)
 
@@ -564,76 +1279,164 @@ func wipeCameraRecordings(c echo.Context) error {
 
 // --- EVENT HANDLERS ---
 
-func getEvents(c echo.Context) error {
-	var events []models.Event
-	tx := database.DB.Where("user_id = ?", getUser(c).ID).Preload("Camera")
-	
+// applyEventFilters applies the camera, date-range, reason and class query params shared by the event listings
+func applyEventFilters(tx *gorm.DB, c echo.Context) *gorm.DB {
 	if cid := c.QueryParam("camera_id"); cid != "" {
-		tx = tx.Where("camera_id = ?", cid)
+		tx = tx.Where("events.camera_id = ?", cid)
 	}
-
-	// --- FIX: Add Date Filtering Logic Here ---
 	if start := c.QueryParam("start_ts"); start != "" {
-		tx = tx.Where("start_time >= ?", start)
+		tx = tx.Where("events.start_time >= ?", start)
 	}
 	if end := c.QueryParam("end_ts"); end != "" {
-		tx = tx.Where("start_time <= ?", end)
+		tx = tx.Where("events.start_time <= ?", end)
 	}
-	// -----------------------------------------
-	
-	tx.Order("start_time desc").Limit(100).Find(&events)
-	return c.JSON(http.StatusOK, events)
+	if reason := c.QueryParam("reason"); reason != "" {
+		tx = tx.Where("events.reason = ?", reason)
+	}
+	if f, ok := parseClassFilter(c); ok {
+		tx = f.apply(tx)
+	}
+	return tx
 }
 
-func getEventSummary(c echo.Context) error {
-	var events []models.Event
-	tx := database.DB.Select("id, start_time, end_time, camera_id").Where("user_id = ?", getUser(c).ID)
-	
-	if cid := c.QueryParam("camera_id"); cid != "" {
-		tx = tx.Where("camera_id = ?", cid)
+// parseClassList splits a comma-separated class list, dropping blanks
+func parseClassList(raw string) []string {
+	classes := make([]string, 0)
+	for _, part := range strings.Split(raw, ",") {
+		if p := strings.TrimSpace(part); p != "" {
+			classes = append(classes, p)
+		}
 	}
-	if start := c.QueryParam("start_ts"); start != "" {
-		tx = tx.Where("start_time >= ?", start)
+	return classes
+}
+
+func getEvents(c echo.Context) error {
+	if err := checkClassFilter(c); err != nil {
+		return err
 	}
-	if end := c.QueryParam("end_ts"); end != "" {
-		tx = tx.Where("start_time <= ?", end)
+	if c.QueryParam("grouped") == "true" {
+		return getEventGroups(c)
 	}
+	userID := getUser(c).ID
+	eventsFP := listFingerprint(applyEventFilters(database.DB.Model(&models.Event{}).Where("user_id = ?", userID), c), "events")
+	// Events embed their camera, so camera edits must also invalidate the list
+	if notModified(c, eventsFP, cameraFingerprint(userID)) {
+		return respondNotModified(c)
+	}
+
+	events := make([]models.Event, 0)
+	tx := database.DB.Model(&models.Event{}).Where("user_id = ?", userID)
+	tx = applyEventFilters(tx, c).Session(&gorm.Session{})
+	ordered := tx.Preload("Camera").Order("start_time desc")
+
+	page, paged := parsePagination(c)
+	if !paged {
+		ordered.Limit(100).Find(&events)
+		return c.JSON(http.StatusOK, events)
+	}
+
+	var total int64
+	tx.Count(&total)
+	page.apply(ordered).Find(&events)
+	return respondPage(c, events, total, page)
+}
+
+type EventDetail struct {
+	models.Event
+	VideoURL     string    `json:"video_url,omitempty"`
+	PartURLs     []string  `json:"part_urls,omitempty"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	SnapshotURL  string    `json:"snapshot_url,omitempty"`
+	PreviewURL   string    `json:"preview_url,omitempty"`
+	URLsExpireAt time.Time `json:"urls_expire_at"`
+}
+
+func getEvent(c echo.Context) error {
+	event, err := findOwnedEvent(c)
+	if err != nil {
+		return notFound(c, "Event")
+	}
+	database.DB.First(&event.Camera, event.CameraID)
+
+	expiresAt := time.Now().Add(SignedURLTTL)
+	detail := EventDetail{Event: *event, URLsExpireAt: expiresAt}
+	if event.VideoPath != "" {
+		detail.VideoURL = signMediaURL(event.VideoPath, expiresAt)
+	}
+	var parts []string
+	if json.Unmarshal([]byte(event.Parts), &parts) == nil {
+		for _, part := range parts {
+			detail.PartURLs = append(detail.PartURLs, signMediaURL(part, expiresAt))
+		}
+	}
+	if event.ThumbnailPath != "" {
+		detail.ThumbnailURL = signMediaURL(event.ThumbnailPath, expiresAt)
+	}
+	if event.SnapshotPath != "" {
+		detail.SnapshotURL = signMediaURL(event.SnapshotPath, expiresAt)
+	}
+	if event.PreviewPath != "" {
+		detail.PreviewURL = signMediaURL(event.PreviewPath, expiresAt)
+	}
+	return c.JSON(http.StatusOK, detail)
+}
+
+func getEventPreview(c echo.Context) error {
+	event, err := findOwnedEvent(c)
+	if err != nil || event.PreviewPath == "" {
+		return notFound(c, "Preview")
+	}
+	return serveMediaFile(c, event.PreviewPath)
+}
+
+func getEventReasons(c echo.Context) error {
+	return c.JSON(http.StatusOK, models.EventReasons)
+}
+
+func getEventSummary(c echo.Context) error {
+	if err := checkClassFilter(c); err != nil {
+		return err
+	}
+	var events []models.Event
+	tx := database.DB.Select("id, start_time, end_time, camera_id").Where("user_id = ?", getUser(c).ID)
+	tx = applyEventFilters(tx, c)
 	
 	tx.Order("start_time asc").Find(&events)
 	return c.JSON(http.StatusOK, events)
 }
 
 func deleteEvent(c echo.Context) error {
-	id := c.Param("id")
-	var event models.Event
-	if err := database.DB.First(&event, id).Error; err == nil {
-		if event.VideoPath != "" {
-			os.Remove("/" + event.VideoPath)
-		}
-		if event.ThumbnailPath != "" {
-			os.Remove("/" + event.ThumbnailPath)
-		}
-		database.DB.Delete(&event)
+	event, err := findOwnedEvent(c)
+	if err != nil {
+		return notFound(c, "Event")
 	}
+	removeEventFiles(*event)
+	database.DB.Delete(event)
 	return c.NoContent(http.StatusNoContent)
 }
 
+// removeEventFiles deletes an event's clip (every part, if split), sidecar and thumbnail
+func removeEventFiles(event models.Event) {
+	for _, f := range detector.EventFiles(event) {
+		os.Remove(f)
+	}
+}
+
 func batchDeleteEvents(c echo.Context) error {
 	req := new(BatchDeleteRequest)
 	c.Bind(req)
 	
 	if len(req.EventIDs) > 0 {
 		var events []models.Event
-		database.DB.Where("id IN ?", req.EventIDs).Find(&events)
+		database.DB.Where("id IN ? AND user_id = ?", req.EventIDs, getUser(c).ID).Find(&events)
+		ids := make([]uint, 0, len(events))
 		for _, event := range events {
-			if event.VideoPath != "" {
-				os.Remove("/" + event.VideoPath)
-			}
-			if event.ThumbnailPath != "" {
-				os.Remove("/" + event.ThumbnailPath)
-			}
+			ids = append(ids, event.ID)
+			removeEventFiles(event)
+		}
+		if len(ids) > 0 {
+			database.DB.Delete(&models.Event{}, ids)
 		}
-		database.DB.Delete(&models.Event{}, req.EventIDs)
 	}
 	
 	return c.JSON(http.StatusOK, map[string]string{"message": "Batch deleted"})
@@ -642,8 +1445,12 @@ func batchDeleteEvents(c echo.Context) error {
 // --- RECORDING / SYSTEM HANDLERS ---
 
 func getContinuousRecordings(c echo.Context) error {
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
 	id := c.Param("id")
-	dateStr := c.QueryParam("date_str") // 2023-11-20
+	dateStr := c.QueryParam("date_str") // 2023-11-20 (UTC)
 	cleanDate := strings.ReplaceAll(dateStr, "-", "")
 	
 	type RecFile struct {
@@ -653,27 +1460,25 @@ func getContinuousRecordings(c echo.Context) error {
 	}
 	results := make([]RecFile, 0)
 	
-	dir := filepath.Join("/recordings", "continuous", id)
-	files, _ := os.ReadDir(dir)
-	for _, f := range files {
-		if strings.HasPrefix(f.Name(), cleanDate) && strings.HasSuffix(f.Name(), ".mp4") {
-			parts := strings.Split(f.Name(), "-")
-			if len(parts) > 1 {
-				timePart := strings.Split(parts[1], ".")[0]
-				results = append(results, RecFile{
-					Filename: f.Name(),
-					Url: fmt.Sprintf("continuous/%s/%s", id, f.Name()),
-					Time: timePart,
-				})
-			}
+	// Urls stay continuous/<id>/<name> in either layout; MediaPath finds the partition
+	for _, seg := range continuousSegments(cam.ID, dateStr) {
+		if strings.HasPrefix(seg.Name, cleanDate) {
+			results = append(results, RecFile{
+				Filename: seg.Name,
+				Url: fmt.Sprintf("continuous/%s/%s", id, seg.Name),
+				Time: seg.Start.Format("150405"),
+			})
 		}
 	}
 	return c.JSON(http.StatusOK, results)
 }
 
 func getContinuousTimeline(c echo.Context) error {
-	id := c.Param("id")
-	dateStr := c.QueryParam("date_str") // YYYY-MM-DD
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+	dateStr := c.QueryParam("date_str") // YYYY-MM-DD (UTC)
 	cleanDate := strings.ReplaceAll(dateStr, "-", "")
 
 	type RecordingSegment struct {
@@ -683,44 +1488,93 @@ func getContinuousTimeline(c echo.Context) error {
 	}
 	segments := make([]RecordingSegment, 0)
 
-	dir := filepath.Join("/recordings", "continuous", id)
-	files, err := os.ReadDir(dir)
-	if err != nil {
-		return c.JSON(http.StatusOK, segments)
-	}
-
-	for _, f := range files {
-		if !f.IsDir() && strings.HasPrefix(f.Name(), cleanDate) && strings.HasSuffix(f.Name(), ".mp4") {
-			nameWithoutExt := strings.TrimSuffix(f.Name(), ".mp4")
+	for _, seg := range continuousSegments(cam.ID, dateStr) {
+		if strings.HasPrefix(seg.Name, cleanDate) {
+			// Segment names are UTC, so date_str is a UTC date too
+			t := seg.Start
+			endTime := t.Add(15 * time.Minute)
 			
-			// --- FIX: Parse in LOCAL time (container TZ), not UTC ---
-			t, err := time.ParseInLocation("20060102-150405", nameWithoutExt, time.Local)
-			if err == nil {
-				endTime := t.Add(15 * time.Minute)
-				
-				segments = append(segments, RecordingSegment{
-					StartTime: t.Format(time.RFC3339), // Returns ISO string with correct offset
-					EndTime:   endTime.Format(time.RFC3339),
-					Filename:  f.Name(),
-				})
-			}
+			segments = append(segments, RecordingSegment{
+				StartTime: t.Format(time.RFC3339), // Returns ISO string with correct offset
+				EndTime:   endTime.Format(time.RFC3339),
+				Filename:  seg.Name,
+			})
 		}
 	}
 	
 	return c.JSON(http.StatusOK, segments)
 }
 
+// getContinuousCoverage reports recorded time and gaps in a camera's continuous
+// footage for date_str (YYYY-MM-DD, UTC; defaults to today)
+func getContinuousCoverage(c echo.Context) error {
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+
+	now := time.Now().UTC()
+	day := now
+	if dateStr := c.QueryParam("date_str"); dateStr != "" {
+		if day, err = time.Parse("2006-01-02", dateStr); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"detail": "date_str must be YYYY-MM-DD"})
+		}
+	}
+	return c.JSON(http.StatusOK, detector.ContinuousCoverage(cam.ID, day, now))
+}
+
 func deleteContinuousFile(c echo.Context) error {
-	id := c.Param("id")
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
 	file := c.Param("filename")
-	path := filepath.Join("/recordings", "continuous", id, file)
-	os.Remove(path)
+	if file != filepath.Base(file) || !detector.IsSegmentFile(file) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid filename"})
+	}
+	dir := detector.ContinuousDir(cam.ID)
+	if err := os.Remove(detector.SegmentPath(cam.ID, file)); err != nil {
+		return notFound(c, "Recording")
+	}
+	pruneEmptyContinuousDir(cam, dir)
 	return c.NoContent(http.StatusNoContent)
 }
 
+// deleteContinuousDay removes every continuous segment that started on the given UTC day
+func deleteContinuousDay(c echo.Context, cam *models.Camera, dateStr string) error {
+	day, err := time.Parse("2006-01-02", dateStr)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "date_str must be YYYY-MM-DD"})
+	}
+
+	dir := detector.ContinuousDir(cam.ID)
+	deleted := 0
+	for _, seg := range detector.CameraSegmentsOn(cam.ID, day) {
+		if os.Remove(seg.Path) == nil {
+			deleted++
+		}
+	}
+	pruneEmptyContinuousDir(cam, dir)
+	return c.JSON(http.StatusOK, map[string]int{"deleted": deleted})
+}
+
+// pruneEmptyContinuousDir removes a camera's segment directory once it is empty.
+// While continuous recording is on, ffmpeg still needs it for the next segment.
+func pruneEmptyContinuousDir(cam *models.Camera, dir string) {
+	if cam.ContinuousRecording {
+		return
+	}
+	// os.Remove refuses non-empty directories, so this is a no-op otherwise
+	os.Remove(dir)
+}
+
 func getSystemHealth(c echo.Context) error {
+	return c.JSON(http.StatusOK, systemHealth())
+}
+
+func systemHealth() map[string]interface{} {
 	var stat syscall.Statfs_t
-	syscall.Statfs("/recordings", &stat)
+	syscall.Statfs(detector.EventRoot, &stat)
 	
 	total := stat.Blocks * uint64(stat.Bsize)
 	free := stat.Bavail * uint64(stat.Bsize)
@@ -731,7 +1585,7 @@ func getSystemHealth(c echo.Context) error {
 		percent = (float64(used) / float64(total)) * 100
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
+	return map[string]interface{}{
 		"cpu_percent":    0, 
 		"memory_total":   16000000000, 
 		"memory_used":    4000000000,  
@@ -741,14 +1595,15 @@ func getSystemHealth(c echo.Context) error {
 		"disk_used":      used,
 		"disk_percent":   percent,
 		"uptime_seconds": 3600,
-	})
+		"media_tools":    detector.MediaToolStats(),
+	}
 }
 
 func getSystemSettings(c echo.Context) error {
 	var settings models.SystemSettings
 	if err := database.DB.First(&settings).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			settings = models.SystemSettings{RetentionDays: 30}
+			settings = models.SystemSettings{RetentionDays: 30, AllowRegistration: true}
 			database.DB.Create(&settings)
 		} else {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "DB Error"})
@@ -760,93 +1615,171 @@ func getSystemSettings(c echo.Context) error {
 func updateSystemSettings(c echo.Context) error {
 	req := new(SystemSettingsRequest)
 	c.Bind(req)
+	if p := req.EventCapacityPolicy; p != nil && *p != detector.CapacityReject && *p != detector.CapacityQueue {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "event_capacity_policy must be reject or queue"})
+	}
+	if req.RetentionRules != nil {
+		if _, err := detector.ParseRetentionRules(*req.RetentionRules); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"detail": err.Error()})
+		}
+	}
+	if req.PublicBaseURL != nil {
+		base, err := normalizePublicBaseURL(*req.PublicBaseURL)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"detail": err.Error()})
+		}
+		req.PublicBaseURL = &base
+	}
+	if req.CameraDefaults != nil {
+		defaults, err := normalizeCameraDefaults(*req.CameraDefaults)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"detail": err.Error()})
+		}
+		req.CameraDefaults = &defaults
+	}
 	var settings models.SystemSettings
 	if err := database.DB.First(&settings).Error; err != nil {
-		settings = models.SystemSettings{RetentionDays: req.RetentionDays}
+		settings = models.SystemSettings{RetentionDays: 30, AllowRegistration: true}
+		applySettingsRequest(&settings, req)
 		database.DB.Create(&settings)
 	} else {
-		settings.RetentionDays = req.RetentionDays
+		applySettingsRequest(&settings, req)
 		database.DB.Save(&settings)
 	}
 	return c.JSON(http.StatusOK, settings)
 }
 
+// applySettingsRequest copies the optional fields that were present in the request
+func applySettingsRequest(settings *models.SystemSettings, req *SystemSettingsRequest) {
+	if req.RetentionDays != nil {
+		settings.RetentionDays = max(*req.RetentionDays, 0)
+	}
+	if req.AllowRegistration != nil {
+		settings.AllowRegistration = *req.AllowRegistration
+	}
+	if req.MaxCamerasPerUser != nil {
+		settings.MaxCamerasPerUser = max(*req.MaxCamerasPerUser, 0)
+	}
+	if req.MaxSessionsPerUser != nil {
+		settings.MaxSessionsPerUser = max(*req.MaxSessionsPerUser, 0)
+	}
+	if req.MinEventSeconds != nil {
+		settings.MinEventSeconds = max(*req.MinEventSeconds, 0)
+	}
+	if req.MaintenanceMode != nil {
+		settings.MaintenanceMode = *req.MaintenanceMode
+	}
+	if req.NotifyWebhookURL != nil {
+		settings.NotifyWebhookURL = strings.TrimSpace(*req.NotifyWebhookURL)
+	}
+	if req.PublicBaseURL != nil {
+		settings.PublicBaseURL = *req.PublicBaseURL
+	}
+	if req.CameraDefaults != nil {
+		settings.CameraDefaults = *req.CameraDefaults
+	}
+	if req.StorageWarnThresholdGB != nil {
+		settings.StorageWarnThresholdGB = max(*req.StorageWarnThresholdGB, 0)
+	}
+	if req.AnimatedPreviews != nil {
+		settings.AnimatedPreviews = *req.AnimatedPreviews
+	}
+	if req.MaxConcurrentEventRecordings != nil {
+		settings.MaxConcurrentEventRecordings = max(*req.MaxConcurrentEventRecordings, 0)
+	}
+	if req.EventCapacityPolicy != nil {
+		settings.EventCapacityPolicy = *req.EventCapacityPolicy
+	}
+	if req.RetentionRules != nil {
+		settings.RetentionRules = strings.TrimSpace(*req.RetentionRules)
+	}
+	if req.EventPartMinutes != nil {
+		settings.EventPartMinutes = min(max(*req.EventPartMinutes, 0), 60)
+	}
+	if req.MaxEventMinutes != nil {
+		settings.MaxEventMinutes = max(*req.MaxEventMinutes, 0)
+	}
+	if req.MinEventsKept != nil {
+		settings.MinEventsKept = max(*req.MinEventsKept, 0)
+	}
+	if req.MinFreeRecordingGB != nil {
+		settings.MinFreeRecordingGB = max(*req.MinFreeRecordingGB, 0)
+	}
+	if req.EventGroupGapSeconds != nil {
+		settings.EventGroupGapSeconds = min(max(*req.EventGroupGapSeconds, 0), maxEventGroupGap)
+	}
+}
+
+// verifyRecordings probes every stored recording; ?quarantine=true moves the
+// unplayable ones aside instead of just reporting them
+func verifyRecordings(c echo.Context) error {
+	report := Detector.VerifyRecordings(c.Request().Context(), c.QueryParam("quarantine") == "true")
+	return c.JSON(http.StatusOK, report)
+}
+
 func wipeAllRecordings(c echo.Context) error {
 	database.DB.Exec("DELETE FROM events")
-	files, _ := os.ReadDir("/recordings")
-	for _, f := range files {
-		if !f.IsDir() && (strings.HasSuffix(f.Name(), ".mp4") || strings.HasSuffix(f.Name(), ".jpg")) {
-			os.Remove(filepath.Join("/recordings", f.Name()))
+	detector.WalkEventFiles(func(path string, info os.FileInfo) {
+		if detector.IsRecordingFile(path) {
+			os.Remove(path)
 		}
-	}
-	os.RemoveAll("/recordings/continuous")
-	os.MkdirAll("/recordings/continuous", 0755)
+	})
+	os.RemoveAll(detector.ContinuousRoot)
+	os.MkdirAll(detector.ContinuousRoot, 0755)
 	return c.JSON(http.StatusOK, map[string]string{"message": "Wiped"})
 }
 
-func restartSystem(c echo.Context) error { 
-	go performSystemRestart()
-	return c.JSON(http.StatusOK, map[string]string{"message": "Restarting"}) 
-}
-
 func downloadFile(c echo.Context) error {
-	path := c.QueryParam("path")
-	if strings.Contains(path, "..") || strings.HasPrefix(path, "/") {
+	path, ok := cleanRecordingPath(c.QueryParam("path"))
+	if !ok {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid path")
 	}
-	return c.File("/" + path)
+	if !ownsRecording(getUser(c), path) {
+		return notFound(c, "Recording")
+	}
+	return serveMediaFile(c, path)
 }
 
 // --- WEBHOOKS ---
+// Detectors may pass ?source=<name> so several of them can share a camera;
+// the recording ends only when every source that started it has ended.
+func webhookSource(c echo.Context) string {
+	if source := strings.TrimSpace(c.QueryParam("source")); source != "" {
+		return source
+	}
+	return "default"
+}
+
 func webhookStart(c echo.Context) error {
 	id, _ := strconv.Atoi(c.Param("id"))
-	Detector.StartEventRecord(uint(id))
+	reason := c.QueryParam("reason")
+	if reason != "" && !models.ValidEventReason(reason) {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"detail": "Unknown reason", "allowed": models.EventReasons})
+	}
+	touchWebhook(uint(id))
+	// classes=person:0.91,car — the confidence suffix is optional
+	Detector.StartEventRecord(uint(id), webhookSource(c), reason, parseClassList(c.QueryParam("classes")))
+
+	// An optional JSON body {"boxes": [...]} carries bounding boxes for the sidecar
+	var body struct {
+		Boxes []json.RawMessage `json:"boxes"`
+	}
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		if err := json.NewDecoder(c.Request().Body).Decode(&body); err == nil && len(body.Boxes) > 0 {
+			Detector.AddEventBoxes(uint(id), body.Boxes)
+		}
+	}
 	return c.String(http.StatusOK, "OK")
 }
 func webhookEnd(c echo.Context) error {
 	id, _ := strconv.Atoi(c.Param("id"))
-	Detector.StopEventRecord(uint(id))
+	touchWebhook(uint(id))
+	Detector.StopEventRecord(uint(id), webhookSource(c))
 	return c.String(http.StatusOK, "OK")
 }
 
-// performSystemRestart connects to the Docker Socket
-func performSystemRestart() {
-	log.Println("--- SYSTEM RESTART INITIATED ---")
-	
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		log.Printf("Error creating docker client: %v\n", err)
-		return
-	}
-
-	containers, err := cli.ContainerList(context.Background(), types.ContainerListOptions{})
-	if err != nil {
-		log.Printf("Error listing containers: %v\n", err)
-		return
-	}
-
-	myHostname, _ := os.Hostname()
-
-	for _, c := range containers {
-		if strings.HasPrefix(c.ID, myHostname) || strings.HasPrefix(myHostname, c.ID) {
-			continue
-		}
-		match := false
-		for _, name := range c.Names {
-			if strings.Contains(name, "motion-detector") || strings.Contains(name, "mediamtx") {
-				match = true
-				break
-			}
-		}
-
-		if match {
-			log.Printf("Restarting container: %s\n", c.Names[0])
-			timeout := 10
-			cli.ContainerRestart(context.Background(), c.ID, container.StopOptions{Timeout: &timeout})
-		}
-	}
-
-	log.Println("Restarting Backend (Self)...")
-	time.Sleep(2 * time.Second)
-	os.Exit(0) 
-}
\ No newline at end of file
+// touchWebhook records when the AI last reached us for a camera. UpdateColumn leaves
+// updated_at alone so camera list ETags aren't invalidated by every webhook.
+func touchWebhook(camID uint) {
+	database.DB.Model(&models.Camera{}).Where("id = ?", camID).UpdateColumn("last_webhook_at", time.Now())
+}
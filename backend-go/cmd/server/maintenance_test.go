@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// okHandler stands in for the handler behind a middleware
+func okHandler(c echo.Context) error {
+	return c.NoContent(http.StatusNoContent)
+}
+
+func TestMaintenanceModeBlocksWritesOnly(t *testing.T) {
+	testDB(t)
+	settings := models.SystemSettings{AllowRegistration: true, MaintenanceMode: true}
+	database.DB.Create(&settings)
+	user := createTestUser(t, "user@example.com", false)
+	admin := createTestUser(t, "admin@example.com", true)
+
+	guarded := maintenanceMiddleware(okHandler)
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions} {
+		if rec := callHandler(guarded, method, "/api/cameras", "", user); rec.Code != http.StatusNoContent {
+			t.Errorf("%s during maintenance: status %d, want it let through", method, rec.Code)
+		}
+	}
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		if rec := callHandler(guarded, method, "/api/cameras", "", user); rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s during maintenance: status %d, want 503", method, rec.Code)
+		}
+		if rec := callHandler(guarded, method, "/api/cameras", "", admin); rec.Code != http.StatusNoContent {
+			t.Errorf("admin %s during maintenance: status %d, want it let through", method, rec.Code)
+		}
+	}
+
+	database.DB.Model(&settings).Update("maintenance_mode", false)
+	if rec := callHandler(guarded, http.MethodPost, "/api/cameras", "", user); rec.Code != http.StatusNoContent {
+		t.Errorf("write after maintenance ended: status %d", rec.Code)
+	}
+}
+
+func TestMaintenanceModeReadsSkipSettings(t *testing.T) {
+	// Reads and admins never need the settings row, so they keep working even
+	// when the database can't be asked
+	guarded := maintenanceMiddleware(okHandler)
+	if rec := callHandler(guarded, http.MethodGet, "/api/events", "", &models.User{ID: 1}); rec.Code != http.StatusNoContent {
+		t.Errorf("GET: status %d", rec.Code)
+	}
+	if rec := callHandler(guarded, http.MethodDelete, "/api/events/1", "", &models.User{ID: 2, IsAdmin: true}); rec.Code != http.StatusNoContent {
+		t.Errorf("admin DELETE: status %d", rec.Code)
+	}
+}
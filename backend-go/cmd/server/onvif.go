@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/onvif"
+	"nvr-server/internal/secrets"
+)
+
+// OnvifRequest overrides where and how to reach the camera's ONVIF service.
+// Anything left out comes from the stream URL: its host (port 80) and credentials.
+// The address must still be on the camera's own host (see onvif.CheckTarget).
+type OnvifRequest struct {
+	Address  string `json:"address"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// probeOnvifCapabilities asks the camera over ONVIF for its profiles, stream URIs
+// and PTZ support and caches the answer on the camera (credentials excluded)
+func probeOnvifCapabilities(c echo.Context) error {
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+	req := new(OnvifRequest)
+	if c.Request().ContentLength > 0 {
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid request body"})
+		}
+	}
+
+	streamHost := detector.StreamHost(cam.RTSPUrl)
+	if req.Address == "" {
+		req.Address = streamHost
+	}
+	endpoint, err := onvif.DeviceURL(req.Address)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": err.Error()})
+	}
+	hosts := []string{streamHost, detector.StreamHost(cam.RTSPSubstreamUrl), cam.DeviceHostname}
+	if err := onvif.CheckTarget(c.Request().Context(), endpoint, hosts); err != nil {
+		if errors.Is(err, onvif.ErrTargetNotAllowed) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"detail": err.Error()})
+		}
+		return onvifFailed(c, cam.ID, endpoint, err)
+	}
+
+	// Cameras nearly always share one account between RTSP and ONVIF, but the
+	// stream's credentials only ever go to the stream's own host
+	if req.Username == "" && sameHost(endpoint, streamHost) {
+		if resolved, err := secrets.Resolve(cam.RTSPUrl); err == nil {
+			if u, err := url.Parse(resolved); err == nil && u.User != nil {
+				req.Username = u.User.Username()
+				req.Password, _ = u.User.Password()
+			}
+		}
+	}
+
+	caps, err := onvif.Probe(c.Request().Context(), endpoint, req.Username, req.Password)
+	if err != nil {
+		return onvifFailed(c, cam.ID, endpoint, err)
+	}
+
+	if data, err := json.Marshal(caps); err == nil {
+		database.DB.Model(cam).Update("onvif_capabilities", string(data))
+	}
+	return c.JSON(http.StatusOK, caps)
+}
+
+// onvifFailed reports a failed probe without echoing what the far end said, so
+// the endpoint can't be used to read responses from arbitrary services; the
+// details go to the server log
+func onvifFailed(c echo.Context, camID uint, endpoint string, err error) error {
+	log.Printf("ONVIF probe of camera %d at %s failed: %v\n", camID, endpoint, err)
+	switch {
+	case errors.Is(err, onvif.ErrNotONVIF):
+		return c.JSON(http.StatusBadGateway, map[string]string{
+			"detail":   "Camera does not answer ONVIF at " + endpoint + "; check that ONVIF is enabled or pass its address",
+			"reason":   "not_onvif",
+			"endpoint": endpoint,
+		})
+	case errors.Is(err, onvif.ErrUnauthorized):
+		return c.JSON(http.StatusBadGateway, map[string]string{
+			"detail":   "Camera rejected the ONVIF credentials; pass username and password if they differ from the stream's",
+			"reason":   "unauthorized",
+			"endpoint": endpoint,
+		})
+	}
+	return c.JSON(http.StatusBadGateway, map[string]string{"detail": "ONVIF query failed; see the server log", "reason": "error", "endpoint": endpoint})
+}
+
+func sameHost(endpoint, host string) bool {
+	u, err := url.Parse(endpoint)
+	return err == nil && host != "" && strings.EqualFold(u.Hostname(), host)
+}
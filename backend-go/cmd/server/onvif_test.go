@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSameHost(t *testing.T) {
+	cases := []struct {
+		endpoint, host string
+		want           bool
+	}{
+		{"http://192.0.2.1/onvif/device_service", "192.0.2.1", true},
+		{"http://CAM.local:8080/onvif/device_service", "cam.local", true},
+		{"http://192.0.2.2/onvif/device_service", "192.0.2.1", false},
+		{"http://192.0.2.1/onvif/device_service", "", false},
+	}
+	for _, tc := range cases {
+		if got := sameHost(tc.endpoint, tc.host); got != tc.want {
+			t.Errorf("sameHost(%q, %q) = %v", tc.endpoint, tc.host, got)
+		}
+	}
+}
+
+func TestProbeOnvifRejectsOtherHosts(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	id := strconv.Itoa(int(cam.ID))
+	intruder := createTestUser(t, "intruder@example.com", false)
+
+	if rec := callHandler(probeOnvifCapabilities, http.MethodPost, "/", "", intruder, "id", id); rec.Code != http.StatusNotFound {
+		t.Errorf("another user's camera: status %d, want 404", rec.Code)
+	}
+	// Neither a different host nor the NVR's own services may be probed
+	for _, address := range []string{"192.0.2.99", "http://127.0.0.1:9997"} {
+		rec := callHandler(probeOnvifCapabilities, http.MethodPost, "/", `{"address":"`+address+`"}`, user, "id", id)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "not allowed") {
+			t.Errorf("address %s: status %d, body %s", address, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestDeleteHidesOtherUsersResources(t *testing.T) {
+	testDB(t)
+	owner := createTestUser(t, "owner@example.com", false)
+	intruder := createTestUser(t, "intruder@example.com", false)
+
+	cam := createTestCamera(t, owner, "front")
+	event := &models.Event{CameraID: cam.ID, UserID: owner.ID, StartTime: time.Now(), Reason: models.ReasonMotion}
+	database.DB.Create(event)
+	session := &models.UserSession{JTI: "owner-session", UserID: owner.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	database.DB.Create(session)
+
+	cases := []struct {
+		what    string
+		handler echo.HandlerFunc
+		id      uint
+		model   interface{}
+	}{
+		{"Camera", deleteCamera, cam.ID, &models.Camera{}},
+		{"Event", deleteEvent, event.ID, &models.Event{}},
+		{"Session", deleteSession, session.ID, &models.UserSession{}},
+	}
+	for _, tc := range cases {
+		id := strconv.FormatUint(uint64(tc.id), 10)
+		foreign := callHandler(tc.handler, http.MethodDelete, "/", "", intruder, "id", id)
+		missing := callHandler(tc.handler, http.MethodDelete, "/", "", intruder, "id", "999999")
+
+		if foreign.Code != http.StatusNotFound || missing.Code != http.StatusNotFound {
+			t.Errorf("%s: foreign %d, missing %d; want 404 for both", tc.what, foreign.Code, missing.Code)
+		}
+		if foreign.Body.String() != missing.Body.String() {
+			t.Errorf("%s: foreign body %q differs from missing body %q", tc.what, foreign.Body, missing.Body)
+		}
+
+		var count int64
+		database.DB.Model(tc.model).Where("id = ?", tc.id).Count(&count)
+		if count != 1 {
+			t.Errorf("%s was deleted by another user", tc.what)
+		}
+	}
+
+	if rec := callHandler(deleteSession, http.MethodDelete, "/", "", owner, "id", strconv.Itoa(int(session.ID))); rec.Code != http.StatusNoContent {
+		t.Errorf("owner deleting own session: status %d", rec.Code)
+	}
+	if rec := callHandler(deleteCamera, http.MethodDelete, "/?keep_files=true", "", owner, "id", strconv.Itoa(int(cam.ID))); rec.Code != http.StatusOK {
+		t.Errorf("owner deleting own camera: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+type Pagination struct {
+	Page     int
+	PageSize int
+}
+
+type PagedResponse struct {
+	Items    interface{} `json:"items"`
+	Total    int64       `json:"total"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+}
+
+// parsePagination reads page/page_size; ok is false when the client asked for neither,
+// letting list endpoints keep returning a bare array to older clients
+func parsePagination(c echo.Context) (Pagination, bool) {
+	p := Pagination{Page: 1, PageSize: defaultPageSize}
+	pageStr, sizeStr := c.QueryParam("page"), c.QueryParam("page_size")
+	if pageStr == "" && sizeStr == "" {
+		return p, false
+	}
+	if n, err := strconv.Atoi(pageStr); err == nil && n > 0 {
+		p.Page = n
+	}
+	if n, err := strconv.Atoi(sizeStr); err == nil && n > 0 {
+		p.PageSize = min(n, maxPageSize)
+	}
+	return p, true
+}
+
+func (p Pagination) apply(tx *gorm.DB) *gorm.DB {
+	return tx.Offset((p.Page - 1) * p.PageSize).Limit(p.PageSize)
+}
+
+// respondPage writes one page of a list. X-Total-Count and an RFC 5988 Link header
+// (first/prev/next/last) are always set; the body is a PagedResponse envelope unless
+// the client passes ?envelope=false, in which case it is the bare item array.
+func respondPage(c echo.Context, items interface{}, total int64, p Pagination) error {
+	lastPage := max(1, int((total+int64(p.PageSize)-1)/int64(p.PageSize)))
+
+	h := c.Response().Header()
+	h.Set("X-Total-Count", strconv.FormatInt(total, 10))
+
+	links := []string{p.link(c, 1, "first")}
+	if p.Page > 1 {
+		links = append(links, p.link(c, min(p.Page-1, lastPage), "prev"))
+	}
+	if p.Page < lastPage {
+		links = append(links, p.link(c, p.Page+1, "next"))
+	}
+	links = append(links, p.link(c, lastPage, "last"))
+	h.Set("Link", strings.Join(links, ", "))
+
+	if c.QueryParam("envelope") == "false" {
+		return c.JSON(http.StatusOK, items)
+	}
+	return c.JSON(http.StatusOK, PagedResponse{Items: items, Total: total, Page: p.Page, PageSize: p.PageSize})
+}
+
+// link renders a Link entry for page, keeping the request's other query parameters
+func (p Pagination) link(c echo.Context, page int, rel string) string {
+	u := *c.Request().URL
+	q := u.Query()
+	q.Set("page", strconv.Itoa(page))
+	q.Set("page_size", strconv.Itoa(p.PageSize))
+	u.RawQuery = q.Encode()
+	return fmt.Sprintf("<%s>; rel=%q", u.RequestURI(), rel)
+}
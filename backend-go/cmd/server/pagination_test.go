@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestParsePagination(t *testing.T) {
+	cases := []struct {
+		query string
+		want  Pagination
+		paged bool
+	}{
+		{"", Pagination{1, defaultPageSize}, false},
+		{"?page=3", Pagination{3, defaultPageSize}, true},
+		{"?page_size=10", Pagination{1, 10}, true},
+		{"?page=2&page_size=100000", Pagination{2, maxPageSize}, true},
+		{"?page=-1&page_size=abc", Pagination{1, defaultPageSize}, true},
+	}
+	for _, tc := range cases {
+		c, _ := handlerContext(http.MethodGet, "/api/sessions"+tc.query, "", nil)
+		got, paged := parsePagination(c)
+		if got != tc.want || paged != tc.paged {
+			t.Errorf("%q = %+v, %v; want %+v, %v", tc.query, got, paged, tc.want, tc.paged)
+		}
+	}
+}
+
+func TestRespondPageHeaders(t *testing.T) {
+	cases := []struct {
+		query string
+		page  Pagination
+		total int64
+		links []string
+	}{
+		{"?reason=person&page=1&page_size=10", Pagination{1, 10}, 25, []string{
+			`</api/events?page=1&page_size=10&reason=person>; rel="first"`,
+			`</api/events?page=2&page_size=10&reason=person>; rel="next"`,
+			`</api/events?page=3&page_size=10&reason=person>; rel="last"`,
+		}},
+		{"?page=2&page_size=10", Pagination{2, 10}, 25, []string{
+			`</api/events?page=1&page_size=10>; rel="first"`,
+			`</api/events?page=1&page_size=10>; rel="prev"`,
+			`</api/events?page=3&page_size=10>; rel="next"`,
+			`</api/events?page=3&page_size=10>; rel="last"`,
+		}},
+		// Past the end: prev points at the real last page
+		{"?page=9&page_size=10", Pagination{9, 10}, 25, []string{
+			`</api/events?page=1&page_size=10>; rel="first"`,
+			`</api/events?page=3&page_size=10>; rel="prev"`,
+			`</api/events?page=3&page_size=10>; rel="last"`,
+		}},
+		{"?page=1&page_size=10", Pagination{1, 10}, 0, []string{
+			`</api/events?page=1&page_size=10>; rel="first"`,
+			`</api/events?page=1&page_size=10>; rel="last"`,
+		}},
+	}
+	for _, tc := range cases {
+		c, rec := handlerContext(http.MethodGet, "/api/events"+tc.query, "", nil)
+		respondPage(c, []int{}, tc.total, tc.page)
+		if got := rec.Header().Get("X-Total-Count"); got != strconv.FormatInt(tc.total, 10) {
+			t.Errorf("%s: X-Total-Count = %q", tc.query, got)
+		}
+		if got, want := rec.Header().Get("Link"), strings.Join(tc.links, ", "); got != want {
+			t.Errorf("%s: Link =\n  %s\nwant\n  %s", tc.query, got, want)
+		}
+	}
+
+	c, rec := handlerContext(http.MethodGet, "/api/events?envelope=false", "", nil)
+	respondPage(c, []int{1, 2}, 2, Pagination{1, 10})
+	if body := strings.TrimSpace(rec.Body.String()); body != "[1,2]" {
+		t.Errorf("envelope=false body = %s", body)
+	}
+}
+
+func TestGetEventsPages(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		database.DB.Create(&models.Event{CameraID: cam.ID, UserID: user.ID, StartTime: start.Add(time.Duration(i) * time.Minute)})
+	}
+
+	seen := make(map[uint]bool)
+	for page := 1; page <= 3; page++ {
+		var body struct {
+			Items []models.Event `json:"items"`
+			Total int64          `json:"total"`
+		}
+		rec := callHandler(getEvents, http.MethodGet, fmt.Sprintf("/api/events?page=%d&page_size=2", page), "", user)
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("page %d: status %d, body %s", page, rec.Code, rec.Body)
+		}
+		if want := min(2, 5-2*(page-1)); len(body.Items) != want || body.Total != 5 {
+			t.Errorf("page %d: %d items of %d, want %d of 5", page, len(body.Items), body.Total, want)
+		}
+		if rec.Header().Get("X-Total-Count") != "5" {
+			t.Errorf("page %d: X-Total-Count %q", page, rec.Header().Get("X-Total-Count"))
+		}
+		for _, e := range body.Items {
+			if seen[e.ID] {
+				t.Errorf("event %d on more than one page", e.ID)
+			}
+			seen[e.ID] = true
+		}
+	}
+	if len(seen) != 5 {
+		t.Errorf("pages covered %d of 5 events", len(seen))
+	}
+}
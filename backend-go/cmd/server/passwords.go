@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// BcryptCost applies to new hashes; existing hashes below it are upgraded on the
+// next successful login
+var BcryptCost = min(max(config.Int("NVR_BCRYPT_COST", bcrypt.DefaultCost), bcrypt.MinCost), bcrypt.MaxCost)
+
+func hashPassword(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
+}
+
+// rehashIfWeak re-hashes a just-verified password when its stored hash uses a
+// lower cost than BcryptCost. Failures are logged; login proceeds either way.
+func rehashIfWeak(user *models.User, password string) {
+	cost, err := bcrypt.Cost([]byte(user.HashedPassword))
+	if err != nil || cost >= BcryptCost {
+		return
+	}
+	hashed, err := hashPassword(password)
+	if err != nil {
+		log.Printf("Password rehash for user %d failed: %v\n", user.ID, err)
+		return
+	}
+	if err := database.DB.Model(user).UpdateColumn("hashed_password", string(hashed)).Error; err != nil {
+		log.Printf("Password rehash for user %d failed: %v\n", user.ID, err)
+		return
+	}
+	user.HashedPassword = string(hashed)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// userWithPassword returns an unsaved user whose hash matches password
+func userWithPassword(t *testing.T, password string) *models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &models.User{Email: "user@example.com", HashedPassword: string(hash)}
+}
+
+func TestChangePasswordRejects(t *testing.T) {
+	cases := []struct {
+		what, body string
+	}{
+		{"wrong current password", `{"current_password":"wrong-password","new_password":"brand-new-pass"}`},
+		{"too short", `{"current_password":"old-password","new_password":"short"}`},
+		{"same as current", `{"current_password":"old-password","new_password":"old-password"}`},
+	}
+	for _, tc := range cases {
+		user := userWithPassword(t, "old-password")
+		hash := user.HashedPassword
+		rec := callHandler(changePassword, http.MethodPost, "/api/users/change-password", tc.body, user)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", tc.what, rec.Code)
+		}
+		if user.HashedPassword != hash || !user.TokensValidFrom.IsZero() {
+			t.Errorf("%s: user changed anyway", tc.what)
+		}
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	testDB(t)
+	user := userWithPassword(t, "old-password")
+	database.DB.Create(user)
+	before := time.Now()
+
+	rec := callHandler(changePassword, http.MethodPost, "/api/users/change-password", `{"current_password":"old-password","new_password":"brand-new-pass"}`, user)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+
+	var stored models.User
+	database.DB.First(&stored, user.ID)
+	if bcrypt.CompareHashAndPassword([]byte(stored.HashedPassword), []byte("brand-new-pass")) != nil {
+		t.Error("new password not stored")
+	}
+	if stored.TokensValidFrom.Before(before.Truncate(time.Microsecond)) {
+		t.Errorf("existing tokens not revoked: tokens_valid_from %v", stored.TokensValidFrom)
+	}
+}
+
+func TestLoginRehashesWeakPasswords(t *testing.T) {
+	testDB(t)
+	testSecrets(t)
+	prev := BcryptCost
+	BcryptCost = bcrypt.MinCost + 1
+	t.Cleanup(func() { BcryptCost = prev })
+
+	user := createTestUser(t, "user@example.com", false)
+	weak, _ := bcrypt.GenerateFromPassword([]byte("s3cret-pass"), bcrypt.MinCost)
+	database.DB.Model(user).Update("hashed_password", string(weak))
+
+	attempt := func(password string) int {
+		c, rec := loginContext("application/json", `{"email":"user@example.com","password":"`+password+`"}`)
+		serve(login, c)
+		return rec.Code
+	}
+	stored := func() string {
+		var u models.User
+		database.DB.First(&u, user.ID)
+		return u.HashedPassword
+	}
+
+	// A failed login never touches the hash
+	if code := attempt("wrong"); code == http.StatusOK || stored() != string(weak) {
+		t.Fatalf("wrong password: status %d, hash changed %v", code, stored() != string(weak))
+	}
+
+	if code := attempt("s3cret-pass"); code != http.StatusOK {
+		t.Fatalf("login: status %d", code)
+	}
+	upgraded := stored()
+	if cost, _ := bcrypt.Cost([]byte(upgraded)); cost != BcryptCost {
+		t.Errorf("hash cost after login = %d, want %d", cost, BcryptCost)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(upgraded), []byte("s3cret-pass")) != nil {
+		t.Error("upgraded hash does not verify the password")
+	}
+
+	// Already at cost: left alone
+	if code := attempt("s3cret-pass"); code != http.StatusOK || stored() != upgraded {
+		t.Errorf("second login: status %d, rehashed again %v", code, stored() != upgraded)
+	}
+}
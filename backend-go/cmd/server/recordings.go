@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+	"nvr-server/internal/slug"
+)
+
+// cleanRecordingPath normalizes a "recordings/..." path, refusing anything that
+// would leave the recordings tree
+func cleanRecordingPath(p string) (string, bool) {
+	if p == "" || strings.HasPrefix(p, "/") || strings.Contains(p, "..") {
+		return "", false
+	}
+	p = path.Clean(p)
+	if !strings.HasPrefix(p, "recordings/") {
+		return "", false
+	}
+	return p, true
+}
+
+// continuousSegments lists a camera's segments for date_str (YYYY-MM-DD, UTC):
+// only that day's when it parses, all of them otherwise
+func continuousSegments(camID uint, dateStr string) []detector.Segment {
+	if day, err := time.Parse("2006-01-02", dateStr); err == nil {
+		return detector.CameraSegmentsOn(camID, day)
+	}
+	return detector.CameraSegments(camID)
+}
+
+// ownsRecording reports whether the file belongs to one of the user's cameras
+func ownsRecording(user *models.User, rel string) bool {
+	camID, ok := detector.CameraIDForPath(rel)
+	if !ok {
+		return false
+	}
+	var count int64
+	database.DB.Model(&models.Camera{}).Where("id = ? AND owner_id = ?", camID, user.ID).Count(&count)
+	return count > 0
+}
+
+// serveRecording replaces the old public static route. Requests need either a
+// valid signature for the exact path (see signMediaURL) or a bearer/API token
+// whose user owns the camera the file belongs to.
+func serveRecording(c echo.Context) error {
+	rel, ok := cleanRecordingPath("recordings/" + c.Param("*"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid path")
+	}
+
+	if sig := c.QueryParam("sig"); sig != "" {
+		if !verifyMediaSignature(rel, c.QueryParam("exp"), sig, time.Now()) {
+			return c.JSON(http.StatusForbidden, map[string]string{"detail": "Invalid or expired link"})
+		}
+		return serveMediaFile(c, rel)
+	}
+
+	return jwtMiddleware(func(c echo.Context) error {
+		if !ownsRecording(getUser(c), rel) {
+			return notFound(c, "Recording")
+		}
+		return serveMediaFile(c, rel)
+	})(c)
+}
+
+var mediaContentTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".mkv":  "video/x-matroska",
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+	".log":  "text/plain; charset=utf-8",
+}
+
+// serveMediaFile sends a recording with an explicit Content-Type. With
+// ?download=true it is sent as an attachment named after the camera.
+func serveMediaFile(c echo.Context, rel string) error {
+	ext := path.Ext(rel)
+	if ct, ok := mediaContentTypes[ext]; ok {
+		c.Response().Header().Set(echo.HeaderContentType, ct)
+	} else {
+		c.Response().Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	}
+
+	if c.QueryParam("download") == "true" {
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", downloadFilename(rel)))
+	}
+	return c.File(detector.MediaPath(rel))
+}
+
+// downloadFilename turns "recordings/event_3_20240101-101010.mp4" (or a continuous
+// segment) into "front-door_20240101-101010.mp4"
+func downloadFilename(rel string) string {
+	base := path.Base(rel)
+	camID, ok := detector.CameraIDForPath(rel)
+	if !ok {
+		return base
+	}
+
+	stamp := base
+	if rest, isEvent := strings.CutPrefix(base, "event_"); isEvent {
+		_, stamp, _ = strings.Cut(rest, "_")
+	}
+
+	var cam models.Camera
+	name := fmt.Sprintf("camera-%d", camID)
+	if err := database.DB.Select("name").First(&cam, camID).Error; err == nil {
+		if clean := slug.Make(cam.Name); clean != slug.Fallback {
+			name = clean
+		}
+	}
+	return name + "_" + stamp
+}
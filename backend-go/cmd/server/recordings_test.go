@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+)
+
+// testRecordingRoots points the event and continuous roots at temp dirs
+func testRecordingRoots(t *testing.T) {
+	t.Helper()
+	prevEvents, prevContinuous := detector.EventRoot, detector.ContinuousRoot
+	detector.EventRoot = t.TempDir()
+	detector.ContinuousRoot = t.TempDir()
+	t.Cleanup(func() { detector.EventRoot, detector.ContinuousRoot = prevEvents, prevContinuous })
+}
+
+func TestCleanRecordingPath(t *testing.T) {
+	cases := map[string]string{
+		"recordings/event_1_20240101-080000.mp4":         "recordings/event_1_20240101-080000.mp4",
+		"recordings//continuous/1/./20240101-080000.mp4": "recordings/continuous/1/20240101-080000.mp4",
+		"recordings/../etc/passwd":                       "",
+		"/recordings/event_1.mp4":                        "",
+		"etc/passwd":                                     "",
+		"recordings":                                     "",
+		"":                                               "",
+	}
+	for in, want := range cases {
+		got, ok := cleanRecordingPath(in)
+		if got != want || ok != (want != "") {
+			t.Errorf("cleanRecordingPath(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+}
+
+func TestServeRecordingOwnerOrSignature(t *testing.T) {
+	testDB(t)
+	testSecrets(t)
+	testRecordingRoots(t)
+	owner := createTestUser(t, "owner@example.com", false)
+	cam := createTestCamera(t, owner, "front")
+	name := fmt.Sprintf("event_%d_20240101-080000.mp4", cam.ID)
+	writeFile(t, filepath.Join(detector.EventRoot, name), "clip")
+	rel := "recordings/" + name
+
+	get := func(query, auth string) (int, string) {
+		c, rec := handlerContext(http.MethodGet, "/"+rel+query, "", nil)
+		c.SetParamNames("*")
+		c.SetParamValues(name)
+		if auth != "" {
+			c.Request().Header.Set("Authorization", "Token "+auth)
+		}
+		serve(serveRecording, c)
+		return rec.Code, rec.Body.String()
+	}
+
+	if code, _ := get("", ""); code != http.StatusUnauthorized {
+		t.Errorf("anonymous: status %d, want 401", code)
+	}
+	intruder := createTestUser(t, "intruder@example.com", false)
+	if code, _ := get("", createToken(t, intruder, ScopeRead).Token); code != http.StatusNotFound {
+		t.Errorf("another user: status %d, want 404", code)
+	}
+	if code, body := get("", createToken(t, owner, ScopeRead).Token); code != http.StatusOK || body != "clip" {
+		t.Errorf("owner: status %d, body %q", code, body)
+	}
+
+	exp := time.Now().Add(time.Minute).Unix()
+	signed := url.Values{"exp": {strconv.FormatInt(exp, 10)}, "sig": {mediaSignature(rel, exp)}}
+	if code, body := get("?"+signed.Encode(), ""); code != http.StatusOK || body != "clip" {
+		t.Errorf("signed URL: status %d, body %q", code, body)
+	}
+	signed.Set("sig", mediaSignature("recordings/other.mp4", exp))
+	if code, _ := get("?"+signed.Encode(), ""); code != http.StatusForbidden {
+		t.Errorf("signature for another path: status %d, want 403", code)
+	}
+
+	// /api/download applies the same ownership rule
+	if rec := callHandler(downloadFile, http.MethodGet, "/api/download?path="+rel, "", intruder); rec.Code != http.StatusNotFound {
+		t.Errorf("download by another user: status %d, want 404", rec.Code)
+	}
+	if rec := callHandler(downloadFile, http.MethodGet, "/api/download?path="+rel, "", owner); rec.Code != http.StatusOK {
+		t.Errorf("download by owner: status %d", rec.Code)
+	}
+}
+
+func TestServeMediaFileHeaders(t *testing.T) {
+	testRecordingRoots(t)
+	for _, name := range []string{"motion.log", "clip.mkv", "still.jpg", "blob.bin"} {
+		writeFile(t, filepath.Join(detector.EventRoot, name), "data")
+	}
+
+	cases := map[string]string{
+		"motion.log": "text/plain; charset=utf-8",
+		"clip.mkv":   "video/x-matroska",
+		"still.jpg":  "image/jpeg",
+		"blob.bin":   "application/octet-stream",
+	}
+	for name, want := range cases {
+		c, rec := handlerContext(http.MethodGet, "/", "", nil)
+		serveMediaFile(c, "recordings/"+name)
+		if ct := rec.Header().Get("Content-Type"); ct != want || rec.Body.String() != "data" {
+			t.Errorf("%s: Content-Type %q, body %q", name, ct, rec.Body)
+		}
+		if cd := rec.Header().Get("Content-Disposition"); cd != "" {
+			t.Errorf("%s: Content-Disposition %q without download=true", name, cd)
+		}
+	}
+
+	c, rec := handlerContext(http.MethodGet, "/?download=true", "", nil)
+	serveMediaFile(c, "recordings/still.jpg")
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename="still.jpg"` {
+		t.Errorf("download Content-Disposition = %q", cd)
+	}
+}
+
+func TestDownloadFilenameUsesCameraName(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "Front Door!")
+
+	cases := map[string]string{
+		fmt.Sprintf("recordings/event_%d_20240101-101010.mp4", cam.ID):      "front-door_20240101-101010.mp4",
+		fmt.Sprintf("recordings/continuous/%d/20240101-101010.mp4", cam.ID): "front-door_20240101-101010.mp4",
+		"recordings/event_999_20240101-101010.mp4":                          "camera-999_20240101-101010.mp4",
+		"recordings/other.mp4": "other.mp4",
+	}
+	for rel, want := range cases {
+		if got := downloadFilename(rel); got != want {
+			t.Errorf("downloadFilename(%q) = %q, want %q", rel, got, want)
+		}
+	}
+}
+
+func TestDeleteContinuousFile(t *testing.T) {
+	testDB(t)
+	testRecordingRoots(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	id := strconv.Itoa(int(cam.ID))
+	dir := detector.ContinuousDir(cam.ID)
+	writeFile(t, filepath.Join(dir, "20240101-080000.mp4"), "a")
+	writeFile(t, filepath.Join(dir, "20240101-081000.mp4"), "b")
+
+	for _, name := range []string{"notes.txt", "..", "20240101-080000.mp4.part"} {
+		if rec := callHandler(deleteContinuousFile, http.MethodDelete, "/", "", user, "id", id, "filename", name); rec.Code != http.StatusBadRequest {
+			t.Errorf("deleting %q: status %d, want 400", name, rec.Code)
+		}
+	}
+	if rec := callHandler(deleteContinuousFile, http.MethodDelete, "/", "", user, "id", id, "filename", "20240101-090000.mp4"); rec.Code != http.StatusNotFound {
+		t.Errorf("deleting a missing segment: status %d, want 404", rec.Code)
+	}
+
+	for _, name := range []string{"20240101-080000.mp4", "20240101-081000.mp4"} {
+		if rec := callHandler(deleteContinuousFile, http.MethodDelete, "/", "", user, "id", id, "filename", name); rec.Code != http.StatusNoContent {
+			t.Fatalf("deleting %s: status %d", name, rec.Code)
+		}
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("empty segment directory kept: %v", err)
+	}
+}
+
+func TestDeleteContinuousDay(t *testing.T) {
+	testDB(t)
+	testRecordingRoots(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	database.DB.Model(cam).Update("continuous_recording", true)
+	id := strconv.Itoa(int(cam.ID))
+	dir := detector.ContinuousDir(cam.ID)
+	for _, name := range []string{"20240101-235959.mp4", "20240102-000000.mp4", "20240102-235959.mkv", "20240103-000000.mp4"} {
+		writeFile(t, filepath.Join(dir, name), "x")
+	}
+
+	if rec := callHandler(wipeCameraRecordings, http.MethodDelete, "/?date_str=02-01-2024", "", user, "id", id); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad date: status %d, want 400", rec.Code)
+	}
+
+	rec := callHandler(wipeCameraRecordings, http.MethodDelete, "/?date_str=2024-01-02", "", user, "id", id)
+	var out struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || rec.Code != http.StatusOK || out.Deleted != 2 {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	for name, kept := range map[string]bool{"20240101-235959.mp4": true, "20240102-000000.mp4": false, "20240102-235959.mkv": false, "20240103-000000.mp4": true} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != kept {
+			t.Errorf("%s: kept %v, want %v", name, err == nil, kept)
+		}
+	}
+
+	// A recording camera keeps its directory even once it is empty
+	callHandler(wipeCameraRecordings, http.MethodDelete, "/?date_str=2024-01-01", "", user, "id", id)
+	callHandler(wipeCameraRecordings, http.MethodDelete, "/?date_str=2024-01-03", "", user, "id", id)
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("directory of a recording camera removed: %v", err)
+	}
+}
+
+func TestWipeAllRecordings(t *testing.T) {
+	testDB(t)
+	testRecordingRoots(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	base := filepath.Join(detector.EventRoot, "2024", "01", "02", fmt.Sprintf("event_%d_20240102-120000", cam.ID))
+	var media []string
+	for _, suffix := range []string{".mp4", ".jpg", "_snapshot.jpg", "_preview.webp", ".json"} {
+		media = append(media, base+suffix)
+		writeFile(t, base+suffix, "x")
+	}
+	notes := filepath.Join(detector.EventRoot, "README.txt")
+	writeFile(t, notes, "keep")
+	segment := filepath.Join(detector.ContinuousDir(cam.ID), "20240102-120000.mkv")
+	writeFile(t, segment, "x")
+	database.DB.Create(&models.Event{CameraID: cam.ID, UserID: user.ID, StartTime: time.Now()})
+
+	if rec := callHandler(wipeAllRecordings, http.MethodDelete, "/", "", user); rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	for _, path := range append(media, segment) {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s survived the wipe", path)
+		}
+	}
+	if _, err := os.Stat(notes); err != nil {
+		t.Errorf("non-recording file removed: %v", err)
+	}
+	var n int64
+	database.DB.Model(&models.Event{}).Count(&n)
+	if n != 0 {
+		t.Errorf("%d events left", n)
+	}
+}
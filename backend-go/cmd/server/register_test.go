@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func registerBody(email string) string {
+	return fmt.Sprintf(`{"email":%q,"password":"correct horse battery"}`, email)
+}
+
+func TestRegistrationOpenAndClosed(t *testing.T) {
+	testDB(t)
+	settings := models.SystemSettings{AllowRegistration: false}
+	database.DB.Create(&settings)
+
+	// The first account is always allowed, and administers the install
+	rec := callHandler(register, http.MethodPost, "/register", registerBody("first@example.com"), nil)
+	var first models.User
+	if err := json.Unmarshal(rec.Body.Bytes(), &first); err != nil || rec.Code != http.StatusOK || !first.IsAdmin {
+		t.Fatalf("first sign-up with registration closed: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := callHandler(register, http.MethodPost, "/register", registerBody("second@example.com"), nil); rec.Code != http.StatusForbidden {
+		t.Errorf("second sign-up with registration closed: status %d, want 403", rec.Code)
+	}
+
+	database.DB.Model(&settings).Update("allow_registration", true)
+	rec = callHandler(register, http.MethodPost, "/register", registerBody("second@example.com"), nil)
+	var second models.User
+	if err := json.Unmarshal(rec.Body.Bytes(), &second); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("sign-up with registration open: %d %s", rec.Code, rec.Body)
+	}
+	if second.IsAdmin {
+		t.Error("later sign-up became admin")
+	}
+}
+
+func TestFreshSettingsAllowRegistration(t *testing.T) {
+	testDB(t)
+	ensureDefaultSettings()
+	if !loadSettings().AllowRegistration {
+		t.Fatal("default settings row has registration closed")
+	}
+
+	// Turning it off must stick
+	var s models.SystemSettings
+	database.DB.First(&s)
+	database.DB.Model(&s).Update("allow_registration", false)
+	ensureDefaultSettings()
+	if loadSettings().AllowRegistration {
+		t.Error("allow_registration=false did not survive ensureDefaultSettings")
+	}
+}
+
+func TestConcurrentRegistrationSameEmail(t *testing.T) {
+	testDB(t)
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true})
+	createTestUser(t, "first@example.com", true)
+
+	const attempts = 8
+	codes := make([]int, attempts)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = callHandler(register, http.MethodPost, "/register", registerBody("race@example.com"), nil).Code
+		}()
+	}
+	wg.Wait()
+
+	ok := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusOK:
+			ok++
+		case http.StatusConflict:
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	if ok != 1 {
+		t.Errorf("%d concurrent sign-ups succeeded, want exactly 1 (%v)", ok, codes)
+	}
+
+	var n int64
+	database.DB.Model(&models.User{}).Where("email = ?", "race@example.com").Count(&n)
+	if n != 1 {
+		t.Errorf("%d users stored for the email, want 1", n)
+	}
+}
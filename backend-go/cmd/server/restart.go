@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/config"
+)
+
+var (
+	// Attempts per container before giving up on it
+	RestartAttempts = config.Int("NVR_RESTART_ATTEMPTS", 5)
+
+	// Overall budget for restarting the other containers before this one exits anyway
+	RestartTimeout = config.Duration("NVR_RESTART_TIMEOUT", 2*time.Minute)
+)
+
+// Pause between attempts, doubling up to the max
+var (
+	restartInitialBackoff = time.Second
+	restartMaxBackoff     = 15 * time.Second
+)
+
+const restartStopTimeout = 10 // seconds Docker waits before killing the old process
+
+// Restartable services and the container name each one matches; "backend" is this process
+const backendService = "backend"
+
+var restartServices = map[string]string{
+	"mediamtx": "mediamtx",
+	"ai":       "motion-detector",
+}
+
+type RestartRequest struct {
+	Services []string `json:"services"`
+}
+
+// restartSystem restarts the requested services, or all of them when none are listed
+func restartSystem(c echo.Context) error {
+	var req RestartRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid request"})
+	}
+
+	services, unknown := normalizeRestartServices(req.Services)
+	if unknown != "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"detail": "Unknown service: " + unknown, "allowed": []string{"mediamtx", "ai", backendService}})
+	}
+
+	go performSystemRestart(services)
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "Restarting", "services": services})
+}
+
+// normalizeRestartServices validates and de-duplicates the requested names;
+// an empty list means every service. The first unrecognized name is returned as unknown.
+func normalizeRestartServices(names []string) (services []string, unknown string) {
+	if len(names) == 0 {
+		names = []string{"mediamtx", "ai", backendService}
+	}
+
+	seen := make(map[string]bool)
+	services = make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := restartServices[name]; !ok && name != backendService {
+			return nil, name
+		}
+		if !seen[name] {
+			seen[name] = true
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	return services, ""
+}
+
+// restartTargetsFor returns the container name fragments for the requested services
+func restartTargetsFor(services []string) []string {
+	targets := make([]string, 0, len(services))
+	for _, svc := range services {
+		if target, ok := restartServices[svc]; ok {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// dockerAPI is the part of the Docker client the restart needs
+type dockerAPI interface {
+	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
+	ContainerRestart(ctx context.Context, containerID string, options container.StopOptions) error
+	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+}
+
+// performSystemRestart restarts the requested containers through the Docker socket
+// and, if "backend" is among them, exits so Docker restarts this one. Each container
+// is retried with backoff and confirmed running; the backend exits once all are
+// confirmed or RestartTimeout passes.
+func performSystemRestart(services []string) {
+	log.Printf("--- SYSTEM RESTART INITIATED (%s) ---\n", strings.Join(services, ", "))
+	restartSelf := false
+	for _, svc := range services {
+		if svc == backendService {
+			restartSelf = true
+		}
+	}
+
+	if targets := restartTargetsFor(services); len(targets) > 0 {
+		restartCompanions(targets)
+	}
+	if !restartSelf {
+		return
+	}
+
+	log.Println("Restarting Backend (Self)...")
+	time.Sleep(2 * time.Second)
+	os.Exit(0)
+}
+
+// restartCompanions connects to Docker and restarts the matching containers
+func restartCompanions(targets []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), RestartTimeout)
+	defer cancel()
+
+	var cli dockerAPI
+	err := withBackoff(ctx, RestartAttempts, func() error {
+		c, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		if err == nil {
+			cli = c
+		}
+		return err
+	})
+	if err != nil {
+		log.Printf("Error creating docker client: %v\n", err)
+		return
+	}
+
+	if failed := restartContainers(ctx, cli, targets); len(failed) > 0 {
+		log.Printf("Restart incomplete, not confirmed running: %s\n", strings.Join(failed, ", "))
+	}
+}
+
+// restartContainers restarts every container matching targets except this one and
+// returns the names of those that could not be confirmed running
+func restartContainers(ctx context.Context, cli dockerAPI, targets []string) []string {
+	var containers []types.Container
+	err := withBackoff(ctx, RestartAttempts, func() error {
+		var err error
+		containers, err = cli.ContainerList(ctx, types.ContainerListOptions{})
+		return err
+	})
+	if err != nil {
+		log.Printf("Error listing containers: %v\n", err)
+		return targets
+	}
+
+	myHostname, _ := os.Hostname()
+	var failed []string
+	for _, c := range containers {
+		if strings.HasPrefix(c.ID, myHostname) || strings.HasPrefix(myHostname, c.ID) {
+			continue
+		}
+		if !isRestartTarget(c, targets) {
+			continue
+		}
+
+		name := c.Names[0]
+		log.Printf("Restarting container: %s\n", name)
+		if err := withBackoff(ctx, RestartAttempts, func() error { return restartContainer(ctx, cli, c.ID) }); err != nil {
+			log.Printf("Container %s did not restart: %v\n", name, err)
+			failed = append(failed, name)
+		}
+	}
+	return failed
+}
+
+func isRestartTarget(c types.Container, targets []string) bool {
+	for _, name := range c.Names {
+		for _, target := range targets {
+			if strings.Contains(name, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// restartContainer issues the restart and waits until Docker reports the
+// container running again with a start time after the request
+func restartContainer(ctx context.Context, cli dockerAPI, id string) error {
+	requested := time.Now()
+	timeout := restartStopTimeout
+	if err := cli.ContainerRestart(ctx, id, container.StopOptions{Timeout: &timeout}); err != nil {
+		return err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(restartStopTimeout)*time.Second+20*time.Second)
+	defer cancel()
+	for {
+		info, err := cli.ContainerInspect(waitCtx, id)
+		if err == nil && info.State != nil && info.State.Running && !info.State.Restarting {
+			started, perr := time.Parse(time.RFC3339Nano, info.State.StartedAt)
+			if perr != nil || !started.Before(requested.Add(-time.Second)) {
+				return nil
+			}
+		}
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("not running after restart: %w", waitCtx.Err())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// withBackoff runs fn until it succeeds, attempts are used up or ctx ends,
+// doubling the pause between tries
+func withBackoff(ctx context.Context, attempts int, fn func() error) error {
+	backoff := restartInitialBackoff
+	var err error
+	for i := 0; i < max(attempts, 1); i++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, restartMaxBackoff)
+	}
+	return err
+}
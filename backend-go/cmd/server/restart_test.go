@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// fastBackoff shrinks the pause between restart attempts
+func fastBackoff(t *testing.T) {
+	t.Helper()
+	prevInitial, prevMax := restartInitialBackoff, restartMaxBackoff
+	restartInitialBackoff, restartMaxBackoff = time.Millisecond, 4*time.Millisecond
+	t.Cleanup(func() { restartInitialBackoff, restartMaxBackoff = prevInitial, prevMax })
+}
+
+// fakeDocker fails the first failRestarts restarts of each container and
+// reports containers listed in stuck as never running again
+type fakeDocker struct {
+	mu           sync.Mutex
+	containers   []types.Container
+	failRestarts int
+	stuck        map[string]bool
+	restarts     map[string]int
+	started      map[string]time.Time
+}
+
+func (d *fakeDocker) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	return d.containers, nil
+}
+
+func (d *fakeDocker) ContainerRestart(ctx context.Context, id string, options container.StopOptions) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.restarts[id]++
+	if d.restarts[id] <= d.failRestarts {
+		return errors.New("daemon busy")
+	}
+	d.started[id] = time.Now()
+	return nil
+}
+
+func (d *fakeDocker) ContainerInspect(ctx context.Context, id string) (types.ContainerJSON, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state := &types.ContainerState{Running: !d.stuck[id], StartedAt: d.started[id].Format(time.RFC3339Nano)}
+	return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{State: state}}, nil
+}
+
+func newFakeDocker(names ...string) *fakeDocker {
+	d := &fakeDocker{stuck: make(map[string]bool), restarts: make(map[string]int), started: make(map[string]time.Time)}
+	for _, name := range names {
+		d.containers = append(d.containers, types.Container{ID: "id-" + name, Names: []string{"/nvr-" + name + "-1"}})
+	}
+	return d
+}
+
+func TestWithBackoff(t *testing.T) {
+	fastBackoff(t)
+
+	calls := 0
+	err := withBackoff(context.Background(), 5, func() error {
+		if calls++; calls < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("succeeding on the third try: err %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = withBackoff(context.Background(), 4, func() error { calls++; return errors.New("down") })
+	if err == nil || calls != 4 {
+		t.Errorf("always failing: err %v after %d calls, want 4", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	withBackoff(ctx, 10, func() error { calls++; return errors.New("down") })
+	if calls != 1 {
+		t.Errorf("cancelled context: %d calls, want 1", calls)
+	}
+}
+
+func TestRestartContainersRetries(t *testing.T) {
+	fastBackoff(t)
+	docker := newFakeDocker("mediamtx", "motion-detector", "db")
+	docker.failRestarts = 2
+
+	failed := restartContainers(context.Background(), docker, []string{"mediamtx"})
+	if len(failed) != 0 {
+		t.Errorf("failed = %v", failed)
+	}
+	if docker.restarts["id-mediamtx"] != 3 {
+		t.Errorf("mediamtx restarted %d times, want 3 (two failures, then success)", docker.restarts["id-mediamtx"])
+	}
+	if docker.restarts["id-motion-detector"] != 0 || docker.restarts["id-db"] != 0 {
+		t.Errorf("untargeted containers restarted: %v", docker.restarts)
+	}
+}
+
+func TestRestartContainersReportsStuck(t *testing.T) {
+	fastBackoff(t)
+	docker := newFakeDocker("mediamtx", "motion-detector")
+	docker.stuck["id-motion-detector"] = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	failed := restartContainers(ctx, docker, []string{"mediamtx", "motion-detector"})
+	if len(failed) != 1 || failed[0] != "/nvr-motion-detector-1" {
+		t.Errorf("failed = %v, want only the stuck container", failed)
+	}
+}
+
+func TestNormalizeRestartServices(t *testing.T) {
+	cases := []struct {
+		in      []string
+		want    []string
+		unknown string
+	}{
+		{nil, []string{"ai", "backend", "mediamtx"}, ""},
+		{[]string{" MediaMTX ", "mediamtx"}, []string{"mediamtx"}, ""},
+		{[]string{"backend", "ai"}, []string{"ai", "backend"}, ""},
+		{[]string{"ai", "postgres"}, nil, "postgres"},
+	}
+	for _, tc := range cases {
+		got, unknown := normalizeRestartServices(tc.in)
+		if !reflect.DeepEqual(got, tc.want) || unknown != tc.unknown {
+			t.Errorf("%v = %v, %q; want %v, %q", tc.in, got, unknown, tc.want, tc.unknown)
+		}
+	}
+
+	// The backend restarts by exiting, not through Docker
+	if got := restartTargetsFor([]string{"ai", "backend", "mediamtx"}); !reflect.DeepEqual(got, []string{"motion-detector", "mediamtx"}) {
+		t.Errorf("targets = %v", got)
+	}
+}
+
+func TestRestartSystemRejectsUnknownService(t *testing.T) {
+	rec := callHandler(restartSystem, http.MethodPost, "/api/system/restart", `{"services":["ai","postgres"]}`, nil)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "postgres") {
+		t.Errorf("status %d, body %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+type sessionPage struct {
+	Items    []models.UserSession `json:"items"`
+	Total    int64                `json:"total"`
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"page_size"`
+}
+
+func TestGetSessionsPagedAndActiveOnly(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+	other := createTestUser(t, "other@example.com", false)
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		expires := now.Add(time.Hour)
+		if i < 2 {
+			expires = now.Add(-time.Hour)
+		}
+		database.DB.Create(&models.UserSession{
+			JTI:        fmt.Sprintf("s%d", i),
+			UserID:     user.ID,
+			LastUsedAt: now.Add(time.Duration(i) * time.Minute),
+			ExpiresAt:  expires,
+		})
+	}
+	database.DB.Create(&models.UserSession{JTI: "other", UserID: other.ID, ExpiresAt: now.Add(time.Hour)})
+
+	var page sessionPage
+	rec := callHandler(getSessions, http.MethodGet, "/api/sessions?page=2&page_size=2", "", user)
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	if page.Total != 5 || page.Page != 2 || page.PageSize != 2 || len(page.Items) != 2 {
+		t.Errorf("page 2 = total %d, page %d, size %d, %d items", page.Total, page.Page, page.PageSize, len(page.Items))
+	}
+	// Most recently used first: page 2 holds s2 and s1
+	if len(page.Items) == 2 && (page.Items[0].JTI != "s2" || page.Items[1].JTI != "s1") {
+		t.Errorf("page 2 = %s, %s; want s2, s1", page.Items[0].JTI, page.Items[1].JTI)
+	}
+
+	rec = callHandler(getSessions, http.MethodGet, "/api/sessions?active=true&page=1&page_size=10", "", user)
+	json.Unmarshal(rec.Body.Bytes(), &page)
+	if page.Total != 3 || len(page.Items) != 3 {
+		t.Fatalf("active sessions: total %d, %d items; want 3", page.Total, len(page.Items))
+	}
+	for _, s := range page.Items {
+		if s.UserID != user.ID || !s.ExpiresAt.After(now) {
+			t.Errorf("active list returned %+v", s)
+		}
+	}
+
+	// Without page params the response stays a bare array
+	var all []models.UserSession
+	rec = callHandler(getSessions, http.MethodGet, "/api/sessions", "", user)
+	if err := json.Unmarshal(rec.Body.Bytes(), &all); err != nil || len(all) != 5 {
+		t.Errorf("unpaged list: %d sessions, err %v", len(all), err)
+	}
+}
+
+func TestLoginEvictsLeastRecentlyUsedSession(t *testing.T) {
+	testDB(t)
+	testSecrets(t)
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true, MaxSessionsPerUser: 3})
+	user := createTestUser(t, "user@example.com", false)
+	other := createTestUser(t, "other@example.com", false)
+
+	now := time.Now()
+	sessions := []models.UserSession{
+		{JTI: "expired", UserID: user.ID, LastUsedAt: now, ExpiresAt: now.Add(-time.Minute)},
+		{JTI: "stale", UserID: user.ID, LastUsedAt: now.Add(-3 * time.Hour), ExpiresAt: now.Add(time.Hour)},
+		{JTI: "recent", UserID: user.ID, LastUsedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)},
+		{JTI: "current", UserID: user.ID, LastUsedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{JTI: "theirs", UserID: other.ID, LastUsedAt: now.Add(-9 * time.Hour), ExpiresAt: now.Add(time.Hour)},
+	}
+	for i := range sessions {
+		database.DB.Create(&sessions[i])
+	}
+
+	c, rec := handlerContext(http.MethodPost, "/token", "", nil)
+	if err := generateTokens(c, user); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("generateTokens: %v, status %d", err, rec.Code)
+	}
+
+	var jtis []string
+	database.DB.Model(&models.UserSession{}).Where("user_id = ?", user.ID).Order("jti").Pluck("jti", &jtis)
+	if len(jtis) != 3 {
+		t.Fatalf("user holds %d sessions after login, want the cap of 3: %v", len(jtis), jtis)
+	}
+	for _, gone := range []string{"expired", "stale"} {
+		for _, jti := range jtis {
+			if jti == gone {
+				t.Errorf("session %q kept: %v", gone, jtis)
+			}
+		}
+	}
+
+	var n int64
+	database.DB.Model(&models.UserSession{}).Where("jti = ?", "theirs").Count(&n)
+	if n != 1 {
+		t.Error("another user's session evicted")
+	}
+}
+
+func TestEvictExcessSessionsUnlimited(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+	for i := 0; i < 5; i++ {
+		database.DB.Create(&models.UserSession{JTI: fmt.Sprintf("s%d", i), UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)})
+	}
+
+	evictExcessSessions(user.ID, 0)
+	var n int64
+	database.DB.Model(&models.UserSession{}).Where("user_id = ?", user.ID).Count(&n)
+	if n != 5 {
+		t.Errorf("limit 0 left %d of 5 sessions", n)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestUpdateSystemSettingsRejectsBadValues(t *testing.T) {
+	admin := &models.User{ID: 1, IsAdmin: true}
+	for _, body := range []string{
+		`{"event_capacity_policy":"drop"}`,
+		`{"event_capacity_policy":""}`,
+		`{"retention_rules":"[{\"weekdays\":[\"someday\"],\"days\":3}]"}`,
+	} {
+		if rec := callHandler(updateSystemSettings, http.MethodPut, "/api/system/settings", body, admin); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+func TestUpdateSystemSettingsPartial(t *testing.T) {
+	testDB(t)
+	admin := createTestUser(t, "admin@example.com", true)
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true, RetentionDays: 90, MaxCamerasPerUser: 4})
+
+	for _, body := range []string{`{"max_cameras_per_user":10}`, `{"maintenance_mode":false}`} {
+		rec := callHandler(updateSystemSettings, http.MethodPut, "/api/system/settings", body, admin)
+		var settings models.SystemSettings
+		if err := json.Unmarshal(rec.Body.Bytes(), &settings); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, body %s", body, rec.Code, rec.Body)
+		}
+		if settings.RetentionDays != 90 {
+			t.Errorf("%s: retention changed to %d days", body, settings.RetentionDays)
+		}
+	}
+
+	var stored models.SystemSettings
+	database.DB.First(&stored)
+	if stored.RetentionDays != 90 || stored.MaxCamerasPerUser != 10 {
+		t.Errorf("stored settings = %+v", stored)
+	}
+
+	callHandler(updateSystemSettings, http.MethodPut, "/api/system/settings", `{"retention_days":14}`, admin)
+	database.DB.First(&stored)
+	if stored.RetentionDays != 14 || stored.MaxCamerasPerUser != 10 {
+		t.Errorf("after setting retention: %+v", stored)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/config"
+)
+
+// How long a pre-signed media URL stays valid
+var SignedURLTTL = config.Duration("NVR_SIGNED_URL_TTL", 5*time.Minute)
+
+// MediaSigningKey signs media URLs. It comes from the media_signing_key secret
+// or, without one, is derived from the JWT secret; it is never the JWT secret
+// itself, so a media signature can't be turned into anything a token check accepts.
+var MediaSigningKey []byte
+
+func loadMediaSigningKey() {
+	if content, err := os.ReadFile("/run/secrets/media_signing_key"); err == nil {
+		if key := strings.TrimSpace(string(content)); key != "" {
+			MediaSigningKey = []byte(key)
+			return
+		}
+	}
+	MediaSigningKey = deriveKey("media url signing")
+}
+
+// mediaSignature binds a recording path to an expiry using MediaSigningKey
+func mediaSignature(path string, expires int64) string {
+	mac := hmac.New(sha256.New, MediaSigningKey)
+	fmt.Fprintf(mac, "%s|%d", path, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signMediaURL returns a URL that serves path without a bearer token until expiresAt.
+// It is relative unless PublicBaseURL is configured.
+func signMediaURL(path string, expiresAt time.Time) string {
+	exp := expiresAt.Unix()
+	q := url.Values{}
+	q.Set("path", path)
+	q.Set("exp", strconv.FormatInt(exp, 10))
+	q.Set("sig", mediaSignature(path, exp))
+	return publicURL("/api/media?" + q.Encode())
+}
+
+// publicURL prefixes an absolute path with the configured PublicBaseURL, if any
+func publicURL(path string) string {
+	if base := loadSettings().PublicBaseURL; base != "" {
+		return strings.TrimSuffix(base, "/") + path
+	}
+	return path
+}
+
+// normalizePublicBaseURL validates a PublicBaseURL setting: empty, or an http(s)
+// URL with a host and no query or fragment. The trailing slash is dropped.
+func normalizePublicBaseURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("public_base_url must be an absolute http(s) URL")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("public_base_url cannot have a query or fragment")
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// verifyMediaSignature checks the signature and expiry of a signed media request
+func verifyMediaSignature(path, expStr, sig string, now time.Time) bool {
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil || now.Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(mediaSignature(path, exp)))
+}
+
+// serveSignedMedia is the public counterpart of downloadFile for pre-signed URLs
+func serveSignedMedia(c echo.Context) error {
+	path := c.QueryParam("path")
+	if !verifyMediaSignature(path, c.QueryParam("exp"), c.QueryParam("sig"), time.Now()) {
+		return c.JSON(http.StatusForbidden, map[string]string{"detail": "Invalid or expired link"})
+	}
+	if strings.Contains(path, "..") || strings.HasPrefix(path, "/") {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid path")
+	}
+	return serveMediaFile(c, path)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+	"nvr-server/internal/notify"
+)
+
+// testSecrets installs a known JWT secret and the keys derived from it
+func testSecrets(t *testing.T) {
+	t.Helper()
+	prevJWT, prevMedia := JwtSecret, MediaSigningKey
+	JwtSecret = []byte("test-jwt-secret")
+	MediaSigningKey = deriveKey("media url signing")
+	t.Cleanup(func() { JwtSecret, MediaSigningKey = prevJWT, prevMedia })
+}
+
+func TestMediaSignatureVerifiesAndExpires(t *testing.T) {
+	testSecrets(t)
+	now := time.Now()
+	path := "recordings/event_1_20240101-080000.mp4"
+	exp := now.Add(SignedURLTTL).Unix()
+	expStr := strconv.FormatInt(exp, 10)
+	sig := mediaSignature(path, exp)
+
+	if !verifyMediaSignature(path, expStr, sig, now) {
+		t.Fatal("fresh signature rejected")
+	}
+	if verifyMediaSignature(path, expStr, sig, now.Add(SignedURLTTL+time.Second)) {
+		t.Error("signature accepted after it expired")
+	}
+	if verifyMediaSignature("recordings/event_2_20240101-080000.mp4", expStr, sig, now) {
+		t.Error("signature accepted for another path")
+	}
+	if verifyMediaSignature(path, strconv.FormatInt(exp+3600, 10), sig, now) {
+		t.Error("signature accepted with an extended expiry")
+	}
+	if verifyMediaSignature(path, "soon", sig, now) {
+		t.Error("non-numeric expiry accepted")
+	}
+}
+
+func TestMediaSigningKeyIsNotTheJWTSecret(t *testing.T) {
+	testSecrets(t)
+	if hmac.Equal(MediaSigningKey, JwtSecret) {
+		t.Fatal("media URLs are signed with the JWT secret")
+	}
+	mac := hmac.New(sha256.New, JwtSecret)
+	fmt.Fprintf(mac, "%s|%d", "recordings/a.mp4", int64(1))
+	if mediaSignature("recordings/a.mp4", 1) == hex.EncodeToString(mac.Sum(nil)) {
+		t.Error("media signature is an HMAC under the JWT secret")
+	}
+}
+
+func TestServeSignedMediaRejects(t *testing.T) {
+	testSecrets(t)
+	past := time.Now().Add(-time.Minute).Unix()
+	future := time.Now().Add(time.Minute).Unix()
+	query := func(path string, exp int64, sig string) string {
+		q := url.Values{"path": {path}, "exp": {strconv.FormatInt(exp, 10)}, "sig": {sig}}
+		return "/api/media?" + q.Encode()
+	}
+
+	cases := map[string]int{
+		query("recordings/a.mp4", past, mediaSignature("recordings/a.mp4", past)):                           http.StatusForbidden,
+		query("recordings/a.mp4", future, "deadbeef"):                                                       http.StatusForbidden,
+		query("recordings/../../etc/passwd", future, mediaSignature("recordings/../../etc/passwd", future)): http.StatusBadRequest,
+		query("/etc/passwd", future, mediaSignature("/etc/passwd", future)):                                 http.StatusBadRequest,
+	}
+	for target, want := range cases {
+		if rec := callHandler(serveSignedMedia, http.MethodGet, target, "", nil); rec.Code != want {
+			t.Errorf("%s: status %d, want %d", target, rec.Code, want)
+		}
+	}
+}
+
+func TestGetEventSignsItsMedia(t *testing.T) {
+	testDB(t)
+	testSecrets(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	event := &models.Event{
+		CameraID:      cam.ID,
+		UserID:        user.ID,
+		StartTime:     time.Now(),
+		VideoPath:     "recordings/event_1_000.mp4",
+		Parts:         `["recordings/event_1_000.mp4","recordings/event_1_001.mp4"]`,
+		ThumbnailPath: "recordings/event_1.jpg",
+	}
+	database.DB.Create(event)
+
+	rec := callHandler(getEvent, http.MethodGet, "/", "", user, "id", strconv.Itoa(int(event.ID)))
+	var detail EventDetail
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	urls := append([]string{detail.VideoURL, detail.ThumbnailURL}, detail.PartURLs...)
+	if len(urls) != 4 || detail.SnapshotURL != "" {
+		t.Fatalf("signed URLs = %+v", detail)
+	}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Path != "/api/media" {
+			t.Fatalf("bad media URL %q", raw)
+		}
+		q := u.Query()
+		if !verifyMediaSignature(q.Get("path"), q.Get("exp"), q.Get("sig"), time.Now()) {
+			t.Errorf("%s does not verify", raw)
+		}
+		if verifyMediaSignature(q.Get("path"), q.Get("exp"), q.Get("sig"), detail.URLsExpireAt.Add(time.Second)) {
+			t.Errorf("%s still valid after urls_expire_at", raw)
+		}
+	}
+
+	other := createTestUser(t, "other@example.com", false)
+	if rec := callHandler(getEvent, http.MethodGet, "/", "", other, "id", strconv.Itoa(int(event.ID))); rec.Code != http.StatusNotFound {
+		t.Errorf("another user's event: status %d, want 404", rec.Code)
+	}
+}
+
+func TestNormalizePublicBaseURL(t *testing.T) {
+	cases := map[string]string{
+		"":                              "",
+		"  ":                            "",
+		"https://nvr.example.com/":      "https://nvr.example.com",
+		" http://192.0.2.5:3000 ":       "http://192.0.2.5:3000",
+		"https://example.com/nvr/":      "https://example.com/nvr",
+		"nvr.example.com":               "!",
+		"ftp://nvr.example.com":         "!",
+		"https://":                      "!",
+		"https://nvr.example.com/?a=1":  "!",
+		"https://nvr.example.com/#home": "!",
+	}
+	for raw, want := range cases {
+		got, err := normalizePublicBaseURL(raw)
+		if (err != nil) != (want == "!") || (err == nil && got != want) {
+			t.Errorf("%q = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+}
+
+func TestPublicBaseURLInLinks(t *testing.T) {
+	testDB(t)
+	testSecrets(t)
+
+	var delivered notify.Notification
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&delivered)
+	}))
+	defer hook.Close()
+
+	settings := models.SystemSettings{AllowRegistration: true, NotifyWebhookURL: hook.URL}
+	database.DB.Create(&settings)
+	if u := signMediaURL("recordings/a.mp4", time.Now().Add(time.Hour)); !strings.HasPrefix(u, "/api/media?") {
+		t.Errorf("without a base URL: %s", u)
+	}
+	notify.Send(notify.Notification{Kind: "test", Link: "/?event=7"})
+	if delivered.URL != "" {
+		t.Errorf("notification URL without a base = %q", delivered.URL)
+	}
+
+	database.DB.Model(&settings).Update("public_base_url", "https://nvr.example.com")
+	if u := signMediaURL("recordings/a.mp4", time.Now().Add(time.Hour)); !strings.HasPrefix(u, "https://nvr.example.com/api/media?") {
+		t.Errorf("with a base URL: %s", u)
+	}
+	notify.Send(notify.Notification{Kind: "test", Link: "/?event=7"})
+	if delivered.URL != "https://nvr.example.com/?event=7" {
+		t.Errorf("notification URL = %q", delivered.URL)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+)
+
+// SubstreamIssue is a camera whose substream features are running on the main stream
+type SubstreamIssue struct {
+	CameraID uint     `json:"camera_id"`
+	Name     string   `json:"name"`
+	Warnings []string `json:"warnings"`
+}
+
+// annotateSubstream fills in each camera's substream warnings for the response
+func annotateSubstream(cameras []models.Camera) {
+	for i := range cameras {
+		cameras[i].Warnings = detector.SubstreamWarnings(cameras[i])
+	}
+}
+
+// substreamIssues lists the owner's cameras configured to use a substream they don't have
+func substreamIssues(ownerID uint) []SubstreamIssue {
+	var cameras []models.Camera
+	database.DB.Where("owner_id = ? AND (rtsp_substream_url = '' OR rtsp_substream_url IS NULL)", ownerID).Order("display_order asc").Find(&cameras)
+
+	issues := make([]SubstreamIssue, 0)
+	for _, cam := range cameras {
+		if warnings := detector.SubstreamWarnings(cam); len(warnings) > 0 {
+			issues = append(issues, SubstreamIssue{CameraID: cam.ID, Name: cam.Name, Warnings: warnings})
+		}
+	}
+	return issues
+}
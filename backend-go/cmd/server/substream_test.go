@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"nvr-server/internal/database"
+)
+
+func TestSubstreamIssues(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+	missing := createTestCamera(t, user, "missing")
+	database.DB.Model(missing).Update("motion_type", "active")
+	withSub := createTestCamera(t, user, "with-sub")
+	database.DB.Model(withSub).Updates(map[string]interface{}{"motion_type": "active", "rtsp_substream_url": "rtsp://192.0.2.1/sub"})
+	createTestCamera(t, user, "no-features")
+	other := createTestCamera(t, createTestUser(t, "other@example.com", false), "other")
+	database.DB.Model(other).Update("motion_type", "active")
+
+	issues := substreamIssues(user.ID)
+	if len(issues) != 1 || issues[0].CameraID != missing.ID || len(issues[0].Warnings) != 1 {
+		t.Errorf("issues = %+v, want only %q", issues, missing.Name)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/mediamtx"
+)
+
+// testPathPrefix marks throwaway MediaMTX paths created by testConnection
+const testPathPrefix = "test_"
+
+var (
+	// How long a test path lives before being removed from MediaMTX
+	TestPathTTL = config.Duration("NVR_TEST_PATH_TTL", 60*time.Second)
+
+	// How long testConnection waits for MediaMTX to report the source ready
+	TestPathReadyTimeout = config.Duration("NVR_TEST_PATH_READY_TIMEOUT", 8*time.Second)
+
+	testPathsMu sync.Mutex
+	testPaths   = make(map[string]*time.Timer)
+)
+
+// scheduleTestPathCleanup tracks a test path and deletes it once TestPathTTL elapses
+func scheduleTestPathCleanup(name string) {
+	testPathsMu.Lock()
+	defer testPathsMu.Unlock()
+
+	testPaths[name] = time.AfterFunc(TestPathTTL, func() {
+		testPathsMu.Lock()
+		delete(testPaths, name)
+		testPathsMu.Unlock()
+
+		if err := mediamtx.DeletePath(name); err != nil {
+			log.Printf("Test path %s cleanup failed: %v\n", name, err)
+		}
+	})
+}
+
+// discardTestPath removes a test path right away instead of waiting for its TTL
+func discardTestPath(name string) {
+	testPathsMu.Lock()
+	if timer, ok := testPaths[name]; ok {
+		timer.Stop()
+		delete(testPaths, name)
+	}
+	testPathsMu.Unlock()
+
+	if err := mediamtx.DeletePath(name); err != nil {
+		log.Printf("Test path %s cleanup failed: %v\n", name, err)
+	}
+}
+
+// waitTestPathReady polls MediaMTX until the path's source is ready, the
+// timeout passes or ctx ends. MediaMTX accepts any source on add, so this is
+// the only way to tell a working camera from one that will never connect.
+func waitTestPathReady(ctx context.Context, name string) bool {
+	ctx, cancel := context.WithTimeout(ctx, TestPathReadyTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if stats, err := mediamtx.GetPath(ctx, name); err == nil && stats.Ready {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanupPendingTestPaths deletes test paths whose timers have not fired yet (used on shutdown)
+func cleanupPendingTestPaths() {
+	testPathsMu.Lock()
+	pending := make([]string, 0, len(testPaths))
+	for name, timer := range testPaths {
+		if timer.Stop() {
+			pending = append(pending, name)
+		}
+		delete(testPaths, name)
+	}
+	testPathsMu.Unlock()
+
+	for _, name := range pending {
+		mediamtx.DeletePath(name)
+	}
+}
+
+// reconcileTestPaths removes test paths left behind by a previous run.
+// Nothing from before startup can still be in use, so any test_* path is stale.
+// MediaMTX may still be booting, so retry with backoff before giving up.
+func reconcileTestPaths() {
+	backoff := 2 * time.Second
+	for attempt := 0; attempt < 6; attempt++ {
+		paths, err := mediamtx.ListPathConfigs()
+		if err != nil {
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+
+		removed := 0
+		for _, p := range paths {
+			if !strings.HasPrefix(p.Name, testPathPrefix) || isTrackedTestPath(p.Name) {
+				continue
+			}
+			if err := mediamtx.DeletePath(p.Name); err == nil {
+				removed++
+			}
+		}
+		if removed > 0 {
+			log.Printf("Removed %d stale test paths from MediaMTX\n", removed)
+		}
+		return
+	}
+	log.Println("Test path reconciliation skipped: MediaMTX unreachable")
+}
+
+func isTrackedTestPath(name string) bool {
+	testPathsMu.Lock()
+	defer testPathsMu.Unlock()
+	_, ok := testPaths[name]
+	return ok
+}
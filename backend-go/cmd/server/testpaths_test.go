@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nvr-server/internal/mediamtx"
+)
+
+// fakeMediaMTX is a stub of the MediaMTX API holding path configs in memory.
+// Paths listed in ready report a connected source; with readyOnAdd every
+// added path connects at once.
+type fakeMediaMTX struct {
+	mu         sync.Mutex
+	paths      map[string]bool
+	ready      map[string]bool
+	readyOnAdd bool
+	added      []string
+	deleted    []string
+}
+
+// newFakeMediaMTX starts the stub and points the mediamtx client at it
+func newFakeMediaMTX(t *testing.T, paths ...string) *fakeMediaMTX {
+	t.Helper()
+	f := &fakeMediaMTX{paths: make(map[string]bool), ready: make(map[string]bool)}
+	for _, p := range paths {
+		f.paths[p] = true
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	prev := mediamtx.APIBase
+	mediamtx.APIBase = srv.URL
+	t.Cleanup(func() {
+		mediamtx.APIBase = prev
+		srv.Close()
+	})
+	return f
+}
+
+func (f *fakeMediaMTX) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := r.URL.Path
+	switch {
+	case path == "/v3/config/paths/list":
+		items := make([]mediamtx.PathConfig, 0)
+		for _, name := range f.sortedPaths() {
+			items = append(items, mediamtx.PathConfig{Name: name})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"pageCount": 1, "items": items})
+	case path == "/v3/paths/list":
+		items := make([]mediamtx.PathStats, 0)
+		for _, name := range f.sortedPaths() {
+			items = append(items, mediamtx.PathStats{Name: name, Ready: f.ready[name]})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"pageCount": 1, "items": items})
+	case strings.HasPrefix(path, "/v3/paths/get/"):
+		name := strings.TrimPrefix(path, "/v3/paths/get/")
+		if !f.paths[name] || !f.ready[name] {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(mediamtx.PathStats{Name: name, Ready: true})
+	case strings.HasPrefix(path, "/v3/config/paths/add/"):
+		name := strings.TrimPrefix(path, "/v3/config/paths/add/")
+		f.paths[name] = true
+		f.ready[name] = f.readyOnAdd
+		f.added = append(f.added, name)
+	case strings.HasPrefix(path, "/v3/config/paths/delete/"):
+		name := strings.TrimPrefix(path, "/v3/config/paths/delete/")
+		if !f.paths[name] {
+			http.NotFound(w, r)
+			return
+		}
+		delete(f.paths, name)
+		f.deleted = append(f.deleted, name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeMediaMTX) sortedPaths() []string {
+	names := make([]string, 0, len(f.paths))
+	for name := range f.paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *fakeMediaMTX) has(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.paths[name]
+}
+
+func TestReconcileTestPathsRemovesStalePaths(t *testing.T) {
+	fake := newFakeMediaMTX(t, "cam_front", "test_stale1", "test_stale2", "test_live")
+
+	testPathsMu.Lock()
+	testPaths["test_live"] = time.AfterFunc(time.Hour, func() {})
+	testPathsMu.Unlock()
+	t.Cleanup(func() {
+		testPathsMu.Lock()
+		testPaths["test_live"].Stop()
+		delete(testPaths, "test_live")
+		testPathsMu.Unlock()
+	})
+
+	reconcileTestPaths()
+
+	if fake.has("test_stale1") || fake.has("test_stale2") {
+		t.Errorf("stale test paths survived: %v", fake.sortedPaths())
+	}
+	if !fake.has("cam_front") {
+		t.Error("camera path was removed")
+	}
+	if !fake.has("test_live") {
+		t.Error("test path still tracked by this run was removed")
+	}
+}
+
+func TestTestPathCleanupAfterTTL(t *testing.T) {
+	fake := newFakeMediaMTX(t, "test_ttl")
+
+	prev := TestPathTTL
+	TestPathTTL = 20 * time.Millisecond
+	t.Cleanup(func() { TestPathTTL = prev })
+
+	scheduleTestPathCleanup("test_ttl")
+	if !isTrackedTestPath("test_ttl") {
+		t.Fatal("scheduled path is not tracked")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for fake.has("test_ttl") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if fake.has("test_ttl") {
+		t.Fatal("test path not deleted after its TTL")
+	}
+	if isTrackedTestPath("test_ttl") {
+		t.Error("deleted path is still tracked")
+	}
+}
+
+func TestCleanupPendingTestPaths(t *testing.T) {
+	fake := newFakeMediaMTX(t, "test_pending")
+	scheduleTestPathCleanup("test_pending")
+
+	cleanupPendingTestPaths()
+
+	if fake.has("test_pending") || isTrackedTestPath("test_pending") {
+		t.Error("pending test path left behind on shutdown")
+	}
+}
+
+func TestTestConnectionWaitsForReady(t *testing.T) {
+	fake := newFakeMediaMTX(t)
+	t.Cleanup(cleanupPendingTestPaths)
+	prev := TestPathReadyTimeout
+	TestPathReadyTimeout = 300 * time.Millisecond
+	t.Cleanup(func() { TestPathReadyTimeout = prev })
+	body := `{"rtsp_url":"rtsp://192.0.2.10/live"}`
+
+	// MediaMTX accepts the path but the source never connects
+	rec := callHandler(testConnection, http.MethodPost, "/api/cameras/test", body, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("never-ready source: status %d, body %s", rec.Code, rec.Body)
+	}
+	fake.mu.Lock()
+	added, deleted := fake.added, fake.deleted
+	fake.mu.Unlock()
+	if len(added) != 1 || len(deleted) != 1 || deleted[0] != added[0] {
+		t.Errorf("dead test path not removed: added %v, deleted %v", added, deleted)
+	}
+
+	fake.mu.Lock()
+	fake.readyOnAdd = true
+	fake.mu.Unlock()
+	rec = callHandler(testConnection, http.MethodPost, "/api/cameras/test", body, nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), testPathPrefix) {
+		t.Errorf("ready source: status %d, body %s", rec.Code, rec.Body)
+	}
+}
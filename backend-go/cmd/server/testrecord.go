@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/detector"
+)
+
+const (
+	defaultTestRecordSeconds = 10
+	maxTestRecordSeconds     = 30
+)
+
+// testRecord records a short clip from the camera and returns it as a download.
+// The clip lives in a temp file that is removed once it has been served.
+func testRecord(c echo.Context) error {
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+
+	seconds := defaultTestRecordSeconds
+	if s := c.QueryParam("seconds"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxTestRecordSeconds {
+			return c.JSON(http.StatusBadRequest, map[string]string{"detail": fmt.Sprintf("seconds must be between 1 and %d", maxTestRecordSeconds)})
+		}
+		seconds = n
+	}
+
+	tmpDir, err := os.MkdirTemp("", "test-record-")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "Could not create temp file"})
+	}
+	defer os.RemoveAll(tmpDir)
+
+	outPath := filepath.Join(tmpDir, "clip.mp4")
+	ctx, cancel := context.WithTimeout(c.Request().Context(), time.Duration(seconds)*time.Second+20*time.Second)
+	defer cancel()
+
+	if err := detector.RecordClip(ctx, *cam, seconds, outPath); err != nil {
+		return c.JSON(http.StatusBadGateway, map[string]string{"detail": "Recording failed: " + err.Error()})
+	}
+
+	filename := fmt.Sprintf("test_%d_%s.mp4", cam.ID, time.Now().Format("20060102-150405"))
+	return c.Attachment(outPath, filename)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"nvr-server/internal/detector"
+)
+
+// useFFmpeg points detector.FFmpegBin at a shell script for the rest of the test
+func useFFmpeg(t *testing.T, script string) {
+	t.Helper()
+	stub := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(stub, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	prev := detector.FFmpegBin
+	detector.FFmpegBin = stub
+	t.Cleanup(func() { detector.FFmpegBin = prev })
+}
+
+func TestTestRecordServesAndCleansUp(t *testing.T) {
+	testDB(t)
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	argsFile := filepath.Join(t.TempDir(), "args")
+	useFFmpeg(t, `echo "$@" > `+argsFile+`
+for out; do :; done
+printf fake-mp4 > "$out"`)
+
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	id := strconv.Itoa(int(cam.ID))
+
+	rec := callHandler(testRecord, http.MethodPost, "/?seconds=4", "", user, "id", id)
+	if rec.Code != http.StatusOK || rec.Body.String() != "fake-mp4" {
+		t.Fatalf("status %d, body %q", rec.Code, rec.Body)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "attachment") || !strings.Contains(cd, ".mp4") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	args, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(args), " -t 4 ") {
+		t.Errorf("ffmpeg args %q lack -t 4", args)
+	}
+	if left, _ := os.ReadDir(tmp); len(left) != 0 {
+		t.Errorf("temp files left behind: %v", left)
+	}
+
+	for _, bad := range []string{"0", "31", "ten"} {
+		if rec := callHandler(testRecord, http.MethodPost, "/?seconds="+bad, "", user, "id", id); rec.Code != http.StatusBadRequest {
+			t.Errorf("seconds=%s: status %d, want 400", bad, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+)
+
+const (
+	defaultTimelineWindow = 24 * time.Hour
+	maxTimelineWindow     = 7 * 24 * time.Hour
+)
+
+type TimelineEvent struct {
+	ID              uint      `json:"id"`
+	StartTime       time.Time `json:"start_time"`
+	EndTime         time.Time `json:"end_time"`
+	Reason          string    `json:"reason"`
+	DetectedClasses string    `json:"detected_classes"`
+	InProgress      bool      `json:"in_progress"`
+}
+
+// CameraTimeline is one camera's row in the multi-camera timeline
+type CameraTimeline struct {
+	CameraID   uint            `json:"camera_id"`
+	CameraName string          `json:"camera_name"`
+	Continuous []detector.Span `json:"continuous"`
+	Events     []TimelineEvent `json:"events"`
+}
+
+type Timeline struct {
+	Start   time.Time        `json:"start"`
+	End     time.Time        `json:"end"`
+	Cameras []CameraTimeline `json:"cameras"`
+}
+
+// getRecordingsTimeline merges continuous footage and events for every camera
+// the caller owns between start_ts and end_ts (RFC3339; defaults to the last
+// 24 hours, at most 7 days)
+func getRecordingsTimeline(c echo.Context) error {
+	end := time.Now().UTC()
+	if raw := c.QueryParam("end_ts"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid end_ts"})
+		}
+		end = t.UTC()
+	}
+	start := end.Add(-defaultTimelineWindow)
+	if raw := c.QueryParam("start_ts"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"detail": "Invalid start_ts"})
+		}
+		start = t.UTC()
+	}
+	if !end.After(start) || end.Sub(start) > maxTimelineWindow {
+		return c.JSON(http.StatusBadRequest, map[string]string{"detail": fmt.Sprintf("end_ts must be after start_ts and at most %s later", maxTimelineWindow)})
+	}
+
+	user := getUser(c)
+	var cameras []models.Camera
+	if err := database.DB.Select("id, name").Where("owner_id = ?", user.ID).Order("display_order asc").Find(&cameras).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+
+	// Events overlapping the window; an unset end time means still recording
+	var events []models.Event
+	database.DB.Select("id, camera_id, start_time, end_time, reason, detected_classes").
+		Where("user_id = ? AND video_path <> '' AND start_time < ?", user.ID, end).
+		Where("end_time >= ? OR end_time <= start_time", start).
+		Order("start_time asc").
+		Find(&events)
+	byCamera := make(map[uint][]TimelineEvent)
+	for _, ev := range events {
+		byCamera[ev.CameraID] = append(byCamera[ev.CameraID], TimelineEvent{
+			ID:              ev.ID,
+			StartTime:       ev.StartTime,
+			EndTime:         ev.EndTime,
+			Reason:          ev.Reason,
+			DetectedClasses: ev.DetectedClasses,
+			InProgress:      !ev.EndTime.After(ev.StartTime),
+		})
+	}
+
+	timeline := Timeline{Start: start, End: end, Cameras: make([]CameraTimeline, 0, len(cameras))}
+	for _, cam := range cameras {
+		row := CameraTimeline{
+			CameraID:   cam.ID,
+			CameraName: cam.Name,
+			Continuous: detector.ContinuousSpans(cam.ID, start, end),
+			Events:     byCamera[cam.ID],
+		}
+		if row.Events == nil {
+			row.Events = make([]TimelineEvent, 0)
+		}
+		timeline.Cameras = append(timeline.Cameras, row)
+	}
+	return c.JSON(http.StatusOK, timeline)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+)
+
+func TestGetRecordingsTimeline(t *testing.T) {
+	testDB(t)
+	testRecordingRoots(t)
+	user := createTestUser(t, "user@example.com", false)
+	other := createTestUser(t, "other@example.com", false)
+	front := createTestCamera(t, user, "front")
+	back := createTestCamera(t, user, "back")
+	theirs := createTestCamera(t, other, "theirs")
+
+	base := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	segment := filepath.Join(detector.ContinuousDir(front.ID), "20240102-120000.mp4")
+	writeFile(t, segment, "video")
+	os.Chtimes(segment, base.Add(30*time.Minute), base.Add(30*time.Minute))
+
+	event := func(cam *models.Camera, start, end time.Time, video string) uint {
+		e := models.Event{CameraID: cam.ID, UserID: cam.OwnerID, StartTime: start, EndTime: end, VideoPath: video, Reason: models.ReasonMotion}
+		database.DB.Create(&e)
+		return e.ID
+	}
+	inWindow := event(front, base, base.Add(time.Minute), "recordings/a.mp4")
+	recording := event(back, base.Add(time.Hour), time.Time{}, "recordings/b.mp4")
+	event(front, base.Add(-5*time.Hour), base.Add(-5*time.Hour+time.Minute), "recordings/old.mp4")
+	event(front, base, base.Add(time.Minute), "")
+	event(theirs, base, base.Add(time.Minute), "recordings/c.mp4")
+
+	rec := callHandler(getRecordingsTimeline, http.MethodGet, "/api/recordings/timeline?start_ts=2024-01-02T10:00:00Z&end_ts=2024-01-02T14:00:00Z", "", user)
+	var timeline Timeline
+	if err := json.Unmarshal(rec.Body.Bytes(), &timeline); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	if len(timeline.Cameras) != 2 {
+		t.Fatalf("%d camera rows, want the caller's 2", len(timeline.Cameras))
+	}
+	rows := make(map[uint]CameraTimeline)
+	for _, row := range timeline.Cameras {
+		rows[row.CameraID] = row
+	}
+
+	f := rows[front.ID]
+	if len(f.Events) != 1 || f.Events[0].ID != inWindow || f.Events[0].InProgress {
+		t.Errorf("front events = %+v", f.Events)
+	}
+	if len(f.Continuous) != 1 || !f.Continuous[0].Start.Equal(base) || !f.Continuous[0].End.Equal(base.Add(30*time.Minute)) {
+		t.Errorf("front continuous = %+v", f.Continuous)
+	}
+	b := rows[back.ID]
+	if len(b.Events) != 1 || b.Events[0].ID != recording || !b.Events[0].InProgress || len(b.Continuous) != 0 {
+		t.Errorf("back row = %+v", b)
+	}
+
+	for _, query := range []string{"?start_ts=noon", "?end_ts=2024-01-02T10:00:00Z&start_ts=2024-01-02T11:00:00Z", "?start_ts=2024-01-01T00:00:00Z&end_ts=2024-01-20T00:00:00Z"} {
+		if rec := callHandler(getRecordingsTimeline, http.MethodGet, "/api/recordings/timeline"+query, "", user); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/config"
+)
+
+// DefaultRequestTimeout bounds how long a handler may run before the client gets a 504
+var DefaultRequestTimeout = config.Duration("NVR_REQUEST_TIMEOUT", 30*time.Second)
+
+// routeTimeouts overrides DefaultRequestTimeout per route pattern; 0 disables the
+// limit for endpoints that legitimately stream or upload for a long time
+var routeTimeouts = map[string]time.Duration{
+	"/recordings/*":                      0,
+	"/api/media":                         0,
+	"/api/download":                      0,
+	"/api/events/export.csv":             0,
+	"/api/exports/:id/download":          0,
+	"/api/cameras/:id/recordings/import": 0,
+	"/api/cameras/:id/test-record":       time.Duration(maxTestRecordSeconds)*time.Second + 30*time.Second,
+	"/api/system/verify-recordings":      30 * time.Minute,
+}
+
+// requestTimeout puts a deadline on the request context. ffmpeg and MediaMTX calls
+// made with that context are cancelled when it expires. Whatever else the handler
+// is stuck in, the client gets a 504 at the deadline if no response has started;
+// the handler's late writes are dropped.
+func requestTimeout(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		timeout, ok := routeTimeouts[c.Path()]
+		if !ok {
+			timeout = DefaultRequestTimeout
+		}
+		if timeout <= 0 {
+			return next(c)
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+		defer cancel()
+		c.SetRequest(c.Request().WithContext(ctx))
+
+		res := c.Response()
+		orig := res.Writer
+		tw := &deadlineWriter{ResponseWriter: orig, header: orig.Header().Clone()}
+		res.Writer = tw
+
+		done := make(chan handlerResult, 1)
+		go func() {
+			defer func() {
+				// Re-raised below so the Recover middleware still sees it
+				if p := recover(); p != nil {
+					done <- handlerResult{panicked: p}
+				}
+			}()
+			done <- handlerResult{err: next(c)}
+		}()
+
+		var result handlerResult
+		select {
+		case result = <-done:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) && tw.timeOut() {
+				orig.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+				orig.Header().Set(echo.HeaderConnection, "close")
+				orig.WriteHeader(http.StatusGatewayTimeout)
+				json.NewEncoder(orig).Encode(map[string]string{"detail": "Request timed out"})
+				if f, ok := orig.(http.Flusher); ok {
+					f.Flush()
+				}
+			}
+			// The echo.Context goes back to the pool when we return, so the
+			// handler must be done with it first; the client isn't kept waiting
+			result = <-done
+		}
+		res.Writer = orig
+
+		if result.panicked != nil {
+			panic(result.panicked)
+		}
+		if tw.timedOut {
+			res.Status, res.Committed = http.StatusGatewayTimeout, true
+			return nil
+		}
+		return result.err
+	}
+}
+
+type handlerResult struct {
+	err      error
+	panicked interface{}
+}
+
+// deadlineWriter passes the handler's response through until requestTimeout
+// claims it for a 504. The handler gets its own header map so it can't race
+// with the 504 being written.
+type deadlineWriter struct {
+	http.ResponseWriter
+	header http.Header
+
+	mu       sync.Mutex
+	started  bool
+	timedOut bool
+}
+
+func (w *deadlineWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *deadlineWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.started {
+		return
+	}
+	w.start()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *deadlineWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !w.started {
+		w.start()
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *deadlineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *deadlineWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// start copies the handler's headers to the real response. Callers hold w.mu.
+func (w *deadlineWriter) start() {
+	w.started = true
+	dst := w.ResponseWriter.Header()
+	clear(dst)
+	for k, v := range w.header {
+		dst[k] = v
+	}
+}
+
+// timeOut claims the response for the 504, unless the handler already started one
+func (w *deadlineWriter) timeOut() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.started {
+		return false
+	}
+	w.timedOut = true
+	return true
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	prev := DefaultRequestTimeout
+	DefaultRequestTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { DefaultRequestTimeout = prev })
+
+	release := make(chan struct{})
+
+	e := echo.New()
+	e.Use(requestTimeout)
+	e.GET("/fast", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"ok": "yes"})
+	})
+	// Ignores its context entirely: only the middleware can answer in time
+	e.GET("/stuck", func(c echo.Context) error {
+		select {
+		case <-release:
+		case <-time.After(2 * time.Second):
+		}
+		return c.JSON(http.StatusOK, map[string]string{"late": "yes"})
+	})
+	e.GET("/streaming", func(c echo.Context) error {
+		c.Response().WriteHeader(http.StatusOK)
+		c.Response().Flush()
+		time.Sleep(150 * time.Millisecond)
+		_, err := c.Response().Write([]byte("rest"))
+		return err
+	})
+	// Matches the "/recordings/*" override, which has no limit
+	e.GET("/recordings/*", func(c echo.Context) error {
+		time.Sleep(150 * time.Millisecond)
+		return c.String(http.StatusOK, "clip")
+	})
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+	defer close(release)
+
+	get := func(path string) (*http.Response, time.Duration) {
+		start := time.Now()
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		return res, time.Since(start)
+	}
+
+	if res, _ := get("/fast"); res.StatusCode != http.StatusOK {
+		t.Errorf("fast handler: status %d", res.StatusCode)
+	}
+
+	res, elapsed := get("/stuck")
+	var body map[string]string
+	json.NewDecoder(res.Body).Decode(&body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusGatewayTimeout || body["detail"] != "Request timed out" {
+		t.Errorf("stuck handler: status %d, body %v", res.StatusCode, body)
+	}
+	if elapsed > time.Second {
+		t.Errorf("504 took %v; the client waited for the handler", elapsed)
+	}
+
+	// Once the response has started it is left to finish
+	if res, _ := get("/streaming"); res.StatusCode != http.StatusOK {
+		t.Errorf("started response: status %d, want 200", res.StatusCode)
+	}
+	if res, _ := get("/recordings/event_1.mp4"); res.StatusCode != http.StatusOK {
+		t.Errorf("route without a limit: status %d, want 200", res.StatusCode)
+	}
+}
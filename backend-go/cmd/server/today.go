@@ -0,0 +1,128 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// SummaryLocation decides where "today" starts and ends (NVR_TIMEZONE, e.g.
+// "America/Chicago"; defaults to the server's local zone)
+var SummaryLocation = loadSummaryLocation()
+
+func loadSummaryLocation() *time.Location {
+	name := config.String("NVR_TIMEZONE", "")
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Unknown NVR_TIMEZONE %q, using local time\n", name)
+		return time.Local
+	}
+	return loc
+}
+
+type CameraEventCount struct {
+	CameraID   uint   `json:"camera_id"`
+	CameraName string `json:"camera_name"`
+	Count      int    `json:"count"`
+}
+
+type HourCount struct {
+	Hour  int `json:"hour"`
+	Count int `json:"count"`
+}
+
+type LatestEventSummary struct {
+	ID           uint      `json:"id"`
+	CameraID     uint      `json:"camera_id"`
+	StartTime    time.Time `json:"start_time"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+}
+
+type TodaySummary struct {
+	Date        string              `json:"date"`
+	Timezone    string              `json:"timezone"`
+	Total       int                 `json:"total"`
+	PerCamera   []CameraEventCount  `json:"per_camera"`
+	BusiestHour *HourCount          `json:"busiest_hour"`
+	LatestEvent *LatestEventSummary `json:"latest_event"`
+}
+
+// getEventsToday aggregates the caller's events since midnight in SummaryLocation
+func getEventsToday(c echo.Context) error {
+	userID := getUser(c).ID
+	now := time.Now().In(SummaryLocation)
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, SummaryLocation)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	type row struct {
+		CameraID   uint
+		CameraName string
+		StartTime  time.Time
+	}
+	var rows []row
+	err := database.DB.Model(&models.Event{}).
+		Select("events.camera_id, cameras.name AS camera_name, events.start_time").
+		Joins("LEFT JOIN cameras ON cameras.id = events.camera_id").
+		Where("events.user_id = ? AND events.start_time >= ? AND events.start_time < ?", userID, dayStart, dayEnd).
+		Scan(&rows).Error
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+
+	summary := TodaySummary{
+		Date:      dayStart.Format("2006-01-02"),
+		Timezone:  SummaryLocation.String(),
+		Total:     len(rows),
+		PerCamera: make([]CameraEventCount, 0),
+	}
+
+	perCamera := make(map[uint]*CameraEventCount)
+	var hours [24]int
+	for _, r := range rows {
+		cc, ok := perCamera[r.CameraID]
+		if !ok {
+			cc = &CameraEventCount{CameraID: r.CameraID, CameraName: r.CameraName}
+			perCamera[r.CameraID] = cc
+		}
+		cc.Count++
+		hours[r.StartTime.In(SummaryLocation).Hour()]++
+	}
+	for _, cc := range perCamera {
+		summary.PerCamera = append(summary.PerCamera, *cc)
+	}
+	sort.Slice(summary.PerCamera, func(i, j int) bool {
+		if summary.PerCamera[i].Count != summary.PerCamera[j].Count {
+			return summary.PerCamera[i].Count > summary.PerCamera[j].Count
+		}
+		return summary.PerCamera[i].CameraID < summary.PerCamera[j].CameraID
+	})
+
+	for h, n := range hours {
+		if n > 0 && (summary.BusiestHour == nil || n > summary.BusiestHour.Count) {
+			summary.BusiestHour = &HourCount{Hour: h, Count: n}
+		}
+	}
+
+	var latest models.Event
+	err = database.DB.Select("id, camera_id, start_time, thumbnail_path").
+		Where("user_id = ? AND start_time >= ? AND start_time < ?", userID, dayStart, dayEnd).
+		Order("start_time desc").First(&latest).Error
+	if err == nil {
+		summary.LatestEvent = &LatestEventSummary{ID: latest.ID, CameraID: latest.CameraID, StartTime: latest.StartTime}
+		if latest.ThumbnailPath != "" {
+			summary.LatestEvent.ThumbnailURL = signMediaURL(latest.ThumbnailPath, time.Now().Add(SignedURLTTL))
+		}
+	}
+
+	return c.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestGetEventsToday(t *testing.T) {
+	testDB(t)
+	testSecrets(t)
+	prev := SummaryLocation
+	SummaryLocation = time.FixedZone("UTC-5", -5*60*60)
+	t.Cleanup(func() { SummaryLocation = prev })
+
+	user := createTestUser(t, "user@example.com", false)
+	other := createTestUser(t, "other@example.com", false)
+	front := createTestCamera(t, user, "front")
+	back := createTestCamera(t, user, "back")
+	theirs := createTestCamera(t, other, "theirs")
+
+	now := time.Now().In(SummaryLocation)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, SummaryLocation)
+	at := func(h, m int) time.Time {
+		return midnight.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	}
+	events := []models.Event{
+		{CameraID: back.ID, UserID: user.ID, StartTime: at(2, 0)},
+		{CameraID: back.ID, UserID: user.ID, StartTime: at(9, 5)},
+		{CameraID: back.ID, UserID: user.ID, StartTime: at(9, 40)},
+		{CameraID: front.ID, UserID: user.ID, StartTime: at(23, 30), ThumbnailPath: "recordings/latest.jpg"},
+		// Yesterday in the summary zone, though it may be today in UTC
+		{CameraID: front.ID, UserID: user.ID, StartTime: at(-1, 0)},
+		{CameraID: theirs.ID, UserID: other.ID, StartTime: at(9, 10)},
+	}
+	for i := range events {
+		database.DB.Create(&events[i])
+	}
+
+	var summary TodaySummary
+	rec := callHandler(getEventsToday, http.MethodGet, "/api/events/today", "", user)
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+
+	if summary.Date != midnight.Format("2006-01-02") || summary.Timezone != "UTC-5" || summary.Total != 4 {
+		t.Errorf("summary = date %s, zone %s, total %d", summary.Date, summary.Timezone, summary.Total)
+	}
+	want := []CameraEventCount{{back.ID, "back", 3}, {front.ID, "front", 1}}
+	if len(summary.PerCamera) != 2 || summary.PerCamera[0] != want[0] || summary.PerCamera[1] != want[1] {
+		t.Errorf("per_camera = %+v, want %+v", summary.PerCamera, want)
+	}
+	if summary.BusiestHour == nil || *summary.BusiestHour != (HourCount{Hour: 9, Count: 2}) {
+		t.Errorf("busiest_hour = %+v, want 9:00 with 2", summary.BusiestHour)
+	}
+	if l := summary.LatestEvent; l == nil || l.ID != events[3].ID || l.ThumbnailURL == "" {
+		t.Errorf("latest_event = %+v", l)
+	}
+}
+
+func TestGetEventsTodayEmpty(t *testing.T) {
+	testDB(t)
+	user := createTestUser(t, "user@example.com", false)
+
+	rec := callHandler(getEventsToday, http.MethodGet, "/api/events/today", "", user)
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	if string(body["total"]) != "0" || string(body["per_camera"]) != "[]" || string(body["busiest_hour"]) != "null" || string(body["latest_event"]) != "null" {
+		t.Errorf("empty day = %s", rec.Body)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/detector"
+	"nvr-server/internal/models"
+	"nvr-server/internal/secrets"
+)
+
+const maxCameraNameLength = 64
+
+var streamSchemes = map[string]bool{"rtsp": true, "rtsps": true, "http": true, "https": true}
+
+var motionTypes = map[string]bool{"": true, "off": true, "webhook": true, "active": true}
+
+// Motion sensitivity is a percentage
+const (
+	minMotionSensitivity = 0
+	maxMotionSensitivity = 100
+)
+
+// normalizeMotionConfig clamps the sensitivity to 0-100 (150 becomes 100) and
+// reports whether the motion type is one the AI worker understands
+func normalizeMotionConfig(cam *models.Camera) bool {
+	cam.MotionSensitivity = max(minMotionSensitivity, min(cam.MotionSensitivity, maxMotionSensitivity))
+	return motionTypes[cam.MotionType]
+}
+
+// validateCamera checks a camera before it is persisted and returns field -> problem
+// for every invalid field (empty when the camera is fine). Name is trimmed in place.
+func validateCamera(cam *models.Camera) map[string]string {
+	errs := make(map[string]string)
+
+	cam.Name = strings.TrimSpace(cam.Name)
+	switch {
+	case cam.Name == "":
+		errs["name"] = "is required"
+	case utf8.RuneCountInString(cam.Name) > maxCameraNameLength:
+		errs["name"] = fmt.Sprintf("must be at most %d characters", maxCameraNameLength)
+	}
+
+	if cam.RTSPUrl == "" {
+		errs["rtsp_url"] = "is required"
+	} else if msg := checkStreamURL(cam.RTSPUrl); msg != "" {
+		errs["rtsp_url"] = msg
+	}
+	if cam.RTSPSubstreamUrl != "" {
+		if msg := checkStreamURL(cam.RTSPSubstreamUrl); msg != "" {
+			errs["rtsp_substream_url"] = msg
+		}
+	}
+
+	if cam.MotionSensitivity < minMotionSensitivity || cam.MotionSensitivity > maxMotionSensitivity {
+		errs["motion_sensitivity"] = "must be between 0 and 100"
+	}
+	if !normalizeMotionConfig(cam) {
+		errs["motion_type"] = "must be off, webhook or active"
+	}
+	if !detector.ParseROI(cam.MotionROI).Valid() {
+		errs["motion_roi"] = "contains malformed or out-of-range cells"
+	}
+	if !detector.ParseROI(cam.PrivacyMask).Valid() {
+		errs["privacy_mask"] = "contains malformed or out-of-range cells"
+	}
+	if !detector.ValidSegmentFormat(cam.SegmentFormat) {
+		errs["segment_format"] = "must be mp4, fmp4 or mkv"
+	}
+	if cam.RetentionDays < 0 {
+		errs["retention_days"] = "cannot be negative"
+	}
+	return errs
+}
+
+// checkStreamURL returns why a stream URL is unusable, or "" if it looks fine
+func checkStreamURL(raw string) string {
+	masked, _ := secrets.Mask(raw)
+	u, err := url.Parse(masked)
+	if err != nil {
+		return "is not a valid URL"
+	}
+	if !streamSchemes[strings.ToLower(u.Scheme)] {
+		return "must start with rtsp://, rtsps://, http:// or https://"
+	}
+	if u.Hostname() == "" {
+		return "is missing a host"
+	}
+	if err := secrets.Validate(raw); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// findDuplicateStream returns another of the owner's cameras that pulls the same
+// stream (compared via detector.StreamIdentity), or nil
+func findDuplicateStream(cam *models.Camera) *models.Camera {
+	id := detector.StreamIdentity(cam.RTSPUrl)
+	if id == "" {
+		return nil
+	}
+	var others []models.Camera
+	database.DB.Where("owner_id = ? AND id <> ?", cam.OwnerID, cam.ID).Find(&others)
+	for i := range others {
+		if detector.StreamIdentity(others[i].RTSPUrl) == id {
+			return &others[i]
+		}
+	}
+	return nil
+}
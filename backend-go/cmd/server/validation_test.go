@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestValidateCamera(t *testing.T) {
+	valid := models.Camera{Name: " Front door ", RTSPUrl: "rtsp://192.0.2.10/live", MotionSensitivity: 50}
+	if errs := validateCamera(&valid); len(errs) != 0 || valid.Name != "Front door" {
+		t.Errorf("valid camera: %v, name %q", errs, valid.Name)
+	}
+
+	bad := models.Camera{
+		Name:              strings.Repeat("x", maxCameraNameLength+1),
+		RTSPUrl:           "ftp://192.0.2.10/live",
+		RTSPSubstreamUrl:  "rtsp:///nohost",
+		MotionSensitivity: -5,
+		MotionType:        "psychic",
+		MotionROI:         "not-a-grid",
+		SegmentFormat:     "avi",
+		RetentionDays:     -1,
+	}
+	errs := validateCamera(&bad)
+	for _, field := range []string{"name", "rtsp_url", "rtsp_substream_url", "motion_sensitivity", "motion_type", "motion_roi", "segment_format", "retention_days"} {
+		if errs[field] == "" {
+			t.Errorf("%s not reported: %v", field, errs)
+		}
+	}
+
+	errs = validateCamera(&models.Camera{Name: "   "})
+	if errs["name"] != "is required" || errs["rtsp_url"] != "is required" {
+		t.Errorf("blank camera: %v", errs)
+	}
+}
+
+func TestCreateCameraValidation(t *testing.T) {
+	testDB(t)
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true})
+	user := createTestUser(t, "user@example.com", false)
+
+	rec := callHandler(createCamera, http.MethodPost, "/api/cameras", `{"name":"","rtsp_url":"nope","motion_sensitivity":150}`, user)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	for _, field := range []string{`"name"`, `"rtsp_url"`, `"motion_sensitivity"`} {
+		if !strings.Contains(rec.Body.String(), field) {
+			t.Errorf("%s missing from %s", field, rec.Body)
+		}
+	}
+
+	var n int64
+	database.DB.Model(&models.Camera{}).Count(&n)
+	if n != 0 {
+		t.Errorf("%d cameras persisted after a rejected create", n)
+	}
+}
+
+func TestNormalizeMotionConfig(t *testing.T) {
+	cases := []struct {
+		sensitivity, want int
+		motionType        string
+		ok                bool
+	}{
+		{50, 50, "active", true},
+		{150, 100, "webhook", true},
+		{-20, 0, "off", true},
+		{30, 30, "", true},
+		{30, 30, "psychic", false},
+		{30, 30, "Active", false},
+	}
+	for _, tc := range cases {
+		cam := models.Camera{MotionSensitivity: tc.sensitivity, MotionType: tc.motionType}
+		if ok := normalizeMotionConfig(&cam); ok != tc.ok || cam.MotionSensitivity != tc.want {
+			t.Errorf("%d/%q = %d, %v; want %d, %v", tc.sensitivity, tc.motionType, cam.MotionSensitivity, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestMotionConfigOnSaveAndInternalList(t *testing.T) {
+	testDB(t)
+	testSecrets(t)
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	id := strconv.Itoa(int(cam.ID))
+
+	rec := callHandler(updateCamera, http.MethodPut, "/", `{"motion_sensitivity":150,"motion_type":"active"}`, user, "id", id)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"motion_sensitivity":100`) {
+		t.Errorf("out-of-range sensitivity on update: status %d, body %s", rec.Code, rec.Body)
+	}
+	if rec := callHandler(updateCamera, http.MethodPut, "/", `{"motion_type":"psychic"}`, user, "id", id); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown motion type on update: status %d", rec.Code)
+	}
+
+	// Rows written before validation existed are sanitized on the way to the AI worker
+	database.DB.Model(cam).UpdateColumns(map[string]interface{}{"motion_sensitivity": -5, "motion_type": "psychic"})
+	var cameras []InternalCamera
+	rec = callHandler(getAllCameras, http.MethodGet, "/api/internal/cameras", "", nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &cameras); err != nil || len(cameras) != 1 {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	if cameras[0].MotionSensitivity != 0 || cameras[0].MotionType != "off" {
+		t.Errorf("internal camera motion = %d/%q, want 0/off", cameras[0].MotionSensitivity, cameras[0].MotionType)
+	}
+}
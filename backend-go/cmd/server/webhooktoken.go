@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+const webhookTokenPrefix = "whk_"
+
+// InternalCamera is a camera as sent to the AI worker, with the token its
+// motion webhooks must carry. The token is only sent to a caller that presented
+// the internal secret.
+type InternalCamera struct {
+	models.Camera
+	WebhookToken string `json:"webhook_token,omitempty"`
+}
+
+type CameraWithWebhookToken struct {
+	models.Camera
+	WebhookToken string         `json:"webhook_token"`
+	DuplicateOf  *models.Camera `json:"duplicate_of,omitempty"`
+}
+
+// newWebhookToken returns a fresh plaintext token and the hash to store for it
+func newWebhookToken() (string, string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	raw := webhookTokenPrefix + hex.EncodeToString(buf)
+	return raw, hashApiToken(raw), nil
+}
+
+// workerWebhookToken is the token the AI worker presents for a camera. Only the
+// hash of the user's token is stored, so the worker gets one derived from it
+// instead; rotating the camera's token changes both.
+func workerWebhookToken(cam models.Camera) string {
+	mac := hmac.New(sha256.New, deriveKey("webhook worker token"))
+	fmt.Fprintf(mac, "%d:%s", cam.ID, cam.WebhookTokenHash)
+	return webhookTokenPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookTokenValid reports whether raw is the camera's token or its worker token
+func webhookTokenValid(cam models.Camera, raw string) bool {
+	if raw == "" || cam.WebhookTokenHash == "" {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(hashApiToken(raw)), []byte(cam.WebhookTokenHash)) == 1 {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(raw), []byte(workerWebhookToken(cam))) == 1
+}
+
+// webhookAuth checks the per-camera token (X-Webhook-Token header or ?token=) on
+// motion webhooks, so a token issued for one camera can't trigger another
+func webhookAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var cam models.Camera
+		if err := database.DB.Select("id", "webhook_token_hash").First(&cam, c.Param("id")).Error; err != nil {
+			return notFound(c, "Camera")
+		}
+
+		raw := strings.TrimSpace(c.Request().Header.Get("X-Webhook-Token"))
+		if raw == "" {
+			raw = strings.TrimSpace(c.QueryParam("token"))
+		}
+		if !webhookTokenValid(cam, raw) {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"detail": "Invalid webhook token"})
+		}
+		return next(c)
+	}
+}
+
+// backfillWebhookTokens gives cameras created before webhook tokens existed a
+// token, so none is left open. The AI worker picks up its derived token from
+// /api/internal/cameras once the internal secret is set; other callers need the
+// user to rotate and copy one.
+func backfillWebhookTokens() {
+	var cameras []models.Camera
+	database.DB.Select("id").Where("webhook_token_hash = '' OR webhook_token_hash IS NULL").Find(&cameras)
+	for _, cam := range cameras {
+		_, hash, err := newWebhookToken()
+		if err != nil {
+			log.Printf("Could not generate a webhook token for camera %d: %v\n", cam.ID, err)
+			continue
+		}
+		database.DB.Model(&models.Camera{}).Where("id = ? AND (webhook_token_hash = '' OR webhook_token_hash IS NULL)", cam.ID).UpdateColumn("webhook_token_hash", hash)
+	}
+	if len(cameras) > 0 {
+		log.Printf("Issued webhook tokens to %d existing camera(s)\n", len(cameras))
+	}
+}
+
+// rotateWebhookToken issues a new token for the camera, invalidating the old one.
+// Like API tokens, the plaintext is only returned here.
+func rotateWebhookToken(c echo.Context) error {
+	cam, err := findOwnedCamera(c)
+	if err != nil {
+		return notFound(c, "Camera")
+	}
+
+	raw, hash, err := newWebhookToken()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "Could not generate token"})
+	}
+	if err := database.DB.Model(cam).UpdateColumn("webhook_token_hash", hash).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"detail": "DB Error"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"webhook_token": raw})
+}
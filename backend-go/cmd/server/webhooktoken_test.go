@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestWebhookTokenValid(t *testing.T) {
+	testSecrets(t)
+	rawA, hashA, _ := newWebhookToken()
+	rawB, hashB, _ := newWebhookToken()
+	camA := models.Camera{ID: 1, WebhookTokenHash: hashA}
+	camB := models.Camera{ID: 2, WebhookTokenHash: hashB}
+
+	if !webhookTokenValid(camA, rawA) || !webhookTokenValid(camA, workerWebhookToken(camA)) {
+		t.Error("camera A rejects its own tokens")
+	}
+	for _, raw := range []string{rawB, workerWebhookToken(camB), "", hashA} {
+		if webhookTokenValid(camA, raw) {
+			t.Errorf("camera A accepts %q", raw)
+		}
+	}
+	if webhookTokenValid(models.Camera{ID: 3}, "") {
+		t.Error("a camera without a token accepts an empty one")
+	}
+
+	// Rotating the user's token also changes the worker's
+	_, rotated, _ := newWebhookToken()
+	if webhookTokenValid(models.Camera{ID: 1, WebhookTokenHash: rotated}, workerWebhookToken(camA)) {
+		t.Error("worker token survived a rotation")
+	}
+}
+
+func TestWebhookAuth(t *testing.T) {
+	testDB(t)
+	testSecrets(t)
+	user := createTestUser(t, "user@example.com", false)
+	camA := createTestCamera(t, user, "a")
+	camB := createTestCamera(t, user, "b")
+	rawA, hashA, _ := newWebhookToken()
+	_, hashB, _ := newWebhookToken()
+	database.DB.Model(camA).UpdateColumn("webhook_token_hash", hashA)
+	database.DB.Model(camB).UpdateColumn("webhook_token_hash", hashB)
+
+	guarded := webhookAuth(okHandler)
+	call := func(cam *models.Camera, header, query string) int {
+		c, rec := handlerContext(http.MethodPost, "/?token="+query, "", nil, "id", strconv.Itoa(int(cam.ID)))
+		if header != "" {
+			c.Request().Header.Set("X-Webhook-Token", header)
+		}
+		serve(guarded, c)
+		return rec.Code
+	}
+
+	if code := call(camA, rawA, ""); code != http.StatusNoContent {
+		t.Errorf("camera A with its header token: status %d", code)
+	}
+	if code := call(camA, "", rawA); code != http.StatusNoContent {
+		t.Errorf("camera A with its query token: status %d", code)
+	}
+	if code := call(camB, rawA, ""); code != http.StatusUnauthorized {
+		t.Errorf("camera B with camera A's token: status %d, want 401", code)
+	}
+	if code := call(camB, "", ""); code != http.StatusUnauthorized {
+		t.Errorf("camera B without a token: status %d, want 401", code)
+	}
+	if code := call(&models.Camera{ID: 999999}, rawA, ""); code != http.StatusNotFound {
+		t.Errorf("unknown camera: status %d, want 404", code)
+	}
+}
+
+func TestInternalCamerasWebhookTokens(t *testing.T) {
+	testDB(t)
+	testSecrets(t)
+	prev := InternalSecret
+	t.Cleanup(func() { InternalSecret = prev })
+	user := createTestUser(t, "user@example.com", false)
+	cam := createTestCamera(t, user, "front")
+	_, hash, _ := newWebhookToken()
+	database.DB.Model(cam).UpdateColumn("webhook_token_hash", hash)
+	cam.WebhookTokenHash = hash
+
+	list := func(secret string) (int, []InternalCamera) {
+		c, rec := handlerContext(http.MethodGet, "/api/internal/cameras", "", nil)
+		if secret != "" {
+			c.Request().Header.Set("X-Internal-Secret", secret)
+		}
+		serve(internalAuth(getAllCameras), c)
+		var cameras []InternalCamera
+		json.Unmarshal(rec.Body.Bytes(), &cameras)
+		return rec.Code, cameras
+	}
+
+	// With no secret configured the route is open, so it hands out no tokens
+	InternalSecret = ""
+	code, cameras := list("")
+	if code != http.StatusOK || len(cameras) != 1 || cameras[0].WebhookToken != "" {
+		t.Errorf("unauthenticated list: status %d, cameras %+v", code, cameras)
+	}
+
+	InternalSecret = "s3cret"
+	if code, _ := list(""); code != http.StatusUnauthorized {
+		t.Errorf("missing secret: status %d, want 401", code)
+	}
+	code, cameras = list("s3cret")
+	if code != http.StatusOK || len(cameras) != 1 || cameras[0].WebhookToken != workerWebhookToken(*cam) {
+		t.Errorf("authenticated list: status %d, cameras %+v", code, cameras)
+	}
+}
@@ -28,18 +28,30 @@ func InitDB() {
 
 	// 2. Connect
 	var dbErr error
-	DB, dbErr = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// TranslateError maps driver errors (e.g. unique violations) to gorm.ErrDuplicatedKey
+	DB, dbErr = gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if dbErr != nil {
 		log.Fatal("Failed to connect to database: ", dbErr)
 	}
 
 	// 3. Auto-Migrate (Updates table schema if changed)
 	log.Println("--- DB: Running Auto-Migration ---")
+	Migrate()
+}
+
+// Migrate brings the schema on DB up to date
+func Migrate() {
 	DB.AutoMigrate(
 		&models.User{},
 		&models.Camera{},
 		&models.Event{},
+		&models.EventClass{},
 		&models.UserSession{},
 		&models.SystemSettings{},
+		&models.ApiToken{},
+		&models.BandwidthSample{},
+		&models.IdempotencyKey{},
+		&models.CameraChangeLog{},
 	)
+	backfillEventClasses()
 }
\ No newline at end of file
@@ -0,0 +1,24 @@
+package database
+
+import "log"
+
+// backfillEventClasses copies the JSON DetectedClasses of events recorded before
+// the event_classes table existed into it. Events that already have rows are
+// skipped, so this is a no-op after the first run.
+func backfillEventClasses() {
+	res := DB.Exec(`
+		INSERT INTO event_classes (event_id, class)
+		SELECT DISTINCT e.id, c.class
+		FROM events e
+		CROSS JOIN LATERAL jsonb_array_elements_text(e.detected_classes::jsonb) AS c(class)
+		WHERE e.detected_classes LIKE '[%'
+		  AND NOT EXISTS (SELECT 1 FROM event_classes ec WHERE ec.event_id = e.id)
+		ON CONFLICT DO NOTHING`)
+	if res.Error != nil {
+		log.Printf("--- DB: Event class backfill failed: %v ---\n", res.Error)
+		return
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("--- DB: Backfilled %d event classes ---\n", res.RowsAffected)
+	}
+}
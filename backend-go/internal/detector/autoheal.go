@@ -0,0 +1,147 @@
+package detector
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+	"nvr-server/internal/secrets"
+)
+
+// Minimum gap between rediscovery attempts for the same camera
+const healInterval = time.Minute
+
+// arpTable is the kernel neighbour table. It only lists cameras on the same L2
+// segment, i.e. when the backend runs with host networking.
+var arpTable = "/proc/net/arp"
+
+// ipResolver finds a camera's current IP from what was captured when it was added
+type ipResolver func(cam models.Camera) (string, bool)
+
+// ipResolvers are tried in order by healCameraIP
+var ipResolvers = []ipResolver{resolveByHostname, resolveByMAC}
+
+// resolveByHostname re-resolves the camera's DNS/mDNS name
+func resolveByHostname(cam models.Camera) (string, bool) {
+	if cam.DeviceHostname == "" {
+		return "", false
+	}
+	addrs, err := net.LookupHost(cam.DeviceHostname)
+	if err != nil || len(addrs) == 0 {
+		return "", false
+	}
+	return addrs[0], true
+}
+
+// resolveByMAC finds the camera's MAC address in the ARP table
+func resolveByMAC(cam models.Camera) (string, bool) {
+	if cam.DeviceMAC == "" {
+		return "", false
+	}
+	f, err := os.Open(arpTable)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	// IP address  HW type  Flags  HW address  Mask  Device
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 4 && strings.EqualFold(fields[3], cam.DeviceMAC) {
+			return fields[0], true
+		}
+	}
+	return "", false
+}
+
+// LookupMAC returns the MAC address the ARP table holds for an IP, if any.
+// createCamera calls it after a successful connection test to remember the device.
+func LookupMAC(host string) string {
+	f, err := os.Open(arpTable)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 4 && fields[0] == host && fields[3] != "00:00:00:00:00:00" {
+			return fields[3]
+		}
+	}
+	return ""
+}
+
+// StreamHost returns the host part of a stream URL
+func StreamHost(rawURL string) string {
+	masked, _ := secrets.Mask(rawURL)
+	u, err := url.Parse(masked)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// replaceHost swaps the host of a stream URL, keeping credentials, port and path
+func replaceHost(rawURL, newHost string) (string, error) {
+	masked, restore := secrets.Mask(rawURL)
+	u, err := url.Parse(masked)
+	if err != nil {
+		return "", err
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(newHost, port)
+	} else {
+		u.Host = newHost
+	}
+	return restore(u.String()), nil
+}
+
+// maybeHealCameraIP starts a rediscovery for an unreachable AutoHealIP camera,
+// at most once per healInterval. Callers hold m.mu.
+func (m *Manager) maybeHealCameraIP(cam models.Camera) {
+	if !cam.AutoHealIP || time.Since(m.lastHeal[cam.ID]) < healInterval {
+		return
+	}
+	m.lastHeal[cam.ID] = time.Now()
+	m.spawn(func() { m.healCameraIP(cam) })
+}
+
+// healCameraIP looks the camera up by its remembered identity and, if it now
+// answers on a different IP, rewrites its stream URLs to point there
+func (m *Manager) healCameraIP(cam models.Camera) {
+	oldHost := StreamHost(cam.RTSPUrl)
+	for _, resolve := range ipResolvers {
+		ip, ok := resolve(cam)
+		if !ok || ip == oldHost {
+			continue
+		}
+
+		newURL, err := replaceHost(cam.RTSPUrl, ip)
+		if err != nil || checkReachable(newURL) != nil {
+			continue
+		}
+
+		updates := map[string]interface{}{"rtsp_url": newURL}
+		if cam.RTSPSubstreamUrl != "" && StreamHost(cam.RTSPSubstreamUrl) == oldHost {
+			if sub, err := replaceHost(cam.RTSPSubstreamUrl, ip); err == nil {
+				updates["rtsp_substream_url"] = sub
+			}
+		}
+		if err := database.DB.Model(&models.Camera{}).Where("id = ?", cam.ID).Updates(updates).Error; err != nil {
+			return
+		}
+		log.Printf("[%s] Camera moved from %s to %s, stream URLs updated", cam.Name, oldHost, ip)
+		return
+	}
+}
@@ -0,0 +1,113 @@
+package detector
+
+import (
+	"log"
+	"time"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/database"
+	"nvr-server/internal/mediamtx"
+	"nvr-server/internal/models"
+)
+
+var (
+	// How often MediaMTX byte counters are sampled
+	BandwidthSampleInterval = config.Duration("NVR_BANDWIDTH_SAMPLE_INTERVAL", time.Minute)
+
+	// How long samples are kept before the janitor removes them
+	BandwidthRetention = 7 * 24 * time.Hour
+)
+
+func (m *Manager) bandwidthLoop() {
+	ticker := time.NewTicker(BandwidthSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.sampleBandwidth()
+		}
+	}
+}
+
+// sampleBandwidth stores the current MediaMTX counters for every camera path
+func (m *Manager) sampleBandwidth() {
+	stats, err := mediamtx.ListPaths()
+	if err != nil {
+		return
+	}
+
+	var cameras []models.Camera
+	if err := database.DB.Select("id, path").Find(&cameras).Error; err != nil {
+		return
+	}
+	byPath := make(map[string]uint, len(cameras))
+	for _, cam := range cameras {
+		byPath[cam.Path] = cam.ID
+	}
+
+	now := time.Now()
+	samples := make([]models.BandwidthSample, 0, len(stats))
+	for _, p := range stats {
+		if camID, ok := byPath[p.Name]; ok {
+			samples = append(samples, models.BandwidthSample{
+				CameraID:      camID,
+				Time:          now,
+				BytesReceived: p.BytesReceived,
+				BytesSent:     p.BytesSent,
+			})
+		}
+	}
+	if len(samples) > 0 {
+		database.DB.Create(&samples)
+	}
+}
+
+// pruneBandwidthSamples drops samples past BandwidthRetention
+func (m *Manager) pruneBandwidthSamples() {
+	res := database.DB.Where("time < ?", time.Now().Add(-BandwidthRetention)).Delete(&models.BandwidthSample{})
+	if res.Error == nil && res.RowsAffected > 0 {
+		log.Printf("Janitor: Pruned %d bandwidth samples\n", res.RowsAffected)
+	}
+}
+
+// BandwidthPoint is the transfer rate between two consecutive samples
+type BandwidthPoint struct {
+	Time      time.Time `json:"time"`
+	RxBytesPS float64   `json:"rx_bytes_per_sec"`
+	TxBytesPS float64   `json:"tx_bytes_per_sec"`
+}
+
+// BandwidthRates turns cumulative samples (oldest first) into rates plus byte totals.
+// A counter that goes backwards means MediaMTX restarted the path; that interval
+// counts from zero.
+func BandwidthRates(samples []models.BandwidthSample) ([]BandwidthPoint, uint64, uint64) {
+	points := make([]BandwidthPoint, 0, len(samples))
+	var totalRx, totalTx uint64
+
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		secs := cur.Time.Sub(prev.Time).Seconds()
+		if secs <= 0 {
+			continue
+		}
+
+		rx, tx := cur.BytesReceived, cur.BytesSent
+		if rx >= prev.BytesReceived {
+			rx -= prev.BytesReceived
+		}
+		if tx >= prev.BytesSent {
+			tx -= prev.BytesSent
+		}
+
+		totalRx += rx
+		totalTx += tx
+		points = append(points, BandwidthPoint{
+			Time:      cur.Time,
+			RxBytesPS: float64(rx) / secs,
+			TxBytesPS: float64(tx) / secs,
+		})
+	}
+	return points, totalRx, totalTx
+}
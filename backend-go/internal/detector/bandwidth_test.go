@@ -0,0 +1,93 @@
+package detector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/mediamtx"
+	"nvr-server/internal/models"
+)
+
+func TestBandwidthRates(t *testing.T) {
+	start := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	samples := []models.BandwidthSample{
+		{Time: start, BytesReceived: 1000, BytesSent: 0},
+		{Time: start.Add(10 * time.Second), BytesReceived: 6000, BytesSent: 2000},
+		{Time: start.Add(10 * time.Second), BytesReceived: 7000, BytesSent: 2000}, // same instant: skipped
+		{Time: start.Add(30 * time.Second), BytesReceived: 9000, BytesSent: 2000},
+		// MediaMTX restarted the path: the interval counts from zero
+		{Time: start.Add(40 * time.Second), BytesReceived: 500, BytesSent: 100},
+	}
+
+	points, rx, tx := BandwidthRates(samples)
+	want := []BandwidthPoint{
+		{start.Add(10 * time.Second), 500, 200},
+		{start.Add(30 * time.Second), 100, 0},
+		{start.Add(40 * time.Second), 50, 10},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("points = %+v", points)
+	}
+	for i := range want {
+		if !points[i].Time.Equal(want[i].Time) || points[i].RxBytesPS != want[i].RxBytesPS || points[i].TxBytesPS != want[i].TxBytesPS {
+			t.Errorf("point %d = %+v, want %+v", i, points[i], want[i])
+		}
+	}
+	if rx != 5000+2000+500 || tx != 2000+100 {
+		t.Errorf("totals = %d rx, %d tx", rx, tx)
+	}
+
+	if points, _, _ := BandwidthRates(samples[:1]); len(points) != 0 {
+		t.Errorf("a single sample produced %d points", len(points))
+	}
+}
+
+func TestSampleBandwidth(t *testing.T) {
+	testDB(t)
+	user := &models.User{Email: "user@example.com"}
+	database.DB.Create(user)
+	cam := &models.Camera{OwnerID: user.ID, Name: "front", Path: "user_1_front", RTSPUrl: "rtsp://192.0.2.1/front"}
+	database.DB.Create(cam)
+
+	// Every poll reports more bytes than the last
+	var received uint64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received += 4096
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"pageCount": 1,
+			"items": []mediamtx.PathStats{
+				{Name: cam.Path, BytesReceived: received, BytesSent: received / 2},
+				{Name: "not_a_camera", BytesReceived: 1},
+			},
+		})
+	}))
+	defer srv.Close()
+	prev := mediamtx.APIBase
+	mediamtx.APIBase = srv.URL
+	t.Cleanup(func() { mediamtx.APIBase = prev })
+
+	m := NewManager()
+	m.sampleBandwidth()
+	m.sampleBandwidth()
+
+	var samples []models.BandwidthSample
+	database.DB.Order("time asc, id asc").Find(&samples)
+	if len(samples) != 2 {
+		t.Fatalf("stored %d samples, want 2 (only the camera path)", len(samples))
+	}
+	for i, s := range samples {
+		if s.CameraID != cam.ID || s.BytesReceived != uint64(i+1)*4096 || s.BytesSent != uint64(i+1)*2048 {
+			t.Errorf("sample %d = %+v", i, s)
+		}
+	}
+
+	// Spread the samples a second apart to read back a rate
+	samples[1].Time = samples[0].Time.Add(time.Second)
+	if points, rx, _ := BandwidthRates(samples); len(points) != 1 || points[0].RxBytesPS != 4096 || rx != 4096 {
+		t.Errorf("rates = %+v, rx %d", points, rx)
+	}
+}
@@ -0,0 +1,105 @@
+package detector
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// StopCamera ends every recording process of a camera that is being deleted and
+// drops its cached state. SyncCameras only manages cameras that still exist, so
+// without this the processes would keep writing footage for a deleted camera.
+func (m *Manager) StopCamera(camID uint) {
+	m.mu.Lock()
+	var procs []*exec.Cmd
+	var logs []*RotatingLog
+	if proc, ok := m.ContinuousProcs[camID]; ok {
+		procs, logs = append(procs, proc.Process), append(logs, proc.LogFile)
+		delete(m.ContinuousProcs, camID)
+	}
+	if rec, ok := m.ActiveRecordings[camID]; ok {
+		procs, logs = append(procs, rec.Process), append(logs, rec.LogFile)
+		delete(m.ActiveRecordings, camID)
+	}
+	m.dequeueEvent(camID)
+	delete(m.RegisteredPaths, camID)
+	delete(m.ProbedURLs, camID)
+	delete(m.unreachable, camID)
+	delete(m.reachRetry, camID)
+	delete(m.noSpace, camID)
+	m.mu.Unlock()
+	m.startQueuedEvent()
+
+	// Wait briefly so nothing is still writing when the files are removed
+	for _, cmd := range procs {
+		if cmd == nil || cmd.Process == nil {
+			continue
+		}
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+		select {
+		case <-done:
+		case <-time.After(3 * time.Second):
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}
+	for _, l := range logs {
+		if l != nil {
+			l.Close()
+		}
+	}
+}
+
+// FileError is a file that could not be removed
+type FileError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// RemoveCameraFiles deletes a camera's event files (event_<id>_*) and its
+// continuous directory. It keeps going past failures and returns how many files
+// were removed and which could not be.
+func RemoveCameraFiles(camID uint) (int, []FileError) {
+	removed := 0
+	failed := make([]FileError, 0)
+	remove := func(path string) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			failed = append(failed, FileError{Path: LogicalPath(path), Error: err.Error()})
+			return
+		}
+		removed++
+	}
+
+	prefix := fmt.Sprintf("event_%d_", camID)
+	WalkEventFiles(func(path string, info os.FileInfo) {
+		if strings.HasPrefix(info.Name(), prefix) {
+			remove(path)
+		}
+	})
+
+	dir := ContinuousDir(camID)
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if !os.IsNotExist(err) {
+				failed = append(failed, FileError{Path: LogicalPath(path), Error: err.Error()})
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			remove(path)
+		}
+		return nil
+	})
+	if len(failed) > 0 {
+		log.Printf("Camera %d cleanup: removed %d files, %d could not be removed (first: %s: %s)\n", camID, removed, len(failed), failed[0].Path, failed[0].Error)
+	} else {
+		os.RemoveAll(dir)
+	}
+	return removed, failed
+}
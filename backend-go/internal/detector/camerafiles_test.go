@@ -0,0 +1,75 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveCameraFiles(t *testing.T) {
+	testRoots(t)
+	mine := []string{
+		filepath.Join(EventRoot, "event_1_20240102-120000.mp4"),
+		filepath.Join(EventRoot, "event_1_20240102-120000.jpg"),
+		filepath.Join(EventRoot, "2024", "01", "03", "event_1_20240103-120000.mp4"),
+		filepath.Join(ContinuousDir(1), "20240102-120000.mp4"),
+		filepath.Join(ContinuousDir(1), "2024", "01", "02", "20240102-130000.mp4"),
+	}
+	// Camera 11's files share camera 1's leading digit
+	theirs := []string{
+		filepath.Join(EventRoot, "event_11_20240102-120000.mp4"),
+		filepath.Join(ContinuousDir(11), "20240102-120000.mp4"),
+	}
+	for _, path := range append(mine, theirs...) {
+		writeSegment(t, path)
+	}
+
+	removed, failed := RemoveCameraFiles(1)
+	if removed != len(mine) || len(failed) != 0 {
+		t.Errorf("removed %d, failed %v; want %d, none", removed, failed, len(mine))
+	}
+	for _, path := range mine {
+		if exists(path) {
+			t.Errorf("%s left behind", path)
+		}
+	}
+	if exists(ContinuousDir(1)) {
+		t.Error("continuous directory left behind")
+	}
+	for _, path := range theirs {
+		if !exists(path) {
+			t.Errorf("another camera's %s removed", path)
+		}
+	}
+
+	// Nothing to remove is not an error
+	if removed, failed := RemoveCameraFiles(99); removed != 0 || len(failed) != 0 {
+		t.Errorf("camera without files: removed %d, failed %v", removed, failed)
+	}
+}
+
+func TestRemoveCameraFilesPartialFailure(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root ignores directory permissions")
+	}
+	testRoots(t)
+	event := filepath.Join(EventRoot, "event_1_20240102-120000.mp4")
+	locked := filepath.Join(ContinuousDir(1), "2024", "01", "02")
+	segment := filepath.Join(locked, "20240102-120000.mp4")
+	writeSegment(t, event)
+	writeSegment(t, segment)
+	os.Chmod(locked, 0555)
+	t.Cleanup(func() { os.Chmod(locked, 0755) })
+
+	removed, failed := RemoveCameraFiles(1)
+	if removed != 1 || len(failed) != 1 || failed[0].Path != LogicalPath(segment) || failed[0].Error == "" {
+		t.Errorf("removed %d, failed %+v", removed, failed)
+	}
+	if exists(event) || !exists(segment) {
+		t.Error("the removable file should go and the locked one stay")
+	}
+	// The directory is kept so the leftover can still be found and retried
+	if !exists(ContinuousDir(1)) {
+		t.Error("continuous directory removed despite a failure")
+	}
+}
@@ -0,0 +1,58 @@
+package detector
+
+import (
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// splitClasses parses webhook class labels, each either "person" or
+// "person:0.87", into unique names (in first-seen order) and the highest
+// confidence given for each
+func splitClasses(raw []string) ([]string, map[string]*float64) {
+	names := make([]string, 0, len(raw))
+	confidence := make(map[string]*float64, len(raw))
+	for _, label := range raw {
+		name, conf, hasConf := strings.Cut(label, ":")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prev, seen := confidence[name]
+		if !seen {
+			names = append(names, name)
+			confidence[name] = nil
+		}
+		if !hasConf {
+			continue
+		}
+		if v, err := strconv.ParseFloat(strings.TrimSpace(conf), 64); err == nil && (prev == nil || v > *prev) {
+			confidence[name] = &v
+		}
+	}
+	return names, confidence
+}
+
+// recordEventClasses upserts one EventClass row per class, keeping the highest
+// confidence seen
+func recordEventClasses(eventID uint, names []string, confidence map[string]*float64) {
+	if eventID == 0 || len(names) == 0 {
+		return
+	}
+	rows := make([]models.EventClass, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, models.EventClass{EventID: eventID, Class: name, Confidence: confidence[name]})
+	}
+	database.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "event_id"}, {Name: "class"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"confidence": gorm.Expr("GREATEST(event_classes.confidence, EXCLUDED.confidence)"),
+		}),
+	}).Create(&rows)
+}
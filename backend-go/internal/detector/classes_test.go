@@ -0,0 +1,55 @@
+package detector
+
+import (
+	"reflect"
+	"testing"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestSplitClasses(t *testing.T) {
+	names, conf := splitClasses([]string{"person:0.6", " car ", "person:0.87", "dog:bad", "", ":0.5", "person:0.7"})
+	if !reflect.DeepEqual(names, []string{"person", "car", "dog"}) {
+		t.Errorf("names = %v", names)
+	}
+	if conf["person"] == nil || *conf["person"] != 0.87 {
+		t.Errorf("person confidence = %v, want the highest (0.87)", conf["person"])
+	}
+	if conf["car"] != nil || conf["dog"] != nil {
+		t.Errorf("classes without a usable confidence = %v, %v", conf["car"], conf["dog"])
+	}
+}
+
+func TestRecordEventClasses(t *testing.T) {
+	testDB(t)
+	event := models.Event{CameraID: 1, UserID: 1}
+	database.DB.Create(&event)
+
+	record := func(labels ...string) {
+		names, conf := splitClasses(labels)
+		recordEventClasses(event.ID, names, conf)
+	}
+	record("person:0.6", "car")
+	record("person:0.9", "car:0.4")
+	record("person:0.5")
+
+	var rows []models.EventClass
+	database.DB.Where("event_id = ?", event.ID).Order("class").Find(&rows)
+	if len(rows) != 2 {
+		t.Fatalf("rows = %+v, want one per class", rows)
+	}
+	if rows[0].Class != "car" || rows[0].Confidence == nil || *rows[0].Confidence != 0.4 {
+		t.Errorf("car = %+v", rows[0])
+	}
+	if rows[1].Class != "person" || rows[1].Confidence == nil || *rows[1].Confidence != 0.9 {
+		t.Errorf("person = %+v, want the highest confidence kept", rows[1])
+	}
+
+	recordEventClasses(0, []string{"person"}, nil)
+	var n int64
+	database.DB.Model(&models.EventClass{}).Where("event_id = 0").Count(&n)
+	if n != 0 {
+		t.Error("rows recorded without an event")
+	}
+}
@@ -0,0 +1,157 @@
+package detector
+
+import (
+	"sort"
+	"time"
+)
+
+// Gap reasons inferred from the segment pattern around a gap
+const (
+	// Short break between two segments: ffmpeg exited and SyncCameras restarted it
+	GapProcessRestart = "process_restart"
+
+	// Longer break between two segments: stream down, disk full, maintenance, ...
+	GapOutage = "outage"
+
+	// No segment at the start or end of the window: recording wasn't running
+	GapNotRecording = "not_recording"
+)
+
+const (
+	// Breaks up to this long between segments are attributed to a process restart
+	coverageRestartGap = 2 * time.Minute
+
+	// Breaks shorter than this are keyframe/segment-boundary jitter, not gaps
+	coverageTolerance = 2 * time.Second
+)
+
+// CoverageGap is an interval inside the window with no continuous footage
+type CoverageGap struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Seconds float64   `json:"seconds"`
+	Reason  string    `json:"reason"`
+}
+
+// Coverage summarises how much of a UTC day continuous recording covered
+type Coverage struct {
+	Date            string        `json:"date"`
+	WindowStart     time.Time     `json:"window_start"`
+	WindowEnd       time.Time     `json:"window_end"`
+	Segments        int           `json:"segments"`
+	RecordedSeconds float64       `json:"recorded_seconds"`
+	GapSeconds      float64       `json:"gap_seconds"`
+	CoveragePercent float64       `json:"coverage_percent"`
+	Gaps            []CoverageGap `json:"gaps"`
+}
+
+type span struct{ start, end time.Time }
+
+// ContinuousCoverage measures a camera's continuous footage for the UTC day.
+// A segment runs from the time in its name to its last write (mtime), so
+// segments cut short by a crash count only for what they contain. For today the
+// window ends at now.
+func ContinuousCoverage(camID uint, day, now time.Time) Coverage {
+	windowStart := day.UTC().Truncate(24 * time.Hour)
+	windowEnd := windowStart.Add(24 * time.Hour)
+	if now.Before(windowEnd) {
+		windowEnd = now
+	}
+	cov := Coverage{
+		Date:        windowStart.Format("2006-01-02"),
+		WindowStart: windowStart,
+		WindowEnd:   windowEnd,
+		Gaps:        make([]CoverageGap, 0),
+	}
+	if !windowEnd.After(windowStart) {
+		return cov
+	}
+
+	spans := segmentSpans(camID, windowStart, windowEnd)
+	cov.Segments = len(spans)
+
+	cursor := windowStart
+	for i, s := range spans {
+		if s.start.Sub(cursor) >= coverageTolerance {
+			reason := GapOutage
+			if i == 0 {
+				reason = GapNotRecording
+			} else if s.start.Sub(cursor) <= coverageRestartGap {
+				reason = GapProcessRestart
+			}
+			cov.addGap(cursor, s.start, reason)
+		}
+		if s.end.After(cursor) {
+			cov.RecordedSeconds += s.end.Sub(maxTime(s.start, cursor)).Seconds()
+			cursor = s.end
+		}
+	}
+	if windowEnd.Sub(cursor) >= coverageTolerance {
+		cov.addGap(cursor, windowEnd, GapNotRecording)
+	}
+
+	if total := windowEnd.Sub(windowStart).Seconds(); total > 0 {
+		cov.CoveragePercent = cov.RecordedSeconds / total * 100
+	}
+	return cov
+}
+
+func (cov *Coverage) addGap(start, end time.Time, reason string) {
+	seconds := end.Sub(start).Seconds()
+	cov.GapSeconds += seconds
+	cov.Gaps = append(cov.Gaps, CoverageGap{Start: start, End: end, Seconds: seconds, Reason: reason})
+}
+
+// segmentSpans returns the camera's segments overlapping the window, clipped to
+// it and sorted by start. The previous day's last segment may run past midnight,
+// so every segment file is considered, not just those named for the day.
+func segmentSpans(camID uint, windowStart, windowEnd time.Time) []span {
+	var spans []span
+	for _, seg := range CameraSegments(camID) {
+		start := seg.Start
+		if !start.Before(windowEnd) {
+			continue
+		}
+		end := seg.Info.ModTime().UTC()
+		if !end.After(windowStart) || !end.After(start) {
+			continue
+		}
+		spans = append(spans, span{start: maxTime(start, windowStart), end: minTime(end, windowEnd)})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start.Before(spans[j].start) })
+	return spans
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// Span is a stretch of uninterrupted continuous footage
+type Span struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// ContinuousSpans returns a camera's continuous footage between start and end as
+// merged spans: segments closer than the gap tolerance join into one span
+func ContinuousSpans(camID uint, start, end time.Time) []Span {
+	merged := make([]Span, 0)
+	for _, s := range segmentSpans(camID, start, end) {
+		if n := len(merged); n > 0 && s.start.Sub(merged[n-1].End) < coverageTolerance {
+			merged[n-1].End = maxTime(merged[n-1].End, s.end)
+			continue
+		}
+		merged = append(merged, Span{Start: s.start, End: s.end})
+	}
+	return merged
+}
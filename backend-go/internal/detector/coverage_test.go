@@ -0,0 +1,91 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordedSegment writes a segment named for start whose last write was at end
+func recordedSegment(t *testing.T, camID uint, start, end time.Time) {
+	t.Helper()
+	path := filepath.Join(ContinuousDir(camID), start.UTC().Format(SegmentTimeLayout)+".mp4")
+	writeSegment(t, path)
+	if err := os.Chtimes(path, end, end); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContinuousCoverage(t *testing.T) {
+	testRoots(t)
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	at := func(h, m, s int) time.Time {
+		return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second)
+	}
+
+	// Yesterday's last segment runs past midnight
+	recordedSegment(t, 1, at(-1, 55, 0), at(0, 10, 0))
+	// One second later: segment-boundary jitter, not a gap
+	recordedSegment(t, 1, at(0, 10, 1), at(0, 30, 0))
+	// A minute later: ffmpeg restarted
+	recordedSegment(t, 1, at(0, 31, 0), at(1, 0, 0))
+	// An hour later: outage
+	recordedSegment(t, 1, at(2, 0, 0), at(3, 0, 0))
+	// Another camera's footage is not counted
+	recordedSegment(t, 2, at(5, 0, 0), at(6, 0, 0))
+
+	cov := ContinuousCoverage(1, day.Add(13*time.Hour), day.Add(48*time.Hour))
+	if cov.Date != "2024-01-02" || cov.Segments != 4 {
+		t.Errorf("date %s, %d segments", cov.Date, cov.Segments)
+	}
+	type gap struct {
+		Start, End time.Time
+		Reason     string
+	}
+	var gaps []gap
+	for _, g := range cov.Gaps {
+		gaps = append(gaps, gap{g.Start, g.End, g.Reason})
+	}
+	want := []gap{
+		{at(0, 30, 0), at(0, 31, 0), GapProcessRestart},
+		{at(1, 0, 0), at(2, 0, 0), GapOutage},
+		{at(3, 0, 0), at(24, 0, 0), GapNotRecording},
+	}
+	if !reflect.DeepEqual(gaps, want) {
+		t.Errorf("gaps =\n  %+v\nwant\n  %+v", gaps, want)
+	}
+	if recorded := (2*time.Hour - time.Minute - time.Second).Seconds(); cov.RecordedSeconds != recorded {
+		t.Errorf("recorded %v s, want %v", cov.RecordedSeconds, recorded)
+	}
+
+	// Today: the window stops at now, so nothing after it is a gap
+	today := ContinuousCoverage(1, day, at(2, 30, 0))
+	if last := today.Gaps[len(today.Gaps)-1]; last.Reason != GapOutage || !today.WindowEnd.Equal(at(2, 30, 0)) {
+		t.Errorf("today: window end %s, last gap %+v", today.WindowEnd, last)
+	}
+
+	// A day with no footage is one not_recording gap
+	empty := ContinuousCoverage(1, day.Add(-48*time.Hour), day)
+	if len(empty.Gaps) != 1 || empty.Gaps[0].Reason != GapNotRecording || empty.CoveragePercent != 0 {
+		t.Errorf("empty day = %+v", empty)
+	}
+}
+
+func TestContinuousSpans(t *testing.T) {
+	testRoots(t)
+	base := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	recordedSegment(t, 1, base, base.Add(10*time.Minute))
+	recordedSegment(t, 1, base.Add(10*time.Minute+time.Second), base.Add(20*time.Minute))
+	recordedSegment(t, 1, base.Add(30*time.Minute), base.Add(40*time.Minute))
+
+	spans := ContinuousSpans(1, base, base.Add(35*time.Minute))
+	want := []Span{
+		{base, base.Add(20 * time.Minute)},
+		{base.Add(30 * time.Minute), base.Add(35 * time.Minute)},
+	}
+	if !reflect.DeepEqual(spans, want) {
+		t.Errorf("spans = %+v, want %+v", spans, want)
+	}
+}
@@ -0,0 +1,46 @@
+package detector
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"nvr-server/internal/database"
+)
+
+// testDB points database.DB at the Postgres named by NVR_TEST_DATABASE_URL,
+// migrated and emptied. Tests that need it are skipped when the variable is
+// unset. The database is wiped, so never aim it at real data.
+func testDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("NVR_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("NVR_TEST_DATABASE_URL not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true, Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = prev
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	database.Migrate()
+	var tables []string
+	db.Raw("SELECT quote_ident(tablename) FROM pg_tables WHERE schemaname = current_schema()").Scan(&tables)
+	if len(tables) > 0 {
+		if err := db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error; err != nil {
+			t.Fatalf("empty test database: %v", err)
+		}
+	}
+}
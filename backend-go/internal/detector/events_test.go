@@ -0,0 +1,458 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// testRoots points the event and continuous roots at temp dirs
+func testRoots(t *testing.T) {
+	t.Helper()
+	prevEvents, prevContinuous := EventRoot, ContinuousRoot
+	EventRoot = t.TempDir()
+	ContinuousRoot = t.TempDir()
+	t.Cleanup(func() { EventRoot, ContinuousRoot = prevEvents, prevContinuous })
+}
+
+// useFakeFFmpeg stands in for ffmpeg: an .mp4 output gets a plausible clip and
+// the process keeps "recording" until signalled; any other output is just created
+func useFakeFFmpeg(t *testing.T) {
+	t.Helper()
+	prev := FFmpegBin
+	FFmpegBin = stubTool(t, `for out; do :; done
+case "$out" in
+*.mp4) head -c 60000 /dev/zero > "$out"; exec sleep 30 ;;
+*) : > "$out" ;;
+esac`)
+	t.Cleanup(func() { FFmpegBin = prev })
+}
+
+func stopManager(t *testing.T, m *Manager) {
+	t.Helper()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		m.Stop(ctx)
+	})
+}
+
+func TestEventSourcesShareOneRecording(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	useFakeFFmpeg(t)
+	useFFprobe(t, "echo 10")
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true})
+	cam := models.Camera{Name: "yard", Path: "yard", RTSPUrl: "rtsp://192.0.2.1/yard", OwnerID: 1}
+	database.DB.Create(&cam)
+
+	m := NewManager()
+	stopManager(t, m)
+
+	active := func() (*ActiveRecording, bool) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		rec, ok := m.ActiveRecordings[cam.ID]
+		return rec, ok
+	}
+	events := func() int64 {
+		var n int64
+		database.DB.Model(&models.Event{}).Where("camera_id = ?", cam.ID).Count(&n)
+		return n
+	}
+
+	if err := m.StartEventRecord(cam.ID, "ai", models.ReasonPerson, nil); err != nil {
+		t.Fatalf("start ai: %v", err)
+	}
+	m.StartEventRecord(cam.ID, "pir", models.ReasonMotion, nil)
+	if n := events(); n != 1 {
+		t.Fatalf("two overlapping sources made %d events, want 1", n)
+	}
+
+	// Skip finishEventRecord's five second minimum
+	m.mu.Lock()
+	m.ActiveRecordings[cam.ID].StartTime = time.Now().Add(-time.Minute)
+	m.mu.Unlock()
+
+	m.StopEventRecord(cam.ID, "ai")
+	if _, ok := active(); !ok {
+		t.Fatal("recording stopped while pir still holds it")
+	}
+	m.StartEventRecord(cam.ID, "ai", models.ReasonPerson, nil)
+	m.StopEventRecord(cam.ID, "pir")
+	if rec, ok := active(); !ok || len(rec.Sources) != 1 || !rec.Sources["ai"] {
+		t.Fatalf("after ai rejoined and pir left: active %v, sources %v", ok, rec)
+	}
+
+	m.StopEventRecord(cam.ID, "ai")
+	if _, ok := active(); ok {
+		t.Fatal("recording still active after every source ended")
+	}
+	var event models.Event
+	database.DB.Where("camera_id = ?", cam.ID).First(&event)
+	if events() != 1 || event.EndTime.IsZero() || event.Reason != models.ReasonPerson {
+		t.Errorf("finalized event = %+v", event)
+	}
+}
+
+func TestQueuedEventReleasedByItsSources(t *testing.T) {
+	m := NewManager()
+	m.queuedEvents[7] = &QueuedEvent{Sources: map[string]bool{"ai": true, "pir": true}}
+	m.eventQueue = []uint{3, 7}
+
+	m.StopEventRecord(7, "ai")
+	if _, ok := m.queuedEvents[7]; !ok {
+		t.Fatal("queued event dropped while pir still wants it")
+	}
+
+	m.StopEventRecord(7, "pir")
+	if _, ok := m.queuedEvents[7]; ok || len(m.eventQueue) != 1 || m.eventQueue[0] != 3 {
+		t.Errorf("after both sources ended: queued %v, queue %v", m.queuedEvents, m.eventQueue)
+	}
+}
+
+func TestMaintenanceModeIgnoresEvents(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	useFakeFFmpeg(t)
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true, MaintenanceMode: true})
+	cam := models.Camera{Name: "yard", Path: "yard", RTSPUrl: "rtsp://192.0.2.1/yard", OwnerID: 1}
+	database.DB.Create(&cam)
+
+	m := NewManager()
+	stopManager(t, m)
+	if err := m.StartEventRecord(cam.ID, "ai", models.ReasonMotion, nil); err != nil {
+		t.Fatalf("StartEventRecord: %v", err)
+	}
+
+	var n int64
+	database.DB.Model(&models.Event{}).Count(&n)
+	if n != 0 || len(m.ActiveRecordings) != 0 {
+		t.Errorf("maintenance mode still recorded: %d events, %d active", n, len(m.ActiveRecordings))
+	}
+}
+
+func TestRecordingStatsCountOutcomes(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	useFakeFFmpeg(t)
+	useFFprobe(t, "echo 10")
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true})
+	cam := models.Camera{Name: "yard", Path: "yard", RTSPUrl: "rtsp://192.0.2.1/yard", OwnerID: 1}
+	database.DB.Create(&cam)
+
+	m := NewManager()
+	stopManager(t, m)
+
+	// record runs one event through to its end once the fake clip is written
+	record := func() {
+		t.Helper()
+		if err := m.StartEventRecord(cam.ID, "ai", models.ReasonMotion, nil); err != nil {
+			t.Fatalf("StartEventRecord: %v", err)
+		}
+		m.mu.Lock()
+		rec := m.ActiveRecordings[cam.ID]
+		rec.StartTime = time.Now().Add(-time.Minute)
+		m.mu.Unlock()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if info, err := os.Stat(rec.VideoPath); err == nil && info.Size() >= 60000 {
+				break
+			}
+		}
+		m.StopEventRecord(cam.ID, "ai")
+	}
+
+	record()
+	if got := m.RecordingStats(cam.ID); got != (RecordingStats{Started: 1, Finalized: 1}) {
+		t.Errorf("after a good clip: %+v", got)
+	}
+
+	// Shorter than the default MinEventSeconds
+	useFFprobe(t, "echo 1")
+	record()
+	if got := m.RecordingStats(cam.ID); got != (RecordingStats{Started: 2, Finalized: 1, Discarded: 1}) {
+		t.Errorf("after a bad clip: %+v", got)
+	}
+
+	FFmpegBin = filepath.Join(t.TempDir(), "missing-ffmpeg")
+	if err := m.StartEventRecord(cam.ID, "ai", models.ReasonMotion, nil); err == nil {
+		t.Fatal("start succeeded without ffmpeg")
+	}
+	if got := m.RecordingStats(cam.ID); got != (RecordingStats{Started: 2, Finalized: 1, Discarded: 1, Failed: 1}) {
+		t.Errorf("after a failed start: %+v", got)
+	}
+	var n int64
+	database.DB.Model(&models.Event{}).Count(&n)
+	if n != 1 {
+		t.Errorf("%d events stored, want only the finalized one", n)
+	}
+
+	if got := m.RecordingStats(99); got != (RecordingStats{}) {
+		t.Errorf("unknown camera: %+v", got)
+	}
+}
+
+func TestEventCapacityPolicies(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	useFakeFFmpeg(t)
+	useFFprobe(t, "echo 10")
+	settings := models.SystemSettings{AllowRegistration: true, MaxConcurrentEventRecordings: 1, EventCapacityPolicy: CapacityReject}
+	database.DB.Create(&settings)
+	front := models.Camera{Name: "front", Path: "front", RTSPUrl: "rtsp://192.0.2.1/front", OwnerID: 1}
+	back := models.Camera{Name: "back", Path: "back", RTSPUrl: "rtsp://192.0.2.1/back", OwnerID: 1}
+	database.DB.Create(&front)
+	database.DB.Create(&back)
+
+	m := NewManager()
+	stopManager(t, m)
+	active := func(camID uint) bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		_, ok := m.ActiveRecordings[camID]
+		return ok
+	}
+
+	if err := m.StartEventRecord(front.ID, "ai", models.ReasonMotion, nil); err != nil || !active(front.ID) {
+		t.Fatalf("first event: %v, active %v", err, active(front.ID))
+	}
+
+	// reject: the second camera's event is logged as dropped and not recorded
+	m.StartEventRecord(back.ID, "ai", models.ReasonMotion, nil)
+	var dropped models.Event
+	if active(back.ID) || database.DB.Where("camera_id = ?", back.ID).First(&dropped).Error != nil || dropped.Reason != "dropped_capacity" {
+		t.Fatalf("over capacity with reject: active %v, event %+v", active(back.ID), dropped)
+	}
+	if got := m.RecordingStats(back.ID).Discarded; got != 1 {
+		t.Errorf("dropped event counted %d times as discarded", got)
+	}
+	database.DB.Delete(&dropped)
+
+	// queue: the second camera starts once the first finishes
+	database.DB.Model(&settings).Update("event_capacity_policy", CapacityQueue)
+	m.StartEventRecord(back.ID, "ai", models.ReasonMotion, nil)
+	if active(back.ID) {
+		t.Fatal("queued event started while at capacity")
+	}
+	m.mu.Lock()
+	m.ActiveRecordings[front.ID].StartTime = time.Now().Add(-time.Minute)
+	m.mu.Unlock()
+	m.StopEventRecord(front.ID, "ai")
+
+	if active(front.ID) || !active(back.ID) {
+		t.Errorf("after the first event ended: front active %v, back active %v", active(front.ID), active(back.ID))
+	}
+	var n int64
+	database.DB.Model(&models.Event{}).Where("camera_id = ? AND reason <> ?", back.ID, "dropped_capacity").Count(&n)
+	if n != 1 {
+		t.Errorf("queued event stored %d rows, want 1", n)
+	}
+}
+
+func TestEventTimeoutClosesEvent(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	useFakeFFmpeg(t)
+	useFFprobe(t, "echo 10")
+	sent := captureNotifications(t)
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true})
+	cam := models.Camera{Name: "yard", Path: "yard", RTSPUrl: "rtsp://192.0.2.1/yard", OwnerID: 1}
+	database.DB.Create(&cam)
+
+	m := NewManager()
+	stopManager(t, m)
+	if err := m.StartEventRecord(cam.ID, "ai", models.ReasonPerson, nil); err != nil {
+		t.Fatal(err)
+	}
+	m.mu.Lock()
+	rec := m.ActiveRecordings[cam.ID]
+	rec.StartTime = time.Now().Add(-time.Minute)
+	eventID := rec.EventID
+	m.mu.Unlock()
+
+	// A timer left over from an earlier event must not close this one
+	m.eventTimeout(cam, eventID+1000, time.Millisecond)
+	if _, ok := m.ActiveRecordings[cam.ID]; !ok {
+		t.Fatal("stale timeout closed the current event")
+	}
+
+	m.eventTimeout(cam, eventID, time.Millisecond)
+	if _, ok := m.ActiveRecordings[cam.ID]; ok {
+		t.Fatal("event still recording after its timeout")
+	}
+	var event models.Event
+	database.DB.First(&event, eventID)
+	if !event.AutoTerminated || event.Reason != models.ReasonTimeout || event.EndTime.IsZero() {
+		t.Errorf("timed-out event = %+v", event)
+	}
+
+	select {
+	case n := <-sent:
+		if n.Kind != "event_timeout" || fmt.Sprint(n.Data["sources"]) != "[ai]" {
+			t.Errorf("notification = %+v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no timeout notification sent")
+	}
+}
+
+func TestEventDroppedOnLowDisk(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	useFakeFFmpeg(t)
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true, MinFreeRecordingGB: 1 << 20})
+	cam := models.Camera{Name: "yard", Path: "yard", RTSPUrl: "rtsp://192.0.2.1/yard", OwnerID: 1}
+	database.DB.Create(&cam)
+
+	m := NewManager()
+	stopManager(t, m)
+	if err := m.StartEventRecord(cam.ID, "ai", models.ReasonMotion, nil); !errors.Is(err, ErrNoSpace) {
+		t.Fatalf("StartEventRecord = %v, want ErrNoSpace", err)
+	}
+	if _, ok := m.ActiveRecordings[cam.ID]; ok {
+		t.Error("recording started below the free-space floor")
+	}
+
+	var events []models.Event
+	database.DB.Where("camera_id = ?", cam.ID).Find(&events)
+	if len(events) != 1 || events[0].Reason != models.ReasonDroppedNoSpace || events[0].VideoPath != "" {
+		t.Errorf("events = %+v, want one dropped_nospace marker", events)
+	}
+	if got := m.RecordingStats(cam.ID).Discarded; got != 1 {
+		t.Errorf("discarded = %d", got)
+	}
+}
+
+// useSlowStopFFmpeg is useFakeFFmpeg whose recordings take a moment to exit on
+// SIGTERM, holding finishEventRecord in its unlocked window
+func useSlowStopFFmpeg(t *testing.T) {
+	t.Helper()
+	prev := FFmpegBin
+	FFmpegBin = stubTool(t, `for out; do :; done
+case "$out" in
+*.mp4) head -c 60000 /dev/zero > "$out"; trap 'kill $!; sleep 0.5; exit 0' TERM; sleep 30 & wait ;;
+*) : > "$out" ;;
+esac`)
+	t.Cleanup(func() { FFmpegBin = prev })
+}
+
+// closingRecording stops source on camID in the background and returns the
+// recording once its finisher has claimed it, with a channel closed when it's done
+func closingRecording(t *testing.T, m *Manager, camID uint, source string) (*ActiveRecording, chan struct{}) {
+	t.Helper()
+	m.mu.Lock()
+	rec := m.ActiveRecordings[camID]
+	rec.StartTime = time.Now().Add(-time.Minute)
+	m.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		m.StopEventRecord(camID, source)
+		close(stopped)
+	}()
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		m.mu.Lock()
+		closing := rec.Closing
+		m.mu.Unlock()
+		if closing {
+			return rec, stopped
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("recording never started closing")
+		}
+	}
+}
+
+func TestStartDuringStopOpensNewRecording(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	useSlowStopFFmpeg(t)
+	useFFprobe(t, "echo 10")
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true, MaxConcurrentEventRecordings: 1})
+	cam := models.Camera{Name: "yard", Path: "yard", RTSPUrl: "rtsp://192.0.2.1/yard", OwnerID: 1}
+	database.DB.Create(&cam)
+
+	m := NewManager()
+	stopManager(t, m)
+	if err := m.StartEventRecord(cam.ID, "ai", models.ReasonPerson, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Clip names carry their start second
+	time.Sleep(1100 * time.Millisecond)
+	first, stopped := closingRecording(t, m, cam.ID, "ai")
+
+	// A second finisher backs off, and a new event gets its own recording even
+	// at the one-recording cap
+	m.finishEventRecord(cam.ID)
+	if err := m.StartEventRecord(cam.ID, "pir", models.ReasonMotion, nil); err != nil {
+		t.Fatal(err)
+	}
+	<-stopped
+
+	m.mu.Lock()
+	second, ok := m.ActiveRecordings[cam.ID]
+	m.mu.Unlock()
+	if !ok || second == first || !second.Sources["pir"] || second.EventID == first.EventID {
+		t.Fatalf("start during stop: active %v, recording %+v", ok, second)
+	}
+	if _, joined := first.Sources["pir"]; joined {
+		t.Error("new source joined the closing recording")
+	}
+	var event models.Event
+	database.DB.First(&event, first.EventID)
+	if event.EndTime.IsZero() {
+		t.Errorf("first event not finalized: %+v", event)
+	}
+	if stats := m.RecordingStats(cam.ID); stats.Started != 2 || stats.Finalized != 1 {
+		t.Errorf("stats = %+v, want 2 started and 1 finalized", stats)
+	}
+
+	_, stopped = closingRecording(t, m, cam.ID, "pir")
+	<-stopped
+	if _, ok := m.ActiveRecordings[cam.ID]; ok {
+		t.Error("second recording still active after its source ended")
+	}
+}
+
+func TestEventTimeoutDuringStop(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	useSlowStopFFmpeg(t)
+	useFFprobe(t, "echo 10")
+	sent := captureNotifications(t)
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true})
+	cam := models.Camera{Name: "yard", Path: "yard", RTSPUrl: "rtsp://192.0.2.1/yard", OwnerID: 1}
+	database.DB.Create(&cam)
+
+	m := NewManager()
+	stopManager(t, m)
+	if err := m.StartEventRecord(cam.ID, "ai", models.ReasonPerson, nil); err != nil {
+		t.Fatal(err)
+	}
+	rec, stopped := closingRecording(t, m, cam.ID, "ai")
+
+	// The cap firing while the normal stop waits on ffmpeg leaves the event to it
+	m.eventTimeout(cam, rec.EventID, time.Millisecond)
+	<-stopped
+
+	var event models.Event
+	database.DB.First(&event, rec.EventID)
+	if event.AutoTerminated || event.Reason != models.ReasonPerson || event.EndTime.IsZero() {
+		t.Errorf("event = %+v, want a normal stop", event)
+	}
+	if stats := m.RecordingStats(cam.ID); stats.Finalized != 1 {
+		t.Errorf("finalized %d times", stats.Finalized)
+	}
+	select {
+	case n := <-sent:
+		t.Errorf("timeout notification sent for a stopped event: %+v", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
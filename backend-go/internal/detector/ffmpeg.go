@@ -0,0 +1,177 @@
+package detector
+
+import (
+	"context"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/models"
+	"nvr-server/internal/secrets"
+)
+
+// x264 settings used when a camera needs re-encoding for browser playback
+var (
+	TranscodePreset = config.String("NVR_TRANSCODE_PRESET", "veryfast")
+	TranscodeCRF    = config.Int("NVR_TRANSCODE_CRF", 23)
+)
+
+// SegmentTimeLayout is the strftime-equivalent layout of continuous segment names.
+// Segments are always named in UTC (see spawnContinuous), matching the DB.
+const SegmentTimeLayout = "20060102-150405"
+
+// ParseSegmentTime extracts the UTC start time encoded in a segment filename
+func ParseSegmentTime(filename string) (time.Time, bool) {
+	name := strings.TrimSuffix(filename, filepath.Ext(filename))
+	t, err := time.ParseInLocation(SegmentTimeLayout, name, time.UTC)
+	return t, err == nil
+}
+
+// Continuous segment container formats
+const (
+	SegmentMP4  = "mp4"
+	SegmentFMP4 = "fmp4"
+	SegmentMKV  = "mkv"
+)
+
+// ValidSegmentFormat reports whether f is a supported SegmentFormat ("" means mp4)
+func ValidSegmentFormat(f string) bool {
+	switch f {
+	case "", SegmentMP4, SegmentFMP4, SegmentMKV:
+		return true
+	}
+	return false
+}
+
+// IsSegmentFile reports whether a filename looks like a continuous segment of any format
+func IsSegmentFile(name string) bool {
+	return strings.HasSuffix(name, ".mp4") || strings.HasSuffix(name, ".mkv")
+}
+
+// IsRecordingFile reports whether a filename is one the recorders write: clips and
+// segments, thumbnails and snapshots, animated previews and metadata sidecars
+func IsRecordingFile(name string) bool {
+	return IsSegmentFile(name) || strings.HasSuffix(name, ".jpg") || strings.HasSuffix(name, ".webp") || strings.HasSuffix(name, ".json")
+}
+
+// segmentMuxArgs returns the segment muxer options and file extension for a camera's format
+func segmentMuxArgs(cam models.Camera) ([]string, string) {
+	switch cam.SegmentFormat {
+	case SegmentMKV:
+		// Matroska stays playable if the process is killed mid-segment
+		return []string{"-segment_format", "matroska"}, ".mkv"
+	case SegmentFMP4:
+		return []string{
+			"-segment_format", "mp4",
+			"-segment_format_options", "movflags=+frag_keyframe+empty_moov+default_base_moof",
+		}, ".mp4"
+	default:
+		return []string{"-segment_format", "mp4"}, ".mp4"
+	}
+}
+
+// reconnectArgs are ffmpeg's HTTP-protocol reconnect options. The RTSP demuxer
+// ignores them, so they are only added for http(s):// sources (MJPEG, HLS, ...).
+var reconnectArgs = []string{"-reconnect", "1", "-reconnect_streamed", "1", "-reconnect_delay_max", "5"}
+
+// inputArgs builds the ffmpeg input options shared by every recording of a camera
+func inputArgs(cam models.Camera) []string {
+	lowerURL := strings.ToLower(cam.RTSPUrl)
+	if strings.HasPrefix(lowerURL, "http://") || strings.HasPrefix(lowerURL, "https://") {
+		var args []string
+		if cam.AutoReconnect {
+			args = append(args, reconnectArgs...)
+		}
+		return append(args, "-i", streamURL(cam.RTSPUrl))
+	}
+
+	args := []string{"-rtsp_transport", "tcp"}
+
+	// ffmpeg's TLS layer does not verify peers unless told to, so be explicit either way
+	if strings.HasPrefix(lowerURL, "rtsps://") {
+		if cam.RTSPSkipCertVerify {
+			args = append(args, "-tls_verify", "0")
+		} else {
+			args = append(args, "-tls_verify", "1")
+		}
+	}
+
+	return append(args, "-i", streamURL(cam.RTSPUrl))
+}
+
+// streamURL fills in ${secret:name} placeholders right before a URL leaves the
+// process. An unresolved placeholder is passed through for ffmpeg/MediaMTX to reject.
+func streamURL(raw string) string {
+	resolved, err := secrets.Resolve(raw)
+	if err != nil {
+		log.Printf("Stream URL: %v", err)
+	}
+	return resolved
+}
+
+// needsTranscode reports whether recordings of cam must be re-encoded rather than copied
+func needsTranscode(cam models.Camera) bool {
+	return cam.TranscodeH264 || privacyFilter(cam.PrivacyMask) != ""
+}
+
+// maskArgs applies the camera's privacy mask, if any. Everything that decodes the
+// stream into something a user sees (recordings, stills, thumbnails) uses it.
+func maskArgs(cam models.Camera) []string {
+	if filter := privacyFilter(cam.PrivacyMask); filter != "" {
+		return []string{"-vf", filter}
+	}
+	return nil
+}
+
+// codecArgs either stream-copies or, for cameras flagged TranscodeH264 or with a
+// privacy mask, re-encodes to H.264/AAC (filters can't be applied to a copy)
+func codecArgs(cam models.Camera) []string {
+	if !needsTranscode(cam) {
+		return []string{"-c:v", "copy", "-c:a", "copy"}
+	}
+	return append(maskArgs(cam),
+		"-c:v", "libx264",
+		"-preset", TranscodePreset,
+		"-crf", strconv.Itoa(TranscodeCRF),
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+	)
+}
+
+// FFmpegBin is the ffmpeg executable used for recordings
+var FFmpegBin = "ffmpeg"
+
+// ffmpegArgs puts -nostdin first: ffmpeg otherwise reads the terminal for
+// interactive commands and prompts, and a prompt nobody answers hangs the run
+func ffmpegArgs(args []string) []string {
+	return append([]string{"-nostdin"}, args...)
+}
+
+// ffmpegCommand builds a long-running ffmpeg (continuous/event recorders) with
+// stdin detached; callers pass -y wherever an output may already exist
+func ffmpegCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command(FFmpegBin, ffmpegArgs(args)...)
+	cmd.Stdin = nil
+	return cmd
+}
+
+// RecordClip records exactly seconds of the camera's stream to outPath and waits for it
+func RecordClip(ctx context.Context, cam models.Camera, seconds int, outPath string) error {
+	args := inputArgs(cam)
+	args = append(args, "-t", strconv.Itoa(seconds))
+	args = append(args, codecArgs(cam)...)
+	args = append(args, "-f", "mp4", "-movflags", "+faststart", "-y", outPath)
+
+	_, err := runTool(ctx, 0, FFmpegBin, args...)
+	return err
+}
+
+// lastLine returns the final non-empty line of ffmpeg output, usually the actual error
+func lastLine(out []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
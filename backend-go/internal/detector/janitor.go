@@ -1,49 +1,130 @@
 package detector
 
 import (
+	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
+	"nvr-server/internal/config"
 	"nvr-server/internal/database"
 	"nvr-server/internal/models"
+	"nvr-server/internal/notify"
 )
 
+// How often expired refresh sessions are purged from the database
+var SessionPruneInterval = config.Duration("NVR_SESSION_PRUNE_INTERVAL", time.Hour)
+
+// Longest the janitor may be paused before it resumes on its own
+var JanitorMaxPause = config.Duration("NVR_JANITOR_MAX_PAUSE", 24*time.Hour)
+
+var (
+	// Retention deletes nothing until the server has been up this long, leaving
+	// time to notice a wrong RetentionDays after a fresh install or restore
+	RetentionStartupGrace = config.Duration("NVR_RETENTION_STARTUP_GRACE", time.Hour)
+
+	// Files that arrived on disk (ctime: recorded, copied or imported) more
+	// recently than this are never deleted by retention, whatever their mtime says
+	RetentionMinFileAge = config.Duration("NVR_RETENTION_MIN_FILE_AGE", 24*time.Hour)
+)
+
+// How many paths the first retention pass lists in its dry-run log
+const retentionPreviewPaths = 20
+
 // StartJanitor starts the background cleanup loop
 func (m *Manager) StartJanitor() {
 	log.Println("--- Janitor Service Started (Retention & Cleanup) ---")
 	ticker := time.NewTicker(60 * time.Second)
+	defer ticker.Stop()
+
+	var lastSessionPrune time.Time
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
-	for range ticker.C {
-		m.enforceRetention()
+		if !m.janitorPaused() {
+			m.enforceRetention()
+			m.enforceUserQuotas()
+		}
 		m.checkDiskSpace()
 		m.cleanupZombies()
+		m.ensureSegmentPartitions()
+
+		if time.Since(lastSessionPrune) >= SessionPruneInterval {
+			m.pruneExpiredSessions()
+			m.pruneBandwidthSamples()
+			m.pruneIdempotencyKeys()
+			pruneEmptyPartitions()
+			lastSessionPrune = time.Now()
+		}
+	}
+}
+
+// janitorPaused reports whether file deletion is on hold, clearing a pause
+// whose deadline has passed so it can't be forgotten indefinitely
+func (m *Manager) janitorPaused() bool {
+	var settings models.SystemSettings
+	if err := database.DB.First(&settings).Error; err != nil || settings.JanitorPausedUntil == nil {
+		return false
+	}
+	if time.Now().Before(*settings.JanitorPausedUntil) {
+		return true
 	}
+	database.DB.Model(&settings).Update("janitor_paused_until", nil)
+	log.Println("Janitor: Pause expired, resuming retention")
+	return false
+}
+
+// pruneExpiredSessions deletes refresh sessions that can no longer be used
+func (m *Manager) pruneExpiredSessions() {
+	res := database.DB.Where("expires_at < ?", time.Now()).Delete(&models.UserSession{})
+	if res.Error == nil && res.RowsAffected > 0 {
+		log.Printf("Janitor: Pruned %d expired sessions\n", res.RowsAffected)
+	}
+}
+
+// pruneIdempotencyKeys deletes createCamera retry keys past their TTL
+func (m *Manager) pruneIdempotencyKeys() {
+	database.DB.Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyKey{})
 }
 
 // cleanupZombies removes entries from memory if the process has already died
 func (m *Manager) cleanupZombies() {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	freed := false
 
 	// Check Event Recordings
 	for id, rec := range m.ActiveRecordings {
-		// If process marked done, remove from map
-		if rec.Process.ProcessState != nil && rec.Process.ProcessState.Exited() {
+		// If process marked done, remove from map; a closing one is its finisher's to remove
+		if !rec.Closing && rec.Process.ProcessState != nil && rec.Process.ProcessState.Exited() {
 			log.Printf("Janitor: Removed dead event recording for Camera %d\n", id)
 			if rec.LogFile != nil {
 				rec.LogFile.Close()
 			}
 			delete(m.ActiveRecordings, id)
+			freed = true
 		}
 	}
+	m.mu.Unlock()
+
+	if freed {
+		m.startQueuedEvent()
+	}
 }
 
-// enforceRetention deletes files older than the configured days
+// enforceRetention deletes files older than the configured days. Nothing is
+// deleted during RetentionStartupGrace, and the first pass after it only logs
+// what it would delete; deletion starts on the following pass.
 func (m *Manager) enforceRetention() {
+	if time.Since(m.startedAt) < RetentionStartupGrace {
+		return
+	}
+
 	var settings models.SystemSettings
 	if err := database.DB.First(&settings).Error; err != nil {
 		return 
@@ -54,42 +135,136 @@ func (m *Manager) enforceRetention() {
 		days = 30
 	}
 
-	cutoff := time.Now().AddDate(0, 0, -days)
-	deletedCount := 0
+	now := time.Now()
+	var expired []string
+	var expiredBytes int64
+
+	// Weekday rules replace the global default; a bad rule set is ignored, not fatal
+	rules, err := ParseRetentionRules(settings.RetentionRules)
+	if err != nil {
+		log.Printf("Janitor: Ignoring retention rules: %v\n", err)
+	}
+
+	// Cameras with their own retention override both for their files
+	var cameras []models.Camera
+	database.DB.Select("id, retention_days").Where("retention_days > 0").Find(&cameras)
+	cameraDays := make(map[uint]int, len(cameras))
+	for _, cam := range cameras {
+		cameraDays[cam.ID] = cam.RetentionDays
+	}
 
-	// Walk the recordings directory
-	err := filepath.Walk("/recordings", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
+	// Quiet cameras keep their last few events past the cutoff
+	kept := keptEventFiles(settings.MinEventsKept)
+
+	// Walk the recordings directories
+	walkRecordings(func(path string, info os.FileInfo) {
+		if kept[path] {
+			return
+		}
+		fileDays, overridden := 0, false
+		if camID, ok := CameraIDForPath(path); ok {
+			fileDays, overridden = cameraDays[camID]
 		}
-		if !info.IsDir() && info.ModTime().Before(cutoff) {
+		if !overridden {
+			fileDays = retentionDaysFor(rules, recordedTime(path, info), days)
+		}
+		if info.ModTime().Before(now.AddDate(0, 0, -fileDays)) && now.Sub(arrivedAt(info)) >= RetentionMinFileAge {
 			// Only delete media/log files
-			if strings.HasSuffix(path, ".mp4") || strings.HasSuffix(path, ".jpg") || strings.HasSuffix(path, ".log") {
-				os.Remove(path)
-				deletedCount++
+			if IsRecordingFile(path) || strings.HasSuffix(path, ".log") {
+				expired = append(expired, path)
+				expiredBytes += info.Size()
 			}
 		}
-		return nil
 	})
 
-	if err == nil && deletedCount > 0 {
+	if !m.retentionPreviewed {
+		m.retentionPreviewed = true
+		if len(expired) > 0 {
+			logRetentionPreview(expired, expiredBytes, days)
+			return
+		}
+	}
+
+	deletedCount := 0
+	for _, path := range expired {
+		if os.Remove(path) == nil {
+			deletedCount++
+		}
+	}
+	if deletedCount > 0 {
 		log.Printf("Janitor: Cleaned up %d files older than %d days\n", deletedCount, days)
 	}
 }
 
-// checkDiskSpace performs emergency cleanup if disk is full (<15GB)
+// logRetentionPreview is the dry run of the first retention pass since startup
+func logRetentionPreview(paths []string, bytes int64, days int) {
+	log.Printf("Janitor: First retention pass would delete %d files (%d MB, default retention %d days); deleting from the next pass on. Pause the janitor now if this is wrong.\n", len(paths), bytes>>20, days)
+	for i, path := range paths {
+		if i == retentionPreviewPaths {
+			log.Printf("Janitor:   ... and %d more\n", len(paths)-i)
+			break
+		}
+		log.Printf("Janitor:   would delete %s\n", LogicalPath(path))
+	}
+}
+
+// arrivedAt is when the file last changed on this disk (ctime), which for copied
+// or imported footage is the copy time even when mtime was preserved
+func arrivedAt(info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Ctim.Sec, st.Ctim.Nsec)
+	}
+	return info.ModTime()
+}
+
+// emergencyFreeBytes is the hard floor below which cleanup kicks in
+const emergencyFreeBytes = uint64(15 * 1024 * 1024 * 1024) // 15 GB
+
+// checkDiskSpace warns once when free space crosses the configured threshold and
+// performs emergency cleanup if disk is full (<15GB)
 func (m *Manager) checkDiskSpace() {
-	var stat syscall.Statfs_t
-	if err := syscall.Statfs("/recordings", &stat); err != nil {
+	freeBytes, ok := FreeBytes()
+	if !ok {
 		return
 	}
 
-	// Available blocks * size per block
-	freeBytes := stat.Bavail * uint64(stat.Bsize)
-	minFree := uint64(15 * 1024 * 1024 * 1024) // 15 GB
+	var settings models.SystemSettings
+	database.DB.First(&settings)
+	m.checkStorageWarning(freeBytes, settings.StorageWarnThresholdGB)
 
-	if freeBytes < minFree {
+	if freeBytes < emergencyFreeBytes {
 		log.Println("WARNING: Low Disk Space! Triggering emergency cleanup...")
 		// (For MVP, we just rely on retention, but you could add aggressive deletion here)
 	}
-}
\ No newline at end of file
+}
+
+// sendNotification delivers manager notifications; a variable so tests can capture them
+var sendNotification = notify.Send
+
+// checkStorageWarning notifies on the downward crossing only; the flag re-arms once
+// free space recovers, so a disk hovering below the threshold doesn't alert every tick
+func (m *Manager) checkStorageWarning(freeBytes uint64, thresholdGB int) {
+	if thresholdGB <= 0 {
+		m.storageWarned = false
+		return
+	}
+
+	threshold := uint64(thresholdGB) * 1024 * 1024 * 1024
+	if freeBytes >= threshold {
+		m.storageWarned = false
+		return
+	}
+	if m.storageWarned {
+		return
+	}
+
+	m.storageWarned = true
+	freeGB := float64(freeBytes) / (1024 * 1024 * 1024)
+	go sendNotification(notify.Notification{
+		Kind:    "storage_low",
+		Title:   "Storage running low",
+		Message: fmt.Sprintf("Only %.1f GB free on /recordings (warning threshold %d GB)", freeGB, thresholdGB),
+		Data:    map[string]interface{}{"free_bytes": freeBytes, "threshold_gb": thresholdGB},
+		Link:    "/",
+	})
+}
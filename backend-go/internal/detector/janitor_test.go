@@ -0,0 +1,104 @@
+package detector
+
+import (
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+	"nvr-server/internal/notify"
+)
+
+func TestPruneExpiredSessions(t *testing.T) {
+	testDB(t)
+	now := time.Now()
+	database.DB.Create(&[]models.UserSession{
+		{JTI: "expired", UserID: 1, ExpiresAt: now.Add(-time.Minute)},
+		{JTI: "long-expired", UserID: 2, ExpiresAt: now.Add(-30 * 24 * time.Hour)},
+		{JTI: "valid", UserID: 1, ExpiresAt: now.Add(time.Hour)},
+	})
+
+	NewManager().pruneExpiredSessions()
+
+	var left []models.UserSession
+	database.DB.Find(&left)
+	if len(left) != 1 || left[0].JTI != "valid" {
+		t.Errorf("sessions left = %+v, want only the valid one", left)
+	}
+}
+
+// captureNotifications replaces sendNotification, returning the channel every
+// notification is delivered to
+func captureNotifications(t *testing.T) chan notify.Notification {
+	t.Helper()
+	sent := make(chan notify.Notification, 16)
+	prev := sendNotification
+	sendNotification = func(n notify.Notification) error {
+		sent <- n
+		return nil
+	}
+	t.Cleanup(func() { sendNotification = prev })
+	return sent
+}
+
+func TestStorageWarningOncePerCrossing(t *testing.T) {
+	sent := captureNotifications(t)
+	m := NewManager()
+	const gb = 1 << 30
+
+	ticks := []uint64{80 * gb, 40 * gb, 39 * gb, 30 * gb, 60 * gb, 45 * gb, 44 * gb}
+	for _, free := range ticks {
+		m.checkStorageWarning(free, 50)
+	}
+
+	// Two downward crossings (80->40 and 60->45), one notification each
+	for i := 0; i < 2; i++ {
+		select {
+		case n := <-sent:
+			if n.Kind != "storage_low" {
+				t.Errorf("notification kind = %q", n.Kind)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("got %d notifications, want 2", i)
+		}
+	}
+	select {
+	case n := <-sent:
+		t.Errorf("extra notification while still below the threshold: %+v", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A threshold of 0 disables the warning
+	m.checkStorageWarning(1, 0)
+	select {
+	case <-sent:
+		t.Error("notified with the warning disabled")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestJanitorPaused(t *testing.T) {
+	testDB(t)
+	m := NewManager()
+	if m.janitorPaused() {
+		t.Error("paused without a settings row")
+	}
+
+	future := time.Now().Add(time.Hour)
+	settings := models.SystemSettings{AllowRegistration: true, JanitorPausedUntil: &future}
+	database.DB.Create(&settings)
+	if !m.janitorPaused() {
+		t.Error("not paused before the deadline")
+	}
+
+	// A pause past its deadline is cleared, not just ignored
+	past := time.Now().Add(-time.Minute)
+	database.DB.Model(&settings).Update("janitor_paused_until", past)
+	if m.janitorPaused() {
+		t.Error("still paused after the deadline")
+	}
+	database.DB.First(&settings, settings.ID)
+	if settings.JanitorPausedUntil != nil {
+		t.Errorf("expired pause kept: %v", settings.JanitorPausedUntil)
+	}
+}
@@ -0,0 +1,45 @@
+package detector
+
+import (
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// EventFiles lists the absolute paths of everything stored for an event: the
+// clip (every part if split), metadata sidecar, thumbnail, snapshot and preview
+func EventFiles(event models.Event) []string {
+	var files []string
+	if event.VideoPath != "" {
+		videoPath := MediaPath(event.VideoPath)
+		files = append(files, EventParts(videoPath)...)
+		files = append(files, SidecarPath(videoPath))
+	}
+	for _, p := range []string{event.ThumbnailPath, event.SnapshotPath, event.PreviewPath} {
+		if p != "" {
+			files = append(files, MediaPath(p))
+		}
+	}
+	return files
+}
+
+// keptEventFiles returns the files of each camera's newest n events, which
+// retention must leave alone however old they are
+func keptEventFiles(n int) map[string]bool {
+	kept := make(map[string]bool)
+	if n <= 0 {
+		return kept
+	}
+
+	var events []models.Event
+	database.DB.Raw(`SELECT id, camera_id, video_path, thumbnail_path, snapshot_path, preview_path FROM (
+		SELECT events.*, ROW_NUMBER() OVER (PARTITION BY camera_id ORDER BY start_time DESC) AS rn
+		FROM events WHERE video_path <> ''
+	) ranked WHERE rn <= ?`, n).Scan(&events)
+
+	for _, event := range events {
+		for _, f := range EventFiles(event) {
+			kept[f] = true
+		}
+	}
+	return kept
+}
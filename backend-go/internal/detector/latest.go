@@ -0,0 +1,114 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+var (
+	// How often each camera's latest frame is refreshed (0 disables the cache)
+	LatestFrameInterval = config.Duration("NVR_LATEST_FRAME_INTERVAL", 30*time.Second)
+
+	// Frame grabs running at once during a refresh
+	latestFrameWorkers = 4
+)
+
+// Frame is a cached JPEG still of a camera
+type Frame struct {
+	JPEG       []byte
+	CapturedAt time.Time
+}
+
+func (m *Manager) latestFrameLoop() {
+	if LatestFrameInterval <= 0 {
+		return
+	}
+
+	m.refreshLatestFrames()
+	ticker := time.NewTicker(LatestFrameInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.refreshLatestFrames()
+		}
+	}
+}
+
+// refreshLatestFrames grabs one frame from every camera, a few at a time
+func (m *Manager) refreshLatestFrames() {
+	var cameras []models.Camera
+	if err := database.DB.Find(&cameras).Error; err != nil {
+		return
+	}
+
+	// Drop stills of cameras that no longer exist
+	current := make(map[uint]bool, len(cameras))
+	for _, cam := range cameras {
+		current[cam.ID] = true
+	}
+	m.framesMu.Lock()
+	for id := range m.frames {
+		if !current[id] {
+			delete(m.frames, id)
+		}
+	}
+	m.framesMu.Unlock()
+
+	sem := make(chan struct{}, latestFrameWorkers)
+	var wg sync.WaitGroup
+	for _, cam := range cameras {
+		if m.ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			m.CaptureFrame(m.ctx, cam)
+		}()
+	}
+	wg.Wait()
+}
+
+// LatestFrame returns the cached still for a camera, if one has been captured
+func (m *Manager) LatestFrame(camID uint) (Frame, bool) {
+	m.framesMu.Lock()
+	defer m.framesMu.Unlock()
+	f, ok := m.frames[camID]
+	return f, ok
+}
+
+// CaptureFrame grabs a still from the live stream (substream when set, it's
+// cheaper), stores it as the camera's latest frame and returns it
+func (m *Manager) CaptureFrame(ctx context.Context, cam models.Camera) (Frame, error) {
+	if cam.RTSPSubstreamUrl != "" {
+		cam.RTSPUrl = cam.RTSPSubstreamUrl
+	}
+
+	args := []string{"-v", "error"}
+	args = append(args, inputArgs(cam)...)
+	args = append(args, maskArgs(cam)...)
+	args = append(args, "-frames:v", "1", "-q:v", "4", "-f", "image2", "-c:v", "mjpeg", "pipe:1")
+	out, err := runTool(ctx, 10*time.Second, FFmpegBin, args...)
+	if err != nil {
+		return Frame{}, err
+	}
+	if len(out) == 0 {
+		return Frame{}, fmt.Errorf("ffmpeg: no frame")
+	}
+
+	f := Frame{JPEG: out, CapturedAt: time.Now()}
+	m.framesMu.Lock()
+	m.frames[cam.ID] = f
+	m.framesMu.Unlock()
+	return f, nil
+}
@@ -0,0 +1,74 @@
+package detector
+
+import (
+	"context"
+	"testing"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// useFrameFFmpeg stands in for ffmpeg by writing the input URL to stdout as the "JPEG"
+func useFrameFFmpeg(t *testing.T) {
+	t.Helper()
+	prev := FFmpegBin
+	FFmpegBin = stubTool(t, `while [ "$1" != "-i" ]; do shift; done
+printf '%s' "$2"`)
+	t.Cleanup(func() { FFmpegBin = prev })
+}
+
+func TestCaptureFrameCaches(t *testing.T) {
+	useFrameFFmpeg(t)
+	m := NewManager()
+
+	if _, ok := m.LatestFrame(1); ok {
+		t.Fatal("frame cached before any capture")
+	}
+	cam := models.Camera{ID: 1, RTSPUrl: "rtsp://192.0.2.1/main", RTSPSubstreamUrl: "rtsp://192.0.2.1/sub"}
+	f, err := m.CaptureFrame(context.Background(), cam)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(f.JPEG) != "rtsp://192.0.2.1/sub" || f.CapturedAt.IsZero() {
+		t.Errorf("captured %q at %v, want the substream", f.JPEG, f.CapturedAt)
+	}
+	if cached, ok := m.LatestFrame(1); !ok || string(cached.JPEG) != string(f.JPEG) {
+		t.Errorf("LatestFrame = %q, %v", cached.JPEG, ok)
+	}
+
+	// A failed grab keeps the last good frame
+	FFmpegBin = stubTool(t, "exit 1")
+	if _, err := m.CaptureFrame(context.Background(), cam); err == nil {
+		t.Error("failed grab returned no error")
+	}
+	FFmpegBin = stubTool(t, "exit 0")
+	if _, err := m.CaptureFrame(context.Background(), cam); err == nil {
+		t.Error("empty output returned no error")
+	}
+	if cached, _ := m.LatestFrame(1); string(cached.JPEG) != "rtsp://192.0.2.1/sub" {
+		t.Errorf("cache after failures = %q", cached.JPEG)
+	}
+}
+
+func TestRefreshLatestFrames(t *testing.T) {
+	testDB(t)
+	useFrameFFmpeg(t)
+	cams := []models.Camera{
+		{Name: "a", Path: "a", RTSPUrl: "rtsp://192.0.2.1/a", OwnerID: 1},
+		{Name: "b", Path: "b", RTSPUrl: "rtsp://192.0.2.1/b", OwnerID: 1},
+	}
+	database.DB.Create(&cams)
+
+	m := NewManager()
+	m.frames[999] = Frame{JPEG: []byte("gone")}
+	m.refreshLatestFrames()
+
+	for _, cam := range cams {
+		if f, ok := m.LatestFrame(cam.ID); !ok || string(f.JPEG) != cam.RTSPUrl {
+			t.Errorf("camera %s frame = %q, %v", cam.Name, f.JPEG, ok)
+		}
+	}
+	if _, ok := m.LatestFrame(999); ok {
+		t.Error("frame of a deleted camera kept")
+	}
+}
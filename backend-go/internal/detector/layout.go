@@ -0,0 +1,212 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"nvr-server/internal/config"
+)
+
+// DatePartitions stores new event clips under EventRoot/YYYY/MM/DD and new
+// segments under ContinuousDir/YYYY/MM/DD (UTC) instead of one flat directory.
+// Files already written stay where they are; every reader handles both layouts.
+var DatePartitions = config.Bool("NVR_DATE_PARTITIONS", false)
+
+const partitionLayout = "2006/01/02"
+
+// datePartition is the YYYY/MM/DD subdirectory for t
+func datePartition(t time.Time) string {
+	return filepath.FromSlash(t.UTC().Format(partitionLayout))
+}
+
+// EventDir is the directory an event clip started at t is written to
+func EventDir(t time.Time) string {
+	if DatePartitions {
+		return filepath.Join(EventRoot, datePartition(t))
+	}
+	return EventRoot
+}
+
+// isPartitionDir reports whether name looks like a year directory, the top of
+// a date partition (and not continuous/, .corrupt/ or anything else)
+func isPartitionDir(name string) bool {
+	if len(name) != 4 {
+		return false
+	}
+	for _, r := range name {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// WalkEventFiles calls fn for every file in EventRoot and its date partitions
+func WalkEventFiles(fn func(path string, info os.FileInfo)) {
+	entries, err := os.ReadDir(EventRoot)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		path := filepath.Join(EventRoot, e.Name())
+		if !e.IsDir() {
+			if info, err := e.Info(); err == nil {
+				fn(path, info)
+			}
+			continue
+		}
+		if !isPartitionDir(e.Name()) {
+			continue
+		}
+		filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				fn(p, info)
+			}
+			return nil
+		})
+	}
+}
+
+// SegmentDir is the directory a camera's segment starting at t belongs in
+func SegmentDir(camID uint, t time.Time) string {
+	if DatePartitions {
+		return filepath.Join(ContinuousDir(camID), datePartition(t))
+	}
+	return ContinuousDir(camID)
+}
+
+// Segment is one continuous segment file on disk
+type Segment struct {
+	Path  string
+	Name  string
+	Start time.Time
+	Info  os.FileInfo
+}
+
+// CameraSegments lists all of a camera's segments in either layout, by start time
+func CameraSegments(camID uint) []Segment {
+	var segments []Segment
+	filepath.Walk(ContinuousDir(camID), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			segments = appendSegment(segments, path, info)
+		}
+		return nil
+	})
+	sortSegments(segments)
+	return segments
+}
+
+// CameraSegmentsOn lists the camera's segments that started on the UTC day. Only
+// the top directory and that day's partition are read, not the whole tree.
+func CameraSegmentsOn(camID uint, day time.Time) []Segment {
+	dir := ContinuousDir(camID)
+	prefix := day.UTC().Format("20060102")
+
+	var segments []Segment
+	for _, d := range []string{dir, filepath.Join(dir, datePartition(day))} {
+		entries, _ := os.ReadDir(d)
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
+				continue
+			}
+			if info, err := e.Info(); err == nil {
+				segments = appendSegment(segments, filepath.Join(d, e.Name()), info)
+			}
+		}
+	}
+	sortSegments(segments)
+	return segments
+}
+
+func appendSegment(segments []Segment, path string, info os.FileInfo) []Segment {
+	if !IsSegmentFile(info.Name()) {
+		return segments
+	}
+	start, ok := ParseSegmentTime(info.Name())
+	if !ok {
+		return segments
+	}
+	return append(segments, Segment{Path: path, Name: info.Name(), Start: start, Info: info})
+}
+
+func sortSegments(segments []Segment) {
+	sort.Slice(segments, func(i, j int) bool { return segments[i].Start.Before(segments[j].Start) })
+}
+
+// SegmentPath locates a camera's segment by file name: in its date partition if
+// it is there, otherwise in the flat directory. Names carry their start time,
+// so "continuous/<id>/<name>" URLs keep working whichever layout wrote the file.
+func SegmentPath(camID uint, name string) string {
+	dir := ContinuousDir(camID)
+	if start, ok := ParseSegmentTime(name); ok {
+		partitioned := filepath.Join(dir, datePartition(start), name)
+		if _, err := os.Stat(partitioned); err == nil {
+			return partitioned
+		}
+	}
+	return filepath.Join(dir, name)
+}
+
+// ensureSegmentPartitions creates today's and tomorrow's partitions for each
+// running continuous recorder. ffmpeg's segment muxer doesn't create
+// directories, so the one a segment rolls into at midnight has to exist already.
+func (m *Manager) ensureSegmentPartitions() {
+	if !DatePartitions {
+		return
+	}
+	m.mu.Lock()
+	ids := make([]uint, 0, len(m.ContinuousProcs))
+	for id := range m.ContinuousProcs {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range ids {
+		makeSegmentPartitions(id, now)
+	}
+}
+
+func makeSegmentPartitions(camID uint, now time.Time) {
+	for _, t := range []time.Time{now, now.Add(24 * time.Hour)} {
+		os.MkdirAll(filepath.Join(ContinuousDir(camID), datePartition(t)), 0755)
+	}
+}
+
+// pruneEmptyPartitions removes date partitions retention has emptied. Today's
+// and later ones are kept: recorders are about to write into them.
+func pruneEmptyPartitions() {
+	today := time.Now().UTC().Format(partitionLayout)
+	prune := func(root string) {
+		var dirs []string
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() || path == root {
+				return nil
+			}
+			rel, _ := relativeTo(root, path)
+			if !isPartitionDir(strings.SplitN(rel, "/", 2)[0]) {
+				return filepath.SkipDir
+			}
+			if len(rel) <= len(today) && rel < today[:len(rel)] {
+				dirs = append(dirs, path)
+			}
+			return nil
+		})
+		// Deepest first, so emptied days let their month and year go too;
+		// os.Remove refuses directories that still hold anything
+		for i := len(dirs) - 1; i >= 0; i-- {
+			os.Remove(dirs[i])
+		}
+	}
+
+	prune(EventRoot)
+	cams, _ := os.ReadDir(ContinuousRoot)
+	for _, e := range cams {
+		if id, ok := parseCameraID(e.Name()); ok && e.IsDir() {
+			prune(ContinuousDir(id))
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSegmentTimeIsUTC(t *testing.T) {
+	got, ok := ParseSegmentTime("20240102-003000.mp4")
+	want := time.Date(2024, 1, 2, 0, 30, 0, 0, time.UTC)
+	if !ok || !got.Equal(want) || got.Location() != time.UTC {
+		t.Errorf("ParseSegmentTime = %v, %v; want %v", got, ok, want)
+	}
+	for _, bad := range []string{"event_1.mp4", "2024-01-02.mp4", "20241302-000000.mkv"} {
+		if _, ok := ParseSegmentTime(bad); ok {
+			t.Errorf("ParseSegmentTime(%q) accepted", bad)
+		}
+	}
+}
+
+func TestCameraSegmentsOnUTCDay(t *testing.T) {
+	testRoots(t)
+	dir := ContinuousDir(4)
+	for _, name := range []string{"20240101-233000.mp4", "20240102-003000.mp4", "20240102-120000.mkv", "notes.txt"} {
+		writeSegment(t, filepath.Join(dir, name))
+	}
+
+	// 19:30 on Jan 1 in New York is 00:30 on Jan 2 UTC, so that's the day listed
+	local := time.Date(2024, 1, 1, 19, 30, 0, 0, time.FixedZone("EST", -5*3600))
+	segments := CameraSegmentsOn(4, local)
+	if len(segments) != 2 || segments[0].Name != "20240102-003000.mp4" || segments[1].Name != "20240102-120000.mkv" {
+		t.Fatalf("segments = %+v", segments)
+	}
+	if !segments[0].Start.Equal(local) {
+		t.Errorf("start = %v, want the instant %v", segments[0].Start, local)
+	}
+}
+
+// writeSegment creates an empty segment file, making its directory
+func writeSegment(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// usePartitions turns date partitions on for the rest of the test
+func usePartitions(t *testing.T) {
+	t.Helper()
+	prev := DatePartitions
+	DatePartitions = true
+	t.Cleanup(func() { DatePartitions = prev })
+}
+
+func TestDatePartitionedLayout(t *testing.T) {
+	testRoots(t)
+	at := time.Date(2024, 1, 2, 23, 30, 0, 0, time.FixedZone("EST", -5*3600))
+	if got := SegmentDir(4, at); got != ContinuousDir(4) {
+		t.Errorf("flat SegmentDir = %s", got)
+	}
+
+	usePartitions(t)
+	// 23:30 EST is already Jan 3 in UTC
+	if got, want := SegmentDir(4, at), filepath.Join(ContinuousDir(4), "2024", "01", "03"); got != want {
+		t.Errorf("SegmentDir = %s, want %s", got, want)
+	}
+	if got, want := EventDir(at), filepath.Join(EventRoot, "2024", "01", "03"); got != want {
+		t.Errorf("EventDir = %s, want %s", got, want)
+	}
+}
+
+func TestCameraSegmentsBothLayouts(t *testing.T) {
+	testRoots(t)
+	dir := ContinuousDir(4)
+	flat := filepath.Join(dir, "20240102-120000.mp4")
+	partitioned := filepath.Join(dir, "2024", "01", "02", "20240102-000000.mp4")
+	writeSegment(t, flat)
+	writeSegment(t, partitioned)
+	writeSegment(t, filepath.Join(dir, "2024", "01", "03", "20240103-000000.mp4"))
+
+	all := CameraSegments(4)
+	if len(all) != 3 || all[0].Path != partitioned || all[1].Path != flat {
+		t.Fatalf("CameraSegments = %+v", all)
+	}
+	day := CameraSegmentsOn(4, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+	if len(day) != 2 || day[0].Path != partitioned || day[1].Path != flat {
+		t.Errorf("CameraSegmentsOn = %+v", day)
+	}
+
+	if got := SegmentPath(4, "20240102-000000.mp4"); got != partitioned {
+		t.Errorf("SegmentPath found %s, want %s", got, partitioned)
+	}
+	if got := SegmentPath(4, "20240102-120000.mp4"); got != flat {
+		t.Errorf("SegmentPath found %s, want %s", got, flat)
+	}
+}
+
+func TestWalkEventFiles(t *testing.T) {
+	testRoots(t)
+	want := map[string]bool{
+		filepath.Join(EventRoot, "event_1_20240102-120000.mp4"):                     true,
+		filepath.Join(EventRoot, "2024", "01", "02", "event_1_20240102-130000.mp4"): true,
+	}
+	for path := range want {
+		writeSegment(t, path)
+	}
+	// Not a date partition: skipped
+	writeSegment(t, filepath.Join(EventRoot, ".corrupt", "event_1_20240101-000000.mp4"))
+
+	got := make(map[string]bool)
+	WalkEventFiles(func(path string, info os.FileInfo) { got[path] = true })
+	if len(got) != len(want) {
+		t.Errorf("walked %v, want %v", got, want)
+	}
+	for path := range want {
+		if !got[path] {
+			t.Errorf("%s not walked", path)
+		}
+	}
+}
+
+func TestSegmentPartitionsLifecycle(t *testing.T) {
+	testRoots(t)
+	usePartitions(t)
+	m := NewManager()
+	m.ContinuousProcs[4] = &ContinuousProcess{}
+	m.ensureSegmentPartitions()
+
+	now := time.Now()
+	today := SegmentDir(4, now)
+	tomorrow := SegmentDir(4, now.Add(24*time.Hour))
+	for _, dir := range []string{today, tomorrow} {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			t.Fatalf("partition %s not created: %v", dir, err)
+		}
+	}
+
+	// Emptied past partitions go, up to the year; today's and ones holding files stay
+	old := filepath.Join(ContinuousDir(4), "2020", "03", "04")
+	kept := filepath.Join(ContinuousDir(4), "2020", "03", "05", "20200305-000000.mp4")
+	emptyEvents := filepath.Join(EventRoot, "2021", "06", "07")
+	for _, dir := range []string{old, emptyEvents} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeSegment(t, kept)
+
+	pruneEmptyPartitions()
+	for _, dir := range []string{old, emptyEvents, filepath.Join(EventRoot, "2021")} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("empty partition %s kept", dir)
+		}
+	}
+	for _, path := range []string{kept, today, tomorrow} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s removed: %v", path, err)
+		}
+	}
+}
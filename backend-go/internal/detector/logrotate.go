@@ -0,0 +1,73 @@
+package detector
+
+import (
+	"os"
+	"sync"
+
+	"nvr-server/internal/config"
+)
+
+// Size at which an ffmpeg log is rotated; one previous generation (.1) is kept
+var MaxLogBytes = int64(config.Int("NVR_MAX_LOG_MB", 10)) << 20
+
+// RotatingLog is an append-only log file that rotates itself to path.1 once it
+// grows past its limit, so long-running ffmpeg processes can't fill the disk
+type RotatingLog struct {
+	mu   sync.Mutex
+	path string
+	max  int64
+	f    *os.File
+	size int64
+}
+
+// OpenRotatingLog opens (or continues) the log at path
+func OpenRotatingLog(path string, max int64) (*RotatingLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	l := &RotatingLog{path: path, max: max, f: f}
+	if info, err := f.Stat(); err == nil {
+		l.size = info.Size()
+	}
+	return l, nil
+}
+
+func (l *RotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return len(p), nil
+	}
+	if l.max > 0 && l.size > 0 && l.size+int64(len(p)) > l.max {
+		l.rotate()
+	}
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file to path.1 and starts an empty one. Callers hold l.mu.
+func (l *RotatingLog) rotate() {
+	l.f.Close()
+	os.Rename(l.path, l.path+".1")
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		// Keep accepting writes so ffmpeg never blocks on a full stderr pipe
+		l.f = nil
+		return
+	}
+	l.f, l.size = f, 0
+}
+
+func (l *RotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,68 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "continuous_1.log")
+	os.WriteFile(path, []byte("earlier run\n"), 0644)
+
+	l, err := OpenRotatingLog(path, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Continues the existing file until the cap
+	l.Write([]byte("0123456789\n"))
+	if data, _ := os.ReadFile(path); string(data) != "earlier run\n0123456789\n" || exists(path+".1") {
+		t.Fatalf("before the cap: %q, rotated %v", data, exists(path+".1"))
+	}
+
+	l.Write([]byte("this line passes the cap\n"))
+	if data, _ := os.ReadFile(path + ".1"); string(data) != "earlier run\n0123456789\n" {
+		t.Errorf("rotated generation = %q", data)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "this line passes the cap\n" {
+		t.Errorf("current log = %q", data)
+	}
+
+	// Only one previous generation is kept
+	l.Write([]byte(strings.Repeat("x", 20) + "\n"))
+	if data, _ := os.ReadFile(path + ".1"); string(data) != "this line passes the cap\n" {
+		t.Errorf("second rotation left .1 = %q", data)
+	}
+	matches, _ := filepath.Glob(path + ".*")
+	if len(matches) != 1 {
+		t.Errorf("generations on disk: %v", matches)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Writes after Close are swallowed so a late ffmpeg write can't fail
+	if n, err := l.Write([]byte("late")); n != 4 || err != nil {
+		t.Errorf("write after close = %d, %v", n, err)
+	}
+}
+
+func TestRotatingLogOversizedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "event_1.log")
+	l, err := OpenRotatingLog(path, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// A single write larger than the cap goes into a fresh file whole
+	l.Write([]byte("a much longer line than the cap\n"))
+	if data, _ := os.ReadFile(path); string(data) != "a much longer line than the cap\n" || exists(path+".1") {
+		t.Errorf("oversized first write: %q, rotated %v", data, exists(path+".1"))
+	}
+	l.Write([]byte("next\n"))
+	if data, _ := os.ReadFile(path); string(data) != "next\n" {
+		t.Errorf("after rotation = %q", data)
+	}
+}
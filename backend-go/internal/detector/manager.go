@@ -2,6 +2,7 @@ package detector
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -9,51 +10,132 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"nvr-server/internal/database"
 	"nvr-server/internal/models"
+	"nvr-server/internal/notify"
 )
 
 // Start kicks off the loops
 func (m *Manager) Start() {
 	// Ensure directories exist
-	os.MkdirAll("/recordings", 0755)
-	os.MkdirAll("/var/log/nvr", 0755)
+	os.MkdirAll(EventRoot, 0755)
+	os.MkdirAll(ContinuousRoot, 0755)
+	os.MkdirAll(LogDir, 0755)
 
 	log.Println("--- Detector Manager Started ---")
-	m.SyncCameras()
-	go m.StartJanitor()
-	go m.monitorLoop()
+	startedAt := time.Now()
+	m.spawn(func() { m.recoverUnfinishedEvents(startedAt) })
+	m.spawn(m.StartJanitor)
+	m.spawn(m.monitorLoop)
+	m.spawn(m.bandwidthLoop)
+	m.spawn(m.latestFrameLoop)
+}
+
+// Stop cancels the background loops and waits for them (and any thumbnail or probe
+// workers) to return, or for ctx to expire. Recording processes are left alone.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.loopMu.Lock()
+	m.stopping = true
+	m.loopMu.Unlock()
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("--- Detector Manager Stopped ---")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// spawn runs fn in a goroutine tracked by Stop; nothing new starts once stopping
+func (m *Manager) spawn(fn func()) {
+	m.loopMu.Lock()
+	defer m.loopMu.Unlock()
+	if m.stopping {
+		return
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		fn()
+	}()
+}
+
+// sleep waits for d, returning false if the manager is stopped first
+func (m *Manager) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-m.ctx.Done():
+		return false
+	}
 }
 
 func (m *Manager) monitorLoop() {
+	if !m.awaitMediaMTX() {
+		return
+	}
+	m.synced.Store(true)
+	m.SyncCameras()
+
 	ticker := time.NewTicker(10 * time.Second)
-	for range ticker.C {
-		m.SyncCameras()
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.SyncCameras()
+		}
 	}
 }
 
 func (m *Manager) SyncCameras() {
+	if !m.synced.Load() {
+		return
+	}
+
 	var cameras []models.Camera
 	if err := database.DB.Find(&cameras).Error; err != nil {
 		return
 	}
 
+	// Read once per sync, before the lock, rather than per camera under it
+	var settings models.SystemSettings
+	database.DB.First(&settings)
+	free, hasSpace := hasRecordingSpace(ContinuousRoot, settings.MinFreeRecordingGB)
+
+	// 0. Register with MediaMTX (dials the cameras, so not under the lock)
+	m.registerCameras(cameras)
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	for _, cam := range cameras {
-		// 0. Register with MediaMTX
-		m.registerMediaMTX(cam)
+		// Probe resolution/codec once per RTSP URL (slow, so off the lock)
+		if cam.RTSPUrl != "" && m.ProbedURLs[cam.ID] != cam.RTSPUrl {
+			m.ProbedURLs[cam.ID] = cam.RTSPUrl
+			m.spawn(func() { m.refreshStreamInfo(cam) })
+		}
 
 		// 1. Handle Continuous Recording
 		if cam.ContinuousRecording {
-			if _, exists := m.ContinuousProcs[cam.ID]; !exists {
-				m.spawnContinuous(cam)
+			if _, exists := m.ContinuousProcs[cam.ID]; !exists && !settings.MaintenanceMode {
+				m.spawnContinuous(cam, settings.MinFreeRecordingGB, free, hasSpace)
 			}
 		} else {
 			if proc, exists := m.ContinuousProcs[cam.ID]; exists {
@@ -68,15 +150,59 @@ func (m *Manager) SyncCameras() {
 	}
 }
 
-func (m *Manager) registerMediaMTX(cam models.Camera) {
-	if cam.RTSPUrl == "" { return }
+// registerCameras registers every camera whose stream URL MediaMTX doesn't have
+// yet. The reachability dials and API calls run in parallel without m.mu, so
+// one dead camera neither stalls the others nor blocks recordings.
+func (m *Manager) registerCameras(cameras []models.Camera) {
+	now := time.Now()
+	var pending []models.Camera
+	m.mu.Lock()
+	for _, cam := range cameras {
+		if cam.RTSPUrl == "" || m.RegisteredPaths[cam.ID] == cam.RTSPUrl {
+			continue
+		}
+		if retry, ok := m.reachRetry[cam.ID]; ok && retry.url == cam.RTSPUrl && now.Before(retry.next) {
+			continue
+		}
+		pending = append(pending, cam)
+	}
+	m.mu.Unlock()
 
-	if lastURL, ok := m.RegisteredPaths[cam.ID]; ok && lastURL == cam.RTSPUrl {
+	var wg sync.WaitGroup
+	for _, cam := range pending {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.registerMediaMTX(cam)
+		}()
+	}
+	wg.Wait()
+}
+
+// registerMediaMTX adds or updates the camera's MediaMTX path. Called without m.mu.
+func (m *Manager) registerMediaMTX(cam models.Camera) {
+	// Don't leave dead paths in MediaMTX; the next sync tries again
+	if err := checkReachable(cam.RTSPUrl); err != nil {
+		m.mu.Lock()
+		if !m.unreachable[cam.ID] {
+			log.Printf("[%s] Camera unreachable, skipping MediaMTX registration: %v", cam.Name, err)
+		}
+		m.unreachable[cam.ID] = true
+		m.backOffReachability(cam)
+		m.maybeHealCameraIP(cam)
+		m.mu.Unlock()
 		return
 	}
+	m.mu.Lock()
+	if m.unreachable[cam.ID] {
+		log.Printf("[%s] Camera reachable again", cam.Name)
+		delete(m.unreachable, cam.ID)
+	}
+	delete(m.reachRetry, cam.ID)
+	m.mu.Unlock()
 
 	payload := map[string]interface{}{
-		"source":         cam.RTSPUrl,
+		"source":         streamURL(cam.RTSPUrl),
 		"sourceOnDemand": false, 
 	}
 	jsonData, _ := json.Marshal(payload)
@@ -107,87 +233,261 @@ func (m *Manager) registerMediaMTX(cam models.Camera) {
 			defer respPost.Body.Close()
 		}
 	}
+	m.mu.Lock()
 	m.RegisteredPaths[cam.ID] = cam.RTSPUrl
+	m.mu.Unlock()
 	log.Printf("[%s] Registered with MediaMTX (Cached)", cam.Name)
 }
 
-func (m *Manager) spawnContinuous(cam models.Camera) {
+// spawnContinuous starts the camera's 24/7 recorder. free and hasSpace are
+// SyncCameras' reading of ContinuousRoot against floorGB. Callers hold m.mu.
+func (m *Manager) spawnContinuous(cam models.Camera, floorGB int, free uint64, hasSpace bool) {
+	if !hasSpace {
+		if !m.noSpace[cam.ID] {
+			log.Printf("[%s] Not starting 24/7 recording: only %d MB free (floor %d GB)\n", cam.Name, free>>20, floorGB)
+			m.noSpace[cam.ID] = true
+		}
+		return
+	}
+	delete(m.noSpace, cam.ID)
+
 	log.Printf("[%s] Starting 24/7 Recording...\n", cam.Name)
-	outDir := filepath.Join("/recordings", "continuous", strconv.Itoa(int(cam.ID)))
+	if needsTranscode(cam) {
+		log.Printf("[%s] WARNING: H.264 transcoding enabled, expect significant CPU use\n", cam.Name)
+	}
+	outDir := ContinuousDir(cam.ID)
 	os.MkdirAll(outDir, 0755)
-	outPattern := filepath.Join(outDir, "%Y%m%d-%H%M%S.mp4")
-
-	cmd := exec.Command("ffmpeg",
-		"-rtsp_transport", "tcp",
-		"-i", cam.RTSPUrl,
-		"-c:v", "copy",
-		"-c:a", "copy",
-		"-f", "segment",
+	muxArgs, ext := segmentMuxArgs(cam)
+	outPattern := filepath.Join(outDir, "%Y%m%d-%H%M%S"+ext)
+	if DatePartitions {
+		makeSegmentPartitions(cam.ID, time.Now())
+		outPattern = filepath.Join(outDir, "%Y", "%m", "%d", "%Y%m%d-%H%M%S"+ext)
+	}
+
+	args := inputArgs(cam)
+	args = append(args, codecArgs(cam)...)
+	args = append(args, "-f", "segment")
+	args = append(args, muxArgs...)
+	args = append(args,
 		"-segment_time", "900",
 		"-strftime", "1",
 		"-reset_timestamps", "1",
-		outPattern,
+		"-y", outPattern,
 	)
+	cmd := ffmpegCommand(args...)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-	logFile, _ := os.Create(fmt.Sprintf("/var/log/nvr/continuous_%d.log", cam.ID))
-	cmd.Stderr = logFile
+	// -strftime uses the process timezone; pin it so names are UTC regardless of container TZ
+	cmd.Env = append(os.Environ(), "TZ=UTC")
+	logFile, err := OpenRotatingLog(filepath.Join(LogDir, fmt.Sprintf("continuous_%d.log", cam.ID)), MaxLogBytes)
+	if err == nil {
+		cmd.Stderr = logFile
+	}
 
-	if err := cmd.Start(); err != nil { return }
-	m.ContinuousProcs[cam.ID] = &ContinuousProcess{Process: cmd, LogFile: logFile}
+	if err := cmd.Start(); err != nil {
+		if logFile != nil {
+			logFile.Close()
+		}
+		log.Printf("[%s] Continuous recording failed to start: %v\n", cam.Name, err)
+		m.statsFor(cam.ID).Failed++
+		return
+	}
+	m.ContinuousProcs[cam.ID] = &ContinuousProcess{Process: cmd, LogFile: logFile, StartedAt: time.Now()}
 }
 
-func (m *Manager) StartEventRecord(camID uint) error {
+// StartEventRecord begins (or joins) the event recording for a camera. Each detector
+// identifies itself with a source so overlapping detectors share one recording.
+func (m *Manager) StartEventRecord(camID uint, source, reason string, classes []string) error {
+	if reason == "" {
+		reason = models.ReasonMotion
+	}
+
+	// Rows are read before taking m.mu so a slow database doesn't stall every recorder
+	var cam models.Camera
+	camErr := database.DB.First(&cam, camID).Error
+	var settings models.SystemSettings
+	database.DB.First(&settings)
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.ActiveRecordings[camID]; exists { return nil }
+	rec, exists := m.ActiveRecordings[camID]
+	if exists && !rec.Closing {
+		rec.Sources[source] = true
+		if len(classes) > 0 {
+			mergeEventClasses(rec.EventID, classes)
+		}
+		return nil
+	}
+	if q, queued := m.queuedEvents[camID]; queued {
+		q.Sources[source] = true
+		for _, c := range classes {
+			if !slices.Contains(q.Classes, c) {
+				q.Classes = append(q.Classes, c)
+			}
+		}
+		return nil
+	}
 
-	var cam models.Camera
-	if err := database.DB.First(&cam, camID).Error; err != nil { return err }
+	if settings.MaintenanceMode {
+		log.Printf("Maintenance mode: ignoring event for Camera %d\n", camID)
+		return nil
+	}
+	if camErr != nil {
+		return camErr
+	}
 
+	// A recording being finalized keeps its slot until the new one takes it over
+	if limit := settings.MaxConcurrentEventRecordings; limit > 0 && !exists && len(m.ActiveRecordings) >= limit {
+		if settings.EventCapacityPolicy == CapacityQueue {
+			log.Printf("At capacity (%d recordings): queueing event for Camera %d\n", limit, camID)
+			m.queuedEvents[camID] = &QueuedEvent{Sources: map[string]bool{source: true}, Reason: reason, Classes: classes}
+			m.eventQueue = append(m.eventQueue, camID)
+			return nil
+		}
+
+		log.Printf("At capacity (%d recordings): dropping event for Camera %d\n", limit, camID)
+		m.dropEvent(cam, models.ReasonDroppedCapacity)
+		return nil
+	}
+
+	return m.beginEventRecord(cam, settings, map[string]bool{source: true}, reason, classes)
+}
+
+// dropEvent records a zero-length event so the refusal shows up in the timeline
+func (m *Manager) dropEvent(cam models.Camera, reason string) {
+	now := time.Now()
+	database.DB.Create(&models.Event{
+		CameraID:  cam.ID,
+		UserID:    cam.OwnerID,
+		StartTime: now,
+		EndTime:   now,
+		Reason:    reason,
+	})
+	m.statsFor(cam.ID).Discarded++
+}
+
+// beginEventRecord creates the event row and spawns its ffmpeg. Callers hold m.mu.
+func (m *Manager) beginEventRecord(cam models.Camera, settings models.SystemSettings, sources map[string]bool, reason string, classes []string) error {
+	camID := cam.ID
+	if free, ok := hasRecordingSpace(EventRoot, settings.MinFreeRecordingGB); !ok {
+		log.Printf("[%s] Low disk space (%d MB free, floor %d GB): dropping event\n", cam.Name, free>>20, settings.MinFreeRecordingGB)
+		m.dropEvent(cam, models.ReasonDroppedNoSpace)
+		return ErrNoSpace
+	}
 	now := time.Now()
-	filename := fmt.Sprintf("event_%d_%s.mp4", camID, now.Format("20060102-150405"))
-	relPath := filepath.Join("recordings", filename)
-	absPath := filepath.Join("/", relPath)
+	eventDir := EventDir(now)
+	os.MkdirAll(eventDir, 0755)
+	base := filepath.Join(eventDir, fmt.Sprintf("event_%d_%s", camID, now.Format("20060102-150405")))
+	absPath := base + ".mp4"
+	var outArgs []string
+	if settings.EventPartMinutes > 0 {
+		var pattern string
+		outArgs, pattern = eventPartArgs(base, settings.EventPartMinutes)
+		outArgs = append(outArgs, "-y", pattern)
+		absPath = base + firstPartSuffix
+	} else {
+		outArgs = []string{
+			"-f", "mp4",
+			"-movflags", "frag_keyframe+empty_moov",
+			"-y", absPath,
+		}
+	}
+	relPath := LogicalPath(absPath)
 
 	event := models.Event{
 		CameraID:  cam.ID,
 		UserID:    cam.OwnerID,
 		StartTime: now,
 		VideoPath: relPath,
-		Reason:    "motion",
+		Reason:    reason,
+	}
+	names, confidence := splitClasses(classes)
+	if len(names) > 0 {
+		classJSON, _ := json.Marshal(names)
+		event.DetectedClasses = string(classJSON)
 	}
 	database.DB.Create(&event)
+	recordEventClasses(event.ID, names, confidence)
 
-	cmd := exec.Command("ffmpeg",
-		"-rtsp_transport", "tcp",
-		"-i", cam.RTSPUrl,
-		"-c:v", "copy",
-		"-c:a", "copy",
-		"-f", "mp4",
-		"-movflags", "frag_keyframe+empty_moov",
-		absPath,
-	)
+	args := inputArgs(cam)
+	args = append(args, codecArgs(cam)...)
+	args = append(args, outArgs...)
+	cmd := ffmpegCommand(args...)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	logFile, err := OpenRotatingLog(filepath.Join(LogDir, fmt.Sprintf("event_%d.log", camID)), MaxLogBytes)
+	if err == nil {
+		cmd.Stderr = logFile
+	}
 	
-	if err := cmd.Start(); err != nil { return err }
+	if err := cmd.Start(); err != nil {
+		if logFile != nil {
+			logFile.Close()
+		}
+		log.Printf("Event %d for Camera %d failed to start: %v\n", event.ID, camID, err)
+		m.statsFor(camID).Failed++
+		database.DB.Delete(&models.Event{}, event.ID)
+		return err
+	}
+	m.statsFor(camID).Started++
 
 	m.ActiveRecordings[camID] = &ActiveRecording{
 		Process:   cmd,
 		EventID:   event.ID,
 		VideoPath: absPath,
 		StartTime: now,
+		LogFile:   logFile,
+		Sources:   sources,
 	}
 	
+	eventID := event.ID
+	if cam.ProvisionalThumbnail {
+		m.spawn(func() { m.grabProvisionalThumbnail(cam, eventID, absPath) })
+	}
+	if cam.SnapshotOnEvent {
+		m.spawn(func() { m.grabEventSnapshot(cam, eventID, absPath) })
+	}
+	if settings.MaxEventMinutes > 0 {
+		limit := time.Duration(settings.MaxEventMinutes) * time.Minute
+		m.spawn(func() { m.eventTimeout(cam, eventID, limit) })
+	}
+
 	log.Printf("Started Event %d for Camera %d\n", event.ID, camID)
 	return nil
 }
 
-func (m *Manager) StopEventRecord(camID uint) error {
+// StopEventRecord releases a source's hold on the camera's recording; the clip is
+// only finalized once every source that started it has ended.
+func (m *Manager) StopEventRecord(camID uint, source string) error {
 	m.mu.Lock()
 
 	rec, exists := m.ActiveRecordings[camID]
 	if !exists {
+		if q, queued := m.queuedEvents[camID]; queued {
+			delete(q.Sources, source)
+			if len(q.Sources) == 0 {
+				m.dequeueEvent(camID)
+			}
+		}
+		m.mu.Unlock()
+		return nil
+	}
+
+	delete(rec.Sources, source)
+	if len(rec.Sources) > 0 {
+		m.mu.Unlock()
+		return nil
+	}
+	m.mu.Unlock()
+
+	return m.finishEventRecord(camID)
+}
+
+func (m *Manager) finishEventRecord(camID uint) error {
+	m.mu.Lock()
+
+	rec, exists := m.ActiveRecordings[camID]
+	// Closing: another stop, the timeout or a delayed stop is already finalizing it
+	if !exists || rec.Closing || len(rec.Sources) > 0 {
 		m.mu.Unlock()
 		return nil
 	}
@@ -195,13 +495,25 @@ func (m *Manager) StopEventRecord(camID uint) error {
 	duration := time.Since(rec.StartTime)
 	if duration < 5*time.Second {
 		m.mu.Unlock()
-		go func(id uint, delay time.Duration) {
-			time.Sleep(delay)
-			m.delayedStop(id)
-		}(camID, 5*time.Second - duration)
+		delay := 5*time.Second - duration
+		m.spawn(func() {
+			if m.sleep(delay) {
+				m.delayedStop(camID)
+			}
+		})
 		return nil
 	}
 
+	rec.Closing = true
+	m.mu.Unlock()
+
+	m.finalizeEventRecord(camID, rec)
+	return nil
+}
+
+// finalizeEventRecord stops a recording's ffmpeg and keeps or discards the clip.
+// Only the caller that set rec.Closing may run it.
+func (m *Manager) finalizeEventRecord(camID uint, rec *ActiveRecording) {
 	if rec.Process.Process != nil {
 		rec.Process.Process.Signal(syscall.SIGTERM)
 	}
@@ -209,8 +521,6 @@ func (m *Manager) StopEventRecord(camID uint) error {
 	done := make(chan error, 1)
 	go func() { done <- rec.Process.Wait() }()
 
-	m.mu.Unlock()
-
 	select {
 	case <-done:
 	case <-time.After(2 * time.Second):
@@ -218,43 +528,220 @@ func (m *Manager) StopEventRecord(camID uint) error {
 			rec.Process.Process.Kill()
 		}
 	}
+	if rec.LogFile != nil {
+		rec.LogFile.Close()
+	}
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	// Validate File (outside the lock, ffprobe can take a moment)
+	var settings models.SystemSettings
+	database.DB.First(&settings)
+	isValid, why := validateEventFile(rec.VideoPath, settings.MinEventSeconds)
 
-	// Validate File
-	info, err := os.Stat(rec.VideoPath)
-	isValid := false
-	if err == nil && info.Size() > 50000 { 
-		isValid = true
-	}
+	m.mu.Lock()
 
 	if !isValid {
-		log.Printf("Event %d discarded (too small).", rec.EventID)
-		os.Remove(rec.VideoPath)
+		log.Printf("Event %d discarded (%s).", rec.EventID, why)
+		m.statsFor(camID).Discarded++
+		for _, part := range EventParts(rec.VideoPath) {
+			os.Remove(part)
+		}
+		os.Remove(provisionalThumbPath(rec.VideoPath))
+		os.Remove(snapshotPath(rec.VideoPath))
 		database.DB.Delete(&models.Event{}, rec.EventID)
 	} else {
 		var event models.Event
 		if err := database.DB.First(&event, rec.EventID).Error; err == nil {
 			event.EndTime = time.Now()
-			go m.generateThumbnail(rec.VideoPath, event.ID)
+			event.Parts = finalizeEventParts(rec.VideoPath)
+			event.AutoTerminated = rec.TimedOut
+			if rec.TimedOut {
+				// Stands out in the reason filter; the detected classes still say what started it
+				event.Reason = models.ReasonTimeout
+			}
+			m.statsFor(camID).Finalized++
+			videoPath, eventID, boxes := rec.VideoPath, event.ID, rec.Boxes
+			m.spawn(func() { m.generateThumbnail(videoPath, eventID) })
+			if loadPreviewSetting() {
+				m.spawn(func() { m.generatePreview(videoPath, eventID) })
+			}
 			database.DB.Save(&event)
+			m.spawn(func() { writeSidecar(event, videoPath, boxes) })
 		}
 	}
 
-	delete(m.ActiveRecordings, camID)
-	return nil
+	// An event that started meanwhile has its own recording in this slot
+	if m.ActiveRecordings[camID] == rec {
+		delete(m.ActiveRecordings, camID)
+	}
+	m.mu.Unlock()
+
+	m.startQueuedEvent()
+}
+
+// eventTimeout closes an event that is still recording after limit, which means
+// every source that started it failed to send motion-end
+func (m *Manager) eventTimeout(cam models.Camera, eventID uint, limit time.Duration) {
+	if !m.sleep(limit) {
+		return
+	}
+
+	m.mu.Lock()
+	rec, exists := m.ActiveRecordings[cam.ID]
+	// A stop that is already finalizing the event wins; it ended normally
+	if !exists || rec.EventID != eventID || rec.Closing {
+		m.mu.Unlock()
+		return
+	}
+	rec.TimedOut = true
+	rec.Closing = true
+	sources := make([]string, 0, len(rec.Sources))
+	for s := range rec.Sources {
+		sources = append(sources, s)
+	}
+	rec.Sources = make(map[string]bool)
+	m.mu.Unlock()
+
+	log.Printf("Event %d for Camera %d hit the %s cap without a motion end; closing it\n", eventID, cam.ID, limit)
+	go sendNotification(notify.Notification{
+		Kind:    "event_timeout",
+		Title:   "Recording closed by timeout",
+		Message: fmt.Sprintf("%s recorded for %s without a motion-end webhook; the AI worker may be down", cam.Name, limit),
+		Data:    map[string]interface{}{"camera_id": cam.ID, "event_id": eventID, "sources": sources},
+		Link:    fmt.Sprintf("/?event=%d", eventID),
+	})
+	m.finalizeEventRecord(cam.ID, rec)
+}
+
+// dequeueEvent drops a camera from the capacity queue. Callers hold m.mu.
+func (m *Manager) dequeueEvent(camID uint) {
+	delete(m.queuedEvents, camID)
+	for i, id := range m.eventQueue {
+		if id == camID {
+			m.eventQueue = append(m.eventQueue[:i], m.eventQueue[i+1:]...)
+			break
+		}
+	}
+}
+
+// startQueuedEvent starts the oldest queued event once a recording slot frees up.
+// Settings and camera rows are loaded before m.mu is taken, so callers must not
+// hold it.
+func (m *Manager) startQueuedEvent() {
+	for {
+		m.mu.Lock()
+		queued := slices.Clone(m.eventQueue)
+		m.mu.Unlock()
+		if len(queued) == 0 {
+			return
+		}
+
+		var settings models.SystemSettings
+		database.DB.First(&settings)
+		var cameras []models.Camera
+		database.DB.Where("id IN ?", queued).Find(&cameras)
+		loaded := make(map[uint]models.Camera, len(cameras))
+		for _, cam := range cameras {
+			loaded[cam.ID] = cam
+		}
+
+		if !m.startLoadedEvent(settings, queued, loaded) {
+			return
+		}
+	}
+}
+
+// startLoadedEvent does startQueuedEvent's work under m.mu with the rows already
+// loaded. It reports true when the queue head was queued after they were loaded,
+// meaning the caller should load again.
+func (m *Manager) startLoadedEvent(settings models.SystemSettings, queued []uint, loaded map[uint]models.Camera) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for len(m.eventQueue) > 0 {
+		if limit := settings.MaxConcurrentEventRecordings; limit > 0 && len(m.ActiveRecordings) >= limit {
+			return false
+		}
+
+		camID := m.eventQueue[0]
+		if !slices.Contains(queued, camID) {
+			return true
+		}
+		q := m.queuedEvents[camID]
+		m.dequeueEvent(camID)
+
+		cam, ok := loaded[camID]
+		if !ok {
+			continue
+		}
+		log.Printf("Starting queued event for Camera %d\n", camID)
+		if err := m.beginEventRecord(cam, settings, q.Sources, q.Reason, q.Classes); err == nil {
+			return false
+		}
+	}
+	return false
+}
+
+// validateEventFile decides whether a finished clip is worth keeping. Duration from
+// ffprobe is the real test (skipped when minSeconds is 0); the size check still
+// catches missing/empty files.
+func validateEventFile(path string, minSeconds int) (bool, string) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() <= 50000 {
+		return false, "too small"
+	}
+	if minSeconds <= 0 {
+		return true, ""
+	}
+
+	duration, err := probeDuration(path)
+	if err != nil {
+		// Unknown duration: fall back to the size verdict rather than dropping footage
+		log.Printf("Could not probe %s: %v", path, err)
+		return true, ""
+	}
+	if duration < float64(minSeconds) {
+		return false, fmt.Sprintf("too short: %.1fs", duration)
+	}
+	return true, ""
 }
 
 func (m *Manager) delayedStop(camID uint) {
 	m.mu.Lock()
-	_, exists := m.ActiveRecordings[camID]
-	if !exists {
+	rec, exists := m.ActiveRecordings[camID]
+	// A source may have restarted the event while we waited
+	if !exists || len(rec.Sources) > 0 {
 		m.mu.Unlock()
 		return
 	}
 	m.mu.Unlock() 
-	m.StopEventRecord(camID)
+	m.finishEventRecord(camID)
+}
+
+// mergeEventClasses adds newly reported classes to an in-progress event
+func mergeEventClasses(eventID uint, classes []string) {
+	var event models.Event
+	if err := database.DB.First(&event, eventID).Error; err != nil {
+		return
+	}
+
+	names, confidence := splitClasses(classes)
+	recordEventClasses(event.ID, names, confidence)
+
+	var existing []string
+	json.Unmarshal([]byte(event.DetectedClasses), &existing)
+	seen := make(map[string]bool)
+	for _, c := range existing {
+		seen[c] = true
+	}
+	for _, c := range names {
+		if !seen[c] {
+			existing = append(existing, c)
+			seen[c] = true
+		}
+	}
+
+	classJSON, _ := json.Marshal(existing)
+	database.DB.Model(&event).Update("detected_classes", string(classJSON))
 }
 
 func (m *Manager) killProcess(cmd *exec.Cmd) {
@@ -264,17 +751,124 @@ func (m *Manager) killProcess(cmd *exec.Cmd) {
 }
 
 func (m *Manager) generateThumbnail(videoPath string, eventID uint) {
-	time.Sleep(500 * time.Millisecond)
+	if !m.sleep(500 * time.Millisecond) {
+		return
+	}
 	thumbPath := strings.Replace(videoPath, ".mp4", ".jpg", 1)
-	cmd := exec.Command("ffmpeg", 
-		"-i", videoPath, 
-		"-ss", "00:00:01", 
-		"-vframes", "1", 
-		"-q:v", "2", 
-		thumbPath,
+	duration, err := probeDurationContext(m.ctx, videoPath)
+	if err != nil {
+		duration = 0
+	}
+	_, err = runTool(m.ctx, 30*time.Second, FFmpegBin,
+		"-v", "error",
+		"-ss", thumbnailSeek(duration),
+		"-i", videoPath,
+		"-vframes", "1",
+		"-q:v", "2",
+		"-y", thumbPath,
 	)
-	if err := cmd.Run(); err == nil {
-		relThumb := strings.TrimPrefix(thumbPath, "/")
-		database.DB.Model(&models.Event{}).Where("id = ?", eventID).Update("thumbnail_path", relThumb)
+	if err != nil {
+		log.Printf("Event %d thumbnail failed: %v", eventID, err)
+		return
+	}
+	relThumb := LogicalPath(thumbPath)
+	database.DB.Model(&models.Event{}).Where("id = ?", eventID).Update("thumbnail_path", relThumb)
+	os.Remove(provisionalThumbPath(videoPath))
+}
+
+// thumbnailSeek is where in a clip of the given length its thumbnail is taken:
+// the midpoint, or one second in when the length is unknown
+func thumbnailSeek(duration float64) string {
+	if duration <= 0 {
+		return "1"
+	}
+	return strconv.FormatFloat(duration/2, 'f', 3, 64)
+}
+
+// provisionalThumbPath is where the live frame grabbed at event start is stored
+func provisionalThumbPath(videoPath string) string {
+	return strings.TrimSuffix(videoPath, ".mp4") + "_live.jpg"
+}
+
+// grabProvisionalThumbnail captures one frame from the live stream (the substream
+// when configured, it's cheaper) so the event has a thumbnail while recording.
+// generateThumbnail replaces it once the clip is finalized.
+func (m *Manager) grabProvisionalThumbnail(cam models.Camera, eventID uint, videoPath string) {
+	if cam.RTSPSubstreamUrl != "" {
+		cam.RTSPUrl = cam.RTSPSubstreamUrl
+	}
+	thumbPath := provisionalThumbPath(videoPath)
+	if err := m.grabFrame(cam, thumbPath); err != nil {
+		log.Printf("[%s] Provisional thumbnail failed: %v", cam.Name, err)
+		return
 	}
-}
\ No newline at end of file
+
+	// Only fill an empty slot; the final thumbnail may already have landed
+	res := database.DB.Model(&models.Event{}).
+		Where("id = ? AND (thumbnail_path = '' OR thumbnail_path IS NULL)", eventID).
+		Update("thumbnail_path", LogicalPath(thumbPath))
+	if res.Error != nil || res.RowsAffected == 0 {
+		os.Remove(thumbPath)
+	}
+}
+
+// statsFor returns the camera's counters, creating them on first use. Callers hold m.mu.
+func (m *Manager) statsFor(camID uint) *RecordingStats {
+	s, ok := m.stats[camID]
+	if !ok {
+		s = &RecordingStats{}
+		m.stats[camID] = s
+	}
+	return s
+}
+
+// RecordingStats returns a snapshot of a camera's recording counters
+func (m *Manager) RecordingStats(camID uint) RecordingStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.stats[camID]; ok {
+		return *s
+	}
+	return RecordingStats{}
+}
+
+// IsRecording reports whether the camera has an event recording or a continuous
+// recorder running right now
+func (m *Manager) IsRecording(camID uint) (event bool, continuous bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, event = m.ActiveRecordings[camID]
+	_, continuous = m.ContinuousProcs[camID]
+	return event, continuous
+}
+
+// snapshotPath is where the full-resolution still taken at event start is stored
+func snapshotPath(videoPath string) string {
+	return strings.TrimSuffix(videoPath, ".mp4") + "_snapshot.jpg"
+}
+
+// grabEventSnapshot saves one full-resolution frame from the main stream next to the clip
+func (m *Manager) grabEventSnapshot(cam models.Camera, eventID uint, videoPath string) {
+	path := snapshotPath(videoPath)
+	if err := m.grabFrame(cam, path); err != nil {
+		log.Printf("[%s] Event snapshot failed: %v", cam.Name, err)
+		return
+	}
+
+	// The event may have been discarded or deleted while ffmpeg ran
+	res := database.DB.Model(&models.Event{}).Where("id = ?", eventID).Update("snapshot_path", LogicalPath(path))
+	if res.Error != nil || res.RowsAffected == 0 {
+		os.Remove(path)
+	}
+}
+
+// grabFrame writes a single JPEG from the camera's live stream (cam.RTSPUrl),
+// privacy mask applied
+func (m *Manager) grabFrame(cam models.Camera, outPath string) error {
+	args := []string{"-v", "error"}
+	args = append(args, inputArgs(cam)...)
+	args = append(args, maskArgs(cam)...)
+	args = append(args, "-frames:v", "1", "-q:v", "2", "-y", outPath)
+	_, err := runTool(m.ctx, 10*time.Second, FFmpegBin, args...)
+	return err
+}
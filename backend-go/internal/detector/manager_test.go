@@ -0,0 +1,48 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStopEndsLoops(t *testing.T) {
+	m := NewManager()
+	m.spawn(m.bandwidthLoop)
+	m.spawn(m.monitorLoop)
+	m.spawn(m.StartJanitor)
+
+	slept := make(chan bool, 1)
+	m.spawn(func() { slept <- m.sleep(time.Hour) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := m.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if <-slept {
+		t.Error("sleep reported a full wait after Stop")
+	}
+
+	ran := make(chan struct{}, 1)
+	m.spawn(func() { ran <- struct{}{} })
+	select {
+	case <-ran:
+		t.Error("spawn started work after Stop")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestStopGivesUpOnStuckWorker(t *testing.T) {
+	m := NewManager()
+	release := make(chan struct{})
+	m.spawn(func() { <-release })
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := m.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop = %v, want deadline exceeded", err)
+	}
+}
@@ -1,27 +1,70 @@
 package detector
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	"image/png"
 	"os"
 	"strconv"
 	"strings"
 )
 
-// generateMaskFile creates a PGM P5 mask file for Motion
-// ROI is a comma-separated list of indices (0-99) for a 10x10 grid
-func generateMaskFile(roi string, path string) error {
+// Motion grid dimensions (10x10)
+const (
+	GridSize  = 10
+	GridCells = GridSize * GridSize
+)
+
+// ROIResult is the outcome of parsing a MotionROI string
+type ROIResult struct {
+	Cells      []int    `json:"cells"`
+	OutOfRange []int    `json:"out_of_range"`
+	Malformed  []string `json:"malformed"`
+}
+
+// Valid reports whether every entry in the ROI string was usable
+func (r ROIResult) Valid() bool {
+	return len(r.OutOfRange) == 0 && len(r.Malformed) == 0
+}
+
+// ParseROI parses comma-separated grid indices the way generateMaskFile applies them.
+// Blank entries (e.g. a trailing comma) are ignored.
+func ParseROI(roi string) ROIResult {
+	result := ROIResult{Cells: []int{}, OutOfRange: []int{}, Malformed: []string{}}
+	if strings.TrimSpace(roi) == "" {
+		return result
+	}
+
+	for _, part := range strings.Split(roi, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		idx, err := strconv.Atoi(part)
+		switch {
+		case err != nil:
+			result.Malformed = append(result.Malformed, part)
+		case idx < 0 || idx >= GridCells:
+			result.OutOfRange = append(result.OutOfRange, idx)
+		default:
+			result.Cells = append(result.Cells, idx)
+		}
+	}
+	return result
+}
+
+// maskCells expands an ROI string into one byte per grid cell
+func maskCells(roi string) []byte {
 	// 1. Initialize 10x10 grid (100 bytes) with 0 (Masked/Black)
 	// Motion uses: 0 = ignore motion, 255 = detect motion
-	maskData := make([]byte, 100)
+	maskData := make([]byte, GridCells)
 
 	// 2. Parse ROI string
 	if roi != "" {
-		parts := strings.Split(roi, ",")
-		for _, part := range parts {
-			idx, err := strconv.Atoi(strings.TrimSpace(part))
-			if err == nil && idx >= 0 && idx < 100 {
-				maskData[idx] = 255 // Unmask this cell
-			}
+		result := ParseROI(roi)
+		for _, idx := range result.Cells {
+			maskData[idx] = 255 // Unmask this cell
 		}
 	} else {
 		// If empty ROI, assume full screen detection? 
@@ -31,6 +74,31 @@ func generateMaskFile(roi string, path string) error {
 			maskData[i] = 255
 		}
 	}
+	return maskData
+}
+
+// RenderMaskPNG draws the mask as a PNG with each grid cell scaled to scale x scale
+// pixels: detected cells white, ignored cells black
+func RenderMaskPNG(roi string, scale int) ([]byte, error) {
+	cells := maskCells(roi)
+	img := image.NewGray(image.Rect(0, 0, GridSize*scale, GridSize*scale))
+	for y := 0; y < GridSize*scale; y++ {
+		for x := 0; x < GridSize*scale; x++ {
+			img.Pix[y*img.Stride+x] = cells[(y/scale)*GridSize+x/scale]
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// generateMaskFile creates a PGM P5 mask file for Motion
+// ROI is a comma-separated list of indices (0-99) for a 10x10 grid
+func generateMaskFile(roi string, path string) error {
+	maskData := maskCells(roi)
 
 	// 3. Create PGM File
 	// Header: P5 <width> <height> <maxval>
@@ -53,4 +121,32 @@ func generateMaskFile(roi string, path string) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// privacyFilter builds an ffmpeg drawbox chain that blacks out the masked grid
+// cells. Adjacent cells in a row are merged into one box to keep the chain short.
+func privacyFilter(mask string) string {
+	masked := make([]bool, GridCells)
+	for _, idx := range ParseROI(mask).Cells {
+		masked[idx] = true
+	}
+
+	boxes := make([]string, 0)
+	for row := 0; row < GridSize; row++ {
+		for col := 0; col < GridSize; {
+			if !masked[row*GridSize+col] {
+				col++
+				continue
+			}
+			start := col
+			for col < GridSize && masked[row*GridSize+col] {
+				col++
+			}
+			boxes = append(boxes, fmt.Sprintf(
+				"drawbox=x=iw*%d/%d:y=ih*%d/%d:w=iw*%d/%d:h=ih/%d:color=black:t=fill",
+				start, GridSize, row, GridSize, col-start, GridSize, GridSize,
+			))
+		}
+	}
+	return strings.Join(boxes, ",")
+}
@@ -0,0 +1,129 @@
+package detector
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"nvr-server/internal/models"
+)
+
+func TestParseROI(t *testing.T) {
+	cases := []struct {
+		roi        string
+		cells      []int
+		outOfRange []int
+		malformed  []string
+	}{
+		{"", []int{}, []int{}, []string{}},
+		{"0, 1,99,", []int{0, 1, 99}, []int{}, []string{}},
+		{"5,100,-1", []int{5}, []int{100, -1}, []string{}},
+		{"3,abc,4.5, ,7", []int{3, 7}, []int{}, []string{"abc", "4.5"}},
+	}
+	for _, tc := range cases {
+		got := ParseROI(tc.roi)
+		if !reflect.DeepEqual(got.Cells, tc.cells) || !reflect.DeepEqual(got.OutOfRange, tc.outOfRange) || !reflect.DeepEqual(got.Malformed, tc.malformed) {
+			t.Errorf("ParseROI(%q) = %+v", tc.roi, got)
+		}
+		if want := len(tc.outOfRange) == 0 && len(tc.malformed) == 0; got.Valid() != want {
+			t.Errorf("ParseROI(%q).Valid() = %v, want %v", tc.roi, got.Valid(), want)
+		}
+	}
+}
+
+func TestRenderMaskPNG(t *testing.T) {
+	data, err := RenderMaskPNG("0,11,99", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 40 || b.Dy() != 40 {
+		t.Fatalf("size = %dx%d, want 40x40", b.Dx(), b.Dy())
+	}
+
+	light := func(x, y int) bool {
+		r, _, _, _ := img.At(x, y).RGBA()
+		return r > 0x8000
+	}
+	for cell := 0; cell < GridCells; cell++ {
+		want := cell == 0 || cell == 11 || cell == 99
+		// Check both corners of the cell's block
+		x, y := (cell%GridSize)*4, (cell/GridSize)*4
+		if light(x, y) != want || light(x+3, y+3) != want {
+			t.Errorf("cell %d light = %v, want %v", cell, light(x, y), want)
+		}
+	}
+
+	// An empty ROI detects everywhere
+	data, _ = RenderMaskPNG("", 1)
+	img, _ = png.Decode(bytes.NewReader(data))
+	if b := img.Bounds(); b.Dx() != GridSize || !light(0, 0) || !light(9, 9) {
+		t.Errorf("empty ROI: size %d, corners light %v/%v", b.Dx(), light(0, 0), light(9, 9))
+	}
+}
+
+func TestPrivacyFilter(t *testing.T) {
+	cases := map[string]string{
+		"":    "",
+		"bad": "",
+		"0":   "drawbox=x=iw*0/10:y=ih*0/10:w=iw*1/10:h=ih/10:color=black:t=fill",
+		// 3,4,5 in row 0 merge into one box; 9 and 10 sit in different rows
+		"3,4,5,9,10": "drawbox=x=iw*3/10:y=ih*0/10:w=iw*3/10:h=ih/10:color=black:t=fill," +
+			"drawbox=x=iw*9/10:y=ih*0/10:w=iw*1/10:h=ih/10:color=black:t=fill," +
+			"drawbox=x=iw*0/10:y=ih*1/10:w=iw*1/10:h=ih/10:color=black:t=fill",
+		"99,97": "drawbox=x=iw*7/10:y=ih*9/10:w=iw*1/10:h=ih/10:color=black:t=fill," +
+			"drawbox=x=iw*9/10:y=ih*9/10:w=iw*1/10:h=ih/10:color=black:t=fill",
+	}
+	for mask, want := range cases {
+		if got := privacyFilter(mask); got != want {
+			t.Errorf("privacyFilter(%q) =\n  %s\nwant\n  %s", mask, got, want)
+		}
+	}
+}
+
+func TestMaskArgs(t *testing.T) {
+	plain := models.Camera{}
+	masked := models.Camera{PrivacyMask: "0,1"}
+
+	if args := maskArgs(plain); args != nil {
+		t.Errorf("maskArgs without a mask = %v", args)
+	}
+	if needsTranscode(plain) || !needsTranscode(masked) {
+		t.Errorf("needsTranscode: plain %v, masked %v", needsTranscode(plain), needsTranscode(masked))
+	}
+
+	// A mask can't be drawn on a stream copy, so it forces a re-encode
+	args := codecArgs(masked)
+	if vf, _ := flagValue(args, "-vf"); vf != privacyFilter(masked.PrivacyMask) {
+		t.Errorf("-vf = %q", vf)
+	}
+	if v, _ := flagValue(args, "-c:v"); v != "libx264" {
+		t.Errorf("masked camera -c:v = %q, want libx264", v)
+	}
+}
+
+func TestGrabFrameAppliesMask(t *testing.T) {
+	argsFile := useArgsFFmpeg(t)
+	m := NewManager()
+	out := filepath.Join(t.TempDir(), "still.jpg")
+	if err := m.grabFrame(models.Camera{RTSPUrl: "rtsp://192.0.2.1/main", PrivacyMask: "55"}, out); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(argsFile)
+	args := strings.Fields(string(data))
+	vf, _ := flagValue(args, "-vf")
+	if vf != privacyFilter("55") {
+		t.Errorf("still -vf = %q", vf)
+	}
+	if input, _ := flagValue(args, "-i"); input != "rtsp://192.0.2.1/main" {
+		t.Errorf("-i = %q", input)
+	}
+}
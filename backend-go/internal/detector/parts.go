@@ -0,0 +1,81 @@
+package detector
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// firstPartSuffix marks an event recorded as numbered parts (see eventPartArgs)
+const firstPartSuffix = "_000.mp4"
+
+// minPartBytes matches the size floor in validateEventFile
+const minPartBytes = 50000
+
+// eventPartArgs returns the muxer options that split an event into parts of the
+// given length, and the file pattern ffmpeg should write to. The first part is
+// base+firstPartSuffix.
+func eventPartArgs(base string, minutes int) ([]string, string) {
+	return []string{
+		"-f", "segment",
+		"-segment_time", strconv.Itoa(minutes * 60),
+		"-segment_format", "mp4",
+		"-segment_format_options", "movflags=+frag_keyframe+empty_moov+default_base_moof",
+		"-reset_timestamps", "1",
+	}, base + "_%03d.mp4"
+}
+
+// EventParts returns every file belonging to an event clip in order. For events
+// that were not split this is just the clip itself.
+func EventParts(videoPath string) []string {
+	if !strings.HasSuffix(videoPath, firstPartSuffix) {
+		return []string{videoPath}
+	}
+	matches, _ := filepath.Glob(strings.TrimSuffix(videoPath, firstPartSuffix) + "_[0-9][0-9][0-9].mp4")
+	if len(matches) == 0 {
+		return []string{videoPath}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// eventFirstPart maps any part of a split event clip to its first part, the path
+// the event row stores. Other paths are returned unchanged.
+func eventFirstPart(path string) string {
+	const partSuffixLen = len(firstPartSuffix)
+	if len(path) < partSuffixLen || !strings.HasSuffix(path, ".mp4") || path[len(path)-partSuffixLen] != '_' {
+		return path
+	}
+	if _, err := strconv.Atoi(path[len(path)-partSuffixLen+1 : len(path)-len(".mp4")]); err != nil {
+		return path
+	}
+	return path[:len(path)-partSuffixLen] + firstPartSuffix
+}
+
+// finalizeEventParts drops trailing parts too small to play (ffmpeg opens a new
+// part right before it is stopped) and returns the parts column value: a JSON array
+// of relative paths, or "" when only one part remains.
+func finalizeEventParts(videoPath string) string {
+	parts := EventParts(videoPath)
+	for len(parts) > 1 {
+		last := parts[len(parts)-1]
+		if info, err := os.Stat(last); err == nil && info.Size() > minPartBytes {
+			break
+		}
+		os.Remove(last)
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) < 2 {
+		return ""
+	}
+
+	rel := make([]string, len(parts))
+	for i, p := range parts {
+		rel[i] = LogicalPath(p)
+	}
+	partsJSON, _ := json.Marshal(rel)
+	return string(partsJSON)
+}
@@ -0,0 +1,81 @@
+package detector
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEventPartArgs(t *testing.T) {
+	args, pattern := eventPartArgs("/recordings/event_1_20240102-120000", 5)
+	if pattern != "/recordings/event_1_20240102-120000_%03d.mp4" {
+		t.Errorf("pattern = %q", pattern)
+	}
+	if v, _ := flagValue(args, "-segment_time"); v != "300" {
+		t.Errorf("-segment_time = %q, want 300", v)
+	}
+	if v, _ := flagValue(args, "-f"); v != "segment" {
+		t.Errorf("-f = %q, want segment", v)
+	}
+}
+
+func TestEventPartsAcrossBoundary(t *testing.T) {
+	testRoots(t)
+	base := filepath.Join(EventRoot, "event_1_20240102-120000")
+	first := base + firstPartSuffix
+
+	// Parts 0-1 were full length; ffmpeg opened part 2 right before it stopped
+	sizes := map[string]int{"_000.mp4": 80000, "_001.mp4": 80000, "_002.mp4": 100}
+	for suffix, size := range sizes {
+		if err := os.WriteFile(base+suffix, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Another event's parts are not picked up
+	writeSegment(t, filepath.Join(EventRoot, "event_1_20240102-130000_000.mp4"))
+
+	want := []string{base + "_000.mp4", base + "_001.mp4", base + "_002.mp4"}
+	if got := EventParts(first); !reflect.DeepEqual(got, want) {
+		t.Errorf("EventParts = %v, want %v", got, want)
+	}
+
+	var parts []string
+	if err := json.Unmarshal([]byte(finalizeEventParts(first)), &parts); err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0] != LogicalPath(want[0]) || parts[1] != LogicalPath(want[1]) {
+		t.Errorf("finalized parts = %v", parts)
+	}
+	if _, err := os.Stat(want[2]); !os.IsNotExist(err) {
+		t.Error("the stub trailing part was kept")
+	}
+
+	// Removing part 1 leaves a single part: no parts column
+	os.Remove(want[1])
+	if got := finalizeEventParts(first); got != "" {
+		t.Errorf("single part finalized to %q", got)
+	}
+
+	// A clip that was never split is its own only part
+	if got := EventParts("/recordings/event_1.mp4"); !reflect.DeepEqual(got, []string{"/recordings/event_1.mp4"}) {
+		t.Errorf("unsplit EventParts = %v", got)
+	}
+}
+
+func TestEventFirstPart(t *testing.T) {
+	cases := map[string]string{
+		"/recordings/event_1_20240102-120000_007.mp4": "/recordings/event_1_20240102-120000_000.mp4",
+		"/recordings/event_1_20240102-120000_000.mp4": "/recordings/event_1_20240102-120000_000.mp4",
+		"/recordings/event_1_20240102-120000.mp4":     "/recordings/event_1_20240102-120000.mp4",
+		"/recordings/event_1_20240102-120000_abc.mp4": "/recordings/event_1_20240102-120000_abc.mp4",
+		"/recordings/event_1_000.jpg":                 "/recordings/event_1_000.jpg",
+		"_1.mp4":                                      "_1.mp4",
+	}
+	for path, want := range cases {
+		if got := eventFirstPart(path); got != want {
+			t.Errorf("eventFirstPart(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
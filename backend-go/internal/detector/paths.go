@@ -0,0 +1,41 @@
+package detector
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CameraIDForPath works out which camera a recording file belongs to from its
+// location: continuous/<id>/[YYYY/MM/DD/]<segment> for 24/7 footage, event_<id>_<time>.* for
+// event clips and thumbnails. Works on absolute or "recordings/..." paths.
+func CameraIDForPath(path string) (uint, bool) {
+	if filepath.IsAbs(path) {
+		path = LogicalPath(path)
+	}
+	base := filepath.Base(path)
+	if rest, ok := strings.CutPrefix(base, "event_"); ok {
+		idStr, _, found := strings.Cut(rest, "_")
+		if !found {
+			return 0, false
+		}
+		return parseCameraID(idStr)
+	}
+
+	// Segments sit in continuous/<id>/, or deeper in a date partition
+	parts := strings.Split(filepath.ToSlash(filepath.Dir(path)), "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "continuous" {
+			return parseCameraID(parts[i+1])
+		}
+	}
+	return 0, false
+}
+
+func parseCameraID(s string) (uint, bool) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,29 @@
+package detector
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCameraIDForPath(t *testing.T) {
+	testRoots(t)
+	cases := map[string]uint{
+		"recordings/event_12_20240101-080000.mp4":                 12,
+		"recordings/event_12_20240101-080000_001.mp4":             12,
+		"recordings/event_3_20240101-080000.jpg":                  3,
+		"recordings/continuous/7/20240101-080000.mp4":             7,
+		"recordings/continuous/7/2024/01/01/20240101-080000.mp4":  7,
+		filepath.Join(EventRoot, "event_5_20240101-080000.mp4"):   5,
+		filepath.Join(ContinuousRoot, "9", "20240101-080000.mkv"): 9,
+		"recordings/event_x_20240101.mp4":                         0,
+		"recordings/event_0_20240101.mp4":                         0,
+		"recordings/event_4.mp4":                                  0,
+		"recordings/notes.txt":                                    0,
+	}
+	for path, want := range cases {
+		got, ok := CameraIDForPath(path)
+		if got != want || ok != (want != 0) {
+			t.Errorf("CameraIDForPath(%q) = %d, %v; want %d", path, got, ok, want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package detector
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// Animated preview shape: a few seconds from just after the start, small and choppy
+const (
+	previewOffset  = "1"
+	previewSeconds = "4"
+	previewFilter  = "fps=5,scale=320:-2:flags=lanczos"
+)
+
+// loadPreviewSetting reports whether animated previews are switched on
+func loadPreviewSetting() bool {
+	var settings models.SystemSettings
+	database.DB.First(&settings)
+	return settings.AnimatedPreviews
+}
+
+// previewPath is where an event's animated preview is stored
+func previewPath(videoPath string) string {
+	return strings.TrimSuffix(videoPath, ".mp4") + "_preview.webp"
+}
+
+// generatePreview renders a looping animated WebP from a finished clip
+func (m *Manager) generatePreview(videoPath string, eventID uint) {
+	out := previewPath(videoPath)
+	_, err := runTool(m.ctx, 60*time.Second, FFmpegBin,
+		"-v", "error",
+		"-ss", previewOffset,
+		"-t", previewSeconds,
+		"-i", videoPath,
+		"-vf", previewFilter,
+		"-an",
+		"-c:v", "libwebp",
+		"-loop", "0",
+		"-q:v", "50",
+		"-y", out,
+	)
+	if err != nil {
+		log.Printf("Event %d preview failed: %v", eventID, err)
+		return
+	}
+	database.DB.Model(&models.Event{}).Where("id = ?", eventID).Update("preview_path", LogicalPath(out))
+}
@@ -0,0 +1,66 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestGeneratePreview(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	argsFile := useArgsFFmpeg(t)
+
+	event := models.Event{CameraID: 1, StartTime: time.Now()}
+	database.DB.Create(&event)
+	videoPath := filepath.Join(EventRoot, "event_1_20240102-120000.mp4")
+	writeSegment(t, videoPath)
+
+	m := NewManager()
+	stopManager(t, m)
+	m.generatePreview(videoPath, event.ID)
+
+	want := filepath.Join(EventRoot, "event_1_20240102-120000_preview.webp")
+	if !exists(want) {
+		t.Fatalf("preview not written to %s", want)
+	}
+	data, _ := os.ReadFile(argsFile)
+	args := strings.Fields(string(data))
+	for flag, value := range map[string]string{"-ss": previewOffset, "-t": previewSeconds, "-c:v": "libwebp", "-loop": "0", "-i": videoPath} {
+		if got, _ := flagValue(args, flag); got != value {
+			t.Errorf("%s = %q, want %q", flag, got, value)
+		}
+	}
+	if _, ok := flagValue(args, "-an"); !ok {
+		t.Error("preview keeps audio")
+	}
+
+	database.DB.First(&event, event.ID)
+	if event.PreviewPath != LogicalPath(want) || MediaPath(event.PreviewPath) != want {
+		t.Errorf("preview_path = %q", event.PreviewPath)
+	}
+}
+
+func TestGeneratePreviewFailureLeavesEventAlone(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	prev := FFmpegBin
+	FFmpegBin = stubTool(t, "echo 'Unknown encoder libwebp' >&2; exit 1")
+	t.Cleanup(func() { FFmpegBin = prev })
+
+	event := models.Event{CameraID: 1, StartTime: time.Now()}
+	database.DB.Create(&event)
+	m := NewManager()
+	stopManager(t, m)
+	m.generatePreview(filepath.Join(EventRoot, "event_1_20240102-120000.mp4"), event.ID)
+
+	database.DB.First(&event, event.ID)
+	if event.PreviewPath != "" {
+		t.Errorf("failed preview recorded as %q", event.PreviewPath)
+	}
+}
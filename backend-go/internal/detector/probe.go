@@ -0,0 +1,131 @@
+package detector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// FFprobeBin is the ffprobe executable used for media inspection
+var FFprobeBin = "ffprobe"
+
+// probeDuration returns the container duration of a media file in seconds
+func probeDuration(path string) (float64, error) {
+	return probeDurationContext(context.Background(), path)
+}
+
+func probeDurationContext(ctx context.Context, path string) (float64, error) {
+	out, err := runTool(ctx, 0, FFprobeBin,
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	value := strings.TrimSpace(string(out))
+	duration, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe: unexpected duration %q", value)
+	}
+	return duration, nil
+}
+
+// StreamInfo describes the primary video stream of a camera
+type StreamInfo struct {
+	Width  int
+	Height int
+	FPS    float64
+	Codec  string
+}
+
+// probeStream connects to a live source and reads its first video stream's parameters
+func probeStream(parent context.Context, cam models.Camera) (*StreamInfo, error) {
+	args := []string{"-v", "error"}
+	args = append(args, inputArgs(cam)...)
+	args = append(args,
+		"-select_streams", "v:0",
+		"-show_entries", "stream=codec_name,width,height,avg_frame_rate",
+		"-of", "json",
+	)
+
+	out, err := runTool(parent, 15*time.Second, FFprobeBin, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed struct {
+		Streams []struct {
+			CodecName    string `json:"codec_name"`
+			Width        int    `json:"width"`
+			Height       int    `json:"height"`
+			AvgFrameRate string `json:"avg_frame_rate"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return nil, err
+	}
+	if len(parsed.Streams) == 0 {
+		return nil, fmt.Errorf("ffprobe: no video stream")
+	}
+
+	s := parsed.Streams[0]
+	return &StreamInfo{Width: s.Width, Height: s.Height, FPS: parseFrameRate(s.AvgFrameRate), Codec: s.CodecName}, nil
+}
+
+// parseFrameRate converts ffprobe's "num/den" rate into frames per second
+func parseFrameRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !found {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+// refreshStreamInfo probes a camera and caches the result on its row
+func (m *Manager) refreshStreamInfo(cam models.Camera) {
+	info, err := probeStream(m.ctx, cam)
+	if err != nil {
+		log.Printf("[%s] Stream probe failed: %v", cam.Name, err)
+		m.mu.Lock()
+		delete(m.ProbedURLs, cam.ID)
+		m.mu.Unlock()
+		return
+	}
+
+	database.DB.Model(&models.Camera{}).Where("id = ?", cam.ID).Updates(map[string]interface{}{
+		"stream_width":  info.Width,
+		"stream_height": info.Height,
+		"stream_fps":    info.FPS,
+		"stream_codec":  info.Codec,
+	})
+}
+
+// ProbeClip checks that a file is a readable video and returns its duration in seconds
+func ProbeClip(ctx context.Context, path string) (float64, error) {
+	duration, err := probeDurationContext(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("ffprobe: zero duration")
+	}
+	return duration, nil
+}
@@ -0,0 +1,123 @@
+package detector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// stubTool writes a shell script standing in for ffmpeg/ffprobe and returns its path
+func stubTool(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tool")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// useFFprobe points FFprobeBin at a stub for the rest of the test
+func useFFprobe(t *testing.T, script string) {
+	t.Helper()
+	prev := FFprobeBin
+	FFprobeBin = stubTool(t, script)
+	t.Cleanup(func() { FFprobeBin = prev })
+}
+
+// writeClip creates a file of size bytes standing in for a recording
+func writeClip(t *testing.T, size int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "event.mp4")
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateEventFileDuration(t *testing.T) {
+	clip := writeClip(t, 60000)
+
+	useFFprobe(t, "echo 2.5")
+	if ok, why := validateEventFile(clip, 3); ok || !strings.Contains(why, "too short: 2.5s") {
+		t.Errorf("2.5s clip with 3s minimum: %v, %q", ok, why)
+	}
+
+	useFFprobe(t, "echo 12.040000")
+	if ok, why := validateEventFile(clip, 3); !ok {
+		t.Errorf("12s clip rejected: %q", why)
+	}
+
+	// An unreadable duration keeps the clip rather than dropping footage
+	useFFprobe(t, "echo 'moov atom not found' >&2; exit 1")
+	if ok, _ := validateEventFile(clip, 3); !ok {
+		t.Error("clip dropped when ffprobe failed")
+	}
+
+	// No minimum: ffprobe is not consulted at all
+	marker := filepath.Join(t.TempDir(), "probed")
+	useFFprobe(t, "touch "+marker+"; echo 0.1")
+	if ok, _ := validateEventFile(clip, 0); !ok {
+		t.Error("clip rejected with no minimum")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("ffprobe ran with no minimum set")
+	}
+}
+
+func TestValidateEventFileSize(t *testing.T) {
+	useFFprobe(t, "echo 60")
+	if ok, why := validateEventFile(writeClip(t, 1000), 3); ok || why != "too small" {
+		t.Errorf("tiny clip: %v, %q", ok, why)
+	}
+	if ok, _ := validateEventFile(filepath.Join(t.TempDir(), "missing.mp4"), 0); ok {
+		t.Error("missing clip accepted")
+	}
+}
+
+func TestProbeStreamParsesFFprobe(t *testing.T) {
+	useFFprobe(t, `cat <<'JSON'
+{"streams":[{"codec_name":"h264","width":2560,"height":1440,"avg_frame_rate":"30000/1001"}]}
+JSON`)
+
+	info, err := probeStream(context.Background(), models.Camera{RTSPUrl: "rtsp://192.0.2.1/main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Codec != "h264" || info.Width != 2560 || info.Height != 1440 || info.FPS < 29.96 || info.FPS > 29.98 {
+		t.Errorf("info = %+v", info)
+	}
+
+	useFFprobe(t, `echo '{"streams":[]}'`)
+	if _, err := probeStream(context.Background(), models.Camera{RTSPUrl: "rtsp://192.0.2.1/main"}); err == nil {
+		t.Error("audio-only source probed without error")
+	}
+}
+
+func TestParseFrameRate(t *testing.T) {
+	cases := map[string]float64{"25/1": 25, "15": 15, "0/0": 0, "": 0, "x/1": 0, "30/x": 0}
+	for in, want := range cases {
+		if got := parseFrameRate(in); got != want {
+			t.Errorf("parseFrameRate(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestRefreshStreamInfoCachesFields(t *testing.T) {
+	testDB(t)
+	useFFprobe(t, `echo '{"streams":[{"codec_name":"hevc","width":1920,"height":1080,"avg_frame_rate":"20/1"}]}'`)
+	cam := models.Camera{Name: "yard", Path: "yard", RTSPUrl: "rtsp://192.0.2.1/yard"}
+	database.DB.Create(&cam)
+
+	NewManager().refreshStreamInfo(cam)
+
+	var got models.Camera
+	database.DB.First(&got, cam.ID)
+	if got.StreamCodec != "hevc" || got.StreamWidth != 1920 || got.StreamHeight != 1080 || got.StreamFPS != 20 {
+		t.Errorf("cached stream info = %s %dx%d @ %v", got.StreamCodec, got.StreamWidth, got.StreamHeight, got.StreamFPS)
+	}
+}
@@ -0,0 +1,93 @@
+package detector
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ProcessStatus is the live state of one recording ffmpeg
+type ProcessStatus struct {
+	PID           int       `json:"pid"`
+	Running       bool      `json:"running"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+}
+
+// CameraProcessStatus reports a camera's recording processes and when continuous
+// footage was last written
+type CameraProcessStatus struct {
+	CameraID         uint           `json:"camera_id"`
+	Continuous       *ProcessStatus `json:"continuous"`
+	LastSegmentWrite *time.Time     `json:"last_segment_write"`
+	Event            *ProcessStatus `json:"event"`
+	EventID          uint           `json:"event_id,omitempty"`
+}
+
+// ProcessStatus snapshots the manager's process maps under the lock, then checks
+// the processes and the segment directory without it
+func (m *Manager) ProcessStatus(camID uint) CameraProcessStatus {
+	status := CameraProcessStatus{CameraID: camID}
+
+	var contPID, eventPID int
+	var contStart, eventStart time.Time
+	m.mu.Lock()
+	if proc, ok := m.ContinuousProcs[camID]; ok && proc.Process != nil && proc.Process.Process != nil {
+		contPID, contStart = proc.Process.Process.Pid, proc.StartedAt
+	}
+	if rec, ok := m.ActiveRecordings[camID]; ok && rec.Process != nil && rec.Process.Process != nil {
+		eventPID, eventStart = rec.Process.Process.Pid, rec.StartTime
+		status.EventID = rec.EventID
+	}
+	m.mu.Unlock()
+
+	now := time.Now()
+	if contPID != 0 {
+		status.Continuous = processStatus(contPID, contStart, now)
+	}
+	if eventPID != 0 {
+		status.Event = processStatus(eventPID, eventStart, now)
+	}
+	if t, ok := lastSegmentWrite(camID); ok {
+		status.LastSegmentWrite = &t
+	}
+	return status
+}
+
+func processStatus(pid int, started, now time.Time) *ProcessStatus {
+	s := &ProcessStatus{PID: pid, Running: processAlive(pid), StartedAt: started}
+	if s.Running && !started.IsZero() {
+		s.UptimeSeconds = now.Sub(started).Seconds()
+	}
+	return s
+}
+
+// processAlive reports whether pid exists and is not a zombie. Continuous
+// recorders are never waited on, so an exited one lingers as a zombie.
+func processAlive(pid int) bool {
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid)); err == nil {
+		// Format: pid (comm) state ...; comm may contain spaces, so split after ')'
+		if i := strings.LastIndexByte(string(data), ')'); i >= 0 {
+			fields := strings.Fields(string(data[i+1:]))
+			return len(fields) > 0 && fields[0] != "Z" && fields[0] != "X"
+		}
+	}
+	return syscall.Kill(pid, 0) == nil
+}
+
+// lastSegmentWrite is the newest modification time among the camera's segments
+// from today and yesterday (UTC); anything older is stale either way
+func lastSegmentWrite(camID uint) (time.Time, bool) {
+	now := time.Now()
+	var newest time.Time
+	for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+		for _, seg := range CameraSegmentsOn(camID, day) {
+			if seg.Info.ModTime().After(newest) {
+				newest = seg.Info.ModTime()
+			}
+		}
+	}
+	return newest, !newest.IsZero()
+}
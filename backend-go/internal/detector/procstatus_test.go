@@ -0,0 +1,68 @@
+package detector
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestProcessAlive(t *testing.T) {
+	if !processAlive(os.Getpid()) {
+		t.Error("own process reported dead")
+	}
+
+	// An exited child that was never waited on lingers as a zombie
+	zombie := exec.Command("true")
+	if err := zombie.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { zombie.Wait() })
+	deadline := time.Now().Add(2 * time.Second)
+	for processAlive(zombie.Process.Pid) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if processAlive(zombie.Process.Pid) {
+		t.Error("zombie process reported running")
+	}
+}
+
+func TestManagerProcessStatus(t *testing.T) {
+	testRoots(t)
+	m := NewManager()
+	if s := m.ProcessStatus(1); s.Continuous != nil || s.Event != nil || s.LastSegmentWrite != nil {
+		t.Fatalf("idle camera: %+v", s)
+	}
+
+	recorder := exec.Command("sleep", "30")
+	if err := recorder.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		recorder.Process.Kill()
+		recorder.Wait()
+	})
+	started := time.Now().Add(-time.Minute)
+	m.ContinuousProcs[1] = &ContinuousProcess{Process: recorder, StartedAt: started}
+	m.ActiveRecordings[1] = &ActiveRecording{Process: recorder, EventID: 7, StartTime: started}
+	written := time.Now().Add(-30 * time.Second).Truncate(time.Second)
+	recordedSegment(t, 1, written.Add(-time.Minute), written)
+
+	s := m.ProcessStatus(1)
+	if s.Continuous == nil || !s.Continuous.Running || s.Continuous.PID != recorder.Process.Pid || s.Continuous.UptimeSeconds < 60 {
+		t.Errorf("continuous: %+v", s.Continuous)
+	}
+	if s.Event == nil || !s.Event.Running || s.EventID != 7 {
+		t.Errorf("event: %+v, id %d", s.Event, s.EventID)
+	}
+	if s.LastSegmentWrite == nil || !s.LastSegmentWrite.Equal(written) {
+		t.Errorf("last segment write %v, want %v", s.LastSegmentWrite, written)
+	}
+
+	// A recorder that died reports not running and no uptime
+	recorder.Process.Kill()
+	recorder.Wait()
+	if s := m.ProcessStatus(1); s.Continuous == nil || s.Continuous.Running || s.Continuous.UptimeSeconds != 0 {
+		t.Errorf("dead recorder: %+v", s.Continuous)
+	}
+}
@@ -0,0 +1,136 @@
+package detector
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// storedFile is one recording on disk counted against a quota
+type storedFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// userFiles lists every event and continuous file belonging to the given cameras
+func userFiles(cameraIDs []uint) []storedFile {
+	files := make([]storedFile, 0)
+	add := func(path string, info os.FileInfo) {
+		if IsSegmentFile(path) || strings.HasSuffix(path, ".jpg") || strings.HasSuffix(path, ".webp") || strings.HasSuffix(path, ".gif") {
+			files = append(files, storedFile{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+		}
+	}
+
+	prefixes := make([]string, len(cameraIDs))
+	for i, id := range cameraIDs {
+		prefixes[i] = fmt.Sprintf("event_%d_", id)
+
+		filepath.Walk(ContinuousDir(id), func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				add(path, info)
+			}
+			return nil
+		})
+	}
+
+	WalkEventFiles(func(path string, info os.FileInfo) {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(info.Name(), prefix) {
+				add(path, info)
+				break
+			}
+		}
+	})
+	return files
+}
+
+// ownedCameraIDs returns the IDs of a user's cameras
+func ownedCameraIDs(userID uint) []uint {
+	var ids []uint
+	database.DB.Model(&models.Camera{}).Where("owner_id = ?", userID).Pluck("id", &ids)
+	return ids
+}
+
+// UserStorageBytes is the total size of a user's footage on disk
+func UserStorageBytes(userID uint) int64 {
+	var total int64
+	for _, f := range userFiles(ownedCameraIDs(userID)) {
+		total += f.Size
+	}
+	return total
+}
+
+// enforceUserQuotas deletes each over-quota user's oldest files until they fit
+func (m *Manager) enforceUserQuotas() {
+	var users []models.User
+	if err := database.DB.Where("max_storage_mb > 0").Find(&users).Error; err != nil {
+		return
+	}
+
+	for _, user := range users {
+		limit := int64(user.MaxStorageMB) << 20
+		files := userFiles(ownedCameraIDs(user.ID))
+
+		var total int64
+		for _, f := range files {
+			total += f.Size
+		}
+		if total <= limit {
+			continue
+		}
+
+		sizes := make(map[string]int64, len(files))
+		for _, f := range files {
+			sizes[f.Path] = f.Size
+		}
+
+		sort.Slice(files, func(i, j int) bool { return files[i].ModTime.Before(files[j].ModTime) })
+		deleted := 0
+		for _, f := range files {
+			if total <= limit {
+				break
+			}
+
+			// An event clip goes as a whole event: every part, its thumbnails and
+			// its row, so no part or row is left orphaned
+			if strings.HasPrefix(filepath.Base(f.Path), "event_") && IsSegmentFile(f.Path) {
+				if event, ok := eventForFile(f.Path); ok {
+					for _, path := range EventFiles(event) {
+						if size, counted := sizes[path]; counted && os.Remove(path) == nil {
+							delete(sizes, path)
+							total -= size
+							deleted++
+						} else if !counted {
+							os.Remove(path)
+						}
+					}
+					database.DB.Delete(&models.Event{}, event.ID)
+					continue
+				}
+			}
+
+			if _, pending := sizes[f.Path]; !pending || os.Remove(f.Path) != nil {
+				continue
+			}
+			delete(sizes, f.Path)
+			total -= f.Size
+			deleted++
+		}
+		log.Printf("Janitor: User %d over quota (%d MB), removed %d oldest files\n", user.ID, user.MaxStorageMB, deleted)
+	}
+}
+
+// eventForFile finds the event a clip file (any part of it) belongs to
+func eventForFile(path string) (models.Event, bool) {
+	var event models.Event
+	err := database.DB.Where("video_path = ?", LogicalPath(eventFirstPart(path))).First(&event).Error
+	return event, err == nil
+}
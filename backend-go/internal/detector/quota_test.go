@@ -0,0 +1,84 @@
+package detector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// sizedFile creates path holding kb kilobytes, with its mtime set age in the past
+func sizedFile(t *testing.T, path string, kb int, age time.Duration) string {
+	t.Helper()
+	agedFile(t, path, age)
+	if err := os.Truncate(path, int64(kb)<<10); err != nil {
+		t.Fatal(err)
+	}
+	when := time.Now().Add(-age)
+	os.Chtimes(path, when, when)
+	return path
+}
+
+func TestEnforceUserQuotas(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	alice := models.User{Email: "alice@example.com", HashedPassword: "x", MaxStorageMB: 1}
+	bob := models.User{Email: "bob@example.com", HashedPassword: "x"}
+	database.DB.Create(&alice)
+	database.DB.Create(&bob)
+	cam := models.Camera{Name: "front", Path: "front", RTSPUrl: "rtsp://192.0.2.1/front", OwnerID: alice.ID}
+	other := models.Camera{Name: "back", Path: "back", RTSPUrl: "rtsp://192.0.2.1/back", OwnerID: bob.ID}
+	database.DB.Create(&cam)
+	database.DB.Create(&other)
+
+	// Alice holds 1600 KB against a 1024 KB quota; her oldest footage is a split event
+	base := filepath.Join(EventRoot, fmt.Sprintf("event_%d_20240102-120000", cam.ID))
+	parts := []string{
+		sizedFile(t, base+"_000.mp4", 300, 3*day),
+		sizedFile(t, base+"_001.mp4", 300, 3*day-time.Minute),
+	}
+	thumb := sizedFile(t, base+".jpg", 0, 3*day)
+	event := models.Event{
+		CameraID:      cam.ID,
+		UserID:        alice.ID,
+		StartTime:     time.Now().Add(-3 * day),
+		VideoPath:     LogicalPath(parts[0]),
+		ThumbnailPath: LogicalPath(thumb),
+	}
+	database.DB.Create(&event)
+	older := sizedFile(t, filepath.Join(ContinuousDir(cam.ID), "20240103-120000.mp4"), 600, 2*day)
+	newest := sizedFile(t, filepath.Join(ContinuousDir(cam.ID), "20240105-120000.mp4"), 400, time.Hour)
+
+	// Bob has no quota, and his files never count against Alice's
+	bobs := sizedFile(t, filepath.Join(EventRoot, fmt.Sprintf("event_%d_20240101-120000.mp4", other.ID)), 2048, 10*day)
+
+	if got := UserStorageBytes(alice.ID); got != 1600<<10 {
+		t.Fatalf("UserStorageBytes = %d, want %d", got, 1600<<10)
+	}
+
+	NewManager().enforceUserQuotas()
+
+	// Deleting the whole event (both parts) brings her to 1000 KB, under quota
+	for _, path := range append(parts, thumb) {
+		if exists(path) {
+			t.Errorf("%s kept; the oldest event should go as a whole", path)
+		}
+	}
+	var n int64
+	database.DB.Model(&models.Event{}).Where("id = ?", event.ID).Count(&n)
+	if n != 0 {
+		t.Error("event row kept after its files were pruned")
+	}
+	for _, path := range []string{older, newest, bobs} {
+		if !exists(path) {
+			t.Errorf("%s deleted though the quota was already met", path)
+		}
+	}
+	if got := UserStorageBytes(alice.ID); got != 1000<<10 {
+		t.Errorf("after pruning UserStorageBytes = %d, want %d", got, 1000<<10)
+	}
+}
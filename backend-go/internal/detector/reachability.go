@@ -0,0 +1,106 @@
+package detector
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/models"
+	"nvr-server/internal/secrets"
+)
+
+// How long to wait for a camera's TCP handshake before calling it unreachable
+var ReachabilityTimeout = config.Duration("NVR_REACHABILITY_TIMEOUT", 1500*time.Millisecond)
+
+// A camera that stays unreachable is re-checked after ReachabilityBackoff,
+// doubling on each failure up to ReachabilityMaxBackoff, rather than every sync
+var (
+	ReachabilityBackoff    = config.Duration("NVR_REACHABILITY_BACKOFF", 30*time.Second)
+	ReachabilityMaxBackoff = config.Duration("NVR_REACHABILITY_MAX_BACKOFF", 5*time.Minute)
+)
+
+// reachRetry is when an unreachable camera's stream URL is next dialled
+type reachRetry struct {
+	url   string
+	next  time.Time
+	delay time.Duration
+}
+
+var defaultPorts = map[string]string{
+	"rtsp":  "554",
+	"rtsps": "322",
+	"http":  "80",
+	"https": "443",
+}
+
+// streamAddress extracts host:port from a stream URL, filling in the scheme's default port
+func streamAddress(rawURL string) (string, bool) {
+	masked, _ := secrets.Mask(rawURL)
+	u, err := url.Parse(masked)
+	if err != nil || u.Hostname() == "" {
+		return "", false
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultPorts[strings.ToLower(u.Scheme)]
+	}
+	if port == "" {
+		return "", false
+	}
+	return net.JoinHostPort(u.Hostname(), port), true
+}
+
+// StreamIdentity normalizes a stream URL for duplicate detection: credentials are
+// dropped, host is lowercased and the scheme's default port made explicit, so
+// rtsp://user:pw@Cam:554/live and rtsp://cam/live compare equal. Empty if unparseable.
+func StreamIdentity(rawURL string) string {
+	addr, ok := streamAddress(rawURL)
+	if !ok {
+		return ""
+	}
+	masked, _ := secrets.Mask(rawURL)
+	u, _ := url.Parse(masked)
+	id := strings.ToLower(addr) + "/" + strings.Trim(u.Path, "/")
+	if u.RawQuery != "" {
+		id += "?" + u.RawQuery
+	}
+	return id
+}
+
+// checkReachable does a bare TCP connect to the stream's host. URLs we can't parse
+// are let through so MediaMTX can report the real problem.
+func checkReachable(rawURL string) error {
+	addr, ok := streamAddress(rawURL)
+	if !ok {
+		return nil
+	}
+	conn, err := net.DialTimeout("tcp", addr, ReachabilityTimeout)
+	if err != nil {
+		return err
+	}
+	conn.Close()
+	return nil
+}
+
+// backOffReachability schedules the next check of a camera that failed one. A
+// changed stream URL starts over, so an edit or a healed IP is tried at once
+// on the following sync. Callers hold m.mu.
+func (m *Manager) backOffReachability(cam models.Camera) {
+	retry := m.reachRetry[cam.ID]
+	if retry.url != cam.RTSPUrl || retry.delay == 0 {
+		retry = reachRetry{url: cam.RTSPUrl, delay: ReachabilityBackoff}
+	} else {
+		retry.delay = min(2*retry.delay, ReachabilityMaxBackoff)
+	}
+	retry.next = time.Now().Add(retry.delay)
+	m.reachRetry[cam.ID] = retry
+}
+
+// Reachable reports whether the camera answered the last pre-registration check
+func (m *Manager) Reachable(camID uint) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !m.unreachable[camID]
+}
@@ -0,0 +1,47 @@
+package detector
+
+import (
+	"log"
+	"time"
+
+	"nvr-server/internal/config"
+	"nvr-server/internal/mediamtx"
+)
+
+// MediaMTXStartupWait caps how long the first sync waits for the MediaMTX API.
+// After that cameras are synced anyway and registration is retried every tick.
+var MediaMTXStartupWait = config.Duration("NVR_MEDIAMTX_STARTUP_WAIT", 2*time.Minute)
+
+const (
+	mediamtxInitialBackoff = time.Second
+	mediamtxMaxBackoff     = 15 * time.Second
+)
+
+// awaitMediaMTX polls the MediaMTX API with exponential backoff so the first
+// SyncCameras doesn't race a container that is still starting. It returns false
+// only if the manager is stopped while waiting.
+func (m *Manager) awaitMediaMTX() bool {
+	deadline := time.Now().Add(MediaMTXStartupWait)
+	backoff := mediamtxInitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := mediamtx.Ping()
+		if err == nil {
+			if attempt > 1 {
+				log.Println("MediaMTX is up, syncing cameras")
+			}
+			return true
+		}
+		if time.Now().After(deadline) {
+			log.Printf("MediaMTX still unavailable after %s (%v), syncing cameras anyway\n", MediaMTXStartupWait, err)
+			return true
+		}
+		if attempt == 1 {
+			log.Printf("Waiting for MediaMTX before syncing cameras: %v\n", err)
+		}
+		if !m.sleep(backoff) {
+			return false
+		}
+		backoff = min(backoff*2, mediamtxMaxBackoff)
+	}
+}
@@ -0,0 +1,90 @@
+package detector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/mediamtx"
+)
+
+// useMediaMTX points the MediaMTX client at h for the rest of the test
+func useMediaMTX(t *testing.T, h http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	prev := mediamtx.APIBase
+	mediamtx.APIBase = srv.URL
+	t.Cleanup(func() {
+		mediamtx.APIBase = prev
+		srv.Close()
+	})
+}
+
+func TestAwaitMediaMTXComesUpLate(t *testing.T) {
+	var calls atomic.Int32
+	useMediaMTX(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"items":[]}`))
+	})
+
+	m := NewManager()
+	start := time.Now()
+	if !m.awaitMediaMTX() {
+		t.Fatal("awaitMediaMTX gave up on a running manager")
+	}
+	if calls.Load() != 2 {
+		t.Errorf("MediaMTX polled %d times, want 2", calls.Load())
+	}
+	if elapsed := time.Since(start); elapsed < mediamtxInitialBackoff {
+		t.Errorf("retried after %v, before the initial backoff", elapsed)
+	}
+}
+
+func TestAwaitMediaMTXGivesUpAfterStartupWait(t *testing.T) {
+	useMediaMTX(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	prev := MediaMTXStartupWait
+	MediaMTXStartupWait = 0
+	t.Cleanup(func() { MediaMTXStartupWait = prev })
+
+	if !NewManager().awaitMediaMTX() {
+		t.Error("past the startup wait cameras should be synced anyway")
+	}
+}
+
+func TestAwaitMediaMTXStops(t *testing.T) {
+	useMediaMTX(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	m := NewManager()
+	done := make(chan bool, 1)
+	go func() { done <- m.awaitMediaMTX() }()
+	time.Sleep(50 * time.Millisecond)
+	m.cancel()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("awaitMediaMTX reported ready after Stop")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("awaitMediaMTX kept waiting after Stop")
+	}
+}
+
+func TestSyncCamerasWaitsForFirstSync(t *testing.T) {
+	// Any database access would panic: SyncCameras must return first
+	prev := database.DB
+	database.DB = nil
+	t.Cleanup(func() { database.DB = prev })
+
+	NewManager().SyncCameras()
+}
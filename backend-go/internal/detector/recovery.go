@@ -0,0 +1,106 @@
+package detector
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// recoverUnfinishedEvents repairs events left open by a crash. Their ffmpeg never
+// got to finalize the file, so each clip is remuxed and its end time taken from the
+// file's mtime; clips that cannot be salvaged are dropped with their rows.
+func (m *Manager) recoverUnfinishedEvents(startedAt time.Time) {
+	var events []models.Event
+	database.DB.Where("(end_time IS NULL OR end_time <= ?) AND start_time < ?", time.Time{}, startedAt).Find(&events)
+	if len(events) == 0 {
+		return
+	}
+	log.Printf("Recovery: %d unfinished events from a previous run\n", len(events))
+
+	for _, event := range events {
+		if m.ctx.Err() != nil {
+			return
+		}
+		if m.isRecordingEvent(event.ID) {
+			continue
+		}
+
+		absPath := MediaPath(event.VideoPath)
+		info, err := os.Stat(absPath)
+		if err != nil || event.VideoPath == "" {
+			log.Printf("Recovery: Event %d has no file, removing\n", event.ID)
+			database.DB.Delete(&models.Event{}, event.ID)
+			continue
+		}
+		endTime := info.ModTime()
+
+		if err := remuxInPlace(absPath); err != nil {
+			log.Printf("Recovery: Event %d unrecoverable (%v), discarding\n", event.ID, err)
+			for _, part := range EventParts(absPath) {
+				os.Remove(part)
+			}
+			database.DB.Delete(&models.Event{}, event.ID)
+			continue
+		}
+
+		// Later parts of a split event were interrupted the same way
+		parts := EventParts(absPath)
+		for _, part := range parts[1:] {
+			if info, err := os.Stat(part); err == nil {
+				endTime = info.ModTime()
+			}
+			if err := remuxInPlace(part); err != nil {
+				log.Printf("Recovery: Event %d part %s unrecoverable (%v), dropping\n", event.ID, filepath.Base(part), err)
+				os.Remove(part)
+			}
+		}
+
+		database.DB.Model(&models.Event{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+			"end_time": endTime,
+			"parts":    finalizeEventParts(absPath),
+		})
+		if event.ThumbnailPath == "" {
+			eventID := event.ID
+			m.spawn(func() { m.generateThumbnail(absPath, eventID) })
+		}
+		log.Printf("Recovery: Event %d finalized\n", event.ID)
+	}
+}
+
+func (m *Manager) isRecordingEvent(eventID uint) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rec := range m.ActiveRecordings {
+		if rec.EventID == eventID {
+			return true
+		}
+	}
+	return false
+}
+
+// remuxInPlace rewrites a clip with a proper index, replacing the original on success
+func remuxInPlace(path string) error {
+	tmpPath := path + ".recover.mp4"
+	_, err := runTool(context.Background(), 2*time.Minute, FFmpegBin,
+		"-v", "error",
+		"-i", path,
+		"-c", "copy",
+		"-movflags", "+faststart",
+		"-y", tmpPath,
+	)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if info, err := os.Stat(tmpPath); err != nil || info.Size() == 0 {
+		os.Remove(tmpPath)
+		return os.ErrNotExist
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,97 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// useRemuxFFmpeg stands in for ffmpeg's remux: inputs named *broken* fail,
+// anything else is rewritten as "fixed"
+func useRemuxFFmpeg(t *testing.T) {
+	t.Helper()
+	prev := FFmpegBin
+	FFmpegBin = stubTool(t, `for out; do :; done
+case "$*" in *broken*) echo "moov atom not found" >&2; exit 1 ;; esac
+printf fixed > "$out"`)
+	t.Cleanup(func() { FFmpegBin = prev })
+}
+
+func TestRemuxInPlace(t *testing.T) {
+	useRemuxFFmpeg(t)
+	dir := t.TempDir()
+
+	good := filepath.Join(dir, "event_1.mp4")
+	os.WriteFile(good, []byte("unindexed"), 0644)
+	if err := remuxInPlace(good); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(good); string(data) != "fixed" {
+		t.Errorf("clip = %q, want the remuxed file", data)
+	}
+
+	broken := filepath.Join(dir, "event_broken.mp4")
+	os.WriteFile(broken, []byte("garbage"), 0644)
+	if err := remuxInPlace(broken); err == nil {
+		t.Error("failed remux reported success")
+	}
+	if data, _ := os.ReadFile(broken); string(data) != "garbage" {
+		t.Error("original replaced after a failed remux")
+	}
+	if _, err := os.Stat(broken + ".recover.mp4"); !os.IsNotExist(err) {
+		t.Error("temp file left behind")
+	}
+}
+
+func TestRecoverUnfinishedEvents(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	useRemuxFFmpeg(t)
+
+	cam := models.Camera{Name: "yard", Path: "yard", RTSPUrl: "rtsp://192.0.2.1/yard"}
+	database.DB.Create(&cam)
+	startedAt := time.Now()
+	mtime := startedAt.Add(-time.Hour).Truncate(time.Second)
+
+	newEvent := func(name string, withFile bool) models.Event {
+		e := models.Event{CameraID: cam.ID, StartTime: startedAt.Add(-2 * time.Hour), VideoPath: "recordings/" + name, ThumbnailPath: "recordings/x.jpg"}
+		if withFile {
+			path := filepath.Join(EventRoot, name)
+			os.WriteFile(path, []byte("unindexed"), 0644)
+			os.Chtimes(path, mtime, mtime)
+		}
+		database.DB.Create(&e)
+		return e
+	}
+	interrupted := newEvent("event_1_20240101-080000.mp4", true)
+	missing := newEvent("event_1_20240101-090000.mp4", false)
+	broken := newEvent("event_1_broken.mp4", true)
+
+	m := NewManager()
+	stopManager(t, m)
+	m.recoverUnfinishedEvents(startedAt)
+
+	var got models.Event
+	if err := database.DB.First(&got, interrupted.ID).Error; err != nil {
+		t.Fatalf("interrupted event removed: %v", err)
+	}
+	if !got.EndTime.Equal(mtime) {
+		t.Errorf("end time = %v, want the file's mtime %v", got.EndTime, mtime)
+	}
+	if data, _ := os.ReadFile(filepath.Join(EventRoot, "event_1_20240101-080000.mp4")); string(data) != "fixed" {
+		t.Error("interrupted clip was not remuxed")
+	}
+
+	for _, gone := range []models.Event{missing, broken} {
+		if database.DB.First(&models.Event{}, gone.ID).Error == nil {
+			t.Errorf("event %s kept", gone.VideoPath)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(EventRoot, "event_1_broken.mp4")); !os.IsNotExist(err) {
+		t.Error("unrecoverable clip left on disk")
+	}
+}
@@ -0,0 +1,56 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"nvr-server/internal/mediamtx"
+	"nvr-server/internal/models"
+)
+
+// ReregisterResult reports what Reregister did to the camera's MediaMTX path
+type ReregisterResult struct {
+	Path       string `json:"path"`
+	Deleted    bool   `json:"deleted"`
+	AddStatus  int    `json:"add_status"`
+	Registered bool   `json:"registered"`
+}
+
+// Reregister drops the camera's cached registration, deletes its MediaMTX path
+// and adds it again from scratch, for streams that got wedged. The MediaMTX
+// calls run without m.mu so a slow API doesn't stall recordings.
+func (m *Manager) Reregister(ctx context.Context, cam models.Camera) (ReregisterResult, error) {
+	result := ReregisterResult{Path: cam.Path}
+	if cam.RTSPUrl == "" {
+		return result, fmt.Errorf("camera has no stream URL")
+	}
+
+	m.mu.Lock()
+	delete(m.RegisteredPaths, cam.ID)
+	m.mu.Unlock()
+
+	if err := mediamtx.DeletePath(cam.Path); err != nil {
+		return result, err
+	}
+	result.Deleted = true
+
+	status, err := mediamtx.AddPathContext(ctx, cam.Path, map[string]interface{}{
+		"source":         streamURL(cam.RTSPUrl),
+		"sourceOnDemand": false,
+	})
+	result.AddStatus = status
+	if err != nil {
+		return result, err
+	}
+	if status >= 400 {
+		return result, fmt.Errorf("mediamtx: add %s returned %d", cam.Path, status)
+	}
+
+	m.mu.Lock()
+	m.RegisteredPaths[cam.ID] = cam.RTSPUrl
+	m.mu.Unlock()
+	result.Registered = true
+	log.Printf("[%s] Re-registered with MediaMTX", cam.Name)
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package detector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"nvr-server/internal/models"
+)
+
+func TestReregister(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		calls   []string
+		source  string
+		addCode = http.StatusOK
+	)
+	useMediaMTX(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodPost {
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			source, _ = body["source"].(string)
+			w.WriteHeader(addCode)
+			return
+		}
+		// The path may already be gone; that is not an error
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	m := NewManager()
+	cam := models.Camera{ID: 5, Name: "yard", Path: "yard", RTSPUrl: "rtsp://192.0.2.1/yard"}
+	m.RegisteredPaths[cam.ID] = "rtsp://192.0.2.1/old"
+
+	result, err := m.Reregister(context.Background(), cam)
+	if err != nil || !result.Deleted || !result.Registered || result.AddStatus != http.StatusOK {
+		t.Fatalf("Reregister = %+v, %v", result, err)
+	}
+	want := []string{"DELETE /v3/config/paths/delete/yard", "POST /v3/config/paths/add/yard"}
+	if len(calls) != 2 || calls[0] != want[0] || calls[1] != want[1] {
+		t.Errorf("MediaMTX calls = %v, want %v", calls, want)
+	}
+	if source != cam.RTSPUrl || m.RegisteredPaths[cam.ID] != cam.RTSPUrl {
+		t.Errorf("source %q, registered %q", source, m.RegisteredPaths[cam.ID])
+	}
+
+	// A rejected add leaves the camera unregistered so the next sync retries it
+	mu.Lock()
+	addCode = http.StatusBadRequest
+	mu.Unlock()
+	result, err = m.Reregister(context.Background(), cam)
+	if err == nil || result.Registered || result.AddStatus != http.StatusBadRequest {
+		t.Errorf("rejected add = %+v, %v", result, err)
+	}
+	if _, ok := m.RegisteredPaths[cam.ID]; ok {
+		t.Error("camera still marked registered after a failed add")
+	}
+
+	if _, err := m.Reregister(context.Background(), models.Camera{ID: 6, Path: "empty"}); err == nil {
+		t.Error("camera without a stream URL re-registered")
+	}
+}
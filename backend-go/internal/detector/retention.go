@@ -0,0 +1,97 @@
+package detector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RetentionRule keeps footage recorded on the listed weekdays for Days instead of
+// the global RetentionDays, e.g. {"weekdays": ["sat", "sun"], "days": 90}
+type RetentionRule struct {
+	Weekdays []string `json:"weekdays"`
+	Days     int      `json:"days"`
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseWeekday accepts short or full English day names in any case
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) < 3 {
+		return 0, false
+	}
+	d, ok := weekdayNames[name[:3]]
+	if !ok || (len(name) > 3 && name != strings.ToLower(d.String())) {
+		return 0, false
+	}
+	return d, true
+}
+
+// ParseRetentionRules decodes and validates the SystemSettings.RetentionRules JSON
+func ParseRetentionRules(raw string) ([]RetentionRule, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var rules []RetentionRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("retention_rules must be a JSON array of {weekdays, days}")
+	}
+	for i, r := range rules {
+		if r.Days < 1 {
+			return nil, fmt.Errorf("retention rule %d: days must be at least 1", i)
+		}
+		if len(r.Weekdays) == 0 {
+			return nil, fmt.Errorf("retention rule %d: no weekdays", i)
+		}
+		for _, w := range r.Weekdays {
+			if _, ok := parseWeekday(w); !ok {
+				return nil, fmt.Errorf("retention rule %d: unknown weekday %q", i, w)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// retentionDaysFor returns the longest retention of any rule matching the day the
+// footage was recorded, or fallback when none match. Weekdays are evaluated in the
+// server's local timezone, since "weekend" means the site's weekend.
+func retentionDaysFor(rules []RetentionRule, recorded time.Time, fallback int) int {
+	day := recorded.In(time.Local).Weekday()
+	days, matched := 0, false
+	for _, r := range rules {
+		for _, w := range r.Weekdays {
+			if d, _ := parseWeekday(w); d == day {
+				days, matched = max(days, r.Days), true
+			}
+		}
+	}
+	if !matched {
+		return fallback
+	}
+	return days
+}
+
+// recordedTime is when a recording started according to its name (segment or
+// event timestamp), falling back to the file's mtime
+func recordedTime(path string, info os.FileInfo) time.Time {
+	base := filepath.Base(path)
+	if t, ok := ParseSegmentTime(base); ok {
+		return t
+	}
+	if rest, ok := strings.CutPrefix(base, "event_"); ok {
+		if _, stamp, found := strings.Cut(rest, "_"); found && len(stamp) >= len(SegmentTimeLayout) {
+			// Event names use server local time (see StartEventRecord)
+			if t, err := time.ParseInLocation(SegmentTimeLayout, stamp[:len(SegmentTimeLayout)], time.Local); err == nil {
+				return t
+			}
+		}
+	}
+	return info.ModTime()
+}
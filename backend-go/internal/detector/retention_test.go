@@ -0,0 +1,236 @@
+package detector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// agedFile creates path with its mtime set age in the past
+func agedFile(t *testing.T, path string, age time.Duration) string {
+	t.Helper()
+	writeSegment(t, path)
+	when := time.Now().Add(-age)
+	if err := os.Chtimes(path, when, when); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// retentionPass runs enforceRetention with the startup grace, the file arrival
+// age and the dry-run pass out of the way
+func retentionPass(t *testing.T, m *Manager) {
+	t.Helper()
+	prevGrace, prevAge := RetentionStartupGrace, RetentionMinFileAge
+	RetentionStartupGrace, RetentionMinFileAge = 0, 0
+	defer func() { RetentionStartupGrace, RetentionMinFileAge = prevGrace, prevAge }()
+	m.retentionPreviewed = true
+	m.enforceRetention()
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+const day = 24 * time.Hour
+
+func TestPerCameraRetention(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true, RetentionDays: 30})
+	short := models.Camera{Name: "short", Path: "short", RTSPUrl: "rtsp://192.0.2.1/short", OwnerID: 1, RetentionDays: 2}
+	normal := models.Camera{Name: "normal", Path: "normal", RTSPUrl: "rtsp://192.0.2.1/normal", OwnerID: 1}
+	database.DB.Create(&short)
+	database.DB.Create(&normal)
+
+	shortDir := filepath.Join(ContinuousRoot, fmt.Sprint(short.ID))
+	normalDir := filepath.Join(ContinuousRoot, fmt.Sprint(normal.ID))
+	gone := []string{
+		// Segments in a date partition still belong to their camera
+		agedFile(t, filepath.Join(shortDir, "2024", "01", "02", "20240102-120000.mp4"), 3*day),
+		agedFile(t, filepath.Join(shortDir, "20240102-130000.mp4"), 3*day),
+		agedFile(t, filepath.Join(EventRoot, "event_"+fmt.Sprint(short.ID)+"_20240102-120000.mp4"), 3*day),
+		agedFile(t, filepath.Join(normalDir, "2024", "01", "02", "20240102-120000.mp4"), 31*day),
+	}
+	kept := []string{
+		agedFile(t, filepath.Join(shortDir, "2024", "01", "05", "20240105-120000.mp4"), day),
+		agedFile(t, filepath.Join(normalDir, "2024", "01", "03", "20240103-120000.mp4"), 3*day),
+		agedFile(t, filepath.Join(EventRoot, "event_"+fmt.Sprint(normal.ID)+"_20240103-120000.mp4"), 3*day),
+		// Not media: never deleted
+		agedFile(t, filepath.Join(shortDir, "notes.txt"), 90*day),
+	}
+
+	retentionPass(t, NewManager())
+	for _, path := range gone {
+		if exists(path) {
+			t.Errorf("%s kept past its retention", path)
+		}
+	}
+	for _, path := range kept {
+		if !exists(path) {
+			t.Errorf("%s deleted early", path)
+		}
+	}
+}
+
+func TestParseRetentionRules(t *testing.T) {
+	rules, err := ParseRetentionRules(`[{"weekdays":["Sat","sunday"],"days":90},{"weekdays":["mon"],"days":7}]`)
+	if err != nil || len(rules) != 2 || rules[0].Days != 90 {
+		t.Fatalf("valid rules = %+v, %v", rules, err)
+	}
+	if rules, err := ParseRetentionRules("  "); err != nil || rules != nil {
+		t.Errorf("blank rules = %+v, %v", rules, err)
+	}
+	for _, bad := range []string{
+		`{"weekdays":["sat"],"days":9}`,
+		`[{"weekdays":["sat"],"days":0}]`,
+		`[{"weekdays":[],"days":5}]`,
+		`[{"weekdays":["saturnday"],"days":5}]`,
+		`[{"weekdays":["sa"],"days":5}]`,
+	} {
+		if _, err := ParseRetentionRules(bad); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
+
+func TestRetentionDaysFor(t *testing.T) {
+	rules := []RetentionRule{
+		{Weekdays: []string{"sat", "sun"}, Days: 90},
+		{Weekdays: []string{"sun"}, Days: 120},
+	}
+	saturday := time.Date(2024, 1, 6, 12, 0, 0, 0, time.Local)
+	cases := map[time.Time]int{
+		saturday:                  90,
+		saturday.AddDate(0, 0, 1): 120, // the longest matching rule wins
+		saturday.AddDate(0, 0, 2): 30,
+	}
+	for recorded, want := range cases {
+		if got := retentionDaysFor(rules, recorded, 30); got != want {
+			t.Errorf("%s: %d days, want %d", recorded.Weekday(), got, want)
+		}
+	}
+	if got := retentionDaysFor(nil, saturday, 30); got != 30 {
+		t.Errorf("no rules: %d days", got)
+	}
+}
+
+func TestWeekdayRetentionPass(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	// Keep footage recorded on the weekday of the first segment for 90 days
+	weekend, _ := ParseSegmentTime("20240106-120000.mp4")
+	rule := `[{"weekdays":["` + weekend.In(time.Local).Weekday().String() + `"],"days":90}]`
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true, RetentionDays: 30, RetentionRules: rule})
+
+	dir := filepath.Join(ContinuousRoot, "1")
+	ruled := agedFile(t, filepath.Join(dir, "20240106-120000.mp4"), 40*day)
+	plain := agedFile(t, filepath.Join(dir, "20240108-120000.mp4"), 40*day)
+	retentionPass(t, NewManager())
+
+	if !exists(ruled) {
+		t.Error("segment covered by a 90 day rule deleted at 40 days")
+	}
+	if exists(plain) {
+		t.Error("segment outside the rule kept past the 30 day default")
+	}
+}
+
+func TestRetentionKeepsLastEvents(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true, RetentionDays: 7, MinEventsKept: 2})
+	cam := models.Camera{Name: "quiet", Path: "quiet", RTSPUrl: "rtsp://192.0.2.1/quiet", OwnerID: 1}
+	database.DB.Create(&cam)
+
+	// Four events, all past retention; the newest two survive
+	var clips, thumbs []string
+	for i := 0; i < 4; i++ {
+		started := time.Now().Add(-time.Duration(40-i) * day)
+		name := fmt.Sprintf("event_%d_%s", cam.ID, started.Format("20060102-150405"))
+		clip := agedFile(t, filepath.Join(EventRoot, name+".mp4"), 40*day)
+		thumb := agedFile(t, filepath.Join(EventRoot, name+".jpg"), 40*day)
+		clips, thumbs = append(clips, clip), append(thumbs, thumb)
+		database.DB.Create(&models.Event{CameraID: cam.ID, StartTime: started, VideoPath: LogicalPath(clip), ThumbnailPath: LogicalPath(thumb)})
+	}
+	stray := agedFile(t, filepath.Join(EventRoot, "orphan.mp4"), 40*day)
+
+	retentionPass(t, NewManager())
+	for i := range clips {
+		if want := i >= 2; exists(clips[i]) != want || exists(thumbs[i]) != want {
+			t.Errorf("event %d: clip kept %v, thumbnail kept %v; want %v", i, exists(clips[i]), exists(thumbs[i]), want)
+		}
+	}
+	if exists(stray) {
+		t.Error("file with no event kept")
+	}
+}
+
+func TestKeptEventFilesPerCamera(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	now := time.Now()
+	for camID := uint(1); camID <= 2; camID++ {
+		for i := 0; i < 3; i++ {
+			database.DB.Create(&models.Event{CameraID: camID, StartTime: now.Add(-time.Duration(i) * time.Hour), VideoPath: fmt.Sprintf("recordings/event_%d_%d.mp4", camID, i)})
+		}
+	}
+
+	if kept := keptEventFiles(0); len(kept) != 0 {
+		t.Errorf("n=0 kept %v", kept)
+	}
+	kept := keptEventFiles(1)
+	for _, want := range []string{"recordings/event_1_0.mp4", "recordings/event_2_0.mp4"} {
+		if !kept[MediaPath(want)] {
+			t.Errorf("%s not kept: %v", want, kept)
+		}
+	}
+	if kept[MediaPath("recordings/event_1_1.mp4")] {
+		t.Error("second-newest event kept with n=1")
+	}
+}
+
+func TestRetentionGraceAndDryRun(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	database.DB.Create(&models.SystemSettings{AllowRegistration: true, RetentionDays: 7})
+	old := agedFile(t, filepath.Join(EventRoot, "event_1_20240102-120000.mp4"), 40*day)
+
+	prevGrace, prevAge := RetentionStartupGrace, RetentionMinFileAge
+	t.Cleanup(func() { RetentionStartupGrace, RetentionMinFileAge = prevGrace, prevAge })
+	RetentionMinFileAge = 0
+	m := NewManager()
+
+	// Inside the startup grace nothing runs, not even the preview
+	RetentionStartupGrace = time.Hour
+	m.enforceRetention()
+	if !exists(old) || m.retentionPreviewed {
+		t.Fatalf("during grace: file kept %v, previewed %v", exists(old), m.retentionPreviewed)
+	}
+
+	// The first pass after it only reports
+	RetentionStartupGrace = 0
+	m.enforceRetention()
+	if !exists(old) || !m.retentionPreviewed {
+		t.Fatalf("first pass: file kept %v, previewed %v", exists(old), m.retentionPreviewed)
+	}
+
+	// Files that only just arrived on this disk (copied with an old mtime) wait
+	RetentionMinFileAge = time.Hour
+	m.enforceRetention()
+	if !exists(old) {
+		t.Fatal("freshly copied file deleted")
+	}
+
+	RetentionMinFileAge = 0
+	m.enforceRetention()
+	if exists(old) {
+		t.Error("expired file kept after the preview pass")
+	}
+}
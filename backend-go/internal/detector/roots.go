@@ -0,0 +1,140 @@
+package detector
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"nvr-server/internal/config"
+)
+
+// Where event clips (with their thumbnails and previews) and continuous segments
+// are written. Both default to the original single /recordings tree; pointing
+// them at different disks keeps events on fast storage and 24/7 footage on bulk.
+var (
+	EventRoot      = filepath.Clean(config.String("NVR_EVENT_ROOT", "/recordings"))
+	ContinuousRoot = filepath.Clean(config.String("NVR_CONTINUOUS_ROOT", "/recordings/continuous"))
+)
+
+// The database and URLs keep addressing files as "recordings/event_..." and
+// "recordings/continuous/<id>/...", whichever disk they are actually on
+const (
+	logicalEvents     = "recordings/"
+	logicalContinuous = "recordings/continuous/"
+)
+
+// ContinuousDir is the directory holding a camera's segments
+func ContinuousDir(camID uint) string {
+	return filepath.Join(ContinuousRoot, strconv.Itoa(int(camID)))
+}
+
+// MediaPath maps a logical "recordings/..." path to the file on disk. A segment
+// addressed as continuous/<id>/<name> is looked up in its date partition too.
+func MediaPath(rel string) string {
+	if rest, ok := strings.CutPrefix(rel, logicalContinuous); ok {
+		if idStr, name, found := strings.Cut(rest, "/"); found && !strings.Contains(name, "/") {
+			if id, ok := parseCameraID(idStr); ok {
+				return SegmentPath(id, name)
+			}
+		}
+		return filepath.Join(ContinuousRoot, rest)
+	}
+	if rest, ok := strings.CutPrefix(rel, logicalEvents); ok {
+		return filepath.Join(EventRoot, rest)
+	}
+	return filepath.Join("/", rel)
+}
+
+// LogicalPath is the inverse of MediaPath. Segments always sit in a per-camera
+// subdirectory, which tells them apart from event files when the roots overlap.
+func LogicalPath(abs string) string {
+	cont, inCont := relativeTo(ContinuousRoot, abs)
+	ev, inEvents := relativeTo(EventRoot, abs)
+
+	isContinuous := inCont && strings.Contains(cont, "/")
+	if isContinuous && inEvents && len(EventRoot) > len(ContinuousRoot) {
+		isContinuous = false
+	}
+	switch {
+	case isContinuous:
+		return logicalContinuous + cont
+	case inEvents:
+		return logicalEvents + ev
+	}
+	return strings.TrimPrefix(abs, "/")
+}
+
+// RecordingRoots lists the directories to walk for every stored file, dropping a
+// root that is nested inside the other so nothing is visited twice
+func RecordingRoots() []string {
+	if _, nested := relativeTo(EventRoot, ContinuousRoot); nested || EventRoot == ContinuousRoot {
+		return []string{EventRoot}
+	}
+	if _, nested := relativeTo(ContinuousRoot, EventRoot); nested {
+		return []string{ContinuousRoot}
+	}
+	return []string{EventRoot, ContinuousRoot}
+}
+
+func relativeTo(root, path string) (string, bool) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// walkRecordings calls fn for every regular file under the recording roots
+func walkRecordings(fn func(path string, info os.FileInfo)) {
+	for _, root := range RecordingRoots() {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				fn(path, info)
+			}
+			return nil
+		})
+	}
+}
+
+// FreeBytes is the free space on the fullest recording disk
+func FreeBytes() (uint64, bool) {
+	var free uint64
+	found := false
+	for _, root := range RecordingRoots() {
+		avail, ok := freeBytesAt(root)
+		if !ok {
+			continue
+		}
+		if !found || avail < free {
+			free = avail
+		}
+		found = true
+	}
+	return free, found
+}
+
+// freeBytesAt is the space available to unprivileged writers on root's disk
+func freeBytesAt(root string) (uint64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(root, &stat); err != nil {
+		return 0, false
+	}
+	// Available blocks * size per block
+	return stat.Bavail * uint64(stat.Bsize), true
+}
+
+// ErrNoSpace means a recording was refused because its disk is below MinFreeRecordingGB
+var ErrNoSpace = errors.New("not enough free disk space to record")
+
+// hasRecordingSpace reports whether root's disk has at least floorGB free, along
+// with the free bytes. An unreadable disk or a floor of 0 never blocks recording.
+func hasRecordingSpace(root string, floorGB int) (uint64, bool) {
+	free, ok := freeBytesAt(root)
+	if !ok || floorGB <= 0 {
+		return free, true
+	}
+	return free, free >= uint64(floorGB)<<30
+}
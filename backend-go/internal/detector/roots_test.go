@@ -0,0 +1,128 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"nvr-server/internal/models"
+)
+
+// setRoots points the recording roots at fixed paths for the test
+func setRoots(t *testing.T, events, continuous string) {
+	t.Helper()
+	prevEvents, prevContinuous := EventRoot, ContinuousRoot
+	EventRoot, ContinuousRoot = events, continuous
+	t.Cleanup(func() { EventRoot, ContinuousRoot = prevEvents, prevContinuous })
+}
+
+func TestMediaPathSeparateRoots(t *testing.T) {
+	setRoots(t, "/fast/events", "/bulk/continuous")
+	cases := map[string]string{
+		"recordings/event_3_20240102-120000.mp4":                 "/fast/events/event_3_20240102-120000.mp4",
+		"recordings/previews/event_3_20240102-120000.webp":       "/fast/events/previews/event_3_20240102-120000.webp",
+		"recordings/continuous/3/2024/01/02/20240102-120000.mp4": "/bulk/continuous/3/2024/01/02/20240102-120000.mp4",
+	}
+	for logical, abs := range cases {
+		if got := MediaPath(logical); got != abs {
+			t.Errorf("MediaPath(%q) = %q, want %q", logical, got, abs)
+		}
+		if got := LogicalPath(abs); got != logical {
+			t.Errorf("LogicalPath(%q) = %q, want %q", abs, got, logical)
+		}
+	}
+	if got := LogicalPath("/elsewhere/file.mp4"); got != "elsewhere/file.mp4" {
+		t.Errorf("path outside both roots = %q", got)
+	}
+}
+
+func TestMediaPathNestedRoots(t *testing.T) {
+	// The original single-tree layout: continuous lives inside the event root
+	setRoots(t, "/recordings", "/recordings/continuous")
+	cases := map[string]string{
+		"recordings/event_3_20240102-120000.mp4":                 "/recordings/event_3_20240102-120000.mp4",
+		"recordings/continuous/3/2024/01/02/20240102-120000.mp4": "/recordings/continuous/3/2024/01/02/20240102-120000.mp4",
+	}
+	for logical, abs := range cases {
+		if got := MediaPath(logical); got != abs {
+			t.Errorf("MediaPath(%q) = %q, want %q", logical, got, abs)
+		}
+		if got := LogicalPath(abs); got != logical {
+			t.Errorf("LogicalPath(%q) = %q, want %q", abs, got, logical)
+		}
+	}
+}
+
+func TestRecordingRoots(t *testing.T) {
+	cases := []struct {
+		events, continuous string
+		want               []string
+	}{
+		{"/recordings", "/recordings/continuous", []string{"/recordings"}},
+		{"/recordings", "/recordings", []string{"/recordings"}},
+		{"/data/continuous/events", "/data/continuous", []string{"/data/continuous"}},
+		{"/fast/events", "/bulk/continuous", []string{"/fast/events", "/bulk/continuous"}},
+		{"/recordings", "/recordings-bulk", []string{"/recordings", "/recordings-bulk"}},
+	}
+	for _, tc := range cases {
+		setRoots(t, tc.events, tc.continuous)
+		if got := RecordingRoots(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s + %s = %v, want %v", tc.events, tc.continuous, got, tc.want)
+		}
+	}
+}
+
+func TestWalkRecordingsVisitsEachFileOnce(t *testing.T) {
+	root := t.TempDir()
+	for _, layout := range [][2]string{
+		{root, filepath.Join(root, "continuous")},
+		{filepath.Join(root, "events"), filepath.Join(root, "bulk")},
+	} {
+		setRoots(t, layout[0], layout[1])
+		event := filepath.Join(EventRoot, "event_1_20240102-120000.mp4")
+		segment := filepath.Join(ContinuousDir(1), "20240102-120000.mp4")
+		writeSegment(t, event)
+		writeSegment(t, segment)
+
+		var seen []string
+		walkRecordings(func(path string, _ os.FileInfo) { seen = append(seen, path) })
+		sort.Strings(seen)
+		want := []string{event, segment}
+		sort.Strings(want)
+		if !reflect.DeepEqual(seen, want) {
+			t.Errorf("roots %v: walked %v, want %v", layout, seen, want)
+		}
+	}
+}
+
+func TestHasRecordingSpace(t *testing.T) {
+	root := t.TempDir()
+	free, ok := hasRecordingSpace(root, 0)
+	if !ok || free == 0 {
+		t.Fatalf("floor 0 = %d, %v", free, ok)
+	}
+	if _, ok := hasRecordingSpace(root, 1<<20); ok {
+		t.Error("a petabyte floor passed")
+	}
+	// A disk we can't read never blocks recording
+	if free, ok := hasRecordingSpace(filepath.Join(root, "missing"), 1<<20); !ok || free != 0 {
+		t.Errorf("unreadable disk = %d, %v", free, ok)
+	}
+}
+
+func TestSpawnContinuousNoSpace(t *testing.T) {
+	testRoots(t)
+	m := NewManager()
+	cam := models.Camera{ID: 4, Name: "yard", RTSPUrl: "rtsp://192.0.2.1/yard", ContinuousRecording: true}
+
+	m.mu.Lock()
+	m.spawnContinuous(cam, 1<<20, 1<<30, false)
+	_, started := m.ContinuousProcs[cam.ID]
+	flagged := m.noSpace[cam.ID]
+	m.mu.Unlock()
+	if started || !flagged {
+		t.Errorf("low disk: started %v, flagged %v", started, flagged)
+	}
+}
@@ -0,0 +1,107 @@
+package detector
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// Boxes beyond this many per event are dropped; a chatty detector would
+// otherwise grow the sidecar (and the active recording) without bound
+const maxSidecarBoxes = 1000
+
+// EventSidecar is the JSON written next to a finished clip for other tools
+type EventSidecar struct {
+	EventID         uint              `json:"event_id"`
+	CameraID        uint              `json:"camera_id"`
+	CameraName      string            `json:"camera_name"`
+	StartTime       time.Time         `json:"start_time"`
+	EndTime         time.Time         `json:"end_time"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Reason          string            `json:"reason"`
+	AutoTerminated  bool              `json:"auto_terminated"`
+	DetectedClasses []SidecarClass    `json:"detected_classes"`
+	Boxes           []json.RawMessage `json:"boxes,omitempty"`
+	Files           []string          `json:"files"`
+}
+
+// SidecarClass is one detected class and the highest confidence reported for it
+type SidecarClass struct {
+	Class      string   `json:"class"`
+	Confidence *float64 `json:"confidence,omitempty"`
+}
+
+// SidecarPath is where an event's metadata sidecar is stored: the clip's name
+// with .json, without the part number for split events
+func SidecarPath(videoPath string) string {
+	if strings.HasSuffix(videoPath, firstPartSuffix) {
+		return strings.TrimSuffix(videoPath, firstPartSuffix) + ".json"
+	}
+	return strings.TrimSuffix(videoPath, ".mp4") + ".json"
+}
+
+// AddEventBoxes attaches detector bounding boxes (passed through as given) to
+// the camera's active recording; they are written to its sidecar when it ends
+func (m *Manager) AddEventBoxes(camID uint, boxes []json.RawMessage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.ActiveRecordings[camID]
+	if !ok {
+		return
+	}
+	if room := maxSidecarBoxes - len(rec.Boxes); room < len(boxes) {
+		boxes = boxes[:max(room, 0)]
+	}
+	rec.Boxes = append(rec.Boxes, boxes...)
+}
+
+// writeSidecar records a finalized event's metadata next to its clip, written
+// via a temp file so readers never see a partial document
+func writeSidecar(event models.Event, videoPath string, boxes []json.RawMessage) {
+	var cam models.Camera
+	database.DB.Select("id, name").First(&cam, event.CameraID)
+
+	var classes []models.EventClass
+	database.DB.Where("event_id = ?", event.ID).Order("id asc").Find(&classes)
+
+	sidecar := EventSidecar{
+		EventID:         event.ID,
+		CameraID:        event.CameraID,
+		CameraName:      cam.Name,
+		StartTime:       event.StartTime.UTC(),
+		EndTime:         event.EndTime.UTC(),
+		DurationSeconds: event.EndTime.Sub(event.StartTime).Seconds(),
+		Reason:          event.Reason,
+		AutoTerminated:  event.AutoTerminated,
+		DetectedClasses: make([]SidecarClass, 0, len(classes)),
+		Boxes:           boxes,
+		Files:           make([]string, 0),
+	}
+	for _, c := range classes {
+		sidecar.DetectedClasses = append(sidecar.DetectedClasses, SidecarClass{Class: c.Class, Confidence: c.Confidence})
+	}
+	for _, part := range EventParts(videoPath) {
+		sidecar.Files = append(sidecar.Files, filepath.Base(part))
+	}
+
+	data, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return
+	}
+	path := SidecarPath(videoPath)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("Event %d sidecar failed: %v", event.ID, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("Event %d sidecar failed: %v", event.ID, err)
+		os.Remove(tmp)
+	}
+}
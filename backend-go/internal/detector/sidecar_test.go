@@ -0,0 +1,80 @@
+package detector
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestSidecarPath(t *testing.T) {
+	cases := map[string]string{
+		"/rec/event_1_20240102-120000.mp4":     "/rec/event_1_20240102-120000.json",
+		"/rec/event_1_20240102-120000_000.mp4": "/rec/event_1_20240102-120000.json",
+	}
+	for in, want := range cases {
+		if got := SidecarPath(in); got != want {
+			t.Errorf("SidecarPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestAddEventBoxesCapped(t *testing.T) {
+	m := NewManager()
+	box := json.RawMessage(`{"x":1}`)
+	m.AddEventBoxes(1, []json.RawMessage{box})
+
+	m.ActiveRecordings[1] = &ActiveRecording{}
+	for i := 0; i < maxSidecarBoxes/100+1; i++ {
+		batch := make([]json.RawMessage, 100)
+		for j := range batch {
+			batch[j] = box
+		}
+		m.AddEventBoxes(1, batch)
+	}
+	if n := len(m.ActiveRecordings[1].Boxes); n != maxSidecarBoxes {
+		t.Errorf("%d boxes kept, want the cap of %d", n, maxSidecarBoxes)
+	}
+}
+
+func TestWriteSidecar(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	cam := models.Camera{Name: "front", Path: "front", RTSPUrl: "rtsp://192.0.2.1/front", OwnerID: 1}
+	database.DB.Create(&cam)
+	start := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	event := models.Event{CameraID: cam.ID, StartTime: start, EndTime: start.Add(90 * time.Second), Reason: "webhook"}
+	database.DB.Create(&event)
+	conf := 0.8
+	database.DB.Create(&models.EventClass{EventID: event.ID, Class: "person", Confidence: &conf})
+
+	videoPath := filepath.Join(EventRoot, "event_1_20240102-120000.mp4")
+	writeSegment(t, videoPath)
+	writeSidecar(event, videoPath, []json.RawMessage{json.RawMessage(`{"x":1}`)})
+
+	data, err := os.ReadFile(SidecarPath(videoPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got EventSidecar
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.EventID != event.ID || got.CameraName != "front" || got.DurationSeconds != 90 || len(got.Boxes) != 1 {
+		t.Errorf("sidecar = %+v", got)
+	}
+	if len(got.DetectedClasses) != 1 || got.DetectedClasses[0].Class != "person" || *got.DetectedClasses[0].Confidence != conf {
+		t.Errorf("classes = %+v", got.DetectedClasses)
+	}
+	if !reflect.DeepEqual(got.Files, []string{"event_1_20240102-120000.mp4"}) {
+		t.Errorf("files = %v", got.Files)
+	}
+	if _, err := os.Stat(SidecarPath(videoPath) + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temp file left behind: %v", err)
+	}
+}
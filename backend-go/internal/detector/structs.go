@@ -1,12 +1,17 @@
 package detector
 
 import (
-	"os"
+	"context"
+	"encoding/json"
 	"os/exec"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// LogDir is where ffmpeg stderr logs for each camera are written
+const LogDir = "/var/log/nvr"
+
 // ActiveRecording tracks an ongoing event recording
 type ActiveRecording struct {
 	Process   *exec.Cmd
@@ -14,13 +19,48 @@ type ActiveRecording struct {
 	VideoPath string
 	ThumbPath string
 	StartTime time.Time
-	LogFile   *os.File
+	LogFile   *RotatingLog
+
+	// Detector sources currently holding the recording open
+	Sources map[string]bool
+
+	// Set when the MaxEventMinutes cap closed the recording instead of a motion-end
+	TimedOut bool
+
+	// Set once a finisher has claimed the recording: new events open a fresh
+	// recording instead of joining it, and no other path finalizes it
+	Closing bool
+
+	// Detector bounding boxes for the sidecar, as received (see AddEventBoxes)
+	Boxes []json.RawMessage
+}
+
+// RecordingStats counts recording outcomes for a camera since the server started
+type RecordingStats struct {
+	Started   uint64 `json:"started"`
+	Finalized uint64 `json:"finalized"`
+	Discarded uint64 `json:"discarded"`
+	Failed    uint64 `json:"failed"`
+}
+
+// Behaviors when MaxConcurrentEventRecordings is reached
+const (
+	CapacityReject = "reject" // log a dropped_capacity event and record nothing
+	CapacityQueue  = "queue"  // start recording once a slot frees up
+)
+
+// QueuedEvent is an event waiting for a free recording slot
+type QueuedEvent struct {
+	Sources map[string]bool
+	Reason  string
+	Classes []string
 }
 
 // ContinuousProcess tracks a 24/7 ffmpeg loop
 type ContinuousProcess struct {
-	Process *exec.Cmd
-	LogFile *os.File
+	Process   *exec.Cmd
+	LogFile   *RotatingLog
+	StartedAt time.Time
 }
 
 // Manager holds the state of all surveillance processes
@@ -34,20 +74,77 @@ type Manager struct {
 	// Map of CameraID -> Active Event Recording
 	ActiveRecordings map[uint]*ActiveRecording
 
+	// Events waiting for a recording slot, and their arrival order
+	queuedEvents map[uint]*QueuedEvent
+	eventQueue   []uint
+
 	// Map of CameraID -> Motion Detection Process
 	MotionProcs map[uint]*exec.Cmd
 
 	// --- FIX: Cache to prevent API spam ---
 	// Map of CameraID -> RTSP URL (Last successfully registered URL)
 	RegisteredPaths map[uint]string
+
+	// Cameras whose host refused or timed out the last TCP pre-check
+	unreachable map[uint]bool
+
+	// Map of CameraID -> when its unreachable stream is next dialled
+	reachRetry map[uint]reachRetry
+
+	// Map of CameraID -> last AutoHealIP rediscovery attempt
+	lastHeal map[uint]time.Time
+
+	// Map of CameraID -> RTSP URL whose stream parameters were last probed
+	ProbedURLs map[uint]string
+
+	// Map of CameraID -> recording outcome counters since startup
+	stats map[uint]*RecordingStats
+
+	// Map of CameraID -> most recent still, refreshed by latestFrameLoop
+	frames   map[uint]Frame
+	framesMu sync.Mutex
+
+	// Set once the low-storage warning has fired, cleared when space recovers
+	storageWarned bool
+
+	// RetentionStartupGrace counts from startedAt; the first retention pass after
+	// it only logs, and sets retentionPreviewed
+	startedAt          time.Time
+	retentionPreviewed bool
+
+	// Cameras whose continuous recording was refused for lack of disk space,
+	// so the refusal is logged once rather than on every sync
+	noSpace map[uint]bool
+
+	// Set once MediaMTX answered (or the startup wait gave up); SyncCameras is a no-op until then
+	synced atomic.Bool
+
+	// Background goroutines watch ctx and are tracked by wg so Stop can wait on them
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	loopMu   sync.Mutex
+	stopping bool
 }
 
 // NewManager initializes the manager
 func NewManager() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Manager{
+		ctx:              ctx,
+		cancel:           cancel,
+		startedAt:        time.Now(),
 		ContinuousProcs:  make(map[uint]*ContinuousProcess),
 		ActiveRecordings: make(map[uint]*ActiveRecording),
 		MotionProcs:      make(map[uint]*exec.Cmd),
+		queuedEvents:     make(map[uint]*QueuedEvent),
 		RegisteredPaths:  make(map[uint]string), // Initialize the map
+		ProbedURLs:       make(map[uint]string),
+		unreachable:      make(map[uint]bool),
+		reachRetry:       make(map[uint]reachRetry),
+		lastHeal:         make(map[uint]time.Time),
+		stats:            make(map[uint]*RecordingStats),
+		frames:           make(map[uint]Frame),
+		noSpace:          make(map[uint]bool),
 	}
 }
\ No newline at end of file
@@ -0,0 +1,24 @@
+package detector
+
+import "nvr-server/internal/models"
+
+// SubstreamWarnings lists the enabled features of cam that are meant to run on
+// its substream but fall back to the main stream because none is set. Nothing is
+// blocked; the main stream works, it just costs the bandwidth and decode the
+// substream was supposed to save.
+func SubstreamWarnings(cam models.Camera) []string {
+	if cam.RTSPSubstreamUrl != "" {
+		return nil
+	}
+	var warnings []string
+	switch cam.MotionType {
+	case "active":
+		warnings = append(warnings, "Motion detection decodes the substream; without one it analyses the main stream")
+	case "webhook":
+		warnings = append(warnings, "AI detection reads the substream; without one it analyses the main stream")
+	}
+	if cam.ProvisionalThumbnail {
+		warnings = append(warnings, "Provisional thumbnails are grabbed from the substream; without one they use the main stream")
+	}
+	return warnings
+}
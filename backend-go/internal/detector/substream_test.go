@@ -0,0 +1,24 @@
+package detector
+
+import (
+	"testing"
+
+	"nvr-server/internal/models"
+)
+
+func TestSubstreamWarnings(t *testing.T) {
+	cases := []struct {
+		cam  models.Camera
+		want int
+	}{
+		{models.Camera{MotionType: "active"}, 1},
+		{models.Camera{MotionType: "webhook", ProvisionalThumbnail: true}, 2},
+		{models.Camera{MotionType: "off"}, 0},
+		{models.Camera{MotionType: "active", ProvisionalThumbnail: true, RTSPSubstreamUrl: "rtsp://192.0.2.1/sub"}, 0},
+	}
+	for _, tc := range cases {
+		if got := SubstreamWarnings(tc.cam); len(got) != tc.want {
+			t.Errorf("%+v: %d warnings %v, want %d", tc.cam, len(got), got, tc.want)
+		}
+	}
+}
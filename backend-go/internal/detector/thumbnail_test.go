@@ -0,0 +1,120 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+func TestThumbnailSeek(t *testing.T) {
+	cases := map[float64]string{0: "1", -1: "1", 20: "10.000", 7.5: "3.750"}
+	for duration, want := range cases {
+		if got := thumbnailSeek(duration); got != want {
+			t.Errorf("thumbnailSeek(%v) = %q, want %q", duration, got, want)
+		}
+	}
+	if got := provisionalThumbPath("/recordings/event_1.mp4"); got != "/recordings/event_1_live.jpg" {
+		t.Errorf("provisionalThumbPath = %q", got)
+	}
+}
+
+// useArgsFFmpeg stands in for ffmpeg by logging each invocation's arguments (one
+// line per run) to the returned file and creating the output
+func useArgsFFmpeg(t *testing.T) string {
+	t.Helper()
+	argsFile := filepath.Join(t.TempDir(), "args")
+	prev := FFmpegBin
+	FFmpegBin = stubTool(t, `echo "$@" >> `+argsFile+`
+for out; do :; done
+: > "$out"`)
+	t.Cleanup(func() { FFmpegBin = prev })
+	return argsFile
+}
+
+func TestProvisionalThumbnailReplaced(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	argsFile := useArgsFFmpeg(t)
+	useFFprobe(t, "echo 20.0")
+
+	cam := models.Camera{Name: "yard", RTSPUrl: "rtsp://192.0.2.1/main", RTSPSubstreamUrl: "rtsp://192.0.2.1/sub"}
+	event := models.Event{CameraID: 1, StartTime: time.Now()}
+	database.DB.Create(&event)
+	videoPath := filepath.Join(EventRoot, "event_1_20240102-120000.mp4")
+	live := provisionalThumbPath(videoPath)
+
+	m := NewManager()
+	m.grabProvisionalThumbnail(cam, event.ID, videoPath)
+	database.DB.First(&event, event.ID)
+	if event.ThumbnailPath != LogicalPath(live) {
+		t.Fatalf("thumbnail while recording = %q, want %q", event.ThumbnailPath, LogicalPath(live))
+	}
+	data, _ := os.ReadFile(argsFile)
+	if grab := string(data); !strings.Contains(grab, "rtsp://192.0.2.1/sub") || strings.Contains(grab, "/main") {
+		t.Errorf("live frame not taken from the substream: %s", grab)
+	}
+
+	m.generateThumbnail(videoPath, event.ID)
+	database.DB.First(&event, event.ID)
+	final := strings.TrimSuffix(videoPath, ".mp4") + ".jpg"
+	if event.ThumbnailPath != LogicalPath(final) {
+		t.Errorf("final thumbnail = %q, want %q", event.ThumbnailPath, LogicalPath(final))
+	}
+	if _, err := os.Stat(live); !os.IsNotExist(err) {
+		t.Error("provisional thumbnail left behind")
+	}
+	data, _ = os.ReadFile(argsFile)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if v, _ := flagValue(strings.Fields(lines[len(lines)-1]), "-ss"); v != "10.000" {
+		t.Errorf("final thumbnail -ss = %q, want the midpoint 10.000", v)
+	}
+
+	// A live frame arriving after the final thumbnail does not replace it
+	m.grabProvisionalThumbnail(cam, event.ID, videoPath)
+	database.DB.First(&event, event.ID)
+	if event.ThumbnailPath != LogicalPath(final) {
+		t.Errorf("late live frame replaced the thumbnail with %q", event.ThumbnailPath)
+	}
+	if _, err := os.Stat(live); !os.IsNotExist(err) {
+		t.Error("late live frame was kept on disk")
+	}
+}
+
+func TestEventSnapshotFromMainStream(t *testing.T) {
+	testDB(t)
+	testRoots(t)
+	argsFile := useArgsFFmpeg(t)
+
+	cam := models.Camera{Name: "yard", RTSPUrl: "rtsp://192.0.2.1/main", RTSPSubstreamUrl: "rtsp://192.0.2.1/sub"}
+	event := models.Event{CameraID: 1, StartTime: time.Now()}
+	database.DB.Create(&event)
+	videoPath := filepath.Join(EventRoot, "event_1_20240102-120000.mp4")
+	snap := snapshotPath(videoPath)
+	if snap != filepath.Join(EventRoot, "event_1_20240102-120000_snapshot.jpg") {
+		t.Fatalf("snapshotPath = %q", snap)
+	}
+
+	m := NewManager()
+	m.grabEventSnapshot(cam, event.ID, videoPath)
+	database.DB.First(&event, event.ID)
+	if event.SnapshotPath != LogicalPath(snap) {
+		t.Errorf("snapshot_path = %q, want %q", event.SnapshotPath, LogicalPath(snap))
+	}
+	data, _ := os.ReadFile(argsFile)
+	if input, _ := flagValue(strings.Fields(string(data)), "-i"); input != cam.RTSPUrl {
+		t.Errorf("snapshot taken from %q, want the main stream", input)
+	}
+
+	// The event was discarded while ffmpeg ran: no orphaned still
+	database.DB.Delete(&event)
+	os.Remove(snap)
+	m.grabEventSnapshot(cam, event.ID, videoPath)
+	if _, err := os.Stat(snap); !os.IsNotExist(err) {
+		t.Error("snapshot kept for a deleted event")
+	}
+}
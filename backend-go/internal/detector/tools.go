@@ -0,0 +1,121 @@
+package detector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ToolError is a failed one-shot ffmpeg/ffprobe run
+type ToolError struct {
+	Tool     string
+	ExitCode int // -1 when the process didn't exit normally (not found, killed)
+	TimedOut bool
+	Timeout  time.Duration
+	Stderr   string
+	Err      error
+}
+
+func (e *ToolError) Error() string {
+	if e.TimedOut {
+		return fmt.Sprintf("%s: timed out after %s", e.Tool, e.Timeout)
+	}
+	if e.Stderr != "" {
+		return fmt.Sprintf("%s: %v: %s", e.Tool, e.Err, lastLine([]byte(e.Stderr)))
+	}
+	return fmt.Sprintf("%s: %v", e.Tool, e.Err)
+}
+
+func (e *ToolError) Unwrap() error { return e.Err }
+
+// ToolStats counts one-shot runs of a tool for the health endpoint
+type ToolStats struct {
+	Runs          int64      `json:"runs"`
+	Failures      int64      `json:"failures"`
+	Timeouts      int64      `json:"timeouts"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+}
+
+var (
+	toolStatsMu sync.Mutex
+	toolStats   = make(map[string]*ToolStats)
+)
+
+// MediaToolStats returns a copy of the per-tool run counters
+func MediaToolStats() map[string]ToolStats {
+	toolStatsMu.Lock()
+	defer toolStatsMu.Unlock()
+	out := make(map[string]ToolStats, len(toolStats))
+	for tool, s := range toolStats {
+		out[tool] = *s
+	}
+	return out
+}
+
+// runTool runs bin to completion under ctx, additionally bounded by timeout
+// (0 = ctx only), and returns its stdout. Stderr is captured for the error;
+// every failure is a *ToolError and is counted in MediaToolStats.
+func runTool(ctx context.Context, timeout time.Duration, bin string, args ...string) ([]byte, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if bin == FFmpegBin {
+		args = ffmpegArgs(args)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = nil, &stdout, &stderr
+	runErr := cmd.Run()
+
+	var err *ToolError
+	if runErr != nil {
+		err = &ToolError{
+			Tool:     filepath.Base(bin),
+			ExitCode: -1,
+			TimedOut: errors.Is(ctx.Err(), context.DeadlineExceeded),
+			Timeout:  timeout,
+			Stderr:   stderr.String(),
+			Err:      runErr,
+		}
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			err.ExitCode = exitErr.ExitCode()
+		}
+	}
+	recordToolRun(filepath.Base(bin), err)
+
+	if err != nil {
+		return stdout.Bytes(), err
+	}
+	return stdout.Bytes(), nil
+}
+
+func recordToolRun(tool string, err *ToolError) {
+	toolStatsMu.Lock()
+	defer toolStatsMu.Unlock()
+	s, ok := toolStats[tool]
+	if !ok {
+		s = &ToolStats{}
+		toolStats[tool] = s
+	}
+	s.Runs++
+	if err == nil {
+		return
+	}
+	now := time.Now()
+	s.Failures++
+	if err.TimedOut {
+		s.Timeouts++
+	}
+	s.LastError, s.LastFailureAt = err.Error(), &now
+}
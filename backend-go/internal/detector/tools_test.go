@@ -0,0 +1,64 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunTool(t *testing.T) {
+	bin := stubTool(t, `case "$1" in
+ok) echo out; echo noise >&2 ;;
+fail) echo "first line" >&2; echo "Invalid data found" >&2; exit 3 ;;
+hang) exec sleep 5 ;;
+esac`)
+	before := MediaToolStats()["tool"]
+
+	out, err := runTool(context.Background(), time.Second, bin, "ok")
+	if err != nil || string(out) != "out\n" {
+		t.Errorf("success = %q, %v", out, err)
+	}
+
+	_, err = runTool(context.Background(), time.Second, bin, "fail")
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) || toolErr.ExitCode != 3 || toolErr.TimedOut {
+		t.Fatalf("non-zero exit = %#v", err)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "tool: ") || !strings.HasSuffix(msg, "Invalid data found") {
+		t.Errorf("error message %q should end with the last stderr line", msg)
+	}
+
+	started := time.Now()
+	_, err = runTool(context.Background(), 100*time.Millisecond, bin, "hang")
+	if !errors.As(err, &toolErr) || !toolErr.TimedOut || toolErr.ExitCode != -1 {
+		t.Fatalf("timeout = %#v", err)
+	}
+	if took := time.Since(started); took > 3*time.Second {
+		t.Errorf("timed-out run took %s", took)
+	}
+	if msg := err.Error(); msg != "tool: timed out after 100ms" {
+		t.Errorf("timeout message = %q", msg)
+	}
+
+	// A cancelled context is a failure but not a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := runTool(ctx, time.Second, bin, "ok"); !errors.As(err, &toolErr) || toolErr.TimedOut {
+		t.Errorf("cancelled = %#v", err)
+	}
+
+	_, err = runTool(context.Background(), 0, "/nonexistent/ffprobe")
+	if !errors.As(err, &toolErr) || toolErr.ExitCode != -1 || toolErr.Tool != "ffprobe" {
+		t.Errorf("missing binary = %#v", err)
+	}
+
+	after := MediaToolStats()["tool"]
+	if after.Runs-before.Runs != 4 || after.Failures-before.Failures != 3 || after.Timeouts-before.Timeouts != 1 {
+		t.Errorf("stats went from %+v to %+v", before, after)
+	}
+	if after.LastFailureAt == nil || after.LastError == "" {
+		t.Errorf("last failure not recorded: %+v", after)
+	}
+}
@@ -0,0 +1,149 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// quarantineDirName is the directory, at the top of each recording root, that
+// holds recordings that failed verification
+const quarantineDirName = ".corrupt"
+
+// QuarantineDir is where a recording that failed verification is moved. Each
+// root has its own, so the move is a rename on the same filesystem even when
+// NVR_CONTINUOUS_ROOT is a separate disk.
+func QuarantineDir(path string) string {
+	if _, ok := relativeTo(ContinuousRoot, path); ok {
+		return filepath.Join(ContinuousRoot, quarantineDirName)
+	}
+	return filepath.Join(EventRoot, quarantineDirName)
+}
+
+// Files touched more recently than this may still be open in ffmpeg
+const verifySettleTime = 30 * time.Second
+
+// At most this many bad files are listed individually in a report
+const maxReportedBadFiles = 500
+
+type BadRecording struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type VerifyReport struct {
+	Scanned     int            `json:"scanned"`
+	Bad         int            `json:"bad"`
+	Quarantined int            `json:"quarantined"`
+	Skipped     int            `json:"skipped"`
+	BadFiles    []BadRecording `json:"bad_files"`
+	Cancelled   bool           `json:"cancelled"`
+}
+
+// VerifyRecordings runs ffprobe over every event clip and continuous segment,
+// optionally moving unplayable ones into their root's QuarantineDir. Files still being written
+// are skipped.
+func (m *Manager) VerifyRecordings(ctx context.Context, quarantine bool) VerifyReport {
+	report := VerifyReport{BadFiles: make([]BadRecording, 0)}
+
+	m.mu.Lock()
+	active := make(map[string]bool, len(m.ActiveRecordings))
+	for _, rec := range m.ActiveRecordings {
+		for _, part := range EventParts(rec.VideoPath) {
+			active[part] = true
+		}
+	}
+	m.mu.Unlock()
+
+	for _, root := range RecordingRoots() {
+		if report.Cancelled {
+			break
+		}
+		m.verifyTree(ctx, root, quarantine, active, &report)
+	}
+	return report
+}
+
+func (m *Manager) verifyTree(ctx context.Context, root string, quarantine bool, active map[string]bool, report *VerifyReport) {
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			report.Cancelled = true
+			return filepath.SkipAll
+		}
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path == QuarantineDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !IsSegmentFile(path) || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		if active[path] || time.Since(info.ModTime()) < verifySettleTime {
+			report.Skipped++
+			return nil
+		}
+
+		report.Scanned++
+		if _, err := ProbeClip(ctx, path); err != nil {
+			if ctx.Err() != nil {
+				report.Cancelled = true
+				return filepath.SkipAll
+			}
+			report.Bad++
+			if len(report.BadFiles) < maxReportedBadFiles {
+				report.BadFiles = append(report.BadFiles, BadRecording{Path: LogicalPath(path), Error: err.Error()})
+			}
+			if quarantine && quarantineFile(path) == nil {
+				report.Quarantined++
+			}
+		}
+		return nil
+	})
+}
+
+// quarantineFile moves a recording under its QuarantineDir, keeping its relative
+// path. A rename that crosses filesystems (a disk mounted inside a root) falls
+// back to copying and removing.
+func quarantineFile(path string) error {
+	dest := filepath.Join(QuarantineDir(path), LogicalPath(path))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	err := os.Rename(path, dest)
+	if errors.Is(err, syscall.EXDEV) {
+		err = moveAcrossDevices(path, dest)
+	}
+	return err
+}
+
+func moveAcrossDevices(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dest)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,104 @@
+package detector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useVerifyProbe makes ffprobe fail for any file whose name contains "bad"
+func useVerifyProbe(t *testing.T) {
+	t.Helper()
+	useFFprobe(t, `for f; do :; done
+case "$f" in
+*bad*) echo "moov atom not found" >&2; exit 1 ;;
+esac
+echo 10`)
+}
+
+func TestVerifyRecordings(t *testing.T) {
+	testRoots(t)
+	useVerifyProbe(t)
+
+	good := agedFile(t, filepath.Join(EventRoot, "event_1_20240101-080000.mp4"), time.Hour)
+	badEvent := agedFile(t, filepath.Join(EventRoot, "event_1_20240101-090000_bad.mp4"), time.Hour)
+	badSegment := agedFile(t, filepath.Join(ContinuousDir(2), "2024", "01", "01", "20240101-080000_bad.mkv"), time.Hour)
+	// Still being written: too fresh, or the clip of a running event
+	fresh := filepath.Join(EventRoot, "event_1_20240101-100000_bad.mp4")
+	writeSegment(t, fresh)
+	recording := agedFile(t, filepath.Join(EventRoot, "event_3_20240101-110000_bad.mp4"), time.Hour)
+	agedFile(t, filepath.Join(EventRoot, "event_1_bad.jpg"), time.Hour)
+
+	m := NewManager()
+	m.ActiveRecordings[3] = &ActiveRecording{VideoPath: recording}
+
+	report := m.VerifyRecordings(context.Background(), false)
+	if report.Scanned != 3 || report.Bad != 2 || report.Skipped != 2 || report.Quarantined != 0 || report.Cancelled {
+		t.Fatalf("report = %+v", report)
+	}
+	for _, path := range []string{good, badEvent, badSegment} {
+		if !exists(path) {
+			t.Errorf("%s moved without quarantine=true", path)
+		}
+	}
+
+	report = m.VerifyRecordings(context.Background(), true)
+	if report.Bad != 2 || report.Quarantined != 2 {
+		t.Fatalf("quarantine report = %+v", report)
+	}
+	// Each bad file lands in its own root's quarantine, under its logical path
+	for _, path := range []string{badEvent, badSegment} {
+		dest := filepath.Join(QuarantineDir(path), LogicalPath(path))
+		if exists(path) || !exists(dest) {
+			t.Errorf("%s not quarantined to %s", path, dest)
+		}
+	}
+	if QuarantineDir(badSegment) != filepath.Join(ContinuousRoot, ".corrupt") || QuarantineDir(badEvent) != filepath.Join(EventRoot, ".corrupt") {
+		t.Errorf("quarantine dirs: %s, %s", QuarantineDir(badSegment), QuarantineDir(badEvent))
+	}
+	if !exists(good) || !exists(recording) || !exists(fresh) {
+		t.Error("a playable or still-recording file was quarantined")
+	}
+
+	// Quarantined files aren't scanned again
+	if report = m.VerifyRecordings(context.Background(), true); report.Bad != 0 || report.Scanned != 1 {
+		t.Errorf("after quarantine: %+v", report)
+	}
+}
+
+func TestVerifyRecordingsCancelled(t *testing.T) {
+	testRoots(t)
+	useVerifyProbe(t)
+	agedFile(t, filepath.Join(EventRoot, "event_1_20240101-080000.mp4"), time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if report := NewManager().VerifyRecordings(ctx, true); !report.Cancelled || report.Scanned != 0 {
+		t.Errorf("cancelled report = %+v", report)
+	}
+}
+
+func TestMoveAcrossDevices(t *testing.T) {
+	dir := t.TempDir()
+	src, dest := filepath.Join(dir, "clip.mp4"), filepath.Join(dir, "quarantine", "clip.mp4")
+	os.WriteFile(src, []byte("clip data"), 0644)
+	os.MkdirAll(filepath.Dir(dest), 0755)
+
+	if err := moveAcrossDevices(src, dest); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "clip data" || exists(src) {
+		t.Errorf("after move: dest %q, source kept %v", data, exists(src))
+	}
+
+	// A failed copy keeps the source and leaves no partial destination
+	if err := moveAcrossDevices(filepath.Join(dir, "missing.mp4"), dest+".2"); err == nil || exists(dest+".2") {
+		t.Errorf("missing source: err %v, dest created %v", err, exists(dest+".2"))
+	}
+	os.WriteFile(src, []byte("again"), 0644)
+	if err := moveAcrossDevices(src, filepath.Join(dir, "nowhere", "clip.mp4")); err == nil || !exists(src) {
+		t.Errorf("unwritable destination: err %v, source kept %v", err, exists(src))
+	}
+}
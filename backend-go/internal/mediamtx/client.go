@@ -0,0 +1,196 @@
+package mediamtx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// --- CONFIGURATION ---
+const (
+	APIUser  = "admin"
+	APIPass  = "mysecretpassword"
+	pageSize = 100
+)
+
+// APIBase is a variable so tests can point the client at a stub server
+var APIBase = "http://mediamtx:9997"
+
+var httpClient = &http.Client{Timeout: 2 * time.Second}
+
+// PathConfig is the subset of a MediaMTX path config we read back
+type PathConfig struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+type pathConfigList struct {
+	PageCount int          `json:"pageCount"`
+	Items     []PathConfig `json:"items"`
+}
+
+func newRequest(ctx context.Context, method, endpoint string, body interface{}) (*http.Request, error) {
+	var reader *bytes.Buffer
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewBuffer(jsonData)
+	} else {
+		reader = &bytes.Buffer{}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, APIBase+endpoint, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(APIUser, APIPass)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+func do(method, endpoint string, body interface{}) (*http.Response, error) {
+	return doContext(context.Background(), method, endpoint, body)
+}
+
+func doContext(ctx context.Context, method, endpoint string, body interface{}) (*http.Response, error) {
+	req, err := newRequest(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	return httpClient.Do(req)
+}
+
+// AddPath creates a new path config; the returned status lets callers treat 4xx as "rejected"
+func AddPath(name string, conf map[string]interface{}) (int, error) {
+	return AddPathContext(context.Background(), name, conf)
+}
+
+// AddPathContext is AddPath bound to a caller's context (e.g. an HTTP request)
+func AddPathContext(ctx context.Context, name string, conf map[string]interface{}) (int, error) {
+	resp, err := doContext(ctx, "POST", "/v3/config/paths/add/"+name, conf)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// DeletePath removes a path config
+func DeletePath(name string) error {
+	resp, err := do("DELETE", "/v3/config/paths/delete/"+name, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("mediamtx: delete %s returned %d", name, resp.StatusCode)
+	}
+	return nil
+}
+
+// ListPathConfigs returns every configured path, following pagination
+func ListPathConfigs() ([]PathConfig, error) {
+	paths := make([]PathConfig, 0)
+	for page := 0; ; page++ {
+		resp, err := do("GET", fmt.Sprintf("/v3/config/paths/list?page=%d&itemsPerPage=%d", page, pageSize), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var list pathConfigList
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("mediamtx: list paths returned %d", resp.StatusCode)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, list.Items...)
+		if page+1 >= list.PageCount {
+			return paths, nil
+		}
+	}
+}
+
+// PathStats is the runtime state of an active path
+type PathStats struct {
+	Name          string        `json:"name"`
+	Ready         bool          `json:"ready"`
+	BytesReceived uint64        `json:"bytesReceived"`
+	BytesSent     uint64        `json:"bytesSent"`
+	Readers       []interface{} `json:"readers"`
+}
+
+type pathStatsList struct {
+	PageCount int         `json:"pageCount"`
+	Items     []PathStats `json:"items"`
+}
+
+// ListPaths returns runtime stats for every active path, following pagination
+func ListPaths() ([]PathStats, error) {
+	paths := make([]PathStats, 0)
+	for page := 0; ; page++ {
+		resp, err := do("GET", fmt.Sprintf("/v3/paths/list?page=%d&itemsPerPage=%d", page, pageSize), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var list pathStatsList
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("mediamtx: list paths returned %d", resp.StatusCode)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, list.Items...)
+		if page+1 >= list.PageCount {
+			return paths, nil
+		}
+	}
+}
+
+// GetPath returns runtime stats for one path; a path that exists in config but
+// has no source yet comes back as not found (404)
+func GetPath(ctx context.Context, name string) (PathStats, error) {
+	var stats PathStats
+	resp, err := doContext(ctx, "GET", "/v3/paths/get/"+name, nil)
+	if err != nil {
+		return stats, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return stats, fmt.Errorf("mediamtx: get path %s returned %d", name, resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	return stats, err
+}
+
+// Ping checks that the API is up and accepts our credentials
+func Ping() error {
+	return PingContext(context.Background())
+}
+
+// PingContext is Ping bound to a caller's context
+func PingContext(ctx context.Context) error {
+	resp, err := doContext(ctx, "GET", "/v3/paths/list?itemsPerPage=1", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("mediamtx: api returned %d", resp.StatusCode)
+	}
+	return nil
+}
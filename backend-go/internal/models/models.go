@@ -11,6 +11,10 @@ type User struct {
 	DisplayName     string    `json:"display_name"`
 	GravatarHash    string    `json:"gravatar_hash"`
 	TokensValidFrom time.Time `json:"tokens_valid_from"`
+	IsAdmin         bool      `json:"is_admin"`
+
+	// Cap on the user's total stored footage (0 = unlimited); set by an admin
+	MaxStorageMB int `json:"max_storage_mb"`
 }
 
 type Camera struct {
@@ -25,14 +29,94 @@ type Camera struct {
 	MotionROI           string `json:"motion_roi"`
 	MotionSensitivity   int    `json:"motion_sensitivity"`
 	ContinuousRecording bool   `json:"continuous_recording"`
+
+	// Only meaningful for rtsps:// sources with self-signed certificates
+	RTSPSkipCertVerify bool `json:"rtsp_skip_cert_verify"`
+
+	// Let ffmpeg reconnect on its own after a dropped connection (HTTP(S) sources only)
+	AutoReconnect bool `json:"auto_reconnect"`
+
+	// Rediscover the camera after a DHCP change, by hostname or by the MAC captured at add time
+	AutoHealIP     bool   `json:"auto_heal_ip"`
+	DeviceHostname string `json:"device_hostname"`
+	DeviceMAC      string `json:"device_mac"`
+
+	// Re-encode to H.264/AAC instead of stream copy (CPU heavy)
+	TranscodeH264 bool `json:"transcode_h264"`
+
+	// Grid cells (same indices as MotionROI) blacked out in recordings; forces a re-encode
+	PrivacyMask string `json:"privacy_mask"`
+
+	// Days to keep this camera's footage (0 = the system-wide RetentionDays)
+	RetentionDays int `json:"retention_days"`
+
+	// Continuous segment container: "mp4" (default), "fmp4" or "mkv"
+	SegmentFormat string `json:"segment_format"`
+
+	// Grab a live frame (substream if set) as the event thumbnail until the clip is done
+	ProvisionalThumbnail bool `json:"provisional_thumbnail"`
+
+	// Save a full-resolution still from the main stream when an event starts
+	SnapshotOnEvent bool `json:"snapshot_on_event"`
+
+	// Last motion webhook (start or end) received for this camera; a stale value
+	// usually means the AI worker is down
+	LastWebhookAt *time.Time `json:"last_webhook_at"`
+
+	// SHA-256 of the token motion webhooks for this camera must present
+	WebhookTokenHash string `json:"-"`
 	
 	// --- REQUIRED FOR SELECTION ---
 	AIClasses string `json:"ai_classes"` 
+
+	// Cached from ffprobe whenever RTSPUrl changes
+	StreamWidth  int     `json:"stream_width"`
+	StreamHeight int     `json:"stream_height"`
+	StreamFPS    float64 `json:"stream_fps"`
+	StreamCodec  string  `json:"stream_codec"`
+
+	// Last ONVIF capabilities probe (onvif.Capabilities as JSON), "" if never probed
+	OnvifCapabilities string `json:"onvif_capabilities"`
+
+	// Substream features falling back to the main stream; computed per response
+	Warnings []string `gorm:"-" json:"warnings,omitempty"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Bumped on every user edit; updates must name the version they were based on
+	Version int `gorm:"default:1" json:"version"`
 	
 	// --- REQUIRED FOR CRASH FIX ---
 	Events []Event `gorm:"foreignKey:CameraID;constraint:OnDelete:CASCADE;" json:"-"`
 }
 
+// Event reasons
+const (
+	ReasonMotion          = "motion"
+	ReasonManual          = "manual"
+	ReasonPerson          = "person"
+	ReasonVehicle         = "vehicle"
+	ReasonAnimal          = "animal"
+	ReasonTimeout         = "timeout"
+	ReasonDroppedCapacity = "dropped_capacity"
+	ReasonDroppedNoSpace  = "dropped_nospace"
+)
+
+// EventReasons is the full reason taxonomy, in display order
+var EventReasons = []string{
+	ReasonMotion, ReasonManual, ReasonPerson, ReasonVehicle, ReasonAnimal, ReasonTimeout, ReasonDroppedCapacity, ReasonDroppedNoSpace,
+}
+
+// ValidEventReason reports whether r is part of the taxonomy
+func ValidEventReason(r string) bool {
+	for _, known := range EventReasons {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
+
 type Event struct {
 	ID            uint      `gorm:"primaryKey" json:"id"`
 	CameraID      uint      `json:"camera_id"`
@@ -42,22 +126,154 @@ type Event struct {
 	Reason        string    `json:"reason"`
 	VideoPath     string    `json:"video_path"`
 	ThumbnailPath string    `json:"thumbnail_path"`
+	SnapshotPath  string    `json:"snapshot_path"`
+	PreviewPath   string    `json:"preview_path"`
+
+	// JSON array of class labels reported by the AI detector, e.g. ["person"].
+	// Mirrored row-per-class in EventClass, which is what the class filters query.
+	DetectedClasses string       `json:"detected_classes"`
+	Classes         []EventClass `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+
+	// JSON array of part file paths when the event was split (VideoPath is the first part)
+	Parts string `json:"parts"`
+
+	// Finalized by the MaxEventMinutes cap because motion-end never arrived
+	AutoTerminated bool `json:"auto_terminated"`
+
+	UpdatedAt time.Time `json:"updated_at"`
 
 	// --- REQUIRED FOR CRASH FIX ---
 	Camera Camera `gorm:"foreignKey:CameraID" json:"camera"`
 }
 
+// EventClass is one class detected during an event, with the highest confidence
+// the detector reported for it (nil when it sent none)
+type EventClass struct {
+	ID         uint     `gorm:"primaryKey" json:"-"`
+	EventID    uint     `gorm:"uniqueIndex:idx_event_class" json:"event_id"`
+	Class      string   `gorm:"uniqueIndex:idx_event_class;index" json:"class"`
+	Confidence *float64 `json:"confidence"`
+}
+
 type UserSession struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	JTI        string    `gorm:"uniqueIndex" json:"jti"`
+	UserID     uint      `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type ApiToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index" json:"user_id"`
+	Name       string     `json:"name"`
+	TokenHash  string     `gorm:"uniqueIndex" json:"-"`
+	Scope      string     `json:"scope"` // "read" or "full"
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// CameraChangeLog records one field of a camera changed through updateCamera.
+// Values are stored with credentials redacted.
+type CameraChangeLog struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	JTI       string    `gorm:"uniqueIndex" json:"jti"`
+	CameraID  uint      `gorm:"index" json:"camera_id"`
 	UserID    uint      `json:"user_id"`
-	UserAgent string    `json:"user_agent"`
-	IPAddress string    `json:"ip_address"`
+	Field     string    `json:"field"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
 	CreatedAt time.Time `json:"created_at"`
-	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IdempotencyKey remembers which camera a client-supplied Idempotency-Key created
+type IdempotencyKey struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"uniqueIndex:idx_idempotency_user_key"`
+	Key       string    `gorm:"size:255;uniqueIndex:idx_idempotency_user_key"`
+	CameraID  uint      // 0 while the create is still in progress
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+// BandwidthSample is a snapshot of MediaMTX's cumulative byte counters for a camera
+type BandwidthSample struct {
+	ID            uint      `gorm:"primaryKey" json:"-"`
+	CameraID      uint      `gorm:"index:idx_bw_camera_time" json:"camera_id"`
+	Time          time.Time `gorm:"index:idx_bw_camera_time" json:"time"`
+	BytesReceived uint64    `json:"bytes_received"`
+	BytesSent     uint64    `json:"bytes_sent"`
 }
 
 type SystemSettings struct {
 	ID            uint `gorm:"primaryKey" json:"id"`
 	RetentionDays int  `json:"retention_days"`
+
+	// JSON array of weekday rules overriding RetentionDays, e.g. [{"weekdays":["sat","sun"],"days":90}]
+	RetentionRules string `json:"retention_rules"`
+
+	// Once an admin exists, new sign-ups are refused when this is off. No gorm
+	// default: Create would skip a false value and let the default turn it back
+	// on, so new rows set it explicitly (see ensureDefaultSettings).
+	AllowRegistration bool `json:"allow_registration"`
+
+	// Cameras a non-admin user may own (0 = unlimited)
+	MaxCamerasPerUser int `gorm:"default:32" json:"max_cameras_per_user"`
+
+	// Refresh sessions a user may hold; the least recently used is evicted (0 = unlimited)
+	MaxSessionsPerUser int `gorm:"default:20" json:"max_sessions_per_user"`
+
+	// Event clips shorter than this (per ffprobe) are discarded (0 = size check only)
+	MinEventSeconds int `gorm:"default:3" json:"min_event_seconds"`
+
+	// Blocks writes for non-admins and stops new recordings from starting
+	MaintenanceMode bool `json:"maintenance_mode"`
+
+	// Notifications are POSTed here as JSON (empty = log only)
+	NotifyWebhookURL string `json:"notify_webhook_url"`
+
+	// External address of the UI/API, e.g. https://nvr.example.com; notification
+	// links and signed media URLs are absolute when set
+	PublicBaseURL string `json:"public_base_url"`
+
+	// Warn once when free space drops below this (0 = disabled)
+	StorageWarnThresholdGB int `gorm:"default:50" json:"storage_warn_threshold_gb"`
+
+	// Cap on simultaneous event recordings (0 = unlimited) and what to do at the cap:
+	// "reject" (default) or "queue"
+	MaxConcurrentEventRecordings int    `json:"max_concurrent_event_recordings"`
+	EventCapacityPolicy          string `gorm:"default:reject" json:"event_capacity_policy"`
+
+	// Render a short animated WebP per event (CPU heavy, off by default)
+	AnimatedPreviews bool `json:"animated_previews"`
+
+	// Fingerprint of the previous JWT secret and when this backend first saw it;
+	// the rotation grace period counts from then, not from each restart
+	PreviousJwtKeyID  string     `json:"-"`
+	PreviousJwtSeenAt *time.Time `json:"-"`
+
+	// Long events are split into parts of this many minutes (0 = one file per event)
+	EventPartMinutes int `json:"event_part_minutes"`
+
+	// Event recordings still open after this many minutes are closed and flagged (0 = no cap)
+	MaxEventMinutes int `gorm:"default:30" json:"max_event_minutes"`
+
+	// Each camera's newest N events survive retention regardless of age (0 = off)
+	MinEventsKept int `json:"min_events_kept"`
+
+	// New recordings are refused while their disk has less than this many GB free (0 = off)
+	MinFreeRecordingGB int `gorm:"default:2" json:"min_free_recording_gb"`
+
+	// ?grouped=true on the event list merges a camera's events separated by at most this many seconds
+	EventGroupGapSeconds int `gorm:"default:60" json:"event_group_gap_seconds"`
+
+	// JSON object of camera fields applied to new cameras that don't set them,
+	// e.g. {"continuous_recording":true,"segment_format":"mkv"}
+	CameraDefaults string `json:"camera_defaults"`
+
+	// Retention and quota deletion are skipped until this time (nil = running)
+	JanitorPausedUntil *time.Time `json:"janitor_paused_until"`
 }
\ No newline at end of file
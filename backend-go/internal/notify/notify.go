@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"nvr-server/internal/database"
+	"nvr-server/internal/models"
+)
+
+// Notification is the JSON payload delivered to the configured webhook
+type Notification struct {
+	Kind    string                 `json:"kind"`
+	Title   string                 `json:"title"`
+	Message string                 `json:"message"`
+	Time    time.Time              `json:"time"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+
+	// Link is a path in the app ("/?event=12"); URL is filled in from PublicBaseURL
+	Link string `json:"-"`
+	URL  string `json:"url,omitempty"`
+}
+
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+// Send delivers a notification to SystemSettings.NotifyWebhookURL. Without a
+// configured URL the notification is only logged.
+func Send(n Notification) error {
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	log.Printf("NOTIFY [%s] %s: %s\n", n.Kind, n.Title, n.Message)
+
+	var settings models.SystemSettings
+	if err := database.DB.First(&settings).Error; err != nil || settings.NotifyWebhookURL == "" {
+		return nil
+	}
+	if n.Link != "" && n.URL == "" && settings.PublicBaseURL != "" {
+		n.URL = strings.TrimSuffix(settings.PublicBaseURL, "/") + n.Link
+	}
+
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(settings.NotifyWebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Notification delivery failed: %v\n", err)
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("notify: webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,367 @@
+package onvif
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"nvr-server/internal/config"
+)
+
+// The handful of ONVIF calls needed to describe a camera, spoken as plain SOAP
+// 1.2 with a WS-Security UsernameToken. Cameras that insist on HTTP digest auth
+// instead are reported as rejecting the credentials.
+
+var (
+	// ErrNotONVIF means nothing at the address answered like an ONVIF device
+	ErrNotONVIF = errors.New("device does not answer ONVIF")
+
+	// ErrUnauthorized means the device is ONVIF but refused the credentials
+	ErrUnauthorized = errors.New("ONVIF credentials rejected")
+)
+
+const (
+	nsDevice = "http://www.onvif.org/ver10/device/wsdl"
+	nsMedia  = "http://www.onvif.org/ver10/media/wsdl"
+	nsSchema = "http://www.onvif.org/ver10/schema"
+
+	// Most cameras serve the device service here
+	devicePath = "/onvif/device_service"
+
+	// Largest SOAP response read; profile lists are a few tens of KB at most
+	maxResponseBytes = 1 << 20
+)
+
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+var (
+	// Ports an ONVIF probe may connect to; device services live on a handful of
+	// well-known ports, and anything else is more likely another service
+	AllowedPorts = config.String("NVR_ONVIF_PORTS", "80,443,2020,8000,8080,8899")
+
+	// Hosts never probed: the NVR's own services on the compose network
+	BlockedHosts = config.String("NVR_ONVIF_BLOCKED_HOSTS", "localhost,backend,db,mediamtx,frontend,ai-detector,motion-detector")
+)
+
+// ErrTargetNotAllowed means the address is not the camera's own ONVIF service
+var ErrTargetNotAllowed = errors.New("ONVIF address not allowed")
+
+// CheckTarget limits probes to the camera itself: the endpoint's host must be
+// one of cameraHosts, on an allowed port, and must not be one of the NVR's own
+// services or resolve to a loopback, link-local or unspecified address
+func CheckTarget(ctx context.Context, endpoint string, cameraHosts []string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ErrTargetNotAllowed
+	}
+	host := strings.ToLower(u.Hostname())
+
+	known := false
+	for _, h := range cameraHosts {
+		if h != "" && strings.EqualFold(h, host) {
+			known = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("%w: %s is not the camera's host", ErrTargetNotAllowed, host)
+	}
+	for _, blocked := range strings.Split(BlockedHosts, ",") {
+		if strings.EqualFold(strings.TrimSpace(blocked), host) {
+			return fmt.Errorf("%w: %s is an NVR service", ErrTargetNotAllowed, host)
+		}
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	if !portAllowed(port) {
+		return fmt.Errorf("%w: port %s (allowed: %s)", ErrTargetNotAllowed, port, AllowedPorts)
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: %s does not resolve", ErrNotONVIF, host)
+	}
+	for _, ip := range ips {
+		if ip.IP.IsLoopback() || ip.IP.IsUnspecified() || ip.IP.IsLinkLocalUnicast() || ip.IP.IsMulticast() {
+			return fmt.Errorf("%w: %s resolves to %s", ErrTargetNotAllowed, host, ip.IP)
+		}
+	}
+	return nil
+}
+
+func portAllowed(port string) bool {
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return false
+	}
+	for _, p := range strings.Split(AllowedPorts, ",") {
+		if allowed, err := strconv.Atoi(strings.TrimSpace(p)); err == nil && allowed == n {
+			return true
+		}
+	}
+	return false
+}
+
+// Profile is one media profile: a stream configuration the camera offers
+type Profile struct {
+	Token     string `json:"token"`
+	Name      string `json:"name"`
+	Encoding  string `json:"encoding,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	PTZ       bool   `json:"ptz"`
+	StreamURI string `json:"stream_uri,omitempty"`
+}
+
+// Capabilities is what a camera reports about itself over ONVIF
+type Capabilities struct {
+	Endpoint     string    `json:"endpoint"`
+	Manufacturer string    `json:"manufacturer,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	Firmware     string    `json:"firmware,omitempty"`
+	PTZ          bool      `json:"ptz"`
+	Profiles     []Profile `json:"profiles"`
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+// DeviceURL turns "host", "host:port" or a full URL into the device service URL
+func DeviceURL(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return "", errors.New("no ONVIF address")
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid ONVIF address %q", address)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("ONVIF address must be http(s), got %q", u.Scheme)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = devicePath
+	}
+	u.User = nil
+	return u.String(), nil
+}
+
+// Client talks to one device
+type Client struct {
+	endpoint string
+	username string
+	password string
+
+	// Camera clock minus ours; WS-Security timestamps must be in the camera's time
+	offset time.Duration
+}
+
+// Probe connects to the device service at endpoint and collects its identity,
+// media profiles, stream URIs and PTZ support
+func Probe(ctx context.Context, endpoint, username, password string) (Capabilities, error) {
+	c := &Client{endpoint: endpoint, username: username, password: password}
+	caps := Capabilities{Endpoint: endpoint, Profiles: make([]Profile, 0), CheckedAt: time.Now().UTC()}
+
+	// Unauthenticated and mandatory, so it doubles as the "is this ONVIF" check
+	if err := c.syncClock(ctx); err != nil {
+		return caps, err
+	}
+
+	var capsResp struct {
+		MediaXAddr string `xml:"Body>GetCapabilitiesResponse>Capabilities>Media>XAddr"`
+		PTZXAddr   string `xml:"Body>GetCapabilitiesResponse>Capabilities>PTZ>XAddr"`
+	}
+	if err := c.call(ctx, c.endpoint, `<GetCapabilities xmlns="`+nsDevice+`"><Category>All</Category></GetCapabilities>`, &capsResp); err != nil {
+		return caps, err
+	}
+	caps.PTZ = capsResp.PTZXAddr != ""
+
+	var info struct {
+		Manufacturer string `xml:"Body>GetDeviceInformationResponse>Manufacturer"`
+		Model        string `xml:"Body>GetDeviceInformationResponse>Model"`
+		Firmware     string `xml:"Body>GetDeviceInformationResponse>FirmwareVersion"`
+	}
+	if err := c.call(ctx, c.endpoint, `<GetDeviceInformation xmlns="`+nsDevice+`"/>`, &info); err == nil {
+		caps.Manufacturer, caps.Model, caps.Firmware = info.Manufacturer, info.Model, info.Firmware
+	}
+
+	if capsResp.MediaXAddr == "" {
+		return caps, nil
+	}
+	mediaURL := c.sameHost(capsResp.MediaXAddr)
+
+	var profiles struct {
+		Profiles []struct {
+			Token    string    `xml:"token,attr"`
+			Name     string    `xml:"Name"`
+			Encoding string    `xml:"VideoEncoderConfiguration>Encoding"`
+			Width    int       `xml:"VideoEncoderConfiguration>Resolution>Width"`
+			Height   int       `xml:"VideoEncoderConfiguration>Resolution>Height"`
+			PTZ      *struct{} `xml:"PTZConfiguration"`
+		} `xml:"Body>GetProfilesResponse>Profiles"`
+	}
+	if err := c.call(ctx, mediaURL, `<GetProfiles xmlns="`+nsMedia+`"/>`, &profiles); err != nil {
+		return caps, err
+	}
+
+	for _, p := range profiles.Profiles {
+		profile := Profile{
+			Token:    p.Token,
+			Name:     p.Name,
+			Encoding: p.Encoding,
+			Width:    p.Width,
+			Height:   p.Height,
+			PTZ:      p.PTZ != nil,
+		}
+		var uri struct {
+			URI string `xml:"Body>GetStreamUriResponse>MediaUri>Uri"`
+		}
+		body := `<GetStreamUri xmlns="` + nsMedia + `"><StreamSetup>` +
+			`<Stream xmlns="` + nsSchema + `">RTP-Unicast</Stream>` +
+			`<Transport xmlns="` + nsSchema + `"><Protocol>RTSP</Protocol></Transport>` +
+			`</StreamSetup><ProfileToken>` + xmlEscape(p.Token) + `</ProfileToken></GetStreamUri>`
+		if err := c.call(ctx, mediaURL, body, &uri); err == nil {
+			profile.StreamURI = strings.TrimSpace(uri.URI)
+		}
+		caps.Profiles = append(caps.Profiles, profile)
+	}
+	return caps, nil
+}
+
+func (c *Client) syncClock(ctx context.Context) error {
+	var resp struct {
+		UTC struct {
+			Year   int `xml:"Date>Year"`
+			Month  int `xml:"Date>Month"`
+			Day    int `xml:"Date>Day"`
+			Hour   int `xml:"Time>Hour"`
+			Minute int `xml:"Time>Minute"`
+			Second int `xml:"Time>Second"`
+		} `xml:"Body>GetSystemDateAndTimeResponse>SystemDateAndTime>UTCDateTime"`
+	}
+	if err := c.send(ctx, c.endpoint, `<GetSystemDateAndTime xmlns="`+nsDevice+`"/>`, false, &resp); err != nil {
+		if errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrNotONVIF) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrNotONVIF, err)
+	}
+	if u := resp.UTC; u.Year > 0 {
+		camera := time.Date(u.Year, time.Month(u.Month), u.Day, u.Hour, u.Minute, u.Second, 0, time.UTC)
+		c.offset = camera.Sub(time.Now())
+	}
+	return nil
+}
+
+// sameHost keeps the path of a service address the camera reported but points
+// it at the host we reached, since cameras behind NAT advertise internal IPs
+func (c *Client) sameHost(xaddr string) string {
+	service, err := url.Parse(xaddr)
+	if err != nil {
+		return c.endpoint
+	}
+	device, _ := url.Parse(c.endpoint)
+	service.Scheme, service.Host = device.Scheme, device.Host
+	return service.String()
+}
+
+func (c *Client) call(ctx context.Context, endpoint, body string, out interface{}) error {
+	return c.send(ctx, endpoint, body, c.username != "", out)
+}
+
+type soapFault struct {
+	XMLName xml.Name
+	Code    string `xml:"Body>Fault>Code>Value"`
+	Subcode string `xml:"Body>Fault>Code>Subcode>Value"`
+	Reason  string `xml:"Body>Fault>Reason>Text"`
+}
+
+func (c *Client) send(ctx context.Context, endpoint, body string, auth bool, out interface{}) error {
+	var header string
+	if auth {
+		header = c.securityHeader()
+	}
+	envelope := `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope">` +
+		`<s:Header>` + header + `</s:Header><s:Body>` + body + `</s:Body></s:Envelope>`
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBufferString(envelope))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/soap+xml; charset=utf-8")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w: %v", ErrNotONVIF, err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrUnauthorized
+	}
+	var fault soapFault
+	if xml.Unmarshal(data, &fault) != nil || fault.XMLName.Local != "Envelope" {
+		return fmt.Errorf("%w: HTTP %d, not a SOAP response", ErrNotONVIF, resp.StatusCode)
+	}
+	if fault.Code != "" || fault.Reason != "" {
+		if strings.Contains(fault.Subcode, "NotAuthorized") || strings.Contains(fault.Subcode, "FailedAuthentication") {
+			return ErrUnauthorized
+		}
+		return fmt.Errorf("ONVIF fault %s: %s", strings.TrimSpace(fault.Subcode+" "+fault.Code), strings.TrimSpace(fault.Reason))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: HTTP %d", ErrNotONVIF, resp.StatusCode)
+	}
+	return xml.Unmarshal(data, out)
+}
+
+// securityHeader builds a WS-Security UsernameToken with a password digest:
+// base64(sha1(nonce + created + password))
+func (c *Client) securityHeader() string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	created := time.Now().Add(c.offset).UTC().Format("2006-01-02T15:04:05Z")
+
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write([]byte(created))
+	h.Write([]byte(c.password))
+	digest := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	return `<wsse:Security s:mustUnderstand="1" xmlns:wsse="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd" xmlns:wsu="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd">` +
+		`<wsse:UsernameToken><wsse:Username>` + xmlEscape(c.username) + `</wsse:Username>` +
+		`<wsse:Password Type="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordDigest">` + digest + `</wsse:Password>` +
+		`<wsse:Nonce EncodingType="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-soap-message-security-1.0#Base64Binary">` + base64.StdEncoding.EncodeToString(nonce) + `</wsse:Nonce>` +
+		`<wsu:Created>` + created + `</wsu:Created></wsse:UsernameToken></wsse:Security>`
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
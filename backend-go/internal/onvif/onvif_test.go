@@ -0,0 +1,191 @@
+package onvif
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const cannedEnvelope = `<?xml version="1.0" encoding="UTF-8"?>
+<SOAP-ENV:Envelope xmlns:SOAP-ENV="http://www.w3.org/2003/05/soap-envelope" xmlns:tds="http://www.onvif.org/ver10/device/wsdl" xmlns:trt="http://www.onvif.org/ver10/media/wsdl" xmlns:tt="http://www.onvif.org/ver10/schema" xmlns:ter="http://www.onvif.org/ver10/error">
+<SOAP-ENV:Body>%s</SOAP-ENV:Body></SOAP-ENV:Envelope>`
+
+const (
+	cannedDateTime = `<tds:GetSystemDateAndTimeResponse><tds:SystemDateAndTime><tt:UTCDateTime>
+<tt:Time><tt:Hour>10</tt:Hour><tt:Minute>0</tt:Minute><tt:Second>0</tt:Second></tt:Time>
+<tt:Date><tt:Year>2024</tt:Year><tt:Month>1</tt:Month><tt:Day>2</tt:Day></tt:Date>
+</tt:UTCDateTime></tds:SystemDateAndTime></tds:GetSystemDateAndTimeResponse>`
+
+	cannedCapabilities = `<tds:GetCapabilitiesResponse><tds:Capabilities>
+<tt:Media><tt:XAddr>http://192.168.1.9/onvif/Media</tt:XAddr></tt:Media>
+<tt:PTZ><tt:XAddr>http://192.168.1.9/onvif/PTZ</tt:XAddr></tt:PTZ>
+</tds:Capabilities></tds:GetCapabilitiesResponse>`
+
+	cannedDeviceInfo = `<tds:GetDeviceInformationResponse><tds:Manufacturer>Acme</tds:Manufacturer>
+<tds:Model>C1</tds:Model><tds:FirmwareVersion>1.2.3</tds:FirmwareVersion></tds:GetDeviceInformationResponse>`
+
+	cannedProfiles = `<trt:GetProfilesResponse>
+<trt:Profiles token="main" fixed="true"><tt:Name>MainStream</tt:Name>
+<tt:VideoEncoderConfiguration><tt:Encoding>H264</tt:Encoding><tt:Resolution><tt:Width>2560</tt:Width><tt:Height>1440</tt:Height></tt:Resolution></tt:VideoEncoderConfiguration>
+<tt:PTZConfiguration token="ptz0"/></trt:Profiles>
+<trt:Profiles token="sub"><tt:Name>SubStream</tt:Name></trt:Profiles>
+</trt:GetProfilesResponse>`
+
+	cannedNotAuthorized = `<SOAP-ENV:Fault><SOAP-ENV:Code><SOAP-ENV:Value>SOAP-ENV:Sender</SOAP-ENV:Value>
+<SOAP-ENV:Subcode><SOAP-ENV:Value>ter:NotAuthorized</SOAP-ENV:Value></SOAP-ENV:Subcode></SOAP-ENV:Code>
+<SOAP-ENV:Reason><SOAP-ENV:Text xml:lang="en">Sender not Authorized</SOAP-ENV:Text></SOAP-ENV:Reason></SOAP-ENV:Fault>`
+)
+
+// fakeCamera answers the ONVIF calls Probe makes with canned responses,
+// accepting only user "admin"
+func fakeCamera(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body := string(data)
+
+		var out string
+		switch {
+		case strings.Contains(body, "GetSystemDateAndTime"):
+			out = cannedDateTime
+		case !strings.Contains(body, "<wsse:Username>admin</wsse:Username>") || !strings.Contains(body, "PasswordDigest"):
+			w.WriteHeader(http.StatusBadRequest)
+			out = cannedNotAuthorized
+		case strings.Contains(body, "GetCapabilities"):
+			out = cannedCapabilities
+		case strings.Contains(body, "GetDeviceInformation"):
+			out = cannedDeviceInfo
+		case strings.Contains(body, "GetProfiles"):
+			if r.URL.Path != "/onvif/Media" {
+				t.Errorf("GetProfiles sent to %s, want the media service", r.URL.Path)
+			}
+			out = cannedProfiles
+		case strings.Contains(body, "GetStreamUri"):
+			token := "main"
+			if strings.Contains(body, "<ProfileToken>sub</ProfileToken>") {
+				token = "sub"
+			}
+			out = `<trt:GetStreamUriResponse><trt:MediaUri><tt:Uri>rtsp://192.168.1.9:554/` + token + `</tt:Uri></trt:MediaUri></trt:GetStreamUriResponse>`
+		default:
+			t.Errorf("unexpected request: %s", body)
+		}
+		fmt.Fprintf(w, cannedEnvelope, out)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProbeParsesCannedResponses(t *testing.T) {
+	srv := fakeCamera(t)
+
+	caps, err := Probe(context.Background(), srv.URL+devicePath, "admin", "secret")
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if !caps.PTZ || caps.Manufacturer != "Acme" || caps.Model != "C1" || caps.Firmware != "1.2.3" {
+		t.Errorf("device fields = %+v", caps)
+	}
+	if len(caps.Profiles) != 2 {
+		t.Fatalf("got %d profiles, want 2", len(caps.Profiles))
+	}
+
+	main, sub := caps.Profiles[0], caps.Profiles[1]
+	if main.Token != "main" || main.Encoding != "H264" || main.Width != 2560 || main.Height != 1440 || !main.PTZ {
+		t.Errorf("main profile = %+v", main)
+	}
+	if main.StreamURI != "rtsp://192.168.1.9:554/main" {
+		t.Errorf("main stream URI = %q", main.StreamURI)
+	}
+	if sub.Token != "sub" || sub.PTZ || sub.StreamURI != "rtsp://192.168.1.9:554/sub" {
+		t.Errorf("sub profile = %+v", sub)
+	}
+}
+
+func TestProbeRejectedCredentials(t *testing.T) {
+	srv := fakeCamera(t)
+
+	_, err := Probe(context.Background(), srv.URL+devicePath, "intruder", "guess")
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("err = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestProbeNotONVIF(t *testing.T) {
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>Camera login</body></html>"))
+	}))
+	defer web.Close()
+
+	if _, err := Probe(context.Background(), web.URL+devicePath, "admin", "x"); !errors.Is(err, ErrNotONVIF) {
+		t.Errorf("plain web page: err = %v, want ErrNotONVIF", err)
+	}
+
+	addr := web.Listener.Addr().String()
+	web.Close()
+	if _, err := Probe(context.Background(), "http://"+addr+devicePath, "admin", "x"); !errors.Is(err, ErrNotONVIF) {
+		t.Errorf("closed port: err = %v, want ErrNotONVIF", err)
+	}
+}
+
+func TestSecurityHeaderUsesCameraClock(t *testing.T) {
+	srv := fakeCamera(t)
+	c := &Client{endpoint: srv.URL + devicePath, username: "admin", password: "pw"}
+	if err := c.syncClock(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	header := c.securityHeader()
+	if !strings.Contains(header, "<wsu:Created>2024-01-02T10:00:") {
+		t.Errorf("Created not in the camera's time: %s", header)
+	}
+}
+
+func TestDeviceURL(t *testing.T) {
+	cases := map[string]string{
+		"192.168.1.9":                     "http://192.168.1.9/onvif/device_service",
+		"192.168.1.9:8000":                "http://192.168.1.9:8000/onvif/device_service",
+		"https://cam.local/onvif/service": "https://cam.local/onvif/service",
+		"http://user:pw@cam.local:8080/":  "http://cam.local:8080/onvif/device_service",
+		"  cam.local  ":                   "http://cam.local/onvif/device_service",
+	}
+	for in, want := range cases {
+		got, err := DeviceURL(in)
+		if err != nil || got != want {
+			t.Errorf("DeviceURL(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "ftp://cam.local", "http://"} {
+		if _, err := DeviceURL(bad); err == nil {
+			t.Errorf("DeviceURL(%q) accepted", bad)
+		}
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	ctx := context.Background()
+	camera := []string{"192.168.1.9"}
+
+	if err := CheckTarget(ctx, "http://192.168.1.9/onvif/device_service", camera); err != nil {
+		t.Errorf("camera host rejected: %v", err)
+	}
+	if err := CheckTarget(ctx, "http://192.168.1.9:8000/onvif/device_service", camera); err != nil {
+		t.Errorf("allowed port rejected: %v", err)
+	}
+
+	notAllowed := map[string][]string{
+		"http://10.0.0.5/onvif/device_service":         camera,              // not the camera
+		"http://192.168.1.9:9997/v3/config/paths/list": camera,              // port not allowed
+		"http://127.0.0.1/onvif/device_service":        {"127.0.0.1"},       // loopback
+		"http://mediamtx/onvif/device_service":         {"mediamtx"},        // NVR service
+		"http://169.254.169.254/latest/meta-data":      {"169.254.169.254"}, // link-local
+	}
+	for endpoint, hosts := range notAllowed {
+		if err := CheckTarget(ctx, endpoint, hosts); !errors.Is(err, ErrTargetNotAllowed) {
+			t.Errorf("CheckTarget(%s) = %v, want ErrTargetNotAllowed", endpoint, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package secrets resolves ${secret:name} placeholders in camera URLs so that
+// credentials never have to be stored in the database or returned by the API.
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"nvr-server/internal/config"
+)
+
+// Dir is where camera credentials are mounted, one file per secret. It must not
+// be /run/secrets: that mount holds the backend's own keys, and any user who can
+// edit a camera URL could otherwise send them to a host of their choosing.
+var Dir = config.String("NVR_CAMERA_SECRETS_DIR", "/run/camera-secrets")
+
+// Only variables with this prefix are read, so placeholders can't reach the
+// rest of the backend's environment
+const envPrefix = "NVR_CAMERA_SECRET_"
+
+// backendMount is the Docker secrets mount of the backend itself
+const backendMount = "/run/secrets"
+
+// The backend's own secrets, refused by name wherever they happen to be mounted
+var reserved = map[string]bool{
+	"jwt_secret_key":          true,
+	"jwt_secret_key_previous": true,
+	"db_password":             true,
+	"db_url_secret":           true,
+	"internal_api_secret":     true,
+	"media_signing_key":       true,
+}
+
+var placeholder = regexp.MustCompile(`\$\{secret:([A-Za-z0-9_][A-Za-z0-9_.-]*)\}`)
+
+// Has reports whether s contains at least one placeholder
+func Has(s string) bool {
+	return placeholder.MatchString(s)
+}
+
+// Resolve substitutes every placeholder in s with its secret value. Values come
+// from Dir/<name>, falling back to the NVR_CAMERA_SECRET_<NAME> environment variable.
+// Call it only right before handing a URL to ffmpeg or MediaMTX.
+func Resolve(s string) (string, error) {
+	var missing []string
+	resolved := placeholder.ReplaceAllStringFunc(s, func(match string) string {
+		name := placeholder.FindStringSubmatch(match)[1]
+		value, ok := lookup(name)
+		if !ok {
+			missing = append(missing, name)
+			return match
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return s, fmt.Errorf("secrets: not found: %s", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// Validate checks that every placeholder in s can currently be resolved
+func Validate(s string) error {
+	_, err := Resolve(s)
+	return err
+}
+
+// Mask swaps placeholders for URL-safe tokens so the string can go through
+// net/url (which rejects braces in userinfo). restore puts the placeholders back.
+func Mask(s string) (masked string, restore func(string) string) {
+	var originals []string
+	masked = placeholder.ReplaceAllStringFunc(s, func(match string) string {
+		originals = append(originals, match)
+		return maskToken(len(originals) - 1)
+	})
+	restore = func(out string) string {
+		for i, orig := range originals {
+			out = strings.Replace(out, maskToken(i), orig, 1)
+		}
+		return out
+	}
+	return masked, restore
+}
+
+func maskToken(i int) string {
+	return fmt.Sprintf("nvr-secret-%d", i)
+}
+
+func lookup(name string) (string, bool) {
+	if strings.Contains(name, "..") || reserved[strings.ToLower(name)] {
+		return "", false
+	}
+	if dir := filepath.Clean(Dir); dir != backendMount && !strings.HasPrefix(backendMount, dir+"/") {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			return strings.TrimRight(string(data), "\r\n"), true
+		}
+	}
+	envKey := envPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+	if v, ok := os.LookupEnv(envKey); ok && v != "" {
+		return v, true
+	}
+	return "", false
+}
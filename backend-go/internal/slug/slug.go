@@ -0,0 +1,88 @@
+// Package slug turns user-supplied names into identifiers that are safe as
+// MediaMTX path names and file names.
+package slug
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Fallback is returned when nothing usable is left of the input
+const Fallback = "camera"
+
+// MaxLen caps the slug so generated paths and file names stay short
+const MaxLen = 48
+
+// Common Latin letters that don't decompose into an ASCII base
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'ø': "o", 'œ': "oe", 'đ': "d", 'ð': "d", 'þ': "th", 'ł': "l", 'ı': "i",
+}
+
+// Accented vowels and consonants mapped to their ASCII base
+var accents = map[rune]rune{}
+
+func init() {
+	groups := map[rune]string{
+		'a': "àáâãäåāăą",
+		'c': "çćĉċč",
+		'e': "èéêëēĕėęě",
+		'g': "ĝğġģ",
+		'i': "ìíîïĩīĭį",
+		'n': "ñńņňŉ",
+		'o': "òóôõöōŏő",
+		's': "śŝşšș",
+		't': "ţťț",
+		'u': "ùúûüũūŭůűų",
+		'y': "ýÿŷ",
+		'z': "źżž",
+	}
+	for base, chars := range groups {
+		for _, r := range chars {
+			accents[r] = base
+		}
+	}
+}
+
+// Make lowercases s, transliterates common accented letters and replaces every
+// run of other characters with one separator: "-" if the run was only dashes,
+// "_" otherwise. Control characters are dropped, separators are trimmed from
+// both ends and the result is never empty.
+func Make(s string) string {
+	var b strings.Builder
+	run := ""
+	word := func(str string) {
+		if run != "" && b.Len() > 0 {
+			if strings.Trim(run, "-") == "" {
+				b.WriteByte('-')
+			} else {
+				b.WriteByte('_')
+			}
+		}
+		run = ""
+		b.WriteString(str)
+	}
+
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			word(string(r))
+		case transliterations[r] != "":
+			word(transliterations[r])
+		case accents[r] != 0:
+			word(string(accents[r]))
+		case unicode.IsControl(r) || unicode.Is(unicode.Cf, r) || unicode.Is(unicode.Mn, r):
+			// Invisible: zero-width joiners, variation selectors, combining marks
+		default:
+			run += string(r)
+		}
+	}
+
+	out := b.String()
+	if len(out) > MaxLen {
+		out = strings.TrimRight(out[:MaxLen], "_-")
+	}
+	if out == "" {
+		return Fallback
+	}
+	return out
+}
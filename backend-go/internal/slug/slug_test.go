@@ -0,0 +1,43 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	cases := map[string]string{
+		"Front Door":                   "front_door",
+		"Back-Yard":                    "back-yard",
+		"a -- b":                       "a_b",
+		"Garage/Driveway":              "garage_driveway",
+		"../../etc":                    "etc",
+		"Cam #1 (porch)":               "cam_1_porch",
+		"Café Ñandú":                   "cafe_nandu",
+		"Straße Øst":                   "strasse_ost",
+		"école":                       "ecole",
+		"🐶 Dog cam 🐶":                  "dog_cam",
+		"Porch📷Left":                   "porch_left",
+		"👨‍👩‍👧":                        Fallback,
+		"!!!":                          Fallback,
+		"":                             Fallback,
+		"\t\n":                         Fallback,
+		"  Trailing spaces   ":         "trailing_spaces",
+		strings.Repeat("a", 60):        strings.Repeat("a", MaxLen),
+		strings.Repeat("a", 47) + " b": strings.Repeat("a", 47),
+	}
+	for in, want := range cases {
+		if got := Make(in); got != want {
+			t.Errorf("Make(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMakeIsPathSafe(t *testing.T) {
+	for _, in := range []string{"a/b\\c", "x\x00y", "名前", "a..b", "%2e%2e"} {
+		got := Make(in)
+		if strings.ContainsAny(got, "/\\.% \x00") || got == "" || len(got) > MaxLen {
+			t.Errorf("Make(%q) = %q is not path-safe", in, got)
+		}
+	}
+}
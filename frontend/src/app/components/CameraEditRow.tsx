@@ -81,10 +81,15 @@ export default function CameraEditRow({
           rtsp_url: rtspUrl,
           rtsp_substream_url: substreamUrl || null,
           continuous_recording: continuousRecording,
+          version: camera.version,
         }),
       });
       if (!response) return;
 
+      if (response.status === 409) {
+        onUpdate();
+        throw new Error("Camera was changed elsewhere. Reloaded, please retry.");
+      }
       if (!response.ok) {
         throw new Error("Failed to update camera");
       }
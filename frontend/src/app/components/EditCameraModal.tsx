@@ -64,9 +64,14 @@ export default function EditCameraModal({
           rtsp_substream_url: substreamUrl || null,
           continuous_recording: continuousRecording,
           ai_classes: aiClasses, // Preserve existing classes
+          version: camera.version,
         }),
       });
 
+      if (response?.status === 409) {
+        onCameraUpdated();
+        throw new Error("Camera was changed elsewhere. Reloaded, please retry.");
+      }
       if (!response || !response.ok) {
         throw new Error("Failed to update camera");
       }
@@ -83,10 +83,15 @@ export default function MotionSettingsPage({
           motion_type: motionType,
           rtsp_substream_url: rtspSubstreamUrl || null,
           ai_classes: Array.from(selectedClasses).join(","),
+          version: selectedCamera.version,
         }),
       });
       if (!response) return;
 
+      if (response.status === 409) {
+        onCamerasUpdate();
+        throw new Error("Camera was changed elsewhere. Reloaded, please retry.");
+      }
       if (!response.ok) {
         const err = await response.json();
         throw new Error(err.detail || "Failed to save settings");
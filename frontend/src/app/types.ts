@@ -12,6 +12,7 @@ export interface Camera {
   motion_sensitivity: number;
   continuous_recording: boolean;
   ai_classes: string;
+  version: number;
 }
 
 export interface User {